@@ -31,6 +31,7 @@ func newCheckCmd() *cobra.Command {
 	}
 
 	cmd.Flags().UintVar(&timeout, "timeout", 60, "Time to wait before aborting in seconds")
+	cmd.AddCommand(newCheckNodes())
 	return cmd
 }
 
@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/edgelesssys/marblerun/injector"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// sgxResources are the resources exposed by the supported SGX device plugins
+var sgxResources = []corev1.ResourceName{intelEpc, intelEnclave, intelProvision, azureEpc}
+
+func newCheckNodes() *cobra.Command {
+	var resourceKey string
+
+	cmd := &cobra.Command{
+		Use:   "nodes",
+		Short: "Check if marbles can be scheduled on the SGX nodes of the cluster",
+		Long: `
+Check if marbles can be scheduled on the SGX nodes of the cluster.
+Reports for each SGX node whether it provides the SGX resource requested by the marble-injector,
+and whether the toleration added by the marble-injector tolerates the taints of the node.
+The resource key is read from the installed marble-injector, unless it is set with --resource-key.`,
+		Args: cobra.NoArgs,
+		RunE: func(cobracmd *cobra.Command, args []string) error {
+			kubeClient, err := getKubernetesInterface()
+			if err != nil {
+				return err
+			}
+			if resourceKey == "" {
+				if resourceKey, err = getInjectorResourceKey(kubeClient); err != nil {
+					return err
+				}
+			}
+			return cliCheckNodes(os.Stdout, kubeClient, resourceKey)
+		},
+		SilenceUsage: true,
+	}
+
+	cmd.Flags().StringVar(&resourceKey, "resource-key", "", "SGX resource requested by the marble-injector")
+	return cmd
+}
+
+// nodeReport holds the result of checking whether marbles can be scheduled on a node
+type nodeReport struct {
+	name     string
+	problems []string
+}
+
+// cliCheckNodes checks and reports for each SGX node if injected marbles requesting resourceKey can be scheduled on it
+func cliCheckNodes(out io.Writer, kubeClient kubernetes.Interface, resourceKey string) error {
+	nodes, err := kubeClient.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Checking SGX nodes for resource %s\n", resourceKey)
+	sgxNodes := 0
+	schedulable := 0
+	for _, node := range nodes.Items {
+		if !isSGXNode(node, resourceKey) {
+			continue
+		}
+		sgxNodes++
+		report := checkNode(node, resourceKey)
+		if len(report.problems) == 0 {
+			schedulable++
+			fmt.Fprintf(out, "%s: OK\n", report.name)
+			continue
+		}
+		fmt.Fprintf(out, "%s: marbles can't be scheduled\n", report.name)
+		for _, problem := range report.problems {
+			fmt.Fprintf(out, "  - %s\n", problem)
+		}
+	}
+
+	if sgxNodes == 0 {
+		return fmt.Errorf("no SGX nodes found in the cluster")
+	}
+	if schedulable < sgxNodes {
+		return fmt.Errorf("marbles can't be scheduled on %d of %d SGX nodes", sgxNodes-schedulable, sgxNodes)
+	}
+	return nil
+}
+
+// isSGXNode checks if a node provides any SGX resource
+func isSGXNode(node corev1.Node, resourceKey string) bool {
+	if nodeSupportsSGX(node.Status.Capacity) {
+		return true
+	}
+	quantity := node.Status.Capacity[corev1.ResourceName(resourceKey)]
+	return !quantity.IsZero()
+}
+
+// checkNode checks if the node provides resourceKey and if the toleration added by the injector tolerates the taints of the node
+func checkNode(node corev1.Node, resourceKey string) nodeReport {
+	report := nodeReport{name: node.Name}
+
+	allocatable := node.Status.Allocatable[corev1.ResourceName(resourceKey)]
+	if allocatable.IsZero() {
+		problem := fmt.Sprintf("no allocatable %s", resourceKey)
+		for _, resource := range sgxResources {
+			if quantity := node.Status.Allocatable[resource]; resource.String() != resourceKey && !quantity.IsZero() {
+				problem += fmt.Sprintf(", but the node provides %s", resource)
+				break
+			}
+		}
+		report.problems = append(report.problems, problem)
+	}
+
+	toleration := injector.SGXToleration(resourceKey)
+	for _, taint := range node.Spec.Taints {
+		// only these effects prevent scheduling
+		if taint.Effect != corev1.TaintEffectNoSchedule && taint.Effect != corev1.TaintEffectNoExecute {
+			continue
+		}
+		if toleration.ToleratesTaint(&taint) {
+			continue
+		}
+		problem := fmt.Sprintf("taint %s is not tolerated", taint.ToString())
+		if isSGXResource(taint.Key) {
+			problem += fmt.Sprintf(": the taint key does not match the resource key %s", resourceKey)
+		}
+		report.problems = append(report.problems, problem)
+	}
+
+	return report
+}
+
+// isSGXResource checks if key is the name of a resource exposed by an SGX device plugin
+func isSGXResource(key string) bool {
+	for _, resource := range sgxResources {
+		if resource.String() == key {
+			return true
+		}
+	}
+	return false
+}
+
+// getInjectorResourceKey returns the SGX resource requested by the installed marble-injector.
+// If the marble-injector is not installed, the resource key is derived from the device plugins of the cluster.
+func getInjectorResourceKey(kubeClient kubernetes.Interface) (string, error) {
+	deployment, err := kubeClient.AppsV1().Deployments("marblerun").Get(context.TODO(), "marble-injector", metav1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return "", err
+	}
+	if err == nil {
+		for _, container := range deployment.Spec.Template.Spec.Containers {
+			for idx, arg := range container.Args {
+				name := strings.TrimLeft(arg, "-")
+				if strings.HasPrefix(name, "sgxResource=") {
+					return strings.TrimPrefix(name, "sgxResource="), nil
+				}
+				if name == "sgxResource" && idx+1 < len(container.Args) {
+					return container.Args[idx+1], nil
+				}
+			}
+		}
+		// the default of the marble-injector
+		return intelEpc.String(), nil
+	}
+	return getSGXResourceKey(kubeClient)
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"testing"
@@ -8,6 +9,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/fake"
 )
@@ -126,3 +129,94 @@ func TestCliCheck(t *testing.T) {
 	err = cliCheck(testClient, 2)
 	assert.Error(err)
 }
+
+func TestCliCheckNodes(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	intelResources := corev1.ResourceList{
+		intelEpc:       resource.MustParse("500"),
+		intelEnclave:   resource.MustParse("10"),
+		intelProvision: resource.MustParse("10"),
+	}
+	newNode := func(name string, resources corev1.ResourceList, taints ...corev1.Taint) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       corev1.NodeSpec{Taints: taints},
+			Status:     corev1.NodeStatus{Capacity: resources, Allocatable: resources},
+		}
+	}
+
+	// correctly tainted node
+	testClient := fake.NewSimpleClientset(
+		newNode("regular-node", nil),
+		newNode("sgx-node", intelResources,
+			corev1.Taint{Key: intelEpc.String(), Effect: corev1.TaintEffectNoSchedule},
+			corev1.Taint{Key: "other", Effect: corev1.TaintEffectPreferNoSchedule},
+		),
+	)
+	var out bytes.Buffer
+	require.NoError(cliCheckNodes(&out, testClient, intelEpc.String()))
+	assert.Contains(out.String(), "sgx-node: OK")
+	assert.NotContains(out.String(), "regular-node")
+
+	// taint key does not match the resource key
+	testClient = fake.NewSimpleClientset(
+		newNode("sgx-node", intelResources, corev1.Taint{Key: azureEpc.String(), Effect: corev1.TaintEffectNoSchedule}),
+	)
+	out.Reset()
+	assert.Error(cliCheckNodes(&out, testClient, intelEpc.String()))
+	assert.Contains(out.String(), "sgx-node: marbles can't be scheduled")
+	assert.Contains(out.String(), "the taint key does not match the resource key "+intelEpc.String())
+
+	// other taints are reported as well
+	testClient = fake.NewSimpleClientset(
+		newNode("sgx-node", intelResources, corev1.Taint{Key: "dedicated", Value: "db", Effect: corev1.TaintEffectNoExecute}),
+	)
+	out.Reset()
+	assert.Error(cliCheckNodes(&out, testClient, intelEpc.String()))
+	assert.Contains(out.String(), "taint dedicated=db:NoExecute is not tolerated")
+
+	// the node does not provide the resource requested by the injector
+	testClient = fake.NewSimpleClientset(
+		newNode("sgx-node", intelResources, corev1.Taint{Key: azureEpc.String(), Effect: corev1.TaintEffectNoSchedule}),
+	)
+	out.Reset()
+	assert.Error(cliCheckNodes(&out, testClient, azureEpc.String()))
+	assert.Contains(out.String(), "no allocatable "+azureEpc.String()+", but the node provides "+intelEpc.String())
+
+	// no SGX nodes
+	testClient = fake.NewSimpleClientset(newNode("regular-node", nil))
+	assert.Error(cliCheckNodes(&out, testClient, intelEpc.String()))
+}
+
+func TestGetInjectorResourceKey(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// without injector, the resource key is derived from the nodes
+	testClient := fake.NewSimpleClientset(&corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "sgx-node"},
+		Status:     corev1.NodeStatus{Capacity: corev1.ResourceList{azureEpc: resource.MustParse("10")}},
+	})
+	resourceKey, err := getInjectorResourceKey(testClient)
+	require.NoError(err)
+	assert.Equal(azureEpc.String(), resourceKey)
+
+	// the injector's argument takes precedence
+	injector := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "marble-injector", Namespace: "marblerun"},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "marble-injector", Args: []string{"-coordAddr=coordinator:2001", "-sgxResource=custom.io/sgx"}}},
+				},
+			},
+		},
+	}
+	_, err = testClient.AppsV1().Deployments("marblerun").Create(context.TODO(), injector, metav1.CreateOptions{})
+	require.NoError(err)
+	resourceKey, err = getInjectorResourceKey(testClient)
+	require.NoError(err)
+	assert.Equal("custom.io/sgx", resourceKey)
+}
@@ -0,0 +1,19 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func newCoordinatorCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "coordinator",
+		Short: "Interacts with the Marblerun coordinator",
+		Long:  `Interacts with the Marblerun coordinator`,
+	}
+
+	cmd.AddCommand(newCoordinatorVerify())
+	cmd.AddCommand(newCoordinatorLogs())
+	cmd.AddCommand(newCoordinatorWait())
+
+	return cmd
+}
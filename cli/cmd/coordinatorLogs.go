@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/edgelesssys/marblerun/coordinator/core"
+	"github.com/spf13/cobra"
+)
+
+func newCoordinatorLogs() *cobra.Command {
+	var clientAdminCert string
+	var clientAdminKey string
+	var follow bool
+
+	cmd := &cobra.Command{
+		Use:   "logs <IP:PORT>",
+		Short: "Prints the recent events of the Marblerun coordinator",
+		Long: `
+Prints the recent events of the Marblerun coordinator, e.g., marble activations and errors.
+Use --follow to keep streaming new events.
+An admin certificate specified in the manifest is needed to read the events.
+`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			hostName := args[0]
+
+			caCert, err := verifyCoordinator(hostName, eraConfig, insecureEra)
+			if err != nil {
+				return err
+			}
+
+			// Load client certificate and key
+			clCert, err := tls.LoadX509KeyPair(clientAdminCert, clientAdminKey)
+			if err != nil {
+				return err
+			}
+
+			return cliCoordinatorLogs(os.Stdout, hostName, clCert, caCert, follow)
+		},
+		SilenceUsage: true,
+	}
+
+	cmd.Flags().StringVarP(&clientAdminCert, "cert", "c", "", "PEM encoded admin certificate file (required)")
+	cmd.MarkFlagRequired("cert")
+	cmd.Flags().StringVarP(&clientAdminKey, "key", "k", "", "PEM encoded admin key file (required)")
+	cmd.MarkFlagRequired("key")
+	cmd.Flags().BoolVarP(&follow, "follow", "f", false, "Keep streaming new events")
+	cmd.Flags().StringVar(&eraConfig, "era-config", "", "Path to remote attestation config file in json format, if none provided the newest configuration will be loaded from github")
+	cmd.Flags().BoolVarP(&insecureEra, "insecure", "i", false, "Set to skip quote verification, needed when running in simulation mode")
+
+	return cmd
+}
+
+// cliCoordinatorLogs reads the events streamed by the coordinators rest api and prints them to out
+func cliCoordinatorLogs(out io.Writer, host string, clCert tls.Certificate, caCert []*pem.Block, follow bool) error {
+	client, err := authenticatedRestClient(caCert, clCert)
+	if err != nil {
+		return err
+	}
+
+	url := url.URL{Scheme: "https", Host: host, Path: "events"}
+	if follow {
+		url.RawQuery = "follow=true"
+	}
+	resp, err := client.Get(url.String())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return fmt.Errorf("unable to authorize user: %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	default:
+		return fmt.Errorf("error connecting to server: %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		data := strings.TrimPrefix(scanner.Text(), "data: ")
+		if data == "" {
+			continue
+		}
+		var event core.Event
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return fmt.Errorf("received invalid event from coordinator: %v", err)
+		}
+		printEvent(out, event)
+	}
+	return scanner.Err()
+}
+
+func printEvent(out io.Writer, event core.Event) {
+	line := fmt.Sprintf("%s %-5s %s", event.Timestamp.Format(time.RFC3339), strings.ToUpper(event.Level), event.Message)
+	if event.MarbleType != "" {
+		line += fmt.Sprintf(" marbleType=%s", event.MarbleType)
+	}
+	if event.UUID != "" {
+		line += fmt.Sprintf(" uuid=%s", event.UUID)
+	}
+	fmt.Fprintln(out, line)
+}
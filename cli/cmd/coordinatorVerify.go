@@ -0,0 +1,284 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/edgelesssys/ego/attestation"
+	"github.com/edgelesssys/ertgolib/erthost"
+	"github.com/edgelesssys/marblerun/coordinator/manifest"
+	"github.com/edgelesssys/marblerun/coordinator/quote"
+	"github.com/spf13/cobra"
+	"github.com/tidwall/gjson"
+)
+
+type certQuoteResponse struct {
+	Cert  string
+	Quote []byte
+}
+
+func newCoordinatorVerify() *cobra.Command {
+	var hostName string
+	var manifestFile string
+	var packageName string
+	var mrenclave string
+	var mrsigner string
+	var productID uint64
+	var securityVersion uint
+	var printChain bool
+
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Verifies the identity of the Marblerun coordinator using remote attestation",
+		Long: `
+Verifies the identity of the Marblerun coordinator using remote attestation.
+The coordinator's quote is checked against the enclave identity of a package defined in the given manifest,
+or against the measurements given by --mrenclave or --mrsigner, e.g., those of a known-good release.
+When verifying by --mrsigner, --product-id and --security-version should be set as well.
+On success, the verified root certificate of the coordinator is printed.
+With --chain, the coordinator's full certificate chain is fetched and checked against the verified root certificate instead.
+`,
+		Example: "coordinator verify --coordinator example.com:4433 --manifest manifest.json [--package coordinator]",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var pp quote.PackageProperties
+			var err error
+			if mrenclave != "" || mrsigner != "" {
+				if manifestFile != "" {
+					return errors.New("--manifest can't be combined with --mrenclave or --mrsigner")
+				}
+				var pID *uint64
+				if cmd.Flags().Changed("product-id") {
+					pID = &productID
+				}
+				var svn *uint
+				if cmd.Flags().Changed("security-version") {
+					svn = &securityVersion
+				}
+				if pp, err = getMeasurementPackage(mrenclave, mrsigner, pID, svn); err != nil {
+					return err
+				}
+			} else {
+				if manifestFile == "" {
+					return errors.New("either --manifest or --mrenclave/--mrsigner must be set to define the expected identity of the coordinator")
+				}
+				rawManifest, err := loadManifestFile(manifestFile)
+				if err != nil {
+					return err
+				}
+				if pp, err = getCoordinatorPackage(rawManifest, packageName); err != nil {
+					return err
+				}
+			}
+
+			rootCert, err := cliCoordinatorVerify(hostName, pp, ertHostValidator{})
+			if err != nil {
+				return err
+			}
+
+			if printChain {
+				chain, err := cliCoordinatorCertChain(hostName, rootCert)
+				if err != nil {
+					return err
+				}
+				fmt.Println("Successfully verified coordinator, certificate chain:")
+				for _, cert := range chain {
+					fmt.Print(string(pem.EncodeToMemory(cert)))
+				}
+				return nil
+			}
+
+			fmt.Println("Successfully verified coordinator, root certificate:")
+			fmt.Print(string(pem.EncodeToMemory(rootCert)))
+			return nil
+		},
+		SilenceUsage: true,
+	}
+
+	cmd.Flags().StringVar(&hostName, "coordinator", "", "Address of the coordinator's client API <IP:PORT> (required)")
+	cmd.MarkFlagRequired("coordinator")
+	cmd.Flags().StringVar(&manifestFile, "manifest", "", "Manifest defining the expected enclave identity of the coordinator")
+	cmd.Flags().BoolVar(&printChain, "chain", false, "Print the coordinator's full certificate chain instead of the root certificate")
+	cmd.Flags().StringVar(&packageName, "package", "", "Name of the manifest package describing the coordinator, may be omitted if the manifest defines a single package")
+	cmd.Flags().StringVar(&mrenclave, "mrenclave", "", "Expected hex encoded MRENCLAVE of the coordinator, instead of a manifest")
+	cmd.Flags().StringVar(&mrsigner, "mrsigner", "", "Expected hex encoded MRSIGNER of the coordinator, instead of a manifest")
+	cmd.Flags().Uint64Var(&productID, "product-id", 0, "Expected product ID of the coordinator, used with --mrsigner")
+	cmd.Flags().UintVar(&securityVersion, "security-version", 0, "Minimum security version of the coordinator, used with --mrsigner")
+
+	return cmd
+}
+
+// getMeasurementPackage returns the package properties the coordinator is expected to comply with from explicit measurements
+func getMeasurementPackage(mrenclave string, mrsigner string, productID *uint64, securityVersion *uint) (quote.PackageProperties, error) {
+	if mrenclave == "" && mrsigner == "" {
+		return quote.PackageProperties{}, errors.New("neither MRENCLAVE nor MRSIGNER is set")
+	}
+	for name, value := range map[string]string{"MRENCLAVE": mrenclave, "MRSIGNER": mrsigner} {
+		if value == "" {
+			continue
+		}
+		if measurement, err := hex.DecodeString(value); err != nil || len(measurement) != sha256.Size {
+			return quote.PackageProperties{}, fmt.Errorf("invalid %s %s: expected %d hex encoded bytes", name, value, sha256.Size)
+		}
+	}
+	return quote.PackageProperties{
+		UniqueID:        mrenclave,
+		SignerID:        mrsigner,
+		ProductID:       productID,
+		SecurityVersion: securityVersion,
+	}, nil
+}
+
+// getCoordinatorPackage returns the package properties the coordinator is expected to comply with
+func getCoordinatorPackage(rawManifest []byte, packageName string) (quote.PackageProperties, error) {
+	var mnf manifest.Manifest
+	if err := json.Unmarshal(rawManifest, &mnf); err != nil {
+		return quote.PackageProperties{}, err
+	}
+
+	if packageName == "" {
+		if len(mnf.Packages) != 1 {
+			return quote.PackageProperties{}, errors.New("manifest defines more than one package, use --package to select the coordinator's package")
+		}
+		for _, pp := range mnf.Packages {
+			return pp, nil
+		}
+	}
+
+	pp, ok := mnf.Packages[packageName]
+	if !ok {
+		return quote.PackageProperties{}, fmt.Errorf("manifest does not contain package %s", packageName)
+	}
+	return pp, nil
+}
+
+// cliCoordinatorVerify fetches the coordinator's certificate and quote and verifies the quote against the expected package properties
+func cliCoordinatorVerify(host string, pp quote.PackageProperties, validator quote.Validator) (*pem.Block, error) {
+	// The certificate is not trusted yet, trust is established by verifying the quote
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	url := url.URL{Scheme: "https", Host: host, Path: "quote"}
+	resp, err := client.Get(url.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error connecting to server: %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var certQuote certQuoteResponse
+	if err := json.Unmarshal([]byte(gjson.GetBytes(respBody, "data").String()), &certQuote); err != nil {
+		return nil, err
+	}
+	if len(certQuote.Quote) == 0 {
+		return nil, errors.New("no quote received, the coordinator might be running in simulation mode")
+	}
+
+	// The root certificate is the last entry of the chain
+	var rootCert *pem.Block
+	rest := []byte(certQuote.Cert)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		rootCert = block
+	}
+	if rootCert == nil {
+		return nil, errors.New("could not parse certificate")
+	}
+
+	if err := validator.Validate(certQuote.Quote, rootCert.Bytes, pp, quote.InfrastructureProperties{}); err != nil {
+		return nil, fmt.Errorf("verifying coordinator quote failed: %v", err)
+	}
+
+	return rootCert, nil
+}
+
+// cliCoordinatorCertChain fetches the coordinator's certificate chain from a coordinator authenticated by the verified root certificate.
+// Each certificate of the chain must be signed by its successor, and the chain must end with the root certificate.
+func cliCoordinatorCertChain(host string, rootCert *pem.Block) ([]*pem.Block, error) {
+	client, err := restClient([]*pem.Block{rootCert})
+	if err != nil {
+		return nil, err
+	}
+
+	url := url.URL{Scheme: "https", Host: host, Path: "certchain"}
+	resp, err := client.Get(url.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error connecting to server: %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var chain []*pem.Block
+	var certs []*x509.Certificate
+	rest := []byte(gjson.GetBytes(respBody, "data.CertChain").String())
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate in chain: %v", err)
+		}
+		chain = append(chain, block)
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("received empty certificate chain")
+	}
+	if !bytes.Equal(certs[len(certs)-1].Raw, rootCert.Bytes) {
+		return nil, errors.New("certificate chain does not end with the verified root certificate")
+	}
+	for i := 0; i < len(certs)-1; i++ {
+		if err := certs[i].CheckSignatureFrom(certs[i+1]); err != nil {
+			return nil, fmt.Errorf("invalid certificate chain: %v", err)
+		}
+	}
+
+	return chain, nil
+}
+
+// ertHostValidator is a quote validator based on EdgelessRT for use outside of an enclave
+type ertHostValidator struct{}
+
+// Validate implements the Validator interface for ertHostValidator
+func (ertHostValidator) Validate(givenQuote []byte, cert []byte, pp quote.PackageProperties, ip quote.InfrastructureProperties) error {
+	report, err := erthost.VerifyRemoteReport(givenQuote)
+	if err != nil {
+		return quote.ReportVerificationError(err)
+	}
+	return quote.CheckReport(attestation.Report(report), cert, pp)
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/edgelesssys/marblerun/coordinator/core"
+	"github.com/spf13/cobra"
+)
+
+func newCoordinatorWait() *cobra.Command {
+	var timeout time.Duration
+	var interval time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "wait <IP:PORT>",
+		Short: "Waits until the Marblerun coordinator is running",
+		Long: `
+Waits until the Marblerun coordinator has accepted a manifest and is running, i.e., ready to accept marbles.
+The status of the coordinator is polled and each state it passes through is printed.
+Exits with an error if the coordinator is not running before the timeout elapses.
+`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			hostName := args[0]
+			deadline := time.Now().Add(timeout)
+
+			// the coordinator may not be reachable yet
+			caCert, err := verifyCoordinator(hostName, eraConfig, insecureEra)
+			for err != nil {
+				if time.Now().Add(interval).After(deadline) {
+					return fmt.Errorf("timed out after %v waiting for the coordinator: %v", timeout, err)
+				}
+				fmt.Printf("Coordinator is not reachable yet: %v\n", err)
+				time.Sleep(interval)
+				caCert, err = verifyCoordinator(hostName, eraConfig, insecureEra)
+			}
+
+			return cliCoordinatorWait(os.Stdout, hostName, caCert, time.Until(deadline), interval)
+		},
+		SilenceUsage: true,
+	}
+
+	cmd.Flags().DurationVar(&timeout, "timeout", 5*time.Minute, "Time to wait for the coordinator to be running")
+	cmd.Flags().DurationVar(&interval, "interval", 2*time.Second, "Interval in which the status of the coordinator is polled")
+	cmd.Flags().StringVar(&eraConfig, "era-config", "", "Path to remote attestation config file in json format, if none provided the newest configuration will be loaded from github")
+	cmd.Flags().BoolVarP(&insecureEra, "insecure", "i", false, "Set to skip quote verification, needed when running in simulation mode")
+
+	return cmd
+}
+
+// cliCoordinatorWait polls the status of the coordinator every interval until it is running, printing each state it passes through to out
+func cliCoordinatorWait(out io.Writer, host string, cert []*pem.Block, timeout time.Duration, interval time.Duration) error {
+	client, err := restClient(cert)
+	if err != nil {
+		return err
+	}
+	// a hanging request must not exceed the timeout
+	client.Timeout = timeout
+
+	deadline := time.Now().Add(timeout)
+	var lastState core.Phase
+	var lastErr error
+	for {
+		status, err := getStatus(client, host)
+		if err != nil {
+			if lastErr == nil || err.Error() != lastErr.Error() {
+				fmt.Fprintf(out, "Unable to get the status of the coordinator: %v\n", err)
+			}
+		} else {
+			if status.State != lastState {
+				fmt.Fprintf(out, "Coordinator is in state %s: %s\n", status.State, status.StatusMessage)
+				lastState = status.State
+			}
+			if status.State == core.PhaseAcceptingMarbles {
+				return nil
+			}
+		}
+		lastErr = err
+
+		if time.Now().Add(interval).After(deadline) {
+			if lastState == "" {
+				return fmt.Errorf("timed out after %v waiting for the coordinator", timeout)
+			}
+			return fmt.Errorf("timed out after %v waiting for the coordinator, last state: %s", timeout, lastState)
+		}
+		time.Sleep(interval)
+	}
+}
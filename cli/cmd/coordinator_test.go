@@ -0,0 +1,304 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/edgelesssys/marblerun/coordinator/core"
+	"github.com/edgelesssys/marblerun/coordinator/quote"
+	"github.com/edgelesssys/marblerun/coordinator/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoordinatorVerify(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	var certQuote certQuoteResponse
+	s, host, cert := newTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("/quote", r.RequestURI)
+		assert.Equal(http.MethodGet, r.Method)
+		serverResp := server.GeneralResponse{
+			Status: "success",
+			Data:   certQuote,
+		}
+		assert.NoError(json.NewEncoder(w).Encode(serverResp))
+	}))
+	defer s.Close()
+
+	productID := uint64(42)
+	securityVersion := uint(2)
+	pp := quote.PackageProperties{
+		SignerID:        "1234",
+		ProductID:       &productID,
+		SecurityVersion: &securityVersion,
+	}
+
+	issuer := quote.NewMockIssuer()
+	mockQuote, err := issuer.Issue(cert.Bytes)
+	require.NoError(err)
+	validator := quote.NewMockValidator()
+	validator.AddValidQuote(mockQuote, cert.Bytes, pp, quote.InfrastructureProperties{})
+
+	certQuote = certQuoteResponse{
+		Cert:  string(pem.EncodeToMemory(cert)),
+		Quote: mockQuote,
+	}
+
+	// valid quote
+	rootCert, err := cliCoordinatorVerify(host, pp, validator)
+	require.NoError(err)
+	assert.Equal(cert.Bytes, rootCert.Bytes)
+
+	// quote does not match the expected package
+	otherProductID := uint64(43)
+	otherPP := pp
+	otherPP.ProductID = &otherProductID
+	_, err = cliCoordinatorVerify(host, otherPP, validator)
+	assert.Error(err)
+
+	// quote does not match the certificate
+	certQuote.Quote = []byte("invalid")
+	_, err = cliCoordinatorVerify(host, pp, validator)
+	assert.Error(err)
+
+	// simulation mode
+	certQuote.Quote = nil
+	_, err = cliCoordinatorVerify(host, pp, validator)
+	assert.Error(err)
+
+	// server error
+	s.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	_, err = cliCoordinatorVerify(host, pp, validator)
+	assert.Error(err)
+}
+
+func TestCoordinatorVerifyMeasurement(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	mrenclave := strings.Repeat("ab", 32)
+	mrsigner := strings.Repeat("cd", 32)
+	productID := uint64(42)
+	securityVersion := uint(2)
+
+	var certQuote certQuoteResponse
+	s, host, cert := newTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serverResp := server.GeneralResponse{
+			Status: "success",
+			Data:   certQuote,
+		}
+		assert.NoError(json.NewEncoder(w).Encode(serverResp))
+	}))
+	defer s.Close()
+
+	// the coordinator reports these properties in its quote
+	issuer := quote.NewMockIssuer()
+	mockQuote, err := issuer.Issue(cert.Bytes)
+	require.NoError(err)
+	validator := quote.NewMockValidator()
+	validator.AddValidQuote(mockQuote, cert.Bytes, quote.PackageProperties{
+		UniqueID:        mrenclave,
+		SignerID:        mrsigner,
+		ProductID:       &productID,
+		SecurityVersion: &securityVersion,
+	}, quote.InfrastructureProperties{})
+	certQuote = certQuoteResponse{
+		Cert:  string(pem.EncodeToMemory(cert)),
+		Quote: mockQuote,
+	}
+
+	// matching measurements
+	pp, err := getMeasurementPackage(mrenclave, "", nil, nil)
+	require.NoError(err)
+	_, err = cliCoordinatorVerify(host, pp, validator)
+	assert.NoError(err)
+	pp, err = getMeasurementPackage("", strings.ToUpper(mrsigner), &productID, &securityVersion)
+	require.NoError(err)
+	_, err = cliCoordinatorVerify(host, pp, validator)
+	assert.NoError(err)
+
+	// mismatching measurements
+	pp, err = getMeasurementPackage(strings.Repeat("ef", 32), "", nil, nil)
+	require.NoError(err)
+	_, err = cliCoordinatorVerify(host, pp, validator)
+	assert.Error(err)
+	newerVersion := uint(3)
+	pp, err = getMeasurementPackage("", mrsigner, &productID, &newerVersion)
+	require.NoError(err)
+	_, err = cliCoordinatorVerify(host, pp, validator)
+	assert.Error(err)
+}
+
+func TestGetMeasurementPackage(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := getMeasurementPackage("", "", nil, nil)
+	assert.Error(err)
+	_, err = getMeasurementPackage("1234", "", nil, nil)
+	assert.Error(err)
+	_, err = getMeasurementPackage("", strings.Repeat("x", 64), nil, nil)
+	assert.Error(err)
+}
+
+func TestCoordinatorCertChain(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	// serve the certificate chain of a coordinator, authenticated with its root certificate
+	c := core.NewCoreWithMocks()
+	chain, err := c.GetCertChain(context.TODO())
+	require.NoError(err)
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("/certchain", r.RequestURI)
+		assert.Equal(http.MethodGet, r.Method)
+		serverResp := server.GeneralResponse{
+			Status: "success",
+			Data:   map[string]string{"CertChain": chain},
+		}
+		assert.NoError(json.NewEncoder(w).Encode(serverResp))
+	}))
+	s.TLS, err = c.GetTLSConfig()
+	require.NoError(err)
+	s.StartTLS()
+	defer s.Close()
+	// connect by name, so the server presents the coordinator's certificate instead of its default one
+	_, port, err := net.SplitHostPort(s.Listener.Addr().String())
+	require.NoError(err)
+	host := net.JoinHostPort("localhost", port)
+
+	intermediateCert, rest := pem.Decode([]byte(chain))
+	rootCert, _ := pem.Decode(rest)
+	require.NotNil(rootCert)
+
+	certs, err := cliCoordinatorCertChain(host, rootCert)
+	require.NoError(err)
+	require.Len(certs, 2)
+	assert.Equal(intermediateCert.Bytes, certs[0].Bytes)
+	assert.Equal(rootCert.Bytes, certs[1].Bytes)
+
+	// the chain must end with the verified root certificate
+	chain = string(pem.EncodeToMemory(intermediateCert))
+	_, err = cliCoordinatorCertChain(host, rootCert)
+	assert.Error(err)
+
+	// the chain must be signed by the root certificate
+	otherChain, err := core.NewCoreWithMocks().GetCertChain(context.TODO())
+	require.NoError(err)
+	otherIntermediate, _ := pem.Decode([]byte(otherChain))
+	chain = string(pem.EncodeToMemory(otherIntermediate)) + string(pem.EncodeToMemory(rootCert))
+	_, err = cliCoordinatorCertChain(host, rootCert)
+	assert.Error(err)
+
+	// the server is not authenticated by another root certificate
+	otherRoot, _ := pem.Decode(rest)
+	otherRoot.Bytes = otherIntermediate.Bytes
+	_, err = cliCoordinatorCertChain(host, otherRoot)
+	assert.Error(err)
+}
+
+func TestGetCoordinatorPackage(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	singlePackage := []byte(`{"Packages": {"coordinator": {"SignerID": "1234"}}}`)
+	pp, err := getCoordinatorPackage(singlePackage, "")
+	require.NoError(err)
+	assert.Equal("1234", pp.SignerID)
+
+	multiplePackages := []byte(`{"Packages": {"coordinator": {"SignerID": "1234"}, "backend": {"SignerID": "5678"}}}`)
+	_, err = getCoordinatorPackage(multiplePackages, "")
+	assert.Error(err)
+	pp, err = getCoordinatorPackage(multiplePackages, "backend")
+	require.NoError(err)
+	assert.Equal("5678", pp.SignerID)
+	_, err = getCoordinatorPackage(multiplePackages, "frontend")
+	assert.Error(err)
+}
+
+func TestCoordinatorLogs(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	timestamp := time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)
+	s, host, cert := newTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("/events", r.URL.Path)
+		assert.Equal("true", r.URL.Query().Get("follow"))
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range []core.Event{
+			{Timestamp: timestamp, Level: core.EventLevelInfo, Message: "marble activated", MarbleType: "frontend", UUID: "1234"},
+			{Timestamp: timestamp, Level: core.EventLevelError, Message: "activation failed"},
+		} {
+			data, err := json.Marshal(event)
+			assert.NoError(err)
+			fmt.Fprintf(w, "data: %s\n\n", data)
+		}
+	}))
+	defer s.Close()
+
+	var out bytes.Buffer
+	require.NoError(cliCoordinatorLogs(&out, host, tls.Certificate{}, []*pem.Block{cert}, true))
+	assert.Equal("2021-05-01T12:00:00Z INFO  marble activated marbleType=frontend uuid=1234\n2021-05-01T12:00:00Z ERROR activation failed\n", out.String())
+
+	// unauthorized
+	s.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	assert.Error(cliCoordinatorLogs(&out, host, tls.Certificate{}, []*pem.Block{cert}, true))
+}
+
+func TestCoordinatorWait(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	// the coordinator recovers on the second request and accepts marbles from the fourth on
+	requests := 0
+	s, host, cert := newTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("/status", r.RequestURI)
+		requests++
+		resp := statusResponse{StatusCode: 0, StatusMessage: "recovery mode", State: core.PhaseRecovery}
+		if requests == 2 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if requests >= 4 {
+			resp = statusResponse{StatusCode: 3, StatusMessage: "running", State: core.PhaseAcceptingMarbles}
+		}
+		assert.NoError(json.NewEncoder(w).Encode(server.GeneralResponse{Status: "success", Data: resp}))
+	}))
+	defer s.Close()
+
+	var out bytes.Buffer
+	require.NoError(cliCoordinatorWait(&out, host, []*pem.Block{cert}, time.Minute, time.Millisecond))
+	assert.Equal(4, requests)
+	assert.Equal("Coordinator is in state recovery: recovery mode\n"+
+		"Unable to get the status of the coordinator: error connecting to server: 500 Internal Server Error\n"+
+		"Coordinator is in state accepting-marbles: running\n", out.String())
+
+	// the coordinator stays in recovery mode
+	requests = 0
+	s.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		resp := statusResponse{StatusCode: 0, StatusMessage: "recovery mode", State: core.PhaseRecovery}
+		assert.NoError(json.NewEncoder(w).Encode(server.GeneralResponse{Status: "success", Data: resp}))
+	})
+	out.Reset()
+	err := cliCoordinatorWait(&out, host, []*pem.Block{cert}, 50*time.Millisecond, 10*time.Millisecond)
+	require.Error(err)
+	assert.Contains(err.Error(), "last state: recovery")
+	assert.Greater(requests, 1)
+	assert.Equal("Coordinator is in state recovery: recovery mode\n", out.String())
+}
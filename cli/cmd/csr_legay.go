@@ -10,6 +10,8 @@ import (
 	"fmt"
 	"math/big"
 	"time"
+
+	"github.com/edgelesssys/marblerun/util"
 )
 
 // certificateLegacy acts as a handler for generating signed certificates
@@ -18,14 +20,21 @@ type certificateLegacy struct {
 	serverCert    *pem.Block
 	caPrivKey     *rsa.PrivateKey
 	caCert        *pem.Block
+	caSerial      *big.Int
 }
 
 // newCertificateLegacy creates a certificate handler for kubernetes versions <=18
 func newCertificateLegacy() (*certificateLegacy, error) {
 	crt := &certificateLegacy{}
 
+	caSerial, err := util.GenerateCertificateSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+	crt.caSerial = caSerial
+
 	caCert := &x509.Certificate{
-		SerialNumber: big.NewInt(2021),
+		SerialNumber: caSerial,
 		Subject: pkix.Name{
 			Organization: []string{"edgeless.systems"},
 		},
@@ -81,8 +90,17 @@ func (crt *certificateLegacy) setCaBundle() ([]string, error) {
 
 // signRequest signs the webhook certificate using the rootCA
 func (crt *certificateLegacy) signRequest() error {
+	// the serial numbers of certificates issued by the same CA must be unique
+	serverSerial, err := util.GenerateCertificateSerialNumber()
+	for err == nil && serverSerial.Cmp(crt.caSerial) == 0 {
+		serverSerial, err = util.GenerateCertificateSerialNumber()
+	}
+	if err != nil {
+		return err
+	}
+
 	serverCert := &x509.Certificate{
-		SerialNumber: big.NewInt(2022),
+		SerialNumber: serverSerial,
 		Subject: pkix.Name{
 			CommonName:   "system:node:marble-injector.marblerun.svc",
 			Organization: []string{"system:nodes"},
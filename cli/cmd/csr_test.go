@@ -3,8 +3,10 @@ package cmd
 import (
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/x509"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"os"
 	"testing"
 
@@ -100,3 +102,28 @@ func TestCertificateLegacy(t *testing.T) {
 	assert.Equal("marbleInjector.start=true", testValues[0], "failed to set start to true")
 	assert.Contains(testValues[1], "LS0t", "failed to set CABundle")
 }
+
+func TestCertificateLegacySerialNumbers(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	var serials []*big.Int
+	for i := 0; i < 2; i++ {
+		testHandler, err := newCertificateLegacy()
+		require.NoError(err)
+		require.NoError(testHandler.signRequest())
+
+		caCert, err := x509.ParseCertificate(testHandler.caCert.Bytes)
+		require.NoError(err)
+		serverCert, err := x509.ParseCertificate(testHandler.serverCert.Bytes)
+		require.NoError(err)
+		serials = append(serials, caCert.SerialNumber, serverCert.SerialNumber)
+	}
+
+	for i, serial := range serials {
+		assert.Equal(1, serial.Sign(), "serial number is not positive")
+		for _, other := range serials[i+1:] {
+			assert.NotEqual(0, serial.Cmp(other), "serial numbers are not unique")
+		}
+	}
+}
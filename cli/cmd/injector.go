@@ -0,0 +1,18 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func newInjectorCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "injector",
+		Short: "Inspects and tests the Marblerun marble-injector",
+		Long:  `Inspects and tests the Marblerun marble-injector`,
+	}
+
+	cmd.AddCommand(newInjectorCABundle())
+	cmd.AddCommand(newInjectorTest())
+
+	return cmd
+}
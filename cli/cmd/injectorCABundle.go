@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func newInjectorCABundle() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "ca-bundle",
+		Short: "Prints the CA bundle of the marble-injector's webhook",
+		Long: `
+Prints the base64 encoded CA bundle of the marble-injector's webhook, as set by [marblerun install].
+For Kubernetes 1.19 and later, the bundle is the CA of the cluster read from the kube-config.
+For older versions, a new self-signed CA is generated, which differs from the CA of an installed marble-injector.
+Use --output to additionally write the bundle in PEM format to a file.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			kubeClient, err := getKubernetesInterface()
+			if err != nil {
+				return err
+			}
+			certificateHandler, err := getCertificateHandler(kubeClient)
+			if err != nil {
+				return err
+			}
+			return cliInjectorCABundle(os.Stdout, certificateHandler, output)
+		},
+		SilenceUsage: true,
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "File to write the CA bundle to in PEM format")
+
+	return cmd
+}
+
+// cliInjectorCABundle prints the CA bundle set by the certificate handler and optionally writes it to a file
+func cliInjectorCABundle(out io.Writer, certificateHandler certificateInterface, output string) error {
+	injectorValues, err := certificateHandler.setCaBundle()
+	if err != nil {
+		return err
+	}
+
+	var caBundle string
+	for _, value := range injectorValues {
+		if strings.HasPrefix(value, "marbleInjector.CABundle=") {
+			caBundle = strings.TrimPrefix(value, "marbleInjector.CABundle=")
+		}
+	}
+	pemBundle, err := base64.StdEncoding.DecodeString(caBundle)
+	if err != nil {
+		return fmt.Errorf("invalid CA bundle: %v", err)
+	}
+	if block, _ := pem.Decode(pemBundle); block == nil || block.Type != "CERTIFICATE" {
+		return fmt.Errorf("CA bundle does not hold a PEM encoded certificate")
+	}
+
+	fmt.Fprintln(out, caBundle)
+	if output != "" {
+		if err := ioutil.WriteFile(output, pemBundle, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func newInjectorTest() *cobra.Command {
+	var marbleType string
+	var namespace string
+	var caFile string
+	var insecure bool
+
+	cmd := &cobra.Command{
+		Use:   "test <IP:PORT>",
+		Short: "Tests the marble-injector's webhook with a sample pod",
+		Long: `
+Tests the marble-injector's webhook with a sample pod, without deploying a workload.
+Sends an AdmissionReview for a pod of the given marble type to the webhook and prints the returned patch.
+Reports whether environment variables, volumes, and SGX resources were injected.
+The webhook is usually reachable after forwarding its port, e.g., with [kubectl -n marblerun port-forward svc/marble-injector 8443:443].
+Its certificate is verified with the CA bundle given by --ca, e.g., as written by [marblerun injector ca-bundle --output].`,
+		Example: "injector test localhost:8443 --marbletype frontend --ca injector-ca.pem",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := injectorClient(caFile, insecure)
+			if err != nil {
+				return err
+			}
+			return cliInjectorTest(os.Stdout, client, args[0], marbleType, namespace)
+		},
+		SilenceUsage: true,
+	}
+
+	cmd.Flags().StringVar(&marbleType, "marbletype", "", "Marble type of the sample pod")
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "default", "Namespace of the sample pod")
+	cmd.Flags().StringVar(&caFile, "ca", "", "PEM encoded CA bundle to verify the webhook's certificate with")
+	cmd.Flags().BoolVarP(&insecure, "insecure", "i", false, "Set to skip verification of the webhook's certificate")
+	cmd.MarkFlagRequired("marbletype")
+
+	return cmd
+}
+
+// injectorClient creates a http client for the marble-injector's webhook, trusting the CA bundle in caFile
+func injectorClient(caFile string, insecure bool) (*http.Client, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: insecure}
+	if !insecure {
+		if caFile == "" {
+			return nil, fmt.Errorf("either --ca or --insecure must be set")
+		}
+		caBundle, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		certPool := x509.NewCertPool()
+		if ok := certPool.AppendCertsFromPEM(caBundle); !ok {
+			return nil, fmt.Errorf("failed to parse CA bundle %s", caFile)
+		}
+		tlsConfig.RootCAs = certPool
+	}
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}, nil
+}
+
+// cliInjectorTest sends a sample pod of marbleType to the marble-injector's webhook and reports the injected fields
+func cliInjectorTest(out io.Writer, client *http.Client, host string, marbleType string, namespace string) error {
+	pod := sampleMarblePod(marbleType, namespace)
+	rawPod, err := json.Marshal(pod)
+	if err != nil {
+		return err
+	}
+	review := admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "AdmissionReview",
+			APIVersion: admissionv1.SchemeGroupVersion.String(),
+		},
+		Request: &admissionv1.AdmissionRequest{
+			UID:       types.UID(uuid.New().String()),
+			Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+			Resource:  metav1.GroupVersionResource{Version: "v1", Resource: "pods"},
+			Namespace: namespace,
+			Operation: admissionv1.Create,
+			Object:    runtime.RawExtension{Raw: rawPod},
+		},
+	}
+	rawReview, err := json.Marshal(review)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Post(fmt.Sprintf("https://%s/mutate", host), "application/json", bytes.NewReader(rawReview))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+
+	var response admissionv1.AdmissionReview
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return fmt.Errorf("invalid response of the webhook: %v", err)
+	}
+	if response.Response == nil {
+		return fmt.Errorf("invalid response of the webhook: missing admission response")
+	}
+	for _, warning := range response.Response.Warnings {
+		fmt.Fprintf(out, "Warning: %s\n", warning)
+	}
+	if !response.Response.Allowed {
+		return fmt.Errorf("webhook denied the pod: %s", statusMessage(response.Response.Result))
+	}
+	if len(response.Response.Patch) <= 0 {
+		return fmt.Errorf("webhook did not inject the pod: %s", statusMessage(response.Response.Result))
+	}
+
+	var operations []map[string]interface{}
+	if err := json.Unmarshal(response.Response.Patch, &operations); err != nil {
+		return fmt.Errorf("invalid patch: %v", err)
+	}
+	fmt.Fprintln(out, "Patch:")
+	for _, operation := range operations {
+		value, err := json.MarshalIndent(operation["value"], "    ", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "  %s %s:\n    %s\n", operation["op"], operation["path"], value)
+	}
+
+	patch, err := jsonpatch.DecodePatch(response.Response.Patch)
+	if err != nil {
+		return fmt.Errorf("invalid patch: %v", err)
+	}
+	rawPatchedPod, err := patch.Apply(rawPod)
+	if err != nil {
+		return fmt.Errorf("unable to apply patch: %v", err)
+	}
+	var patchedPod corev1.Pod
+	if err := json.Unmarshal(rawPatchedPod, &patchedPod); err != nil {
+		return err
+	}
+
+	var env, sgxResources []string
+	for _, container := range patchedPod.Spec.Containers {
+		for _, envVar := range container.Env {
+			env = append(env, envVar.Name)
+		}
+		for name, quantity := range container.Resources.Limits {
+			if isSGXResource(name.String()) {
+				sgxResources = append(sgxResources, fmt.Sprintf("%s=%s", name, quantity.String()))
+			}
+		}
+	}
+	var volumes []string
+	for _, volume := range patchedPod.Spec.Volumes {
+		volumes = append(volumes, volume.Name)
+	}
+	sort.Strings(sgxResources)
+
+	fmt.Fprintln(out, "Injected:")
+	reportInjected(out, "Environment variables", env)
+	reportInjected(out, "Volumes", volumes)
+	reportInjected(out, "SGX resources", sgxResources)
+	return nil
+}
+
+// sampleMarblePod returns a pod with a single container, labeled as marble of marbleType
+func sampleMarblePod(marbleType string, namespace string) corev1.Pod {
+	return corev1.Pod{
+		TypeMeta: metav1.TypeMeta{Kind: "Pod", APIVersion: "v1"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "marblerun-injector-test",
+			Namespace: namespace,
+			Labels:    map[string]string{"marblerun/marbletype": marbleType},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:  "marble",
+					Image: "marblerun-injector-test",
+				},
+			},
+		},
+	}
+}
+
+// reportInjected prints whether values of the given kind were injected
+func reportInjected(out io.Writer, kind string, values []string) {
+	if len(values) <= 0 {
+		fmt.Fprintf(out, "  %s: no\n", kind)
+		return
+	}
+	fmt.Fprintf(out, "  %s: yes (%s)\n", kind, strings.Join(values, ", "))
+}
+
+// statusMessage returns the message of an admission result
+func statusMessage(result *metav1.Status) string {
+	if result == nil || result.Message == "" {
+		return "no reason given"
+	}
+	return result.Message
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/edgelesssys/marblerun/injector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInjectorCABundle(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	certificateHandler, err := newCertificateLegacy()
+	require.NoError(err)
+
+	tempDir, err := ioutil.TempDir("", "")
+	require.NoError(err)
+	defer os.RemoveAll(tempDir)
+	output := filepath.Join(tempDir, "ca.pem")
+
+	var out bytes.Buffer
+	require.NoError(cliInjectorCABundle(&out, certificateHandler, output))
+
+	// the printed bundle is a base64 encoded CA certificate
+	pemBundle, err := base64.StdEncoding.DecodeString(strings.TrimSpace(out.String()))
+	require.NoError(err)
+	block, _ := pem.Decode(pemBundle)
+	require.NotNil(block)
+	caCert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(err)
+	assert.True(caCert.IsCA)
+	assert.NoError(caCert.CheckSignatureFrom(caCert))
+
+	// the written file holds the same bundle in PEM format
+	written, err := ioutil.ReadFile(output)
+	require.NoError(err)
+	assert.Equal(pemBundle, written)
+
+	// the bundle matches the value used for the helm chart
+	injectorValues, err := certificateHandler.setCaBundle()
+	require.NoError(err)
+	assert.Contains(injectorValues, "marbleInjector.CABundle="+strings.TrimSpace(out.String()))
+}
+
+func TestInjectorTest(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mutator := &injector.Mutator{
+		CoordAddr:              "coordinator-mesh-api.marblerun:2001",
+		DomainName:             "cluster.local",
+		SGXResource:            intelEpc.String(),
+		KnownMarbleTypes:       map[string]bool{"frontend": true},
+		DenyUnknownMarbleTypes: true,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/mutate", mutator.HandleMutate)
+	server := httptest.NewTLSServer(mux)
+	defer server.Close()
+	host := server.Listener.Addr().String()
+
+	var out bytes.Buffer
+	require.NoError(cliInjectorTest(&out, server.Client(), host, "frontend", "default"))
+	assert.Contains(out.String(), "add /spec/containers/0/env")
+	assert.Contains(out.String(), "Environment variables: yes (EDG_MARBLE_COORDINATOR_ADDR, EDG_MARBLE_TYPE, EDG_MARBLE_DNS_NAMES, EDG_MARBLE_UUID_FILE)")
+	assert.Contains(out.String(), "Volumes: yes (uuid-file-")
+	assert.Contains(out.String(), "SGX resources: yes (sgx.intel.com/epc=10)")
+
+	// pods of unknown marble types are denied
+	out.Reset()
+	err := cliInjectorTest(&out, server.Client(), host, "backend", "default")
+	require.Error(err)
+	assert.Contains(err.Error(), "webhook denied the pod")
+
+	// the webhook's certificate is verified
+	_, err = injectorClient("", false)
+	assert.Error(err)
+	client, err := injectorClient("", true)
+	require.NoError(err)
+	assert.NoError(cliInjectorTest(&out, client, host, "frontend", "default"))
+}
@@ -7,6 +7,7 @@ import (
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -27,6 +28,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+	k8syaml "sigs.k8s.io/yaml"
 )
 
 type installOptions struct {
@@ -36,10 +38,12 @@ type installOptions struct {
 	resourceKey      string
 	simulation       bool
 	disableInjection bool
+	outputManifests  bool
 	clientPort       int
 	meshPort         int
 	kubeClient       kubernetes.Interface
 	settings         *cli.EnvSettings
+	out              io.Writer
 }
 
 func newInstallCmd() *cobra.Command {
@@ -52,6 +56,11 @@ func newInstallCmd() *cobra.Command {
 		Args:  cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			options.settings = cli.New()
+			options.out = os.Stdout
+			// manifests are rendered without accessing the cluster
+			if options.outputManifests {
+				return cliInstall(options)
+			}
 			var err error
 			options.kubeClient, err = getKubernetesInterface()
 			if err != nil {
@@ -69,6 +78,7 @@ func newInstallCmd() *cobra.Command {
 	cmd.Flags().StringVar(&options.resourceKey, "resource-key", "", "Resource providing SGX, different depending on used device plugin. Use this to set tolerations/resources if your device plugin is not supported by marblerun")
 	cmd.Flags().BoolVar(&options.simulation, "simulation", false, "Set marblerun to start in simulation mode")
 	cmd.Flags().BoolVar(&options.disableInjection, "disable-auto-injection", false, "Disable automatic injection of selected namespaces")
+	cmd.Flags().BoolVar(&options.outputManifests, "output-manifests", false, "Print the rendered manifests, including the webhook configuration and certificate secret, to stdout instead of installing them on the cluster. The resource key defaults to Intel's device plugin")
 	cmd.Flags().IntVar(&options.meshPort, "mesh-server-port", 2001, "Set the mesh server port. Needs to be configured to the same port as in the data-plane marbles")
 	cmd.Flags().IntVar(&options.clientPort, "client-server-port", 4433, "Set the client server port. Needs to be configured to the same port as in your client tool stack")
 
@@ -88,6 +98,10 @@ func cliInstall(options *installOptions) error {
 	installer.Namespace = "marblerun"
 	installer.ReleaseName = "marblerun-coordinator"
 	installer.ChartPathOptions.Version = options.version
+	if options.outputManifests {
+		installer.DryRun = true
+		installer.ClientOnly = true
+	}
 
 	if options.chartPath == "" {
 		// No chart was specified -> add or update edgeless helm repo
@@ -106,7 +120,9 @@ func cliInstall(options *installOptions) error {
 	}
 
 	var resourceKey string
-	if len(options.resourceKey) <= 0 {
+	if len(options.resourceKey) <= 0 && options.outputManifests {
+		resourceKey = intelEpc.String()
+	} else if len(options.resourceKey) <= 0 {
 		resourceKey, err = getSGXResourceKey(options.kubeClient)
 		if err != nil {
 			return err
@@ -169,7 +185,17 @@ func cliInstall(options *installOptions) error {
 		}
 	}
 
-	if !options.disableInjection {
+	var webhookSecret []byte
+	if !options.disableInjection && options.outputManifests {
+		var injectorValues []string
+		injectorValues, webhookSecret, err = renderWebhook()
+		if err != nil {
+			return err
+		}
+
+		stringValues = append(stringValues, injectorValues...)
+		stringValues = append(stringValues, fmt.Sprintf("marbleInjector.resourceKey=%s", resourceKey))
+	} else if !options.disableInjection {
 		injectorValues, err := installWebhook(options.kubeClient)
 		if err != nil {
 			return errorAndCleanup(err, options.kubeClient)
@@ -193,10 +219,19 @@ func cliInstall(options *installOptions) error {
 		return errorAndCleanup(err, options.kubeClient)
 	}
 
-	if _, err := installer.Run(chart, finalValues); err != nil {
+	release, err := installer.Run(chart, finalValues)
+	if err != nil {
 		return errorAndCleanup(err, options.kubeClient)
 	}
 
+	if options.outputManifests {
+		fmt.Fprint(options.out, release.Manifest)
+		if webhookSecret != nil {
+			fmt.Fprintf(options.out, "---\n# Source: marble-injector-webhook-certs\n%s", webhookSecret)
+		}
+		return nil
+	}
+
 	fmt.Println("Marblerun installed successfully")
 	return nil
 }
@@ -293,8 +328,42 @@ func installWebhook(kubeClient kubernetes.Interface) ([]string, error) {
 	return injectorValues, nil
 }
 
+// renderWebhook creates a self-signed certificate for the webhook server like installWebhook does for kubernetes versions < 1.19,
+// but returns the secret holding the certificate as YAML instead of creating it on the cluster
+func renderWebhook() ([]string, []byte, error) {
+	certificateHandler, err := newCertificateLegacy()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := certificateHandler.signRequest(); err != nil {
+		return nil, nil, err
+	}
+	injectorValues, err := certificateHandler.setCaBundle()
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := certificateHandler.get()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	secret := newWebhookSecret(certificateHandler.getKey(), cert)
+	secret.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"}
+	secretYAML, err := k8syaml.Marshal(secret)
+	if err != nil {
+		return nil, nil, err
+	}
+	return injectorValues, secretYAML, nil
+}
+
 // createSecret creates a secret containing the signed certificate and private key for the webhook server
 func createSecret(privKey *rsa.PrivateKey, crt []byte, kubeClient kubernetes.Interface) error {
+	_, err := kubeClient.CoreV1().Secrets("marblerun").Create(context.TODO(), newWebhookSecret(privKey, crt), metav1.CreateOptions{})
+	return err
+}
+
+// newWebhookSecret creates the secret holding the certificate and private key of the webhook server
+func newWebhookSecret(privKey *rsa.PrivateKey, crt []byte) *corev1.Secret {
 	rsaPEM := pem.EncodeToMemory(
 		&pem.Block{
 			Type:  "RSA PRIVATE KEY",
@@ -302,7 +371,7 @@ func createSecret(privKey *rsa.PrivateKey, crt []byte, kubeClient kubernetes.Int
 		},
 	)
 
-	newSecret := &corev1.Secret{
+	return &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "marble-injector-webhook-certs",
 			Namespace: "marblerun",
@@ -312,9 +381,6 @@ func createSecret(privKey *rsa.PrivateKey, crt []byte, kubeClient kubernetes.Int
 			"key.pem":  rsaPEM,
 		},
 	}
-
-	_, err := kubeClient.CoreV1().Secrets("marblerun").Create(context.TODO(), newSecret, metav1.CreateOptions{})
-	return err
 }
 
 func getCertificateHandler(kubeClient kubernetes.Interface) (certificateInterface, error) {
@@ -421,6 +487,10 @@ func setSGXValues(resourceKey string, values, chartValues map[string]interface{}
 // errorAndCleanup returns the given error and deletes resources which might have been created previously
 // This prevents secrets and CSRs to stay on the cluster after a failed installation attempt
 func errorAndCleanup(err error, kubeClient kubernetes.Interface) error {
+	// nothing was created if the manifests are only rendered
+	if kubeClient == nil {
+		return err
+	}
 	// We dont care about any additional errors here
 	cleanupCSR(kubeClient)
 	cleanupSecrets(kubeClient)
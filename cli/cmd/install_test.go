@@ -1,15 +1,25 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
 	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/cli"
+	admissionv1 "k8s.io/api/admissionregistration/v1"
 	certv1 "k8s.io/api/certificates/v1"
 	corev1 "k8s.io/api/core/v1"
 	kubeErrors "k8s.io/apimachinery/pkg/api/errors"
@@ -18,6 +28,7 @@ import (
 	"k8s.io/apimachinery/pkg/version"
 	fakediscovery "k8s.io/client-go/discovery/fake"
 	"k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/yaml"
 )
 
 func TestCreateSecret(t *testing.T) {
@@ -102,6 +113,92 @@ func TestInstallWebhook(t *testing.T) {
 	assert.Contains(testValues[1], "LS0t", "failed to set CABundle")
 }
 
+func TestInstallOutputManifests(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	chartPath, err := ioutil.TempDir("", "unittest")
+	require.NoError(err)
+	defer os.RemoveAll(chartPath)
+	chartFiles := map[string]string{
+		"Chart.yaml": `
+apiVersion: v2
+name: marblerun-coordinator
+version: 0.1.0
+`,
+		"values.yaml": `
+coordinator:
+  resources:
+    limits: {}
+tolerations: []
+marbleInjector:
+  start: false
+  CABundle: ""
+  resourceKey: ""
+`,
+		"templates/webhook.yaml": `
+{{ if .Values.marbleInjector.start }}
+apiVersion: admissionregistration.k8s.io/v1
+kind: MutatingWebhookConfiguration
+metadata:
+  name: marble-injector
+webhooks:
+  - name: marble-injector.cluster.local
+    clientConfig:
+      caBundle: {{ .Values.marbleInjector.CABundle }}
+      service:
+        name: marble-injector
+        namespace: {{ .Release.Namespace }}
+        path: /mutate
+{{ end }}
+`,
+	}
+	require.NoError(os.Mkdir(filepath.Join(chartPath, "templates"), 0700))
+	for name, content := range chartFiles {
+		require.NoError(ioutil.WriteFile(filepath.Join(chartPath, name), []byte(content), 0600))
+	}
+
+	var out bytes.Buffer
+	options := &installOptions{
+		chartPath:       chartPath,
+		outputManifests: true,
+		settings:        cli.New(),
+		out:             &out,
+	}
+	require.NoError(cliInstall(options))
+
+	var webhook admissionv1.MutatingWebhookConfiguration
+	var secret corev1.Secret
+	for _, document := range strings.Split(out.String(), "---") {
+		switch {
+		case strings.Contains(document, "kind: MutatingWebhookConfiguration"):
+			require.NoError(yaml.Unmarshal([]byte(document), &webhook))
+		case strings.Contains(document, "kind: Secret"):
+			require.NoError(yaml.Unmarshal([]byte(document), &secret))
+		}
+	}
+
+	// the webhook points to the injector service and trusts the CA the secret's certificate was issued by
+	require.Len(webhook.Webhooks, 1)
+	clientConfig := webhook.Webhooks[0].ClientConfig
+	require.NotNil(clientConfig.Service)
+	assert.Equal("marble-injector", clientConfig.Service.Name)
+	assert.Equal("marblerun", clientConfig.Service.Namespace)
+	assert.Contains(out.String(), "caBundle: "+base64.StdEncoding.EncodeToString(clientConfig.CABundle))
+
+	assert.Equal("marble-injector-webhook-certs", secret.Name)
+	assert.Equal("marblerun", secret.Namespace)
+	assert.NotEmpty(secret.Data["key.pem"])
+	block, _ := pem.Decode(secret.Data["cert.pem"])
+	require.NotNil(block)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(err)
+	roots := x509.NewCertPool()
+	require.True(roots.AppendCertsFromPEM(clientConfig.CABundle))
+	_, err = cert.Verify(x509.VerifyOptions{DNSName: "marble-injector.marblerun.svc", Roots: roots})
+	assert.NoError(err)
+}
+
 func TestGetSGXResourceKey(t *testing.T) {
 	assert := assert.New(t)
 	require := require.New(t)
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+func newMaintenanceCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "maintenance",
+		Short: "Enters or leaves the maintenance mode of the Marblerun coordinator",
+		Long: `
+Enters or leaves the maintenance mode of the Marblerun coordinator.
+In maintenance mode, the coordinator refuses new activations, e.g., to drain it before an upgrade.
+Activated marbles keep working and the client API stays available.
+A restarted coordinator accepts activations again.`,
+		Example: "maintenance enter example.com:4433 --cert=admin.crt --key=admin.key [--era-config=config.json] [--insecure]",
+	}
+
+	cmd.PersistentFlags().StringVar(&eraConfig, "era-config", "", "Path to remote attestation config file in json format, if none provided the newest configuration will be loaded from github")
+	cmd.PersistentFlags().BoolVarP(&insecureEra, "insecure", "i", false, "Set to skip quote verification, needed when running in simulation mode")
+	cmd.AddCommand(newMaintenanceToggle("enter", "Enters the maintenance mode, new activations are refused", true))
+	cmd.AddCommand(newMaintenanceToggle("leave", "Leaves the maintenance mode, new activations are accepted again", false))
+
+	return cmd
+}
+
+func newMaintenanceToggle(use string, short string, enabled bool) *cobra.Command {
+	var clientAdminCert string
+	var clientAdminKey string
+
+	cmd := &cobra.Command{
+		Use:   use + " <IP:PORT>",
+		Short: short,
+		Long: fmt.Sprintf(`
+%s.
+An admin certificate specified in the manifest is needed to toggle the maintenance mode.
+`, short),
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			hostName := args[0]
+
+			caCert, err := verifyCoordinator(hostName, eraConfig, insecureEra)
+			if err != nil {
+				return err
+			}
+
+			// Load client certificate and key
+			clCert, err := tls.LoadX509KeyPair(clientAdminCert, clientAdminKey)
+			if err != nil {
+				return err
+			}
+
+			if err := cliMaintenance(enabled, hostName, clCert, caCert); err != nil {
+				return err
+			}
+			if enabled {
+				fmt.Println("Coordinator entered maintenance mode")
+			} else {
+				fmt.Println("Coordinator left maintenance mode")
+			}
+			return nil
+		},
+		SilenceUsage: true,
+	}
+
+	cmd.Flags().StringVarP(&clientAdminCert, "cert", "c", "", "PEM encoded admin certificate file (required)")
+	cmd.MarkFlagRequired("cert")
+	cmd.Flags().StringVarP(&clientAdminKey, "key", "k", "", "PEM encoded admin key file (required)")
+	cmd.MarkFlagRequired("key")
+
+	return cmd
+}
+
+// cliMaintenance enters or leaves the maintenance mode using the coordinators rest api
+func cliMaintenance(enabled bool, host string, clCert tls.Certificate, caCert []*pem.Block) error {
+	client, err := authenticatedRestClient(caCert, clCert)
+	if err != nil {
+		return err
+	}
+
+	url := url.URL{Scheme: "https", Host: host, Path: "maintenance", RawQuery: url.Values{"enabled": {strconv.FormatBool(enabled)}}.Encode()}
+	resp, err := client.Post(url.String(), "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusUnauthorized:
+		return fmt.Errorf("unable to authorize user: %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	default:
+		return fmt.Errorf("error connecting to server: %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"testing"
+
+	"github.com/edgelesssys/marblerun/coordinator/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintenance(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	var requested []string
+	s, host, cert := newTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("/maintenance", r.URL.Path)
+		assert.Equal(http.MethodPost, r.Method)
+		requested = append(requested, r.URL.Query().Get("enabled"))
+		assert.NoError(json.NewEncoder(w).Encode(server.GeneralResponse{Status: "success"}))
+	}))
+	defer s.Close()
+
+	require.NoError(cliMaintenance(true, host, tls.Certificate{}, []*pem.Block{cert}))
+	require.NoError(cliMaintenance(false, host, tls.Certificate{}, []*pem.Block{cert}))
+	assert.Equal([]string{"true", "false"}, requested)
+
+	s, host, cert = newTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer s.Close()
+	assert.Error(cliMaintenance(true, host, tls.Certificate{}, []*pem.Block{cert}))
+}
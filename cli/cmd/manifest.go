@@ -22,6 +22,8 @@ or return a signature of the currently set manifest to the user`,
 	cmd.AddCommand(newManifestUpdate())
 	cmd.AddCommand(newManifestSignature())
 	cmd.AddCommand(newManifestVerify())
+	cmd.AddCommand(newManifestDiff())
+	cmd.AddCommand(newManifestInit())
 
 	return cmd
 }
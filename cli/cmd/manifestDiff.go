@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+
+	"github.com/edgelesssys/marblerun/coordinator/manifest"
+	"github.com/spf13/cobra"
+	"github.com/tidwall/gjson"
+)
+
+func newManifestDiff() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff <manifest.json> <IP:PORT>",
+		Short: "Shows the differences between a local manifest and the one deployed to the Marblerun coordinator",
+		Long: `
+Shows the differences between a local manifest and the one deployed to the Marblerun coordinator.
+Added, removed, and changed packages, marbles, and secrets are listed.
+Exits with a non-zero status if the manifests differ.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			manifestFile := args[0]
+			hostName := args[1]
+
+			localManifest, err := loadManifestFile(manifestFile)
+			if err != nil {
+				return err
+			}
+
+			cert, err := verifyCoordinator(hostName, eraConfig, insecureEra)
+			if err != nil {
+				return err
+			}
+
+			deployedManifest, err := cliManifestGetRaw(hostName, cert)
+			if err != nil {
+				return err
+			}
+
+			diff, err := cliManifestDiff(localManifest, deployedManifest)
+			if err != nil {
+				return err
+			}
+			if diff.empty() {
+				fmt.Println("Manifests are identical")
+				return nil
+			}
+			diff.print(os.Stdout)
+			return errors.New("local manifest differs from the deployed manifest")
+		},
+		SilenceUsage: true,
+	}
+
+	return cmd
+}
+
+// manifestDiff holds the differences between two manifests, listed by section
+type manifestDiff struct {
+	Packages mapDiff
+	Marbles  mapDiff
+	Secrets  mapDiff
+}
+
+// mapDiff holds the names of the entries of a manifest section which were added, removed, or changed
+type mapDiff struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+func (d mapDiff) empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+func (d manifestDiff) empty() bool {
+	return d.Packages.empty() && d.Marbles.empty() && d.Secrets.empty()
+}
+
+func (d manifestDiff) print(out io.Writer) {
+	sections := []struct {
+		name string
+		diff mapDiff
+	}{
+		{"Packages", d.Packages},
+		{"Marbles", d.Marbles},
+		{"Secrets", d.Secrets},
+	}
+	for _, section := range sections {
+		if section.diff.empty() {
+			continue
+		}
+		fmt.Fprintf(out, "%s:\n", section.name)
+		for _, name := range section.diff.Added {
+			fmt.Fprintf(out, "  + %s\n", name)
+		}
+		for _, name := range section.diff.Removed {
+			fmt.Fprintf(out, "  - %s\n", name)
+		}
+		for _, name := range section.diff.Changed {
+			fmt.Fprintf(out, "  ~ %s\n", name)
+		}
+	}
+}
+
+// cliManifestDiff compares the local manifest to the deployed one. Entries only present in the local manifest are reported as added.
+func cliManifestDiff(localManifest []byte, deployedManifest []byte) (manifestDiff, error) {
+	var local, deployed manifest.Manifest
+	if err := json.Unmarshal(localManifest, &local); err != nil {
+		return manifestDiff{}, fmt.Errorf("parsing local manifest: %v", err)
+	}
+	if err := json.Unmarshal(deployedManifest, &deployed); err != nil {
+		return manifestDiff{}, fmt.Errorf("parsing deployed manifest: %v", err)
+	}
+
+	return manifestDiff{
+		Packages: diffMaps(local.Packages, deployed.Packages),
+		Marbles:  diffMaps(local.Marbles, deployed.Marbles),
+		Secrets:  diffMaps(local.Secrets, deployed.Secrets),
+	}, nil
+}
+
+// diffMaps compares two maps with string keys and values of the same type
+func diffMaps(local interface{}, deployed interface{}) mapDiff {
+	var diff mapDiff
+	localMap := reflect.ValueOf(local)
+	deployedMap := reflect.ValueOf(deployed)
+
+	for _, key := range localMap.MapKeys() {
+		deployedValue := deployedMap.MapIndex(key)
+		if !deployedValue.IsValid() {
+			diff.Added = append(diff.Added, key.String())
+		} else if !reflect.DeepEqual(localMap.MapIndex(key).Interface(), deployedValue.Interface()) {
+			diff.Changed = append(diff.Changed, key.String())
+		}
+	}
+	for _, key := range deployedMap.MapKeys() {
+		if !localMap.MapIndex(key).IsValid() {
+			diff.Removed = append(diff.Removed, key.String())
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	return diff
+}
+
+// cliManifestGetRaw gets the deployed manifest from the coordinators rest api
+func cliManifestGetRaw(host string, cert []*pem.Block) ([]byte, error) {
+	client, err := restClient(cert)
+	if err != nil {
+		return nil, err
+	}
+
+	url := url.URL{Scheme: "https", Host: host, Path: "manifest"}
+	resp, err := client.Get(url.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		respBody, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		var response struct {
+			Manifest []byte
+		}
+		if err := json.Unmarshal([]byte(gjson.GetBytes(respBody, "data").Raw), &response); err != nil {
+			return nil, err
+		}
+		if len(response.Manifest) == 0 {
+			return nil, errors.New("coordinator has no manifest set")
+		}
+		return response.Manifest, nil
+	default:
+		return nil, fmt.Errorf("error connecting to server: %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+}
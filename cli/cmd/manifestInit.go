@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/edgelesssys/marblerun/coordinator/quote"
+	"github.com/edgelesssys/marblerun/coordinator/rpc"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// marbleTypeLabel is the pod label the marble-injector reads the marble type from
+const marbleTypeLabel = "marblerun/marbletype"
+
+func newManifestInit() *cobra.Command {
+	var namespace string
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "init --from-namespace <namespace>",
+		Short: "Generates a manifest skeleton from the marbles running in a namespace",
+		Long: `
+Generates a manifest skeleton from the pods in a namespace carrying the marblerun/marbletype label.
+A package and a marble are created for each marble type, using the command line of the pod's first container as arguments.
+The enclave measurements of the packages need to be filled in before the manifest can be set.`,
+		Example: "manifest init --from-namespace default -o manifest.yaml",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			kubeClient, err := getKubernetesInterface()
+			if err != nil {
+				return err
+			}
+
+			skeleton, err := cliManifestInit(kubeClient, namespace)
+			if err != nil {
+				return err
+			}
+
+			if len(output) > 0 {
+				return ioutil.WriteFile(output, skeleton, 0644)
+			}
+			_, err = os.Stdout.Write(skeleton)
+			return err
+		},
+		SilenceUsage: true,
+	}
+
+	cmd.Flags().StringVar(&namespace, "from-namespace", "", "Namespace of the pods to generate the manifest from")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Save the manifest to file instead of printing to stdout")
+	cmd.MarkFlagRequired("from-namespace")
+	return cmd
+}
+
+// manifestSkeleton is the subset of the manifest which can be derived from running pods
+type manifestSkeleton struct {
+	Packages map[string]quote.PackageProperties
+	Marbles  map[string]marbleSkeleton
+}
+
+type marbleSkeleton struct {
+	Package        string
+	MaxActivations uint
+	Parameters     *rpc.Parameters
+}
+
+// cliManifestInit creates a manifest skeleton in YAML format with an entry for each marble type found in the namespace
+func cliManifestInit(kubeClient kubernetes.Interface, namespace string) ([]byte, error) {
+	pods, err := kubeClient.CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{
+		LabelSelector: marbleTypeLabel,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(pods.Items) == 0 {
+		return nil, fmt.Errorf("no pods with label %s found in namespace %s", marbleTypeLabel, namespace)
+	}
+
+	// sort the pods, so the first pod of each marble type is chosen deterministically
+	sort.Slice(pods.Items, func(i, j int) bool { return pods.Items[i].Name < pods.Items[j].Name })
+
+	skeleton := manifestSkeleton{
+		Packages: make(map[string]quote.PackageProperties),
+		Marbles:  make(map[string]marbleSkeleton),
+	}
+	for _, pod := range pods.Items {
+		marbleType := pod.Labels[marbleTypeLabel]
+		if _, ok := skeleton.Marbles[marbleType]; ok || marbleType == "" {
+			continue
+		}
+		var productID uint64
+		var securityVersion uint
+		skeleton.Packages[marbleType] = quote.PackageProperties{
+			ProductID:       &productID,
+			SecurityVersion: &securityVersion,
+		}
+		skeleton.Marbles[marbleType] = marbleSkeleton{
+			Package:    marbleType,
+			Parameters: parametersFromContainer(pod.Spec.Containers),
+		}
+	}
+	if len(skeleton.Marbles) == 0 {
+		return nil, errors.New("all pods have an empty marble type")
+	}
+
+	return yaml.Marshal(skeleton)
+}
+
+// parametersFromContainer derives the marble's arguments and environment from the pod's first container.
+// Variables set by the marble-injector and values taken from other resources are skipped.
+func parametersFromContainer(containers []corev1.Container) *rpc.Parameters {
+	params := &rpc.Parameters{}
+	if len(containers) == 0 {
+		return params
+	}
+	container := containers[0]
+
+	params.Argv = append(params.Argv, container.Command...)
+	params.Argv = append(params.Argv, container.Args...)
+	for _, env := range container.Env {
+		if env.ValueFrom != nil || strings.HasPrefix(env.Name, "EDG_") {
+			continue
+		}
+		if params.Env == nil {
+			params.Env = make(map[string]string)
+		}
+		params.Env[env.Name] = env.Value
+	}
+	return params
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"encoding/pem"
 	"fmt"
@@ -9,6 +10,7 @@ import (
 	"net/http"
 	"net/url"
 
+	"github.com/edgelesssys/marblerun/coordinator/server"
 	"github.com/spf13/cobra"
 	"github.com/tidwall/gjson"
 	"sigs.k8s.io/yaml"
@@ -16,6 +18,7 @@ import (
 
 func newManifestSet() *cobra.Command {
 	var recoveryFilename string
+	var signatureFilename string
 
 	cmd := &cobra.Command{
 		Use:   "set <manifest.json> <IP:PORT>",
@@ -41,25 +44,41 @@ func newManifestSet() *cobra.Command {
 			signature := cliManifestSignature(manifest)
 			fmt.Printf("Manifest signature: %s\n", signature)
 
-			return cliManifestSet(manifest, hostName, cert, recoveryFilename)
+			var manifestSignature []byte
+			if signatureFilename != "" {
+				if manifestSignature, err = ioutil.ReadFile(signatureFilename); err != nil {
+					return err
+				}
+			}
+
+			return cliManifestSet(manifest, hostName, cert, recoveryFilename, manifestSignature)
 		},
 		SilenceUsage: true,
 	}
 
 	cmd.Flags().StringVarP(&recoveryFilename, "recoverydata", "r", "", "File to write recovery data to, print to stdout if non specified")
+	cmd.Flags().StringVarP(&signatureFilename, "signature", "s", "", "File containing a signature over the SHA-256 hash of the manifest in JSON format, required if the coordinator is pinned to a manifest signing key")
 
 	return cmd
 }
 
 // cliManifestSet sets the coordinators manifest using its rest api
-func cliManifestSet(manifest []byte, host string, cert []*pem.Block, recover string) error {
+func cliManifestSet(manifest []byte, host string, cert []*pem.Block, recover string, signature []byte) error {
 	client, err := restClient(cert)
 	if err != nil {
 		return err
 	}
 
 	url := url.URL{Scheme: "https", Host: host, Path: "manifest"}
-	resp, err := client.Post(url.String(), "application/json", bytes.NewReader(manifest))
+	req, err := http.NewRequest(http.MethodPost, url.String(), bytes.NewReader(manifest))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(signature) > 0 {
+		req.Header.Set(server.ManifestSignatureHeader, base64.StdEncoding.EncodeToString(signature))
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return err
 	}
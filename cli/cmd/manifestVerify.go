@@ -1,24 +1,44 @@
 package cmd
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"encoding/pem"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"reflect"
+	"sort"
+	"text/template"
 
+	"github.com/edgelesssys/marblerun/coordinator/manifest"
 	"github.com/spf13/cobra"
+	"github.com/tidwall/gjson"
+	"go.uber.org/zap"
 )
 
 func newManifestVerify() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "verify <manifest/signature> <IP:PORT>",
-		Short: "Verifies the signature of a Marblerun manifest",
-		Long:  `Verifies that the signature returned by the Coordinator is equal to a local signature`,
-		Args:  cobra.ExactArgs(2),
+		Use:   "verify <manifest/signature> [IP:PORT]",
+		Short: "Verifies the signature of a Marblerun manifest, or checks a manifest file offline",
+		Long: `
+Verifies that the signature returned by the Coordinator is equal to a local signature.
+If no Coordinator address is given, the manifest file is checked offline instead:
+all references between packages, marbles, secrets, and TLS tags must be defined,
+names must be unique, and measurements must be valid. All problems are reported at once.`,
+		Args: cobra.RangeArgs(1, 2),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			manifest := args[0]
+			if len(args) == 1 {
+				rawManifest, err := loadManifestFile(manifest)
+				if err != nil {
+					return err
+				}
+				return cliManifestVerifyOffline(os.Stdout, rawManifest)
+			}
 			hostName := args[1]
 
 			cert, err := verifyCoordinator(hostName, eraConfig, insecureEra)
@@ -80,3 +100,148 @@ func cliManifestVerify(localSignature string, host string, cert []*pem.Block) er
 	fmt.Println("OK")
 	return nil
 }
+
+// cliManifestVerifyOffline checks a manifest without a Coordinator and prints all problems found
+func cliManifestVerifyOffline(out io.Writer, rawManifest []byte) error {
+	problems := checkManifest(rawManifest)
+	if len(problems) == 0 {
+		fmt.Fprintln(out, "OK")
+		return nil
+	}
+	for _, problem := range problems {
+		fmt.Fprintf(out, "  - %s\n", problem)
+	}
+	return fmt.Errorf("manifest is invalid: found %d problems", len(problems))
+}
+
+// checkManifest returns all problems of a manifest which would cause the Coordinator to reject it or to fail activating marbles
+func checkManifest(rawManifest []byte) []string {
+	var problems []string
+
+	// duplicate names are silently overwritten when decoding the manifest, so they are checked on the raw JSON
+	for _, section := range []string{"Packages", "Infrastructures", "Marbles", "Secrets", "TLS", "Admins", "Clients", "RecoveryKeys"} {
+		seen := make(map[string]bool)
+		gjson.GetBytes(rawManifest, section).ForEach(func(key, _ gjson.Result) bool {
+			if seen[key.String()] {
+				problems = append(problems, fmt.Sprintf("%s: duplicate name %s", section, key.String()))
+			}
+			seen[key.String()] = true
+			return true
+		})
+	}
+
+	var mnf manifest.Manifest
+	if err := json.Unmarshal(rawManifest, &mnf); err != nil {
+		return append(problems, fmt.Sprintf("invalid manifest: %v", err))
+	}
+
+	if len(mnf.Packages) == 0 {
+		problems = append(problems, "no packages defined")
+	}
+	if len(mnf.Marbles) == 0 {
+		problems = append(problems, "no marbles defined")
+	}
+
+	for _, name := range sortedKeys(mnf.Packages) {
+		pkg := mnf.Packages[name]
+		if pkg.UniqueID != "" && !isMeasurement(pkg.UniqueID) {
+			problems = append(problems, fmt.Sprintf("package %s: invalid UniqueID %s: must be 32 bytes in hex", name, pkg.UniqueID))
+		}
+		if pkg.SignerID != "" && !isMeasurement(pkg.SignerID) {
+			problems = append(problems, fmt.Sprintf("package %s: invalid SignerID %s: must be 32 bytes in hex", name, pkg.SignerID))
+		}
+	}
+
+	for _, name := range sortedKeys(mnf.Marbles) {
+		marble := mnf.Marbles[name]
+		if _, ok := mnf.Packages[marble.Package]; !ok {
+			problems = append(problems, fmt.Sprintf("marble %s: undefined package %s", name, marble.Package))
+		}
+		for _, tag := range marble.TLS {
+			if _, ok := mnf.TLS[tag]; !ok {
+				problems = append(problems, fmt.Sprintf("marble %s: undefined TLS tag %s", name, tag))
+			}
+		}
+		if marble.Parameters == nil {
+			continue
+		}
+		for _, path := range sortedKeys(marble.Parameters.Files) {
+			problems = append(problems, checkParameterTemplate(mnf, fmt.Sprintf("marble %s: file %s", name, path), marble.Parameters.Files[path])...)
+		}
+		for _, env := range sortedKeys(marble.Parameters.Env) {
+			problems = append(problems, checkParameterTemplate(mnf, fmt.Sprintf("marble %s: environment variable %s", name, env), marble.Parameters.Env[env])...)
+		}
+	}
+
+	for _, name := range sortedKeys(mnf.Secrets) {
+		if err := checkSecretType(mnf.Secrets[name]); err != nil {
+			problems = append(problems, fmt.Sprintf("secret %s: %v", name, err))
+		}
+	}
+
+	// the remaining rules are enforced by the Coordinator, which stops at the first violation
+	if len(problems) == 0 {
+		if err := mnf.Check(context.Background(), zap.NewNop()); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	return problems
+}
+
+// checkParameterTemplate checks that a parameter of a marble is a valid template referencing only defined secrets
+func checkParameterTemplate(mnf manifest.Manifest, location string, data string) []string {
+	tpl, err := template.New("data").Funcs(manifest.ManifestTemplateFuncMap).Parse(data)
+	if err != nil {
+		return []string{fmt.Sprintf("%s: invalid template: %v", location, err)}
+	}
+	var problems []string
+	for _, secret := range manifest.ReferencedSecrets(tpl.Root) {
+		if _, ok := mnf.Secrets[secret]; !ok {
+			problems = append(problems, fmt.Sprintf("%s: undefined secret %s", location, secret))
+		}
+	}
+	return problems
+}
+
+// checkSecretType checks that the Coordinator can generate a secret of the given type and size
+func checkSecretType(secret manifest.Secret) error {
+	switch secret.Type {
+	case "symmetric-key":
+		if secret.Size == 0 || secret.Size%8 != 0 {
+			return fmt.Errorf("invalid size %d: must be a positive multiple of 8", secret.Size)
+		}
+	case "cert-rsa":
+		if secret.Size == 0 {
+			return fmt.Errorf("missing size")
+		}
+	case "cert-ed25519":
+		if secret.Size != 0 {
+			return fmt.Errorf("invalid size %d: no size is expected", secret.Size)
+		}
+	case "cert-ecdsa":
+		switch secret.Size {
+		case 224, 256, 384, 521:
+		default:
+			return fmt.Errorf("invalid size %d: must be 224, 256, 384, or 521", secret.Size)
+		}
+	default:
+		return fmt.Errorf("unsupported type %s", secret.Type)
+	}
+	return nil
+}
+
+// isMeasurement checks if value is a hex-encoded 32 byte measurement, e.g., a UniqueID or SignerID
+func isMeasurement(value string) bool {
+	decoded, err := hex.DecodeString(value)
+	return err == nil && len(decoded) == sha256.Size
+}
+
+// sortedKeys returns the keys of a map with string keys in sorted order
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	for _, key := range reflect.ValueOf(m).MapKeys() {
+		keys = append(keys, key.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
 	"crypto/sha256"
 	"crypto/tls"
 	"encoding/hex"
@@ -12,9 +14,15 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/edgelesssys/marblerun/coordinator/manifest"
 	"github.com/edgelesssys/marblerun/coordinator/server"
+	"github.com/edgelesssys/marblerun/test"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/yaml"
 )
 
 func TestCliManifestGet(t *testing.T) {
@@ -65,6 +73,11 @@ func TestCliManifestSet(t *testing.T) {
 			return
 		}
 
+		if string(reqData) == "33" {
+			assert.Equal("c2lnbmF0dXJl", r.Header.Get(server.ManifestSignatureHeader))
+			return
+		}
+
 		if string(reqData) == "11" {
 			serverResp := server.GeneralResponse{
 				Status: "success",
@@ -87,20 +100,23 @@ func TestCliManifestSet(t *testing.T) {
 	require.NoError(err)
 	defer os.RemoveAll(dir)
 
-	err = cliManifestSet([]byte("00"), host, []*pem.Block{cert}, "")
+	err = cliManifestSet([]byte("00"), host, []*pem.Block{cert}, "", nil)
+	require.NoError(err)
+
+	err = cliManifestSet([]byte("33"), host, []*pem.Block{cert}, "", []byte("signature"))
 	require.NoError(err)
 
-	err = cliManifestSet([]byte("11"), host, []*pem.Block{cert}, "")
+	err = cliManifestSet([]byte("11"), host, []*pem.Block{cert}, "", nil)
 	require.NoError(err)
 
 	responseFile := filepath.Join(dir, "tmp-recovery.json")
-	err = cliManifestSet([]byte("11"), host, []*pem.Block{cert}, responseFile)
+	err = cliManifestSet([]byte("11"), host, []*pem.Block{cert}, responseFile, nil)
 	require.NoError(err)
 
-	err = cliManifestSet([]byte("22"), host, []*pem.Block{cert}, "")
+	err = cliManifestSet([]byte("22"), host, []*pem.Block{cert}, "", nil)
 	require.Error(err)
 
-	err = cliManifestSet([]byte("55"), host, []*pem.Block{cert}, "")
+	err = cliManifestSet([]byte("55"), host, []*pem.Block{cert}, "", nil)
 	require.Error(err)
 }
 
@@ -302,3 +318,222 @@ func TestGetSignatureFromString(t *testing.T) {
 	_, err = getSignatureFromString("invalidFilename")
 	assert.Error(err)
 }
+
+func TestCliManifestDiff(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	// modify a generic copy of the test manifest, so the remaining entries stay untouched
+	modifyManifest := func(modify func(m map[string]map[string]interface{})) []byte {
+		var m map[string]map[string]interface{}
+		require.NoError(json.Unmarshal([]byte(test.ManifestJSON), &m))
+		modify(m)
+		rawManifest, err := json.Marshal(m)
+		require.NoError(err)
+		return rawManifest
+	}
+
+	// identical
+	diff, err := cliManifestDiff([]byte(test.ManifestJSON), []byte(test.ManifestJSON))
+	require.NoError(err)
+	assert.True(diff.empty())
+
+	// added marble
+	local := modifyManifest(func(m map[string]map[string]interface{}) {
+		m["Marbles"]["new_marble"] = manifest.Marble{Package: "backend"}
+	})
+	diff, err = cliManifestDiff(local, []byte(test.ManifestJSON))
+	require.NoError(err)
+	assert.False(diff.empty())
+	assert.Equal([]string{"new_marble"}, diff.Marbles.Added)
+	assert.True(diff.Packages.empty())
+	assert.True(diff.Secrets.empty())
+
+	// removed marble is reported the other way round
+	diff, err = cliManifestDiff([]byte(test.ManifestJSON), local)
+	require.NoError(err)
+	assert.Equal([]string{"new_marble"}, diff.Marbles.Removed)
+
+	// changed package
+	local = modifyManifest(func(m map[string]map[string]interface{}) {
+		frontend := m["Packages"]["frontend"].(map[string]interface{})
+		frontend["SecurityVersion"] = frontend["SecurityVersion"].(float64) + 1
+	})
+	diff, err = cliManifestDiff(local, []byte(test.ManifestJSON))
+	require.NoError(err)
+	assert.Equal([]string{"frontend"}, diff.Packages.Changed)
+	assert.True(diff.Marbles.empty())
+	assert.True(diff.Secrets.empty())
+
+	var out bytes.Buffer
+	diff.print(&out)
+	assert.Equal("Packages:\n  ~ frontend\n", out.String())
+
+	_, err = cliManifestDiff([]byte("invalid"), []byte(test.ManifestJSON))
+	assert.Error(err)
+}
+
+func TestCliManifestGetRaw(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+	s, host, cert := newTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("/manifest", r.RequestURI)
+		assert.Equal(http.MethodGet, r.Method)
+
+		serverResp := server.GeneralResponse{
+			Status: "success",
+			Data: struct {
+				ManifestSignature string
+				Manifest          []byte
+			}{"TestSignature", []byte(test.ManifestJSON)},
+		}
+		assert.NoError(json.NewEncoder(w).Encode(serverResp))
+	}))
+	defer s.Close()
+
+	resp, err := cliManifestGetRaw(host, []*pem.Block{cert})
+	require.NoError(err)
+	assert.Equal(test.ManifestJSON, string(resp))
+}
+
+func TestCliManifestInit(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	testClient := fake.NewSimpleClientset()
+
+	// no marbles in the namespace
+	_, err := cliManifestInit(testClient, "default")
+	assert.Error(err)
+
+	pods := []*corev1.Pod{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "backend-1", Namespace: "default", Labels: map[string]string{"marblerun/marbletype": "backend"}},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Name:    "backend",
+				Command: []string{"/app/backend"},
+				Args:    []string{"--port", "8080"},
+				Env: []corev1.EnvVar{
+					{Name: "LOG_LEVEL", Value: "debug"},
+					{Name: "EDG_MARBLE_TYPE", Value: "backend"},
+					{Name: "POD_NAME", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"}}},
+				},
+			}}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "backend-2", Namespace: "default", Labels: map[string]string{"marblerun/marbletype": "backend"}},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "backend", Command: []string{"/app/other"}}}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "frontend", Namespace: "default", Labels: map[string]string{"marblerun/marbletype": "frontend"}},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "frontend"}}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "unlabeled", Namespace: "default"},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "unlabeled"}}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "other-namespace", Namespace: "other", Labels: map[string]string{"marblerun/marbletype": "other"}},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "other"}}},
+		},
+	}
+	for _, pod := range pods {
+		_, err := testClient.CoreV1().Pods(pod.Namespace).Create(context.TODO(), pod, metav1.CreateOptions{})
+		require.NoError(err)
+	}
+
+	skeleton, err := cliManifestInit(testClient, "default")
+	require.NoError(err)
+	assert.Equal(`Marbles:
+  backend:
+    MaxActivations: 0
+    Package: backend
+    Parameters:
+      Argv:
+      - /app/backend
+      - --port
+      - "8080"
+      Env:
+        LOG_LEVEL: debug
+  frontend:
+    MaxActivations: 0
+    Package: frontend
+    Parameters: {}
+Packages:
+  backend:
+    Debug: false
+    ProductID: 0
+    SecurityVersion: 0
+    SignerID: ""
+    UniqueID: ""
+  frontend:
+    Debug: false
+    ProductID: 0
+    SecurityVersion: 0
+    SignerID: ""
+    UniqueID: ""
+`, string(skeleton))
+
+	// the skeleton is a valid manifest once converted to JSON
+	manifestJSON, err := yaml.YAMLToJSON(skeleton)
+	require.NoError(err)
+	var mnf manifest.Manifest
+	require.NoError(json.Unmarshal(manifestJSON, &mnf))
+	assert.Equal("backend", mnf.Marbles["backend"].Package)
+}
+
+func TestCliManifestVerifyOffline(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var out bytes.Buffer
+	require.NoError(cliManifestVerifyOffline(&out, []byte(test.ManifestJSON)))
+	assert.Equal("OK\n", out.String())
+
+	const validPackage = `"backend": {"SignerID": "43361affedeb75affee9baec7e054a5e14883213e5a121b67d74a0e12e9d2b7a", "ProductID": 43, "SecurityVersion": 1}`
+	testCases := map[string]struct {
+		manifest string
+		problems []string
+	}{
+		"dangling secret reference": {
+			manifest: `{"Packages": {` + validPackage + `}, "Marbles": {"backend": {"Package": "backend", "Parameters": {"Files": {"/key": "{{ hex .Secrets.missing.Private }}"}}}}}`,
+			problems: []string{"marble backend: file /key: undefined secret missing"},
+		},
+		"duplicate marble name": {
+			manifest: `{"Packages": {` + validPackage + `}, "Marbles": {"backend": {"Package": "backend"}, "backend": {"Package": "backend"}}}`,
+			problems: []string{"Marbles: duplicate name backend"},
+		},
+		"bad measurement": {
+			manifest: `{"Packages": {"backend": {"UniqueID": "not-a-hash"}}, "Marbles": {"backend": {"Package": "backend"}}}`,
+			problems: []string{"package backend: invalid UniqueID not-a-hash: must be 32 bytes in hex"},
+		},
+		"several problems": {
+			manifest: `{
+				"Packages": {` + validPackage + `},
+				"Marbles": {"frontend": {"Package": "frontend", "TLS": ["web"]}},
+				"Secrets": {"key": {"Type": "symmetric-key", "Size": 12}}
+			}`,
+			problems: []string{
+				"marble frontend: undefined package frontend",
+				"marble frontend: undefined TLS tag web",
+				"secret key: invalid size 12: must be a positive multiple of 8",
+			},
+		},
+		"rule enforced by the coordinator": {
+			manifest: `{"Packages": {"backend": {"SignerID": "43361affedeb75affee9baec7e054a5e14883213e5a121b67d74a0e12e9d2b7a"}}, "Marbles": {"backend": {"Package": "backend"}}}`,
+			problems: []string{"manifest misses value for ProductID in package backend"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(tc.problems, checkManifest([]byte(tc.manifest)))
+
+			var out bytes.Buffer
+			assert.Error(cliManifestVerifyOffline(&out, []byte(tc.manifest)))
+			for _, problem := range tc.problems {
+				assert.Contains(out.String(), problem)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func newMarbleCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "marble",
+		Short: "Lists and revokes the marbles activated by the Marblerun coordinator",
+		Long: `
+Lists and revokes the marbles activated by the Marblerun coordinator.
+A revoked marble UUID can't be used to activate again, e.g., after its node was decommissioned.`,
+		Example: "marble revoke <uuid> example.com:4433 --cert=admin.crt --key=admin.key [--era-config=config.json] [--insecure]",
+	}
+
+	cmd.PersistentFlags().StringVar(&eraConfig, "era-config", "", "Path to remote attestation config file in json format, if none provided the newest configuration will be loaded from github")
+	cmd.PersistentFlags().BoolVarP(&insecureEra, "insecure", "i", false, "Set to skip quote verification, needed when running in simulation mode")
+	cmd.AddCommand(newMarbleList())
+	cmd.AddCommand(newMarbleRevoke())
+
+	return cmd
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/edgelesssys/marblerun/coordinator/core"
+	"github.com/spf13/cobra"
+	"github.com/tidwall/gjson"
+)
+
+func newMarbleList() *cobra.Command {
+	var clientAdminCert string
+	var clientAdminKey string
+
+	cmd := &cobra.Command{
+		Use:   "list <IP:PORT>",
+		Short: "Lists the marbles activated by the Marblerun coordinator",
+		Long: `
+Lists the marbles activated by the Marblerun coordinator, including revoked ones.
+An admin certificate specified in the manifest is needed to list the marbles.
+`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			hostName := args[0]
+
+			caCert, err := verifyCoordinator(hostName, eraConfig, insecureEra)
+			if err != nil {
+				return err
+			}
+
+			// Load client certificate and key
+			clCert, err := tls.LoadX509KeyPair(clientAdminCert, clientAdminKey)
+			if err != nil {
+				return err
+			}
+
+			return cliMarbleList(os.Stdout, hostName, clCert, caCert)
+		},
+		SilenceUsage: true,
+	}
+
+	cmd.Flags().StringVarP(&clientAdminCert, "cert", "c", "", "PEM encoded admin certificate file (required)")
+	cmd.MarkFlagRequired("cert")
+	cmd.Flags().StringVarP(&clientAdminKey, "key", "k", "", "PEM encoded admin key file (required)")
+	cmd.MarkFlagRequired("key")
+
+	return cmd
+}
+
+// cliMarbleList gets the leases of the activated marbles from the coordinators rest api and prints them to out
+func cliMarbleList(out io.Writer, host string, clCert tls.Certificate, caCert []*pem.Block) error {
+	client, err := authenticatedRestClient(caCert, clCert)
+	if err != nil {
+		return err
+	}
+
+	url := url.URL{Scheme: "https", Host: host, Path: "marbles"}
+	resp, err := client.Get(url.String())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		respBody, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		var response struct {
+			Marbles []core.MarbleLease
+		}
+		if err := json.Unmarshal([]byte(gjson.GetBytes(respBody, "data").Raw), &response); err != nil {
+			return err
+		}
+		if len(response.Marbles) == 0 {
+			fmt.Fprintln(out, "No marbles have been activated")
+			return nil
+		}
+		for _, lease := range response.Marbles {
+			state := "active"
+			if lease.Revoked {
+				state = "revoked"
+			}
+			fmt.Fprintf(out, "%s %s %s %s\n", lease.UUID, lease.MarbleType, lease.ActivatedAt.Format(time.RFC3339), state)
+		}
+		return nil
+	case http.StatusUnauthorized:
+		return fmt.Errorf("unable to authorize user: %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	default:
+		return fmt.Errorf("error connecting to server: %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+}
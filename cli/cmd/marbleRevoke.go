@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/spf13/cobra"
+)
+
+func newMarbleRevoke() *cobra.Command {
+	var clientAdminCert string
+	var clientAdminKey string
+
+	cmd := &cobra.Command{
+		Use:   "revoke <uuid> <IP:PORT>",
+		Short: "Revokes the lease of a marble activated by the Marblerun coordinator",
+		Long: `
+Revokes the lease of a marble activated by the Marblerun coordinator.
+Future activations with the revoked UUID are denied.
+An admin certificate specified in the manifest is needed to revoke a marble.
+`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			marbleUUID := args[0]
+			hostName := args[1]
+
+			caCert, err := verifyCoordinator(hostName, eraConfig, insecureEra)
+			if err != nil {
+				return err
+			}
+
+			// Load client certificate and key
+			clCert, err := tls.LoadX509KeyPair(clientAdminCert, clientAdminKey)
+			if err != nil {
+				return err
+			}
+
+			if err := cliMarbleRevoke(marbleUUID, hostName, clCert, caCert); err != nil {
+				return err
+			}
+			fmt.Printf("Revoked marble %s\n", marbleUUID)
+			return nil
+		},
+		SilenceUsage: true,
+	}
+
+	cmd.Flags().StringVarP(&clientAdminCert, "cert", "c", "", "PEM encoded admin certificate file (required)")
+	cmd.MarkFlagRequired("cert")
+	cmd.Flags().StringVarP(&clientAdminKey, "key", "k", "", "PEM encoded admin key file (required)")
+	cmd.MarkFlagRequired("key")
+
+	return cmd
+}
+
+// cliMarbleRevoke revokes the lease of a marble using the coordinators rest api
+func cliMarbleRevoke(marbleUUID string, host string, clCert tls.Certificate, caCert []*pem.Block) error {
+	client, err := authenticatedRestClient(caCert, clCert)
+	if err != nil {
+		return err
+	}
+
+	url := url.URL{Scheme: "https", Host: host, Path: "marbles/revoke", RawQuery: url.Values{"uuid": {marbleUUID}}.Encode()}
+	resp, err := client.Post(url.String(), "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return fmt.Errorf("no marble was activated with UUID %s", marbleUUID)
+	case http.StatusUnauthorized:
+		return fmt.Errorf("unable to authorize user: %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	default:
+		return fmt.Errorf("error connecting to server: %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+}
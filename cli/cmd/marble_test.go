@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/edgelesssys/marblerun/coordinator/core"
+	"github.com/edgelesssys/marblerun/coordinator/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarbleList(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	activatedAt := time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)
+	leases := []core.MarbleLease{
+		{MarbleType: "backend", UUID: "1234", ActivatedAt: activatedAt},
+		{MarbleType: "frontend", UUID: "5678", ActivatedAt: activatedAt, Revoked: true},
+	}
+	s, host, cert := newTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("/marbles", r.RequestURI)
+		assert.Equal(http.MethodGet, r.Method)
+		serverResp := server.GeneralResponse{
+			Status: "success",
+			Data:   struct{ Marbles []core.MarbleLease }{leases},
+		}
+		assert.NoError(json.NewEncoder(w).Encode(serverResp))
+	}))
+	defer s.Close()
+
+	var out bytes.Buffer
+	require.NoError(cliMarbleList(&out, host, tls.Certificate{}, []*pem.Block{cert}))
+	assert.Equal("1234 backend 2021-05-01T12:00:00Z active\n5678 frontend 2021-05-01T12:00:00Z revoked\n", out.String())
+
+	// unauthorized
+	s.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	assert.Error(cliMarbleList(&out, host, tls.Certificate{}, []*pem.Block{cert}))
+}
+
+func TestMarbleRevoke(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	s, host, cert := newTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("/marbles/revoke", r.URL.Path)
+		assert.Equal(http.MethodPost, r.Method)
+		if r.URL.Query().Get("uuid") != "1234" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		assert.NoError(json.NewEncoder(w).Encode(server.GeneralResponse{Status: "success"}))
+	}))
+	defer s.Close()
+
+	require.NoError(cliMarbleRevoke("1234", host, tls.Certificate{}, []*pem.Block{cert}))
+
+	err := cliMarbleRevoke("5678", host, tls.Certificate{}, []*pem.Block{cert})
+	require.Error(err)
+	assert.Contains(err.Error(), "5678")
+}
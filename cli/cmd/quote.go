@@ -0,0 +1,19 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func newQuoteCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "quote",
+		Short: "Inspects SGX quotes",
+		Long: `
+Inspects SGX quotes, e.g., to find out why the attestation of a marble failed.`,
+		Example: "quote describe quote.bin",
+	}
+
+	cmd.AddCommand(newQuoteDescribe())
+
+	return cmd
+}
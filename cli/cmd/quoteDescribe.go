@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/edgelesssys/marblerun/coordinator/quote"
+	"github.com/spf13/cobra"
+)
+
+func newQuoteDescribe() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "describe <quote file>",
+		Short: "Prints the fields of an SGX quote",
+		Long: `
+Prints the fields of an SGX quote, e.g., its measurements, report data, and TCB.
+The quote may be binary, or encoded in hex or base64, and may be prefixed with an OpenEnclave report header.
+The quote is not verified, so the printed fields must not be trusted.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rawQuote, err := ioutil.ReadFile(args[0])
+			if err != nil {
+				return err
+			}
+			return cliQuoteDescribe(os.Stdout, rawQuote)
+		},
+		SilenceUsage: true,
+	}
+
+	return cmd
+}
+
+// cliQuoteDescribe parses a quote and prints its fields
+func cliQuoteDescribe(out io.Writer, rawQuote []byte) error {
+	info, err := quote.DescribeQuote(decodeQuote(rawQuote))
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Version:            %d\n", info.Version)
+	fmt.Fprintf(out, "AttestationKeyType: %d\n", info.AttestationKeyType)
+	fmt.Fprintf(out, "UniqueID:           %s\n", info.UniqueID)
+	fmt.Fprintf(out, "SignerID:           %s\n", info.SignerID)
+	fmt.Fprintf(out, "ProductID:          %d\n", info.ProductID)
+	fmt.Fprintf(out, "SecurityVersion:    %d\n", info.SecurityVersion)
+	fmt.Fprintf(out, "Debug:              %t\n", info.Debug)
+	fmt.Fprintf(out, "Attributes:         %s\n", info.Attributes)
+	fmt.Fprintf(out, "MiscSelect:         %d\n", info.MiscSelect)
+	fmt.Fprintf(out, "ReportData:         %s\n", info.ReportData)
+	fmt.Fprintf(out, "CPUSVN:             %s\n", info.CPUSVN)
+	fmt.Fprintf(out, "QESVN:              %d\n", info.QESVN)
+	fmt.Fprintf(out, "PCESVN:             %d\n", info.PCESVN)
+	fmt.Fprintf(out, "QEVendorID:         %s\n", info.QEVendorID)
+	fmt.Fprintf(out, "SignatureSize:      %d\n", info.SignatureSize)
+	return nil
+}
+
+// decodeQuote decodes a hex or base64 encoded quote, binary quotes are returned unchanged
+func decodeQuote(rawQuote []byte) []byte {
+	encoded := strings.Join(strings.Fields(string(rawQuote)), "")
+	if decoded, err := hex.DecodeString(encoded); err == nil {
+		return decoded
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(encoded); err == nil {
+		return decoded
+	}
+	return rawQuote
+}
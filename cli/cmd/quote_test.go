@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCliQuoteDescribe(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	rawHex, err := ioutil.ReadFile("../../coordinator/quote/testdata/quote.hex")
+	require.NoError(err)
+	quote, err := hex.DecodeString(strings.Join(strings.Fields(string(rawHex)), ""))
+	require.NoError(err)
+
+	for _, rawQuote := range [][]byte{rawHex, []byte(base64.StdEncoding.EncodeToString(quote)), quote} {
+		var out bytes.Buffer
+		require.NoError(cliQuoteDescribe(&out, rawQuote))
+		assert.Contains(out.String(), "UniqueID:           4a3ddd19d5e3e8b63b5de0fe8b4aa1ce9f0a79e0b6f1e6d3a7e1b7a3e4c1f2d5\n")
+		assert.Contains(out.String(), "SignerID:           43361affedeb75affee9baec7e054a5e14883213e5a121b67d74a0e12e9d2b7a\n")
+		assert.Contains(out.String(), "SecurityVersion:    2\n")
+		assert.Contains(out.String(), "Debug:              true\n")
+	}
+
+	var out bytes.Buffer
+	assert.Error(cliQuoteDescribe(&out, []byte("not a quote")))
+}
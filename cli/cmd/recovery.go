@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func newRecoveryCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "recovery",
+		Short: "Backs up and restores the recovery key of the Marblerun coordinator",
+		Long: `
+Backs up and restores the recovery key of the Marblerun coordinator.
+Used to either export the recovery key encrypted with a public key of your choice,
+or import a previously exported recovery key to recover a sealed coordinator`,
+		Example: "recovery export public_key.pem example.com:4433 --cert=admin.crt --key=admin.key [--era-config=config.json] [--insecure]",
+	}
+
+	cmd.PersistentFlags().StringVar(&eraConfig, "era-config", "", "Path to remote attestation config file in json format, if none provided the newest configuration will be loaded from github")
+	cmd.PersistentFlags().BoolVarP(&insecureEra, "insecure", "i", false, "Set to skip quote verification, needed when running in simulation mode")
+	cmd.AddCommand(newRecoveryExport())
+	cmd.AddCommand(newRecoveryImport())
+
+	return cmd
+}
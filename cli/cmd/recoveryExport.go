@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/spf13/cobra"
+	"github.com/tidwall/gjson"
+)
+
+func newRecoveryExport() *cobra.Command {
+	var clientAdminCert string
+	var clientAdminKey string
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "export <public_key.pem> <IP:PORT>",
+		Short: "Exports the recovery key of the Marblerun coordinator",
+		Long: `
+Exports the recovery key of the Marblerun coordinator.
+The key is encrypted with the supplied RSA public key before it leaves the coordinator.
+An admin certificate specified in the manifest is needed to export the recovery key.
+`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			publicKeyFile := args[0]
+			hostName := args[1]
+
+			caCert, err := verifyCoordinator(hostName, eraConfig, insecureEra)
+			if err != nil {
+				return err
+			}
+
+			// Load client certificate and key
+			clCert, err := tls.LoadX509KeyPair(clientAdminCert, clientAdminKey)
+			if err != nil {
+				return err
+			}
+
+			publicKey, err := ioutil.ReadFile(publicKeyFile)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println("Successfully verified coordinator, now exporting recovery key")
+
+			encryptedRecoveryKey, err := cliRecoveryExport(publicKey, hostName, clCert, caCert)
+			if err != nil {
+				return err
+			}
+			if err := ioutil.WriteFile(output, encryptedRecoveryKey, 0600); err != nil {
+				return err
+			}
+
+			fmt.Printf("Encrypted recovery key written to %s\n", output)
+			return nil
+		},
+		SilenceUsage: true,
+	}
+
+	cmd.Flags().StringVarP(&clientAdminCert, "cert", "c", "", "PEM encoded admin certificate file (required)")
+	cmd.MarkFlagRequired("cert")
+	cmd.Flags().StringVarP(&clientAdminKey, "key", "k", "", "PEM encoded admin key file (required)")
+	cmd.MarkFlagRequired("key")
+	cmd.Flags().StringVarP(&output, "output", "o", "recovery_key.enc", "File to save the encrypted recovery key to")
+
+	return cmd
+}
+
+// cliRecoveryExport retrieves the recovery key encrypted with publicKey from the coordinators rest api
+func cliRecoveryExport(publicKey []byte, host string, clCert tls.Certificate, caCert []*pem.Block) ([]byte, error) {
+	client, err := authenticatedRestClient(caCert, clCert)
+	if err != nil {
+		return nil, err
+	}
+
+	url := url.URL{Scheme: "https", Host: host, Path: "recovery"}
+	resp, err := client.Post(url.String(), "application/x-pem-file", bytes.NewReader(publicKey))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		respBody, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		encryptedRecoveryKey := gjson.GetBytes(respBody, "data.EncryptedRecoveryKey")
+		if !encryptedRecoveryKey.Exists() {
+			return nil, fmt.Errorf("received invalid response from coordinator")
+		}
+		return []byte(encryptedRecoveryKey.String()), nil
+	case http.StatusBadRequest:
+		return nil, fmt.Errorf("unable to export recovery key: %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	case http.StatusUnauthorized:
+		return nil, fmt.Errorf("unable to authorize user: %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	default:
+		return nil, fmt.Errorf("error connecting to server: %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+}
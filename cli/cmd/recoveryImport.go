@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/edgelesssys/marblerun/util"
+	"github.com/spf13/cobra"
+)
+
+func newRecoveryImport() *cobra.Command {
+	var privateKeyFile string
+
+	cmd := &cobra.Command{
+		Use:   "import <recovery_key.enc> <IP:PORT>",
+		Short: "Imports an exported recovery key to recover the Marblerun coordinator",
+		Long: `
+Imports a recovery key previously exported with "marblerun recovery export".
+The key is decrypted locally with the RSA private key matching the public key used for the export,
+and uploaded to recover the Marblerun coordinator from a sealed state.
+`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			keyFile := args[0]
+			hostName := args[1]
+
+			cert, err := verifyCoordinator(hostName, eraConfig, insecureEra)
+			if err != nil {
+				return err
+			}
+
+			encryptedRecoveryKey, err := ioutil.ReadFile(keyFile)
+			if err != nil {
+				return err
+			}
+			privateKey, err := ioutil.ReadFile(privateKeyFile)
+			if err != nil {
+				return err
+			}
+
+			recoveryKey, err := decryptRecoveryKey(encryptedRecoveryKey, privateKey)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println("Successfully verified coordinator, now uploading key")
+
+			return cliRecover(hostName, recoveryKey, cert)
+		},
+		SilenceUsage: true,
+	}
+
+	cmd.Flags().StringVarP(&privateKeyFile, "private-key", "p", "", "PEM encoded RSA private key matching the public key used for the export (required)")
+	cmd.MarkFlagRequired("private-key")
+
+	return cmd
+}
+
+// decryptRecoveryKey decrypts a base64 encoded recovery key exported by the coordinator
+func decryptRecoveryKey(encryptedRecoveryKey []byte, rawPrivateKey []byte) ([]byte, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encryptedRecoveryKey)))
+	if err != nil {
+		return nil, fmt.Errorf("recovery key is not base64 encoded: %v", err)
+	}
+
+	block, _ := pem.Decode(rawPrivateKey)
+	if block == nil {
+		return nil, errors.New("invalid private key")
+	}
+
+	var privateKey *rsa.PrivateKey
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		if privateKey, ok = key.(*rsa.PrivateKey); !ok {
+			return nil, errors.New("unsupported type of private key")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported private key type: %s", block.Type)
+	}
+
+	return util.DecryptOAEP(privateKey, ciphertext)
+}
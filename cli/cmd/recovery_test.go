@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/edgelesssys/marblerun/coordinator/recovery"
+	"github.com/edgelesssys/marblerun/coordinator/server"
+	"github.com/edgelesssys/marblerun/test"
+	"github.com/edgelesssys/marblerun/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCliRecoveryExport(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	recoveryKey := []byte("0123456789abcdef")
+
+	s, host, cert := newTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("/recovery", r.RequestURI)
+		assert.Equal(http.MethodPost, r.Method)
+
+		publicKey, err := ioutil.ReadAll(r.Body)
+		assert.NoError(err)
+		if string(publicKey) == "unauthorized" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		// emulate the coordinator encrypting the recovery key with the supplied public key
+		rsaPublicKey, err := recovery.ParseRSAPublicKeyFromPEM(string(publicKey))
+		assert.NoError(err)
+		encryptedRecoveryKey, err := util.EncryptOAEP(rsaPublicKey, recoveryKey)
+		assert.NoError(err)
+
+		serverResp := server.GeneralResponse{
+			Status: "success",
+			Data: struct {
+				EncryptedRecoveryKey []byte
+			}{encryptedRecoveryKey},
+		}
+		assert.NoError(json.NewEncoder(w).Encode(serverResp))
+	}))
+	defer s.Close()
+
+	exported, err := cliRecoveryExport(test.RecoveryPublicKey, host, tls.Certificate{}, []*pem.Block{cert})
+	require.NoError(err)
+
+	// the exported payload must not contain the recovery key in plaintext
+	ciphertext, err := base64.StdEncoding.DecodeString(string(exported))
+	require.NoError(err)
+	assert.NotContains(string(ciphertext), string(recoveryKey))
+
+	decrypted, err := util.DecryptOAEP(test.RecoveryPrivateKey, ciphertext)
+	require.NoError(err)
+	assert.Equal(recoveryKey, decrypted)
+
+	_, err = cliRecoveryExport([]byte("unauthorized"), host, tls.Certificate{}, []*pem.Block{cert})
+	assert.Error(err)
+}
+
+func TestCliRecoveryImport(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	recoveryKey := []byte("0123456789abcdef")
+	encryptedRecoveryKey, err := util.EncryptOAEP(&test.RecoveryPrivateKey.PublicKey, recoveryKey)
+	require.NoError(err)
+	exported := []byte(base64.StdEncoding.EncodeToString(encryptedRecoveryKey) + "\n")
+	privateKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(test.RecoveryPrivateKey)})
+
+	decrypted, err := decryptRecoveryKey(exported, privateKey)
+	require.NoError(err)
+	assert.Equal(recoveryKey, decrypted)
+
+	pkcs8Key, err := x509.MarshalPKCS8PrivateKey(test.RecoveryPrivateKey)
+	require.NoError(err)
+	decrypted, err = decryptRecoveryKey(exported, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8Key}))
+	require.NoError(err)
+	assert.Equal(recoveryKey, decrypted)
+
+	_, err = decryptRecoveryKey([]byte("not base64"), privateKey)
+	assert.Error(err)
+	_, err = decryptRecoveryKey(exported, []byte("invalid"))
+	assert.Error(err)
+
+	// the decrypted key is uploaded to the coordinator
+	s, host, cert := newTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("/recover", r.RequestURI)
+		assert.Equal(http.MethodPost, r.Method)
+
+		reqData, err := ioutil.ReadAll(r.Body)
+		assert.NoError(err)
+		assert.Equal(recoveryKey, reqData)
+
+		serverResp := server.GeneralResponse{
+			Status: "success",
+			Data: struct {
+				StatusMessage string
+			}{"Recovery successful."},
+		}
+		assert.NoError(json.NewEncoder(w).Encode(serverResp))
+	}))
+	defer s.Close()
+
+	require.NoError(cliRecover(host, decrypted, []*pem.Block{cert}))
+}
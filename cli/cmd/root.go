@@ -27,12 +27,19 @@ func init() {
 	rootCmd.AddCommand(newCertificateCmd())
 	rootCmd.AddCommand(newCheckCmd())
 	rootCmd.AddCommand(newCompletionCmd())
+	rootCmd.AddCommand(newCoordinatorCmd())
 	rootCmd.AddCommand(newGraphenePrepareCmd())
+	rootCmd.AddCommand(newInjectorCmd())
 	rootCmd.AddCommand(newInstallCmd())
+	rootCmd.AddCommand(newMaintenanceCmd())
 	rootCmd.AddCommand(newManifestCmd())
+	rootCmd.AddCommand(newMarbleCmd())
 	rootCmd.AddCommand(newNamespaceCmd())
 	rootCmd.AddCommand(newPrecheckCmd())
+	rootCmd.AddCommand(newQuoteCmd())
 	rootCmd.AddCommand(newRecoverCmd())
+	rootCmd.AddCommand(newRecoveryCmd())
+	rootCmd.AddCommand(newSecretCmd())
 	rootCmd.AddCommand(newSGXSDKPackageInfoCmd())
 	rootCmd.AddCommand(newStatusCmd())
 	rootCmd.AddCommand(newUninstallCmd())
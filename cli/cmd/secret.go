@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func newSecretCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "secret",
+		Short: "Lists the secrets declared in the manifest of the Marblerun coordinator",
+		Long: `
+Lists the secrets declared in the manifest of the Marblerun coordinator.
+Only the names, types, and generation metadata of the secrets are shown, never their values.`,
+		Example: "secret list example.com:4433 --cert=admin.crt --key=admin.key [--era-config=config.json] [--insecure]",
+	}
+
+	cmd.PersistentFlags().StringVar(&eraConfig, "era-config", "", "Path to remote attestation config file in json format, if none provided the newest configuration will be loaded from github")
+	cmd.PersistentFlags().BoolVarP(&insecureEra, "insecure", "i", false, "Set to skip quote verification, needed when running in simulation mode")
+	cmd.AddCommand(newSecretList())
+
+	return cmd
+}
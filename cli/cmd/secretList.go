@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/edgelesssys/marblerun/coordinator/core"
+	"github.com/spf13/cobra"
+	"github.com/tidwall/gjson"
+)
+
+func newSecretList() *cobra.Command {
+	var clientAdminCert string
+	var clientAdminKey string
+
+	cmd := &cobra.Command{
+		Use:   "list <IP:PORT>",
+		Short: "Lists the secrets declared in the manifest",
+		Long: `
+Lists the secrets declared in the manifest as a table.
+Shared secrets are generated once by the coordinator, the others are generated for each marble on its activation.
+An admin certificate specified in the manifest is needed to list the secrets.
+`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			hostName := args[0]
+
+			caCert, err := verifyCoordinator(hostName, eraConfig, insecureEra)
+			if err != nil {
+				return err
+			}
+
+			// Load client certificate and key
+			clCert, err := tls.LoadX509KeyPair(clientAdminCert, clientAdminKey)
+			if err != nil {
+				return err
+			}
+
+			return cliSecretList(os.Stdout, hostName, clCert, caCert)
+		},
+		SilenceUsage: true,
+	}
+
+	cmd.Flags().StringVarP(&clientAdminCert, "cert", "c", "", "PEM encoded admin certificate file (required)")
+	cmd.MarkFlagRequired("cert")
+	cmd.Flags().StringVarP(&clientAdminKey, "key", "k", "", "PEM encoded admin key file (required)")
+	cmd.MarkFlagRequired("key")
+
+	return cmd
+}
+
+// cliSecretList gets the secrets declared in the manifest from the coordinators rest api and prints them as table to out
+func cliSecretList(out io.Writer, host string, clCert tls.Certificate, caCert []*pem.Block) error {
+	client, err := authenticatedRestClient(caCert, clCert)
+	if err != nil {
+		return err
+	}
+
+	url := url.URL{Scheme: "https", Host: host, Path: "secrets"}
+	resp, err := client.Get(url.String())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		respBody, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		var response struct {
+			Secrets []core.SecretInfo
+		}
+		if err := json.Unmarshal([]byte(gjson.GetBytes(respBody, "data").Raw), &response); err != nil {
+			return err
+		}
+		if len(response.Secrets) == 0 {
+			fmt.Fprintln(out, "No secrets are declared in the manifest")
+			return nil
+		}
+
+		table := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(table, "NAME\tTYPE\tSIZE\tGENERATED\tVERSION\tVALIDITY")
+		for _, secret := range response.Secrets {
+			size, generated, version := "-", "per marble", "-"
+			if secret.Size > 0 {
+				size = strconv.FormatUint(uint64(secret.Size), 10)
+			}
+			if secret.Shared {
+				generated = "shared"
+				version = strconv.FormatUint(secret.Version, 10)
+			}
+			fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\n", secret.Name, secret.Type, size, generated, version, secretValidity(secret))
+		}
+		return table.Flush()
+	case http.StatusUnauthorized:
+		return fmt.Errorf("unable to authorize user: %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	default:
+		return fmt.Errorf("error connecting to server: %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+}
+
+// secretValidity returns the validity of a certificate secret in readable form
+func secretValidity(secret core.SecretInfo) string {
+	switch {
+	case !secret.Certificate:
+		return "-"
+	case secret.NotAfter != nil:
+		return "until " + secret.NotAfter.Format(time.RFC3339)
+	case secret.ValidFor > 0:
+		return fmt.Sprintf("%d days", secret.ValidFor)
+	default:
+		return "365 days"
+	}
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/edgelesssys/marblerun/coordinator/core"
+	"github.com/edgelesssys/marblerun/coordinator/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretList(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	notAfter := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	secrets := []core.SecretInfo{
+		{Name: "cert_private", Type: "cert-rsa", Size: 2048, Certificate: true, ValidFor: 7},
+		{Name: "cert_shared", Type: "cert-ed25519", Shared: true, Version: 1, Certificate: true, NotAfter: &notAfter},
+		{Name: "key_shared", Type: "symmetric-key", Size: 128, Shared: true, Version: 2},
+	}
+	s, host, cert := newTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("/secrets", r.RequestURI)
+		assert.Equal(http.MethodGet, r.Method)
+		serverResp := server.GeneralResponse{
+			Status: "success",
+			Data:   struct{ Secrets []core.SecretInfo }{secrets},
+		}
+		assert.NoError(json.NewEncoder(w).Encode(serverResp))
+	}))
+	defer s.Close()
+
+	var out bytes.Buffer
+	require.NoError(cliSecretList(&out, host, tls.Certificate{}, []*pem.Block{cert}))
+	assert.Equal(""+
+		"NAME          TYPE           SIZE  GENERATED   VERSION  VALIDITY\n"+
+		"cert_private  cert-rsa       2048  per marble  -        7 days\n"+
+		"cert_shared   cert-ed25519   -     shared      1        until 2022-05-01T12:00:00Z\n"+
+		"key_shared    symmetric-key  128   shared      2        -\n", out.String())
+
+	// unauthorized
+	s.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	assert.Error(cliSecretList(&out, host, tls.Certificate{}, []*pem.Block{cert}))
+}
@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"net/http"
 
+	"github.com/edgelesssys/marblerun/coordinator/core"
 	"github.com/spf13/cobra"
 	"github.com/tidwall/gjson"
 )
@@ -30,8 +31,9 @@ The Coordinator will be in one of these 4 states:
 `
 
 type statusResponse struct {
-	StatusCode    int    `json:"StatusCode"`
-	StatusMessage string `json:"StatusMessage"`
+	StatusCode    int        `json:"StatusCode"`
+	StatusMessage string     `json:"StatusMessage"`
+	State         core.Phase `json:"State"`
 }
 
 func newStatusCmd() *cobra.Command {
@@ -64,10 +66,21 @@ func cliStatus(host string, cert []*pem.Block) error {
 		return err
 	}
 
-	resp, err := client.Get("https://" + host + "/status")
+	statusResp, err := getStatus(client, host)
 	if err != nil {
 		return err
 	}
+	fmt.Printf("%d: %s\n", statusResp.StatusCode, statusResp.StatusMessage)
+
+	return nil
+}
+
+// getStatus requests the current status of the coordinator using client
+func getStatus(client *http.Client, host string) (statusResponse, error) {
+	resp, err := client.Get("https://" + host + "/status")
+	if err != nil {
+		return statusResponse{}, err
+	}
 
 	defer resp.Body.Close()
 
@@ -75,17 +88,15 @@ func cliStatus(host string, cert []*pem.Block) error {
 	case http.StatusOK:
 		respBody, err := ioutil.ReadAll(resp.Body)
 		if err != nil {
-			return err
+			return statusResponse{}, err
 		}
 		jsonResponse := gjson.GetBytes(respBody, "data")
 		var statusResp statusResponse
 		if err := json.Unmarshal([]byte(jsonResponse.String()), &statusResp); err != nil {
-			return err
+			return statusResponse{}, err
 		}
-		fmt.Printf("%d: %s\n", statusResp.StatusCode, statusResp.StatusMessage)
+		return statusResp, nil
 	default:
-		return fmt.Errorf("error connecting to server: %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+		return statusResponse{}, fmt.Errorf("error connecting to server: %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
 	}
-
-	return nil
 }
@@ -100,6 +100,16 @@ func restClient(cert []*pem.Block) (*http.Client, error) {
 	return client, nil
 }
 
+// authenticatedRestClient creates a http client like restClient, which additionally authenticates to the Coordinator using an admin client certificate
+func authenticatedRestClient(cert []*pem.Block, clCert tls.Certificate) (*http.Client, error) {
+	client, err := restClient(cert)
+	if err != nil {
+		return nil, err
+	}
+	client.Transport.(*http.Transport).TLSClientConfig.Certificates = []tls.Certificate{clCert}
+	return client, nil
+}
+
 // getKubernetesInterface returns the kubernetes Clientset to interact with the k8s API
 func getKubernetesInterface() (*kubernetes.Clientset, error) {
 	path := os.Getenv(clientcmd.RecommendedConfigPathEnvVar)
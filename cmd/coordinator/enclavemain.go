@@ -9,22 +9,42 @@
 package main
 
 import (
+	"log"
 	"path/filepath"
+	"time"
 
 	"github.com/edgelesssys/marblerun/coordinator/config"
 	"github.com/edgelesssys/marblerun/coordinator/core"
+	"github.com/edgelesssys/marblerun/coordinator/quote"
 	"github.com/edgelesssys/marblerun/coordinator/quote/ertvalidator"
-	"github.com/edgelesssys/marblerun/coordinator/recovery"
 	"github.com/edgelesssys/marblerun/util"
 )
 
 func main() {
-	validator := ertvalidator.NewERTValidator()
+	var validator quote.Validator = ertvalidator.NewERTValidator()
+	retryBackoff, err := quote.LoadRetryBackoff()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if retryBackoff.Retries > 0 {
+		validator = quote.NewRetryingValidator(validator, retryBackoff)
+	}
+	quoteCacheTTL, err := time.ParseDuration(util.Getenv(config.QuoteCacheTTL, config.QuoteCacheTTLDefault))
+	if err != nil || quoteCacheTTL < 0 {
+		log.Fatalf("invalid value for %s: %s", config.QuoteCacheTTL, util.Getenv(config.QuoteCacheTTL, config.QuoteCacheTTLDefault))
+	}
+	if quoteCacheTTL > 0 {
+		validator = quote.NewCachingValidator(validator, quoteCacheTTL)
+	}
 	issuer := ertvalidator.NewERTIssuer()
 	sealDirPrefix := filepath.Join(filepath.FromSlash("/edg"), "hostfs")
 	sealDir := util.Getenv(config.SealDir, config.SealDirDefault())
 	sealDir = filepath.Join(sealDirPrefix, sealDir)
-	sealer := core.NewAESGCMSealer(sealDir)
-	recovery := recovery.NewSinglePartyRecovery()
+	sealMode, err := core.ParseSealMode(util.Getenv(config.SealMode, config.SealModeDefault))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	sealer := getSealer(sealDir, core.NewAESGCMSealer(sealDir, sealMode))
+	recovery := getRecovery()
 	run(validator, issuer, sealDir, sealer, recovery)
 }
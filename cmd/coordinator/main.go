@@ -12,7 +12,6 @@ import (
 	"github.com/edgelesssys/marblerun/coordinator/config"
 	"github.com/edgelesssys/marblerun/coordinator/core"
 	"github.com/edgelesssys/marblerun/coordinator/quote"
-	"github.com/edgelesssys/marblerun/coordinator/recovery"
 	"github.com/edgelesssys/marblerun/util"
 )
 
@@ -20,7 +19,7 @@ func main() {
 	validator := quote.NewFailValidator()
 	issuer := quote.NewFailIssuer()
 	sealDir := util.Getenv(config.SealDir, config.SealDirDefault())
-	sealer := core.NewNoEnclaveSealer(sealDir)
-	recovery := recovery.NewSinglePartyRecovery()
+	sealer := getSealer(sealDir, core.NewNoEnclaveSealer(sealDir))
+	recovery := getRecovery()
 	run(validator, issuer, sealDir, sealer, recovery)
 }
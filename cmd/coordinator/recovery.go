@@ -0,0 +1,29 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/edgelesssys/marblerun/coordinator/config"
+	"github.com/edgelesssys/marblerun/coordinator/recovery"
+	"github.com/edgelesssys/marblerun/util"
+)
+
+// getRecovery returns a multi-party recoverer if a recovery threshold was set, or a single-party recoverer otherwise
+func getRecovery() recovery.Recovery {
+	thresholdStr := util.Getenv(config.RecoveryThreshold, "")
+	if thresholdStr == "" {
+		return recovery.NewSinglePartyRecovery()
+	}
+	threshold, err := strconv.Atoi(thresholdStr)
+	if err != nil || threshold < 1 {
+		log.Fatalln("invalid recovery threshold:", thresholdStr)
+	}
+	return recovery.NewMultiPartyRecovery(threshold)
+}
@@ -7,9 +7,15 @@
 package main
 
 import (
+	"context"
+	"crypto"
+	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/edgelesssys/marblerun/coordinator/config"
 	"github.com/edgelesssys/marblerun/coordinator/core"
@@ -26,6 +32,11 @@ var Version = "0.0.0" // Don't touch! Automatically injected at build-time.
 // GitCommit is the git commit hash
 var GitCommit string // Don't touch! Automatically injected at build-time.
 
+// ManifestSigningKey is a base64 encoded public key in PKIX, ASN.1 DER form, which must have signed any manifest set on the Coordinator.
+// It is set at build-time, so it is part of the enclave's measurement and can't be removed without changing the Coordinator's identity.
+// Any manifest is accepted if empty.
+var ManifestSigningKey string
+
 func run(validator quote.Validator, issuer quote.Issuer, sealDir string, sealer core.Sealer, recovery recovery.Recovery) {
 	// Setup logging with Zap Logger
 	var zapLogger *zap.Logger
@@ -51,20 +62,68 @@ func run(validator quote.Validator, issuer quote.Issuer, sealDir string, sealer
 	clientServerAddr := util.Getenv(config.ClientAddr, config.ClientAddrDefault)
 	meshServerAddr := util.Getenv(config.MeshAddr, config.MeshAddrDefault)
 	promServerAddr := os.Getenv(config.PromAddr)
+	activationRateLimit, err := strconv.ParseFloat(util.Getenv(config.ActivationRateLimit, config.ActivationRateLimitDefault), 64)
+	if err != nil || activationRateLimit < 0 {
+		zapLogger.Fatal("Invalid activation rate limit.", zap.String("value", os.Getenv(config.ActivationRateLimit)))
+	}
+	activationTimeout, err := time.ParseDuration(util.Getenv(config.ActivationTimeout, config.ActivationTimeoutDefault))
+	if err != nil || activationTimeout < 0 {
+		zapLogger.Fatal("Invalid activation timeout.", zap.String("value", os.Getenv(config.ActivationTimeout)))
+	}
+	keepaliveConfig, err := server.LoadKeepaliveConfig()
+	if err != nil {
+		zapLogger.Fatal("Invalid keepalive configuration.", zap.Error(err))
+	}
+	concurrencyConfig, err := server.LoadConcurrencyConfig()
+	if err != nil {
+		zapLogger.Fatal("Invalid activation concurrency configuration.", zap.Error(err))
+	}
 
-	// creating core
-	zapLogger.Info("creating the Core object")
-	if err := os.MkdirAll(sealDir, 0700); err != nil {
-		zapLogger.Fatal("Cannot create or access sealdir. Please check the permissions for the specified path.", zap.Error(err))
+	maxMsgSize, err := server.LoadMaxMsgSize()
+	if err != nil {
+		zapLogger.Fatal("Invalid maximum message size.", zap.Error(err))
 	}
-	core, err := core.NewCore(dnsNames, validator, issuer, sealer, recovery, zapLogger)
+
+	tlsOptions, err := server.LoadTLSOptions()
 	if err != nil {
-		panic(err)
+		zapLogger.Fatal("Invalid TLS configuration.", zap.Error(err))
+	}
+
+	bindBackoff, err := server.LoadBindBackoff()
+	if err != nil {
+		zapLogger.Fatal("Invalid marble server bind backoff.", zap.Error(err))
+	}
+
+	meshALPN, err := server.LoadMeshALPN()
+	if err != nil {
+		zapLogger.Fatal("Invalid ALPN configuration.", zap.Error(err))
+	}
+
+	var manifestSigningKey crypto.PublicKey
+	if ManifestSigningKey != "" {
+		if manifestSigningKey, err = core.ParseManifestSigningKey(ManifestSigningKey); err != nil {
+			zapLogger.Fatal("Invalid manifest signing key.", zap.Error(err))
+		}
+	}
+
+	shutdownTracing, err := server.InitTracing(context.Background(), os.Getenv(config.OTLPEndpoint))
+	if err != nil {
+		zapLogger.Fatal("Cannot set up tracing.", zap.Error(err))
+	}
+	defer shutdownTracing(context.Background())
+
+	core, err := setupCore(dnsNames, validator, issuer, sealDir, sealer, recovery, zapLogger)
+	if err != nil {
+		zapLogger.Fatal("Cannot set up the Coordinator.", zap.Error(err))
+	}
+	if manifestSigningKey != nil {
+		core.SetManifestSigningKey(manifestSigningKey)
+		zapLogger.Info("only accepting manifests signed by the manifest signing key")
 	}
 
 	// start the prometheus server
 	if promServerAddr != "" {
-		go server.RunPrometheusServer(promServerAddr, zapLogger)
+		go server.RunPrometheusServer(promServerAddr, zapLogger, core.Metrics()...)
 	}
 
 	// start client server
@@ -74,13 +133,31 @@ func run(validator quote.Validator, issuer quote.Issuer, sealDir string, sealer
 	if err != nil {
 		panic(err)
 	}
-	go server.RunClientServer(mux, clientServerAddr, clientServerTLSConfig, zapLogger)
+	clientServerTLSConfig = tlsOptions.Apply(clientServerTLSConfig)
+	if sctFile := os.Getenv(config.SCTFile); sctFile != "" {
+		scts, err := server.LoadSCTFile(sctFile)
+		if err != nil {
+			zapLogger.Fatal("Cannot load signed certificate timestamps.", zap.Error(err))
+		}
+		clientServerTLSConfig = server.StapleSCTs(clientServerTLSConfig, scts)
+	}
+	marbleServer := server.NewMarbleServer(core, zapLogger, activationRateLimit, activationTimeout, keepaliveConfig, concurrencyConfig, maxMsgSize, tlsOptions, meshALPN)
+	var clientHandler http.Handler = mux
+	if util.Getenv(config.GRPCWeb, config.GRPCWebDefault) == "1" {
+		var allowedOrigins []string
+		if origins := os.Getenv(config.GRPCWebAllowedOrigins); origins != "" {
+			allowedOrigins = strings.Split(origins, ",")
+		}
+		clientHandler = server.WrapGRPCWeb(marbleServer, mux, allowedOrigins)
+		zapLogger.Info("serving gRPC-web on the client server", zap.Strings("allowedOrigins", allowedOrigins))
+	}
+	go server.RunClientServer(clientHandler, clientServerAddr, clientServerTLSConfig, zapLogger)
 
 	// run marble server
 	zapLogger.Info("starting the marble server")
 	addrChan := make(chan string)
 	errChan := make(chan error)
-	go server.RunMarbleServer(core, meshServerAddr, addrChan, errChan, zapLogger)
+	go server.RunMarbleServer(marbleServer, meshServerAddr, bindBackoff, zapLogger, addrChan, errChan)
 	for {
 		select {
 		case err := <-errChan:
@@ -93,3 +170,24 @@ func run(validator quote.Validator, issuer quote.Issuer, sealDir string, sealer
 		}
 	}
 }
+
+// setupCore prepares the seal directory, connects to the sealer's backend and creates the Core.
+// Each phase is logged, and a failing phase is named in the returned error.
+func setupCore(dnsNames []string, validator quote.Validator, issuer quote.Issuer, sealDir string, sealer core.Sealer, recovery recovery.Recovery, zapLogger *zap.Logger) (*core.Core, error) {
+	zapLogger.Info("preparing the seal directory", zap.String("sealDir", sealDir))
+	if err := os.MkdirAll(sealDir, 0700); err != nil {
+		return nil, fmt.Errorf("preparing the seal directory: cannot create or access it, please check the permissions for the specified path: %w", err)
+	}
+
+	zapLogger.Info("connecting to the sealer backend")
+	if err := core.ConnectSealer(sealer); err != nil {
+		return nil, fmt.Errorf("connecting to the sealer backend: %w", err)
+	}
+
+	zapLogger.Info("creating the Core object")
+	c, err := core.NewCore(dnsNames, validator, issuer, sealer, recovery, zapLogger)
+	if err != nil {
+		return nil, fmt.Errorf("creating the Core object: %w", err)
+	}
+	return c, nil
+}
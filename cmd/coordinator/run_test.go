@@ -0,0 +1,61 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/edgelesssys/marblerun/coordinator/core"
+	"github.com/edgelesssys/marblerun/coordinator/quote"
+	"github.com/edgelesssys/marblerun/coordinator/recovery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSetupCore(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	sealDir, err := ioutil.TempDir("", "")
+	require.NoError(err)
+	defer os.RemoveAll(sealDir)
+
+	c, err := setupCore([]string{"localhost"}, quote.NewMockValidator(), quote.NewMockIssuer(), filepath.Join(sealDir, "seal"), &core.MockSealer{}, recovery.NewSinglePartyRecovery(), zap.NewNop())
+	require.NoError(err)
+	assert.NotNil(c)
+	assert.DirExists(filepath.Join(sealDir, "seal"))
+}
+
+func TestSetupCoreUnreachableSealerBackend(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	sealDir, err := ioutil.TempDir("", "")
+	require.NoError(err)
+	defer os.RemoveAll(sealDir)
+	require.NoError(ioutil.WriteFile(filepath.Join(sealDir, core.SealedKeyFname), []byte("wrapped key"), 0600))
+
+	vault := httptest.NewServer(http.NotFoundHandler())
+	vault.Close()
+	sealer := core.NewVaultSealer(sealDir, core.NewVaultTransitClient(vault.URL, "token", "key"))
+
+	c, err := setupCore([]string{"localhost"}, quote.NewMockValidator(), quote.NewMockIssuer(), sealDir, sealer, recovery.NewSinglePartyRecovery(), zap.NewNop())
+	assert.Nil(c)
+	require.Error(err)
+	assert.Contains(err.Error(), "connecting to the sealer backend")
+	assert.Contains(err.Error(), "vault")
+
+	// without a stored key, the backend isn't needed before the first seal
+	require.NoError(os.Remove(filepath.Join(sealDir, core.SealedKeyFname)))
+	assert.NoError(core.ConnectSealer(sealer))
+}
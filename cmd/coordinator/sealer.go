@@ -0,0 +1,35 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"log"
+
+	"github.com/edgelesssys/marblerun/coordinator/config"
+	"github.com/edgelesssys/marblerun/coordinator/core"
+	"github.com/edgelesssys/marblerun/util"
+)
+
+// getSealer returns the sealer selected by the environment, or defaultSealer if none was selected
+func getSealer(sealDir string, defaultSealer core.Sealer) core.Sealer {
+	switch sealer := util.Getenv(config.Sealer, config.SealerDefault); sealer {
+	case config.SealerDefault:
+		return defaultSealer
+	case config.SealerVault:
+		client := core.NewVaultTransitClient(util.MustGetenv(config.VaultAddr), util.MustGetenv(config.VaultToken), util.MustGetenv(config.VaultTransitKey))
+		return core.NewVaultSealer(sealDir, client)
+	case config.SealerAWSKMS:
+		client, err := core.NewKMSAPIClient(util.MustGetenv(config.KMSKeyARN), util.MustGetenv(config.AWSAccessKeyID), util.MustGetenv(config.AWSSecretAccessKey), util.Getenv(config.AWSSessionToken, ""))
+		if err != nil {
+			log.Fatalln(err)
+		}
+		return core.NewKMSSealer(sealDir, client)
+	default:
+		log.Fatalln("unknown sealer:", sealer)
+		return nil
+	}
+}
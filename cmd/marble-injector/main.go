@@ -1,11 +1,18 @@
 package main
 
 import (
+	"crypto/tls"
 	"flag"
+	"fmt"
+	"io/ioutil"
 	"log"
 	"net/http"
+	"strings"
 
 	"github.com/edgelesssys/marblerun/injector"
+	"github.com/edgelesssys/marblerun/util"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 func main() {
@@ -14,30 +21,292 @@ func main() {
 	var addr string
 	var clusterDomain string
 	var sgxResource string
+	var sgxQuantity string
+	var safePatches bool
+	var labels string
+	var marbleTypeLabel string
+	var setSGXRequests bool
+	var manifestFile string
+	var coordinatorCAConfigMap string
+	var coordinatorCAMountPath string
+	var preStopURL string
+	var runtimeDirPath string
+	var runtimeDirSizeLimit string
+	var restrictMarbleTypes bool
+	var denyUnknownMarbleTypes bool
+	var startupProbe string
+	var tlsMinVersion string
+	var topologySpreadKey string
+	var topologySpreadMaxSkew int
+	var topologySpreadWhenUnsatisfiable string
+	var tlsCipherSuites string
+	var sidecarFile string
+	var maxEnvSize int
+	var denyOversizedEnv bool
+	var deviceResources string
+	var imagePullSecret string
+	var serviceAccountTokenAudience string
+	var serviceAccountTokenExpiration int64
+	var serviceAccountTokenMountPath string
+	var fsGroup int64
+	var dnsOptions string
+	var dnsSearches string
+	var uuidEnv bool
+	var skipUUIDFile bool
+	var restrictedSecurityContext bool
+	var startupProbeFailureThreshold int
+	var startupProbePeriodSeconds int
 	flag.StringVar(&addr, "coordAddr", "coordinator-mesh-api.marblerun:2001", "Address of the Marblerun coordinator")
 	flag.StringVar(&certFile, "tlsCertFile", "/etc/webhook/certs/cert.pem", "File containing the x509 Certificate for HTTPS.")
 	flag.StringVar(&keyFile, "tlsKeyFile", "/etc/webhook/certs/key.pem", "File containing the x509 private key to --tlsCertFile.")
+	flag.StringVar(&tlsMinVersion, "tlsMinVersion", "1.3", "Minimum TLS version accepted by the webhook server: 1.2 or 1.3")
+	flag.StringVar(&tlsCipherSuites, "tlsCipherSuites", "", "Comma-separated list of cipher suites accepted by the webhook server for TLS 1.2, defaults to those of crypto/tls")
 	flag.StringVar(&clusterDomain, "clusterDomain", "cluster.local", "Domain name of the kubernetes cluster")
 	flag.StringVar(&sgxResource, "sgxResource", "sgx.intel.com/epc", "Defines the resource/toleration to inject, this needs to be exposed on a node through a device plugin")
+	flag.StringVar(&sgxQuantity, "sgxQuantity", "10", "Amount of the resource set in --sgxResource to inject, e.g., 10, 500m, or 64Mi for divisible EPC resources")
+	flag.StringVar(&deviceResources, "deviceResources", "", "Comma-separated list of key=quantity device resources to inject alongside --sgxResource, e.g., nvidia.com/gpu=1. The suffix :toleration also injects a toleration for the resource key")
+	flag.StringVar(&imagePullSecret, "imagePullSecret", "", "Name of a secret added to the image pull secrets of injected pods, e.g., for enclave images in a private registry")
+	flag.StringVar(&serviceAccountTokenAudience, "serviceAccountTokenAudience", "", "Audience of a projected service account token mounted into injected containers, e.g., for workload identity. No token is mounted if empty")
+	flag.Int64Var(&serviceAccountTokenExpiration, "serviceAccountTokenExpiration", 3600, "Expiration in seconds of the token set in --serviceAccountTokenAudience, at least 600")
+	flag.StringVar(&serviceAccountTokenMountPath, "serviceAccountTokenMountPath", "/var/run/secrets/tokens", "Path the token set in --serviceAccountTokenAudience is mounted to, the token is stored in the file token within it")
+	flag.Int64Var(&fsGroup, "fsGroup", -1, "fsGroup set in the security context of injected pods without one, so the injected volumes are accessible by non-root users of the group. Negative values disable it")
+	flag.BoolVar(&restrictedSecurityContext, "restrictedSecurityContext", false, "Set the fields of the security contexts of injected pods and containers required by the restricted Pod Security Standard, unless they are set already: runAsNonRoot, the RuntimeDefault seccomp profile, allowPrivilegeEscalation: false, and dropping all capabilities")
+	flag.StringVar(&dnsOptions, "dnsOptions", "", "Comma-separated list of resolver options set in the DNS config of injected pods without one, e.g., ndots:2")
+	flag.StringVar(&dnsSearches, "dnsSearches", "", "Comma-separated list of search domains set in the DNS config of injected pods without one")
+	flag.BoolVar(&uuidEnv, "uuidEnv", false, "Set EDG_MARBLE_UUID in injected containers to the UID of the pod using the downward API, for runtimes reading the UUID from the environment")
+	flag.BoolVar(&skipUUIDFile, "skipUUIDFile", false, "Omit the volume providing the UUID file, requires --uuidEnv")
+	flag.BoolVar(&safePatches, "safePatches", false, "Prepend JSONPatch test operations to the patches, so they are rejected if another webhook modified the pod")
+	flag.StringVar(&labels, "labels", "", "Comma-separated list of key=value labels to add to injected pods")
+	flag.StringVar(&marbleTypeLabel, "marbleTypeLabel", "", "Key of a label holding the marble type to add to injected pods")
+	flag.BoolVar(&setSGXRequests, "setSGXRequests", false, "Set the SGX resource requests in addition to the limits")
+	flag.StringVar(&coordinatorCAConfigMap, "coordinatorCAConfigMap", "", "Name of a ConfigMap holding the coordinator's root certificate under the key ca.crt, which is mounted into injected pods")
+	flag.StringVar(&coordinatorCAMountPath, "coordinatorCAMountPath", "/etc/marblerun/coordinator-ca", "Path the ConfigMap set in --coordinatorCAConfigMap is mounted to")
+	flag.StringVar(&preStopURL, "preStopURL", "", "URL an HTTP GET request is sent to by a preStop hook added to injected containers, e.g., to notify the coordinator of terminating marbles")
+	flag.StringVar(&runtimeDirPath, "runtimeDirPath", "", "Path an emptyDir volume is mounted to in injected containers, providing a writable directory for the runtime files of the enclave, e.g., /graphene-tmp")
+	flag.StringVar(&runtimeDirSizeLimit, "runtimeDirSizeLimit", "", "Size limit of the volume set in --runtimeDirPath, e.g., 64Mi")
+	flag.StringVar(&startupProbe, "startupProbe", "", "Startup probe added to injected containers without one, so slowly booting enclaves are not killed by liveness probes, e.g., http://:8080/healthz, tcp://:8080, or exec:cat /tmp/ready")
+	flag.IntVar(&startupProbeFailureThreshold, "startupProbeFailureThreshold", 30, "Failure threshold of the probe set in --startupProbe")
+	flag.IntVar(&startupProbePeriodSeconds, "startupProbePeriodSeconds", 10, "Period in seconds of the probe set in --startupProbe")
+	flag.StringVar(&topologySpreadKey, "topologySpreadKey", "", "Topology key of a topology spread constraint added to injected pods, spreading the pods of each marble type across its domains, e.g., topology.kubernetes.io/zone")
+	flag.IntVar(&topologySpreadMaxSkew, "topologySpreadMaxSkew", 1, "Max skew of the topology spread constraint set in --topologySpreadKey")
+	flag.StringVar(&topologySpreadWhenUnsatisfiable, "topologySpreadWhenUnsatisfiable", "ScheduleAnyway", "Action if the topology spread constraint set in --topologySpreadKey can't be satisfied: DoNotSchedule or ScheduleAnyway")
+	flag.StringVar(&sidecarFile, "sidecarFile", "", "File containing the specification of a sidecar container in JSON or YAML format, e.g., mounted from a ConfigMap, which is appended to injected pods, e.g., to ship their logs")
+	flag.IntVar(&maxEnvSize, "maxEnvSize", 32768, "Maximum size in bytes of the environment injected into a container, pods exceeding it are admitted with a warning. 0 disables the check")
+	flag.BoolVar(&denyOversizedEnv, "denyOversizedEnv", false, "Deny pods exceeding --maxEnvSize instead of admitting them with a warning")
+	flag.BoolVar(&restrictMarbleTypes, "restrictMarbleTypes", false, "Only inject pods of marble types declared in the manifest set in --manifestFile, pods of other marble types are admitted without injection and with a warning")
+	flag.BoolVar(&denyUnknownMarbleTypes, "denyUnknownMarbleTypes", false, "Deny pods of marble types not declared in the manifest instead of admitting them, requires --restrictMarbleTypes")
+	flag.StringVar(&manifestFile, "manifestFile", "", "File containing the Marblerun manifest, e.g., mounted from a ConfigMap, to read additional volumes, resources, and annotations of marbles from")
 
 	flag.Parse()
 
+	podLabels, err := parseLabels(labels)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	sgxResourceQuantity, err := injector.ParseSGXQuantity(sgxQuantity)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	devices, err := injector.ParseDeviceResources(deviceResources)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var serviceAccountToken *injector.ServiceAccountToken
+	if serviceAccountTokenAudience != "" {
+		if serviceAccountToken, err = injector.NewServiceAccountToken(serviceAccountTokenAudience, serviceAccountTokenExpiration, serviceAccountTokenMountPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var podFSGroup *int64
+	if fsGroup >= 0 {
+		podFSGroup = &fsGroup
+	}
+
+	dnsConfig, err := injector.ParseDNSConfig(dnsOptions, dnsSearches)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if skipUUIDFile && !uuidEnv {
+		log.Fatal("--skipUUIDFile requires --uuidEnv")
+	}
+
+	var preStop *corev1.Handler
+	if preStopURL != "" {
+		if preStop, err = injector.ParsePreStopURL(preStopURL); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var probe *corev1.Probe
+	if startupProbe != "" {
+		if probe, err = injector.ParseStartupProbe(startupProbe, int32(startupProbeFailureThreshold), int32(startupProbePeriodSeconds)); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var topologySpread *corev1.TopologySpreadConstraint
+	if topologySpreadKey != "" {
+		if topologySpread, err = injector.NewTopologySpreadConstraint(topologySpreadKey, int32(topologySpreadMaxSkew), topologySpreadWhenUnsatisfiable); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var sidecar *corev1.Container
+	if sidecarFile != "" {
+		rawSidecar, err := ioutil.ReadFile(sidecarFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if sidecar, err = injector.LoadSidecar(rawSidecar); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var runtimeDirSize resource.Quantity
+	if runtimeDirSizeLimit != "" {
+		if runtimeDirSize, err = resource.ParseQuantity(runtimeDirSizeLimit); err != nil {
+			log.Fatalf("invalid runtime directory size limit %s: %v", runtimeDirSizeLimit, err)
+		}
+	}
+
+	if maxEnvSize < 0 {
+		log.Fatal("--maxEnvSize must not be negative")
+	}
+	if denyOversizedEnv && maxEnvSize == 0 {
+		log.Fatal("--denyOversizedEnv requires --maxEnvSize")
+	}
+
+	if restrictMarbleTypes && manifestFile == "" {
+		log.Fatal("--restrictMarbleTypes requires --manifestFile")
+	}
+	if denyUnknownMarbleTypes && !restrictMarbleTypes {
+		log.Fatal("--denyUnknownMarbleTypes requires --restrictMarbleTypes")
+	}
+
+	var extraVolumes map[string]injector.ExtraVolumes
+	var marbleResources map[string]corev1.ResourceRequirements
+	var marbleAnnotations map[string]map[string]string
+	var knownMarbleTypes map[string]bool
+	if manifestFile != "" {
+		rawManifest, err := ioutil.ReadFile(manifestFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if extraVolumes, err = injector.LoadExtraVolumes(rawManifest); err != nil {
+			log.Fatal(err)
+		}
+		if marbleResources, err = injector.LoadMarbleResources(rawManifest); err != nil {
+			log.Fatal(err)
+		}
+		if marbleAnnotations, err = injector.LoadMarbleAnnotations(rawManifest); err != nil {
+			log.Fatal(err)
+		}
+		if restrictMarbleTypes {
+			if knownMarbleTypes, err = injector.LoadMarbleTypes(rawManifest); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
 	mux := http.NewServeMux()
 	w := &injector.Mutator{
-		CoordAddr:   addr,
-		DomainName:  clusterDomain,
-		SGXResource: sgxResource,
+		CoordAddr:                 addr,
+		DomainName:                clusterDomain,
+		SGXResource:               sgxResource,
+		SGXQuantity:               sgxResourceQuantity,
+		SafePatches:               safePatches,
+		Labels:                    podLabels,
+		MarbleTypeLabel:           marbleTypeLabel,
+		SetSGXRequests:            setSGXRequests,
+		ExtraVolumes:              extraVolumes,
+		CoordinatorCAConfigMap:    coordinatorCAConfigMap,
+		CoordinatorCAMountPath:    coordinatorCAMountPath,
+		PreStop:                   preStop,
+		MarbleResources:           marbleResources,
+		RuntimeDirPath:            runtimeDirPath,
+		RuntimeDirSizeLimit:       runtimeDirSize,
+		KnownMarbleTypes:          knownMarbleTypes,
+		DenyUnknownMarbleTypes:    denyUnknownMarbleTypes,
+		StartupProbe:              probe,
+		TopologySpread:            topologySpread,
+		Sidecar:                   sidecar,
+		MaxEnvSize:                maxEnvSize,
+		DenyOversizedEnv:          denyOversizedEnv,
+		DeviceResources:           devices,
+		ImagePullSecret:           imagePullSecret,
+		ServiceAccountToken:       serviceAccountToken,
+		FSGroup:                   podFSGroup,
+		DNSConfig:                 dnsConfig,
+		UUIDEnv:                   uuidEnv,
+		SkipUUIDFile:              skipUUIDFile,
+		MarbleAnnotations:         marbleAnnotations,
+		RestrictedSecurityContext: restrictedSecurityContext,
 	}
 
+	var health injector.Health
+
 	mux.HandleFunc("/mutate", w.HandleMutate)
 	mux.HandleFunc("/mutate-no-sgx", w.HandleMutateNoSgx)
+	mux.HandleFunc("/healthz", health.HandleHealthz)
+	mux.HandleFunc("/readyz", health.HandleReadyz)
+
+	// load the certificate before accepting connections, so the server only becomes ready if it is able to serve TLS
+	// the files are checked on each handshake, so a rotated certificate is served without a restart
+	certReloader, err := injector.NewCertReloader(certFile, keyFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	minVersion, err := util.ParseTLSVersion(tlsMinVersion)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if minVersion < tls.VersionTLS12 {
+		log.Fatal("TLS versions below 1.2 are insecure")
+	}
+	cipherSuites, err := util.ParseCipherSuites(tlsCipherSuites)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tlsConfig := &tls.Config{
+		GetCertificate: certReloader.GetCertificate,
+		MinVersion:     minVersion,
+		CipherSuites:   cipherSuites,
+	}
 
 	s := &http.Server{
 		// Addresse forwarding to 443 should be handled by the marble-injector service object
-		Addr:    ":8443",
-		Handler: mux,
+		Addr:      ":8443",
+		Handler:   mux,
+		TLSConfig: tlsConfig,
+	}
+
+	listener, err := tls.Listen("tcp", s.Addr, tlsConfig)
+	if err != nil {
+		log.Fatal(err)
 	}
 
 	log.Println("Starting Server")
-	log.Fatal(s.ListenAndServeTLS(certFile, keyFile))
+	health.SetReady(true)
+	log.Fatal(s.Serve(listener))
+}
+
+// parseLabels parses a comma-separated list of key=value pairs
+func parseLabels(labels string) (map[string]string, error) {
+	parsed := make(map[string]string)
+	if labels == "" {
+		return parsed, nil
+	}
+	for _, label := range strings.Split(labels, ",") {
+		keyValue := strings.SplitN(label, "=", 2)
+		if len(keyValue) != 2 || keyValue[0] == "" {
+			return nil, fmt.Errorf("invalid label: %s", label)
+		}
+		parsed[keyValue[0]] = keyValue[1]
+	}
+	return parsed, nil
 }
@@ -19,7 +19,8 @@ const MeshAddr = "EDG_COORDINATOR_MESH_ADDR"
 // MeshAddrDefault is the coordinator's default address for the gRPC server to listen on
 const MeshAddrDefault = ":2001"
 
-// ClientAddr is the coordinator's address for the HTTP-REST server to listen on
+// ClientAddr is the coordinator's address for the HTTP-REST server to listen on, or a unix:// prefixed socket path.
+// The socket serves plain HTTP, so admin endpoints, which require a TLS client certificate, answer 401 on it.
 const ClientAddr = "EDG_COORDINATOR_CLIENT_ADDR"
 
 // ClientAddrDefault is the coordinator's default address for the HTTP-REST server to listen on
@@ -28,7 +29,7 @@ const ClientAddrDefault = ":4433"
 // PromAddr is the coordinator's address for the prometheus endpoint server to listen on
 const PromAddr = "EDG_COORDINATOR_PROMETHEUS_ADDR"
 
-// DNSNames are the alternative dns names for the coordinator's certificate
+// DNSNames are the comma-separated alternative dns names (optionally starting with a "*." wildcard) and IP addresses for the coordinator's certificate
 const DNSNames = "EDG_COORDINATOR_DNS_NAMES"
 
 // DNSNamesDefault are the default dns names for the coordinator's certificate
@@ -45,3 +46,183 @@ const DevMode = "EDG_COORDINATOR_DEV_MODE"
 
 // DevModeDefault is the default logging mode.
 const DevModeDefault = "0"
+
+// Sealer selects the backend used to protect the coordinator's state encryption key
+const Sealer = "EDG_COORDINATOR_SEALER"
+
+// SealerDefault uses the built-in sealer, which seals the encryption key with the enclave's seal key
+const SealerDefault = ""
+
+// SealMode selects the SGX seal key used by the built-in sealer: "product" or "unique".
+// A state sealed with the unique key can't be unsealed after an update of the coordinator and must be recovered.
+const SealMode = "EDG_COORDINATOR_SEAL_MODE"
+
+// SealModeDefault seals with the product key, so the state survives updates of the coordinator
+const SealModeDefault = "product"
+
+// SealerVault wraps the state encryption key using HashiCorp Vault's transit secrets engine
+const SealerVault = "vault"
+
+// VaultAddr is the address of the Vault server used by the vault sealer
+const VaultAddr = "EDG_COORDINATOR_VAULT_ADDR"
+
+// VaultToken is the token used by the vault sealer to authenticate to Vault
+const VaultToken = "EDG_COORDINATOR_VAULT_TOKEN"
+
+// VaultTransitKey is the name of the transit key used by the vault sealer
+const VaultTransitKey = "EDG_COORDINATOR_VAULT_TRANSIT_KEY"
+
+// SealerAWSKMS wraps the state encryption key using AWS Key Management Service
+const SealerAWSKMS = "aws-kms"
+
+// KMSKeyARN is the ARN of the KMS key used by the aws-kms sealer
+const KMSKeyARN = "EDG_COORDINATOR_KMS_KEY_ARN"
+
+// AWSAccessKeyID is the access key ID used by the aws-kms sealer to authenticate to AWS
+const AWSAccessKeyID = "AWS_ACCESS_KEY_ID"
+
+// AWSSecretAccessKey is the secret access key used by the aws-kms sealer to authenticate to AWS
+const AWSSecretAccessKey = "AWS_SECRET_ACCESS_KEY"
+
+// AWSSessionToken is the optional session token used by the aws-kms sealer for temporary credentials
+const AWSSessionToken = "AWS_SESSION_TOKEN"
+
+// RecoveryThreshold enables multi-party recovery and sets the number of recovery key shares required to recover the coordinator
+const RecoveryThreshold = "EDG_COORDINATOR_RECOVERY_THRESHOLD"
+
+// ActivationRateLimit is the number of activation requests per second accepted from a single source address. Unset or 0 disables rate limiting.
+const ActivationRateLimit = "EDG_COORDINATOR_ACTIVATION_RATE_LIMIT"
+
+// ActivationRateLimitDefault disables rate limiting of activation requests
+const ActivationRateLimitDefault = "0"
+
+// ActivationTimeout is the duration after which the marble server aborts an activation request. 0 disables the timeout.
+const ActivationTimeout = "EDG_COORDINATOR_ACTIVATION_TIMEOUT"
+
+// ActivationTimeoutDefault is the default duration after which the marble server aborts an activation request
+const ActivationTimeoutDefault = "1m"
+
+// KeepaliveMaxConnectionIdle is the duration after which the marble server closes idle connections
+const KeepaliveMaxConnectionIdle = "EDG_COORDINATOR_KEEPALIVE_MAX_CONNECTION_IDLE"
+
+// KeepaliveMaxConnectionIdleDefault is the default duration after which the marble server closes idle connections
+const KeepaliveMaxConnectionIdleDefault = "5m"
+
+// KeepaliveTime is the duration of inactivity after which the marble server pings a client
+const KeepaliveTime = "EDG_COORDINATOR_KEEPALIVE_TIME"
+
+// KeepaliveTimeDefault is the default duration of inactivity after which the marble server pings a client
+const KeepaliveTimeDefault = "1m"
+
+// KeepaliveTimeout is the duration the marble server waits for a ping to be acknowledged before closing the connection
+const KeepaliveTimeout = "EDG_COORDINATOR_KEEPALIVE_TIMEOUT"
+
+// KeepaliveTimeoutDefault is the default duration the marble server waits for a ping to be acknowledged
+const KeepaliveTimeoutDefault = "20s"
+
+// KeepaliveMinTime is the minimum interval in which clients are allowed to ping the marble server
+const KeepaliveMinTime = "EDG_COORDINATOR_KEEPALIVE_MIN_TIME"
+
+// KeepaliveMinTimeDefault is the default minimum interval in which clients are allowed to ping the marble server
+const KeepaliveMinTimeDefault = "30s"
+
+// KeepalivePermitWithoutStream allows clients to send pings when there are no active streams
+const KeepalivePermitWithoutStream = "EDG_COORDINATOR_KEEPALIVE_PERMIT_WITHOUT_STREAM"
+
+// KeepalivePermitWithoutStreamDefault allows pings without active streams by default
+const KeepalivePermitWithoutStreamDefault = "1"
+
+// OTLPEndpoint is the host:port of an OpenTelemetry collector receiving traces via OTLP/gRPC. Unset disables tracing.
+const OTLPEndpoint = "EDG_COORDINATOR_OTLP_ENDPOINT"
+
+// SCTFile is the path to a file holding a SignedCertificateTimestampList (RFC 6962, section 3.3) which is stapled into the TLS handshakes of the client API. Unset disables stapling.
+const SCTFile = "EDG_COORDINATOR_SCT_FILE"
+
+// MaxConcurrentActivations is the number of activation requests the marble server handles concurrently. Unset or 0 disables the limit.
+const MaxConcurrentActivations = "EDG_COORDINATOR_MAX_CONCURRENT_ACTIVATIONS"
+
+// MaxConcurrentActivationsDefault disables the concurrency limit of activation requests
+const MaxConcurrentActivationsDefault = "0"
+
+// ConcurrentActivationsPolicy defines how activation requests exceeding MaxConcurrentActivations are handled: "queue" or "reject"
+const ConcurrentActivationsPolicy = "EDG_COORDINATOR_CONCURRENT_ACTIVATIONS_POLICY"
+
+// ConcurrentActivationsPolicyDefault queues excess activation requests until a slot is free or the request times out
+const ConcurrentActivationsPolicyDefault = "queue"
+
+// MaxMsgSize is the maximum size in bytes of gRPC messages sent and received by the marble server, e.g., activation responses holding large secrets.
+// Memory for a message is allocated before it is checked, so a higher limit allows clients to make the coordinator allocate more memory per request.
+const MaxMsgSize = "EDG_COORDINATOR_MAX_MSG_SIZE"
+
+// MaxMsgSizeDefault is the default maximum size of gRPC messages of the marble server (16 MiB), gRPC's own default is 4 MiB
+const MaxMsgSizeDefault = "16777216"
+
+// TLSMinVersion is the minimum TLS version accepted by the client and marble servers: "1.2" or "1.3"
+const TLSMinVersion = "EDG_COORDINATOR_TLS_MIN_VERSION"
+
+// TLSMinVersionDefault only accepts TLS 1.3
+const TLSMinVersionDefault = "1.3"
+
+// TLSCipherSuites is a comma-separated list of the cipher suites accepted by the client and marble servers for TLS 1.2, e.g., "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384".
+// The cipher suites of TLS 1.3 are not configurable. Unset uses the defaults of crypto/tls.
+const TLSCipherSuites = "EDG_COORDINATOR_TLS_CIPHER_SUITES"
+
+// GRPCWeb enables gRPC-web on the client server, so browser-based dashboards can use the gRPC API of the Coordinator
+const GRPCWeb = "EDG_COORDINATOR_GRPC_WEB"
+
+// GRPCWebDefault disables gRPC-web
+const GRPCWebDefault = "0"
+
+// GRPCWebAllowedOrigins is a comma-separated list of origins allowed to send cross-origin gRPC-web requests, e.g., "https://dashboard.example.com". Unset only allows same-origin requests.
+const GRPCWebAllowedOrigins = "EDG_COORDINATOR_GRPC_WEB_ALLOWED_ORIGINS"
+
+// MeshALPN is a comma-separated list of the ALPN protocols advertised by the marble server, e.g., for load balancers routing by ALPN.
+// gRPC requires HTTP/2, so the list must contain "h2".
+const MeshALPN = "EDG_COORDINATOR_MESH_ALPN"
+
+// MeshALPNDefault only advertises HTTP/2
+const MeshALPNDefault = "h2"
+
+// QuoteCacheTTL is the duration the results of successful quote validations are cached, e.g., "5m", so identical quotes are not validated again.
+// A cached result does not reflect changes of the collateral within the TTL, e.g., a revoked TCB.
+const QuoteCacheTTL = "EDG_COORDINATOR_QUOTE_CACHE_TTL"
+
+// QuoteCacheTTLDefault disables caching of quote validations
+const QuoteCacheTTLDefault = "0s"
+
+// QuoteValidationRetries is the number of times a quote validation is retried if the collateral could not be fetched, e.g., because of a network error.
+// Validations failing for other reasons are not retried.
+const QuoteValidationRetries = "EDG_COORDINATOR_QUOTE_VALIDATION_RETRIES"
+
+// QuoteValidationRetriesDefault disables retrying quote validations
+const QuoteValidationRetriesDefault = "0"
+
+// QuoteValidationBackoff is the wait before the first retry of a quote validation, it is doubled after each retry
+const QuoteValidationBackoff = "EDG_COORDINATOR_QUOTE_VALIDATION_BACKOFF"
+
+// QuoteValidationBackoffDefault is the default wait before the first retry of a quote validation
+const QuoteValidationBackoffDefault = "500ms"
+
+// QuoteValidationMaxBackoff is the maximum wait between retries of a quote validation
+const QuoteValidationMaxBackoff = "EDG_COORDINATOR_QUOTE_VALIDATION_MAX_BACKOFF"
+
+// QuoteValidationMaxBackoffDefault is the default maximum wait between retries of a quote validation
+const QuoteValidationMaxBackoffDefault = "5s"
+
+// MeshBindRetries is the number of times binding the address of the marble server is retried, e.g., while the address is still in use during a rolling restart
+const MeshBindRetries = "EDG_COORDINATOR_MESH_BIND_RETRIES"
+
+// MeshBindRetriesDefault is the default number of retries of binding the marble server
+const MeshBindRetriesDefault = "5"
+
+// MeshBindBackoff is the wait before the first retry of binding the marble server, it is doubled after each retry
+const MeshBindBackoff = "EDG_COORDINATOR_MESH_BIND_BACKOFF"
+
+// MeshBindBackoffDefault is the default wait before the first retry of binding the marble server
+const MeshBindBackoffDefault = "1s"
+
+// MeshBindMaxBackoff is the maximum wait between retries of binding the marble server
+const MeshBindMaxBackoff = "EDG_COORDINATOR_MESH_BIND_MAX_BACKOFF"
+
+// MeshBindMaxBackoffDefault is the default maximum wait between retries of binding the marble server
+const MeshBindMaxBackoffDefault = "10s"
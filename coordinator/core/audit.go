@@ -0,0 +1,144 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/edgelesssys/marblerun/coordinator/rpc"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/status"
+)
+
+// ActivationRecord is an entry of the activation audit log
+type ActivationRecord struct {
+	MarbleType string
+	UUID       string
+	Timestamp  time.Time
+	// QuoteHash is the hex encoded SHA-256 hash of the quote sent by the marble
+	QuoteHash string
+	Success   bool
+	// Error holds the reason of a failed activation
+	Error string `json:",omitempty"`
+}
+
+// activationLogSize is the number of most recent activation records kept
+const activationLogSize = 10000
+
+// activationLogSealBatch is the number of failed activations recorded before the log is sealed.
+// Successful activations change the state and are sealed right away, failed ones are sealed with them or in batches.
+const activationLogSealBatch = 100
+
+// unauthenticated activations failing before the marble's quote was verified are recorded at most at this rate per second and burst size,
+// so clients can't flood the log
+const (
+	unauthenticatedRecordRate  = 1
+	unauthenticatedRecordBurst = 10
+)
+
+// activationLog is a ring buffer holding the most recent activation records. It is guarded by the lock of the Core.
+type activationLog struct {
+	records []ActivationRecord
+	next    int
+	// capacity is the maximum number of records, the oldest ones are evicted afterwards
+	capacity int
+	// unsealed is the number of records added since the log was last sealed
+	unsealed int
+}
+
+func newActivationLog(capacity int, records []ActivationRecord) *activationLog {
+	log := &activationLog{capacity: capacity}
+	for _, record := range records {
+		log.add(record)
+	}
+	log.unsealed = 0
+	return log
+}
+
+// add stores a record, evicting the oldest one if the log is full
+func (l *activationLog) add(record ActivationRecord) {
+	l.unsealed++
+	if len(l.records) < l.capacity {
+		l.records = append(l.records, record)
+		return
+	}
+	l.records[l.next] = record
+	l.next = (l.next + 1) % l.capacity
+}
+
+// list returns the records, oldest first
+func (l *activationLog) list() []ActivationRecord {
+	return append(append([]ActivationRecord(nil), l.records[l.next:]...), l.records[:l.next]...)
+}
+
+// recordActivation appends an activation attempt to the audit log. The caller must hold the lock.
+//
+// The log is sealed along with successful activations, while failed ones are sealed in batches.
+// Failures of unauthenticated marbles, i.e., before their quote was verified, are rate-limited.
+func (c *Core) recordActivation(req *rpc.ActivationReq, activationErr error, authenticated bool) {
+	if activationErr != nil && !authenticated && !c.recordLimiter.Allow() {
+		c.zaplogger.Debug("Activation record of unauthenticated marble suppressed", zap.String("MarbleType", req.GetMarbleType()), zap.Error(activationErr))
+		return
+	}
+
+	quoteHash := sha256.Sum256(req.GetQuote())
+	record := ActivationRecord{
+		MarbleType: req.GetMarbleType(),
+		UUID:       req.GetUUID(),
+		Timestamp:  time.Now().UTC(),
+		QuoteHash:  hex.EncodeToString(quoteHash[:]),
+		Success:    activationErr == nil,
+	}
+	event := Event{
+		Timestamp:  record.Timestamp,
+		Level:      EventLevelInfo,
+		Message:    "marble activated",
+		MarbleType: record.MarbleType,
+		UUID:       record.UUID,
+	}
+	if activationErr != nil {
+		record.Error = status.Convert(activationErr).Message()
+		event.Level = EventLevelError
+		event.Message = "activation failed: " + record.Error
+	}
+	c.activationLog.add(record)
+	c.events.add(event)
+
+	if activationErr != nil && c.activationLog.unsealed < activationLogSealBatch {
+		return
+	}
+	recoveryData, err := c.recovery.GetRecoveryData()
+	if err != nil {
+		c.zaplogger.Error("Could not retrieve the current recovery data. The activation log will not be sealed.", zap.Error(err))
+		return
+	}
+	if err := c.sealState(recoveryData); err != nil {
+		c.zaplogger.Error("Could not seal the activation log.", zap.Error(err))
+		c.events.add(Event{Timestamp: time.Now().UTC(), Level: EventLevelError, Message: "sealing the activation log failed: " + err.Error()})
+	}
+}
+
+// GetActivationLog returns up to limit entries of the activation audit log, starting at offset, and the total number of entries.
+// The log only keeps the most recent entries.
+func (c *Core) GetActivationLog(ctx context.Context, offset int, limit int) ([]ActivationRecord, int) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	records := c.activationLog.list()
+	total := len(records)
+	if offset < 0 || offset >= total || limit <= 0 {
+		return []ActivationRecord{}, total
+	}
+	end := offset + limit
+	if end > total || end < offset {
+		end = total
+	}
+	return records[offset:end], total
+}
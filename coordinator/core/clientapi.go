@@ -10,11 +10,16 @@ import (
 	"context"
 	"crypto/sha256"
 	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
 	"encoding/pem"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/edgelesssys/marblerun/coordinator/manifest"
+	"github.com/edgelesssys/marblerun/coordinator/recovery"
+	"github.com/edgelesssys/marblerun/util"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
@@ -22,22 +27,53 @@ import (
 // ClientCore provides the core functionality for the client. It can be used by e.g. a http server
 type ClientCore interface {
 	SetManifest(ctx context.Context, rawManifest []byte) (recoverySecretMap map[string][]byte, err error)
+	SetSignedManifest(ctx context.Context, rawManifest []byte, signature []byte) (recoverySecretMap map[string][]byte, err error)
 	GetCertQuote(ctx context.Context) (cert string, certQuote []byte, err error)
+	GetCertChain(ctx context.Context) (certChain string, err error)
 	GetManifestSignature(ctx context.Context) (manifestSignature []byte)
+	GetManifest(ctx context.Context) (rawManifest []byte)
 	GetStatus(ctx context.Context) (statusCode int, status string, err error)
+	GetPhase(ctx context.Context) Phase
 	Recover(ctx context.Context, encryptionKey []byte) (int, error)
 	VerifyAdmin(ctx context.Context, clientCerts []*x509.Certificate) bool
 	UpdateManifest(ctx context.Context, rawUpdateManifest []byte) error
+	SetManifestUpdate(ctx context.Context, rawUpdateManifest []byte, signature []byte) error
+	ExportRecoveryKey(ctx context.Context, rawPublicKey []byte) (encryptedRecoveryKey []byte, err error)
+	GetActivationLog(ctx context.Context, offset int, limit int) (records []ActivationRecord, total int)
+	SubscribeEvents(ctx context.Context) (buffered []Event, live <-chan Event)
+	EvaluateManifest(ctx context.Context, rawManifest []byte) ([]ManifestViolation, error)
+	GetMarbleLeases(ctx context.Context) []MarbleLease
+	RevokeMarble(ctx context.Context, marbleUUID string) error
+	GetMarbleCertificate(ctx context.Context, marbleUUID string) (*x509.Certificate, error)
+	RotateSecret(ctx context.Context, name string) (version uint64, err error)
+	GetSecrets(ctx context.Context) []SecretInfo
+	SetMaintenance(ctx context.Context, enabled bool)
+	InMaintenance(ctx context.Context) bool
+	RotateRootCertificate(ctx context.Context, transition time.Duration) (RootRotation, error)
+	GetRootRotation(ctx context.Context) *RootRotation
 }
 
 // SetManifest sets the manifest, once and for all
 //
 // rawManifest is the manifest of type Manifest in JSON format.
+// It fails if the Coordinator is pinned to a manifest signing key, use SetSignedManifest instead.
 func (c *Core) SetManifest(ctx context.Context, rawManifest []byte) (map[string][]byte, error) {
+	return c.SetSignedManifest(ctx, rawManifest, nil)
+}
+
+// SetSignedManifest sets the manifest, once and for all
+//
+// rawManifest is the manifest of type Manifest in JSON format.
+// signature is a signature over the SHA-256 hash of rawManifest, created with the private key of the manifest signing key.
+// It is only checked if the Coordinator is pinned to a manifest signing key and may be empty otherwise.
+func (c *Core) SetSignedManifest(ctx context.Context, rawManifest []byte, signature []byte) (map[string][]byte, error) {
 	defer c.mux.Unlock()
 	if err := c.requireState(stateAcceptingManifest, stateRecovery); err != nil {
 		return nil, err
 	}
+	if err := c.verifyManifestSignature(rawManifest, signature); err != nil {
+		return nil, err
+	}
 
 	var manifest manifest.Manifest
 	if err := json.Unmarshal(rawManifest, &manifest); err != nil {
@@ -46,6 +82,9 @@ func (c *Core) SetManifest(ctx context.Context, rawManifest []byte) (map[string]
 	if err := manifest.Check(ctx, c.zaplogger); err != nil {
 		return nil, err
 	}
+	if err := c.checkCertificateValidity(manifest); err != nil {
+		return nil, err
+	}
 
 	// Generate shared secrets specified in manifest
 	secrets, err := c.generateSecrets(ctx, manifest.Secrets, uuid.Nil, c.intermediateCert, c.intermediatePrivK)
@@ -96,19 +135,38 @@ func (c *Core) GetCertQuote(ctx context.Context) (string, []byte, error) {
 		return "", nil, err
 	}
 
+	strCert, err := c.certChainPEM()
+	if err != nil {
+		return "", nil, err
+	}
+	return strCert, c.quote, nil
+}
+
+// GetCertChain gets the Coordinator's certificate chain in PEM format
+//
+// The chain starts with the intermediate certificate, which signs the marbles' certificates, and ends with the self-signed root certificate.
+// Before a manifest is set, the chain consists of the certificates the Coordinator was bootstrapped with.
+func (c *Core) GetCertChain(ctx context.Context) (string, error) {
+	defer c.mux.Unlock()
+	if err := c.requireState(stateAcceptingManifest, stateAcceptingMarbles, stateRecovery); err != nil {
+		return "", err
+	}
+	return c.certChainPEM()
+}
+
+// certChainPEM encodes the intermediate and root certificate in PEM format. The caller must hold the lock.
+func (c *Core) certChainPEM() (string, error) {
 	pemCertRoot := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.rootCert.Raw})
 	if len(pemCertRoot) <= 0 {
-		return "", nil, errors.New("pem.EncodeToMemory failed for root certificate")
+		return "", errors.New("pem.EncodeToMemory failed for root certificate")
 	}
 
-	// Include intermediate certificate if a manifest has been set
 	pemCertIntermediate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.intermediateCert.Raw})
 	if len(pemCertIntermediate) <= 0 {
-		return "", nil, errors.New("pem.EncodeToMemory failed for intermediate certificate")
+		return "", errors.New("pem.EncodeToMemory failed for intermediate certificate")
 	}
 
-	strCert := string(pemCertIntermediate) + string(pemCertRoot)
-	return strCert, c.quote, nil
+	return string(pemCertIntermediate) + string(pemCertRoot), nil
 }
 
 // GetManifestSignature returns the hash of the manifest
@@ -125,6 +183,13 @@ func (c *Core) GetManifestSignature(ctx context.Context) []byte {
 	return hash[:]
 }
 
+// GetManifest returns the active manifest in the JSON format it was set with
+func (c *Core) GetManifest(ctx context.Context) []byte {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.rawManifest
+}
+
 // Recover sets an encryption key (ideally decrypted from the recovery data) and tries to unseal and load a saved state again.
 func (c *Core) Recover(ctx context.Context, secret []byte) (int, error) {
 	defer c.mux.Unlock()
@@ -154,6 +219,13 @@ func (c *Core) GetStatus(ctx context.Context) (statusCode int, status string, er
 	return c.getStatus(ctx)
 }
 
+// GetPhase returns the machine-readable phase the Coordinator is in.
+func (c *Core) GetPhase(ctx context.Context) Phase {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.state.phase()
+}
+
 // VerifyAdmin checks if a given client certificate matches the admin certificates specified in the manifest
 func (c *Core) VerifyAdmin(ctx context.Context, clientCerts []*x509.Certificate) bool {
 	// Check if a supplied client cert matches the supplied ones from the manifest stored in the core
@@ -178,6 +250,39 @@ func (c *Core) UpdateManifest(ctx context.Context, rawUpdateManifest []byte) err
 		return err
 	}
 
+	return c.applyUpdateManifest(ctx, rawUpdateManifest)
+}
+
+// SetManifestUpdate applies an update manifest which is signed by one of the admins of the current manifest
+//
+// signature is a signature over the SHA-256 hash of rawUpdateManifest, created with the private key of an admin certificate.
+func (c *Core) SetManifestUpdate(ctx context.Context, rawUpdateManifest []byte, signature []byte) error {
+	defer c.mux.Unlock()
+
+	// Only accept update manifest if we already have a manifest
+	if err := c.requireState(stateAcceptingMarbles); err != nil {
+		return err
+	}
+
+	if len(signature) == 0 {
+		return errors.New("update manifest is not signed")
+	}
+	if !c.verifyUpdateSignature(rawUpdateManifest, signature) {
+		return errors.New("update manifest signature does not match any admin of the current manifest")
+	}
+
+	return c.applyUpdateManifest(ctx, rawUpdateManifest)
+}
+
+// GetManifestHistory returns the update manifests which were applied to the current manifest
+func (c *Core) GetManifestHistory(ctx context.Context) []ManifestVersion {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return append([]ManifestVersion(nil), c.manifestHistory...)
+}
+
+// applyUpdateManifest checks and applies an update manifest. The caller must hold the lock.
+func (c *Core) applyUpdateManifest(ctx context.Context, rawUpdateManifest []byte) error {
 	// Unmarshal & check update manifest
 	var updateManifest manifest.Manifest
 	if err := json.Unmarshal(rawUpdateManifest, &updateManifest); err != nil {
@@ -188,7 +293,7 @@ func (c *Core) UpdateManifest(ctx context.Context, rawUpdateManifest []byte) err
 	}
 
 	// Generate new intermediate CA for Marble gRPC authentication
-	intermediateCert, intermediatePrivK, err := generateCert(c.rootCert.DNSNames, coordinatorIntermediateName, c.rootCert, c.rootPrivK)
+	intermediateCert, intermediatePrivK, err := generateCert(c.rootCert.DNSNames, c.rootCert.IPAddresses, coordinatorIntermediateName, c.rootCert, c.rootPrivK)
 	if err != nil {
 		c.zaplogger.Error("Could not generate a new intermediate CA for Marble authentication.", zap.Error(err))
 		return err
@@ -216,10 +321,15 @@ func (c *Core) UpdateManifest(ctx context.Context, rawUpdateManifest []byte) err
 		return err
 	}
 
+	updateHash := sha256.Sum256(rawUpdateManifest)
+	c.manifestHistory = append(c.manifestHistory, ManifestVersion{
+		Version:   len(c.manifestHistory) + 1,
+		Hash:      hex.EncodeToString(updateHash[:]),
+		Timestamp: time.Now().UTC(),
+	})
 	c.updateManifest = updateManifest
 	c.rawUpdateManifest = rawUpdateManifest
-	c.intermediateCert = intermediateCert
-	c.intermediatePrivK = intermediatePrivK
+	c.setCertificates(c.rootCert, c.rootPrivK, intermediateCert, intermediatePrivK)
 
 	// Overwrite regenerated secrets in core
 	for name, secret := range regeneratedSecrets {
@@ -232,6 +342,37 @@ func (c *Core) UpdateManifest(ctx context.Context, rawUpdateManifest []byte) err
 	return c.sealState(currentRecoveryData)
 }
 
+// ExportRecoveryKey returns the recovery key encrypted with the supplied PEM encoded RSA public key
+func (c *Core) ExportRecoveryKey(ctx context.Context, rawPublicKey []byte) ([]byte, error) {
+	defer c.mux.Unlock()
+	if err := c.requireState(stateAcceptingMarbles); err != nil {
+		return nil, err
+	}
+
+	publicKey, err := recovery.ParseRSAPublicKeyFromPEM(string(rawPublicKey))
+	if err != nil {
+		return nil, err
+	}
+
+	encryptionKey, err := c.sealer.GetEncryptionKey()
+	if err != nil {
+		c.zaplogger.Error("Could not retrieve the encryption key from the sealer.", zap.Error(err))
+		return nil, err
+	}
+
+	return util.EncryptOAEP(publicKey, encryptionKey)
+}
+
+// verifyUpdateSignature checks if the signature was created by one of the admins of the current manifest
+func (c *Core) verifyUpdateSignature(rawUpdateManifest []byte, signature []byte) bool {
+	for _, adminCert := range c.adminCerts {
+		if checkSignature(adminCert.PublicKey, rawUpdateManifest, signature) == nil {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *Core) performRecovery(encryptionKey []byte) error {
 	if err := c.sealer.SetEncryptionKey(encryptionKey); err != nil {
 		return err
@@ -242,12 +383,24 @@ func (c *Core) performRecovery(encryptionKey []byte) error {
 		return err
 	}
 
-	c.rootCert = rootCert
-	c.rootPrivK = rootPrivK
-	c.intermediateCert = intermediateCert
-	c.intermediatePrivK = intermediatePrivK
+	c.setCertificates(rootCert, rootPrivK, intermediateCert, intermediatePrivK)
 
 	c.quote = c.generateQuote()
 
 	return nil
 }
+
+// checkCertificateValidity checks that the certificates issued to marbles don't outlive the Coordinator's certificate. The caller must hold the lock.
+func (c *Core) checkCertificateValidity(mnf manifest.Manifest) error {
+	remaining := time.Until(c.intermediateCert.NotAfter)
+	for name, marble := range mnf.Marbles {
+		validity, err := marble.GetCertificateValidity()
+		if err != nil {
+			return fmt.Errorf("marble %s: %v", name, err)
+		}
+		if validity > remaining {
+			return fmt.Errorf("marble %s: certificate validity %v exceeds the remaining validity of the coordinator's certificate %v", name, validity, remaining)
+		}
+	}
+	return nil
+}
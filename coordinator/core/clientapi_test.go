@@ -8,14 +8,20 @@ package core
 
 import (
 	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
 	"testing"
 
 	"github.com/edgelesssys/marblerun/coordinator/manifest"
 	"github.com/edgelesssys/marblerun/coordinator/quote"
 	"github.com/edgelesssys/marblerun/test"
+	"github.com/edgelesssys/marblerun/util"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -176,6 +182,49 @@ func TestGetCertQuote(t *testing.T) {
 	//todo check quote
 }
 
+func TestGetCertChain(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	c, _ := mustSetup()
+
+	// parses the chain and checks that the intermediate certificate is signed by the root certificate
+	verifyChain := func(chain string) {
+		var certs []*x509.Certificate
+		rest := []byte(chain)
+		for {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			require.NoError(err)
+			certs = append(certs, cert)
+		}
+		require.Len(certs, 2)
+		assert.NoError(certs[0].CheckSignatureFrom(certs[1]))
+		assert.NoError(certs[1].CheckSignatureFrom(certs[1]))
+		assert.Equal(c.rootCert.Raw, certs[1].Raw)
+	}
+
+	// the bootstrap certificates before a manifest is set
+	chain, err := c.GetCertChain(context.TODO())
+	require.NoError(err)
+	verifyChain(chain)
+
+	_, err = c.SetManifest(context.TODO(), []byte(test.ManifestJSON))
+	require.NoError(err)
+	chain, err = c.GetCertChain(context.TODO())
+	require.NoError(err)
+	verifyChain(chain)
+
+	// the chain matches the one returned with the quote
+	certQuoteChain, _, err := c.GetCertQuote(context.TODO())
+	require.NoError(err)
+	assert.Equal(certQuoteChain, chain)
+}
+
 func TestGetStatus(t *testing.T) {
 	assert := assert.New(t)
 	require := require.New(t)
@@ -370,3 +419,75 @@ func testManifestInvalidDebugCase(c *Core, manifest *manifest.Manifest, marblePa
 	c, _ = mustSetup()
 	return c
 }
+
+func TestExportRecoveryKey(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	c, _ := mustSetup()
+
+	// No manifest set yet, so there is no recovery key to export
+	_, err := c.ExportRecoveryKey(context.TODO(), test.RecoveryPublicKey)
+	assert.Error(err)
+
+	_, err = c.SetManifest(context.TODO(), []byte(test.ManifestJSONWithRecoveryKey))
+	require.NoError(err)
+
+	encryptedRecoveryKey, err := c.ExportRecoveryKey(context.TODO(), test.RecoveryPublicKey)
+	require.NoError(err)
+	recoveryKey, err := util.DecryptOAEP(test.RecoveryPrivateKey, encryptedRecoveryKey)
+	require.NoError(err)
+	encryptionKey, err := c.sealer.GetEncryptionKey()
+	require.NoError(err)
+	assert.Equal(encryptionKey, recoveryKey)
+
+	_, err = c.ExportRecoveryKey(context.TODO(), []byte("invalid"))
+	assert.Error(err)
+}
+
+func TestSetManifestUpdate(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	c, _ := mustSetup()
+
+	sign := func(key *rsa.PrivateKey, data []byte) []byte {
+		hash := sha256.Sum256(data)
+		signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+		require.NoError(err)
+		return signature
+	}
+
+	// Set a manifest containing an admin certificate of test.RecoveryPrivateKey
+	_, err := c.SetManifest(context.TODO(), []byte(test.ManifestJSONWithRecoveryKey))
+	require.NoError(err)
+
+	// Unsigned update
+	err = c.SetManifestUpdate(context.TODO(), []byte(test.UpdateManifest), nil)
+	assert.Error(err)
+
+	// Update signed by someone who is not an admin
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(err)
+	err = c.SetManifestUpdate(context.TODO(), []byte(test.UpdateManifest), sign(otherKey, []byte(test.UpdateManifest)))
+	assert.Error(err)
+	assert.Empty(c.GetManifestHistory(context.TODO()))
+
+	// Allowed SecurityVersion bump signed by the admin
+	err = c.SetManifestUpdate(context.TODO(), []byte(test.UpdateManifest), sign(test.RecoveryPrivateKey, []byte(test.UpdateManifest)))
+	require.NoError(err)
+	assert.EqualValues(5, *c.updateManifest.Packages["frontend"].SecurityVersion)
+	history := c.GetManifestHistory(context.TODO())
+	require.Len(history, 1)
+	assert.Equal(1, history[0].Version)
+	updateHash := sha256.Sum256([]byte(test.UpdateManifest))
+	assert.Equal(hex.EncodeToString(updateHash[:]), history[0].Hash)
+
+	// Update trying to change an immutable field
+	var badUpdateManifest manifest.Manifest
+	require.NoError(json.Unmarshal([]byte(test.UpdateManifest), &badUpdateManifest))
+	badUpdateManifest.Marbles = map[string]manifest.Marble{"frontend": {Package: "frontend"}}
+	badRawManifest, err := json.Marshal(badUpdateManifest)
+	require.NoError(err)
+	err = c.SetManifestUpdate(context.TODO(), badRawManifest, sign(test.RecoveryPrivateKey, badRawManifest))
+	assert.Error(err)
+	assert.Len(c.GetManifestHistory(context.TODO()), 1)
+}
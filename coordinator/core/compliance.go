@@ -0,0 +1,89 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/edgelesssys/marblerun/coordinator/manifest"
+	"github.com/edgelesssys/marblerun/coordinator/quote"
+	"github.com/edgelesssys/marblerun/coordinator/rpc"
+	"go.uber.org/zap"
+)
+
+// MarbleProperties are the package properties reported by an activated marble
+type MarbleProperties struct {
+	MarbleType string
+	UUID       string
+	Properties quote.PackageProperties
+}
+
+// ManifestViolation describes why an activated marble would not comply with a manifest
+type ManifestViolation struct {
+	MarbleType string
+	UUID       string
+	Reason     string
+}
+
+// recordMarbleProperties records the package properties reported in the quote of a successfully activated marble.
+// Properties are only recorded if the quote validator is able to read them. The caller must hold the lock.
+func (c *Core) recordMarbleProperties(req *rpc.ActivationReq) {
+	reader, ok := c.qv.(quote.PropertiesReader)
+	if !ok || c.inSimulationMode() {
+		return
+	}
+	properties, err := reader.PackageProperties(req.GetQuote())
+	if err != nil {
+		c.zaplogger.Warn("Could not read the package properties of the marble.", zap.String("MarbleType", req.GetMarbleType()), zap.Error(err))
+		return
+	}
+	c.marbleProperties[req.GetUUID()] = MarbleProperties{
+		MarbleType: req.GetMarbleType(),
+		UUID:       req.GetUUID(),
+		Properties: properties,
+	}
+}
+
+// EvaluateManifest checks which activated marbles would fail to activate with the given manifest.
+// This is a report-only evaluation: neither the manifest nor the enforcement of the current manifest are changed.
+func (c *Core) EvaluateManifest(ctx context.Context, rawManifest []byte) ([]ManifestViolation, error) {
+	var mnf manifest.Manifest
+	if err := json.Unmarshal(rawManifest, &mnf); err != nil {
+		return nil, err
+	}
+	if err := mnf.Check(ctx, c.zaplogger); err != nil {
+		return nil, err
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	violations := []ManifestViolation{}
+	for _, marble := range c.marbleProperties {
+		violation := ManifestViolation{MarbleType: marble.MarbleType, UUID: marble.UUID}
+		marbleDefinition, ok := mnf.Marbles[marble.MarbleType]
+		if !ok {
+			violation.Reason = "marble type is not defined"
+			violations = append(violations, violation)
+			continue
+		}
+		if !mnf.Packages[marbleDefinition.Package].IsCompliant(marble.Properties) {
+			violation.Reason = "package properties of the marble do not comply with package " + marbleDefinition.Package
+			violations = append(violations, violation)
+		}
+	}
+
+	sort.Slice(violations, func(i, j int) bool {
+		if violations[i].MarbleType != violations[j].MarbleType {
+			return violations[i].MarbleType < violations[j].MarbleType
+		}
+		return violations[i].UUID < violations[j].UUID
+	})
+	return violations, nil
+}
@@ -0,0 +1,102 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/edgelesssys/marblerun/coordinator/manifest"
+	"github.com/edgelesssys/marblerun/coordinator/quote"
+	"github.com/edgelesssys/marblerun/coordinator/recovery"
+	"github.com/edgelesssys/marblerun/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestEvaluateManifest(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var mnf manifest.Manifest
+	require.NoError(json.Unmarshal([]byte(test.ManifestJSON), &mnf))
+
+	validator := quote.NewMockValidator()
+	issuer := quote.NewMockIssuer()
+	coreServer, err := NewCore([]string{"localhost"}, validator, issuer, &MockSealer{}, recovery.NewSinglePartyRecovery(), zap.NewNop())
+	require.NoError(err)
+	_, err = coreServer.SetManifest(context.TODO(), []byte(test.ManifestJSON))
+	require.NoError(err)
+
+	spawner := marbleSpawner{
+		assert:     assert,
+		require:    require,
+		issuer:     issuer,
+		validator:  validator,
+		manifest:   mnf,
+		coreServer: coreServer,
+	}
+	spawner.newMarble("frontend", "Azure", true)
+	spawner.newMarble("backend_first", "Azure", true)
+	spawner.newMarble("backend_other", "Azure", true)
+
+	evaluate := func(modify func(*manifest.Manifest)) []ManifestViolation {
+		var newManifest manifest.Manifest
+		require.NoError(json.Unmarshal([]byte(test.ManifestJSON), &newManifest))
+		modify(&newManifest)
+		rawManifest, err := json.Marshal(newManifest)
+		require.NoError(err)
+		violations, err := coreServer.EvaluateManifest(context.TODO(), rawManifest)
+		require.NoError(err)
+		return violations
+	}
+
+	// all marbles comply with the current manifest
+	assert.Empty(evaluate(func(*manifest.Manifest) {}))
+
+	// a higher security version is only violated by the frontend
+	violations := evaluate(func(m *manifest.Manifest) {
+		pkg := m.Packages["frontend"]
+		securityVersion := *pkg.SecurityVersion + 1
+		pkg.SecurityVersion = &securityVersion
+		m.Packages["frontend"] = pkg
+	})
+	require.Len(violations, 1)
+	assert.Equal("frontend", violations[0].MarbleType)
+	assert.Contains(violations[0].Reason, "package frontend")
+
+	// the backend marbles did not report a signer, product ID, and security version
+	violations = evaluate(func(m *manifest.Manifest) {
+		productID := uint64(42)
+		securityVersion := uint(1)
+		m.Packages["backend"] = quote.PackageProperties{
+			SignerID:        "1f1e1d1c1b1a191817161514131211100f0e0d0c0b0a09080706050403020100",
+			ProductID:       &productID,
+			SecurityVersion: &securityVersion,
+		}
+	})
+	require.Len(violations, 2)
+	assert.Equal("backend_first", violations[0].MarbleType)
+	assert.Equal("backend_other", violations[1].MarbleType)
+
+	// removed marble types are reported
+	violations = evaluate(func(m *manifest.Manifest) {
+		delete(m.Marbles, "backend_other")
+	})
+	require.Len(violations, 1)
+	assert.Equal("backend_other", violations[0].MarbleType)
+	assert.Equal("marble type is not defined", violations[0].Reason)
+
+	// evaluation does not change enforcement
+	assert.Equal(test.ManifestJSON, string(coreServer.GetManifest(context.TODO())))
+	spawner.newMarble("backend_other", "Azure", true)
+
+	_, err = coreServer.EvaluateManifest(context.TODO(), []byte("invalid"))
+	assert.Error(err)
+}
@@ -23,7 +23,10 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"net"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/edgelesssys/marblerun/coordinator/manifest"
@@ -31,7 +34,9 @@ import (
 	"github.com/edgelesssys/marblerun/coordinator/recovery"
 	"github.com/edgelesssys/marblerun/util"
 	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
+	"golang.org/x/time/rate"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/peer"
 )
@@ -44,19 +49,46 @@ type Core struct {
 	quote             []byte
 	rootPrivK         *ecdsa.PrivateKey
 	intermediatePrivK *ecdsa.PrivateKey
+	// derivationKey is the master secret the seal keys and private secrets of marbles are derived from.
+	// It is independent of the root certificate, so the derived keys don't change if the root certificate is rotated.
+	derivationKey     []byte
 	sealer            Sealer
 	recovery          recovery.Recovery
 	manifest          manifest.Manifest
 	rawManifest       []byte
 	updateManifest    manifest.Manifest
 	rawUpdateManifest []byte
+	manifestHistory   []ManifestVersion
+	activationLog     *activationLog
+	marbleProperties  map[string]MarbleProperties
+	leases            map[string]MarbleLease
+	marbleCerts       map[string][]byte
+	secretVersions    map[string]uint64
+	rootRotation      *RootRotation
+	events            *eventBuffer
 	secrets           map[string]manifest.Secret
 	state             state
 	qv                quote.Validator
 	qi                quote.Issuer
 	activations       map[string]uint
-	mux               sync.Mutex
-	zaplogger         *zap.Logger
+	// recordLimiter limits the rate of activation records of unauthenticated marbles
+	recordLimiter *rate.Limiter
+	// maintenance refuses activations if set
+	maintenance bool
+	// activationDuration is exported by Metrics
+	activationDuration prometheus.Histogram
+	// manifestSigningKey must have signed the manifest if set
+	manifestSigningKey crypto.PublicKey
+	// tlsCerts holds the *tlsCertificates served on TLS handshakes, which read it without holding mux
+	tlsCerts  atomic.Value
+	mux       sync.Mutex
+	zaplogger *zap.Logger
+}
+
+// tlsCertificates is a snapshot of the Coordinator's certificates together with their private keys
+type tlsCertificates struct {
+	root         *tls.Certificate
+	intermediate *tls.Certificate
 }
 
 // The sequence of states a Coordinator may be in
@@ -70,6 +102,31 @@ const (
 	stateMax
 )
 
+// Phase is the machine-readable name of the state a Coordinator is in
+type Phase string
+
+// The phases reported for the states of a Coordinator
+const (
+	PhaseUninitialized     Phase = "uninitialized"
+	PhaseRecovery          Phase = "recovery"
+	PhaseAcceptingManifest Phase = "accepting-manifest"
+	PhaseAcceptingMarbles  Phase = "accepting-marbles"
+)
+
+// phase returns the Phase corresponding to the state
+func (s state) phase() Phase {
+	switch s {
+	case stateRecovery:
+		return PhaseRecovery
+	case stateAcceptingManifest:
+		return PhaseAcceptingManifest
+	case stateAcceptingMarbles:
+		return PhaseAcceptingMarbles
+	default:
+		return PhaseUninitialized
+	}
+}
+
 // sealedState represents the state information, required for persistence, that gets sealed to the filesystem
 type sealedState struct {
 	RootPrivK           []byte
@@ -81,6 +138,23 @@ type sealedState struct {
 	Secrets             map[string]manifest.Secret
 	State               state
 	Activations         map[string]uint
+	ManifestHistory     []ManifestVersion
+	ActivationLog       []ActivationRecord
+	MarbleProperties    map[string]MarbleProperties
+	Leases              map[string]MarbleLease
+	MarbleCertificates  map[string][]byte
+	SecretVersions      map[string]uint64
+	RootRotation        *RootRotation
+	DerivationKey       []byte
+}
+
+// ManifestVersion records an update manifest which was applied to the Coordinator
+type ManifestVersion struct {
+	// Version is incremented with every applied update manifest, starting at 1
+	Version int
+	// Hash is the hex encoded SHA-256 hash of the raw update manifest
+	Hash      string
+	Timestamp time.Time
 }
 
 // coordinatorName is the name of the Coordinator. It is used as CN of the root certificate.
@@ -110,53 +184,79 @@ func (c *Core) advanceState(newState state) {
 // NewCore creates and initializes a new Core object
 func NewCore(dnsNames []string, qv quote.Validator, qi quote.Issuer, sealer Sealer, recovery recovery.Recovery, zapLogger *zap.Logger) (*Core, error) {
 	c := &Core{
-		state:       stateUninitialized,
-		activations: make(map[string]uint),
-		qv:          qv,
-		qi:          qi,
-		sealer:      sealer,
-		recovery:    recovery,
-		zaplogger:   zapLogger,
+		state:              stateUninitialized,
+		activations:        make(map[string]uint),
+		marbleProperties:   make(map[string]MarbleProperties),
+		leases:             make(map[string]MarbleLease),
+		marbleCerts:        make(map[string][]byte),
+		secretVersions:     make(map[string]uint64),
+		events:             newEventBuffer(eventBufferSize),
+		activationLog:      newActivationLog(activationLogSize, nil),
+		recordLimiter:      rate.NewLimiter(unauthenticatedRecordRate, unauthenticatedRecordBurst),
+		activationDuration: newActivationDurationHistogram(),
+		qv:                 qv,
+		qi:                 qi,
+		sealer:             sealer,
+		recovery:           recovery,
+		zaplogger:          zapLogger,
+	}
+
+	dnsNames, ipAddrs, err := parseSubjectAltNames(dnsNames)
+	if err != nil {
+		return nil, err
 	}
+	ipAddrs = append(append([]net.IP{}, util.DefaultCertificateIPAddresses...), ipAddrs...)
 
 	zapLogger.Info("loading state")
 	rootCert, rootPrivK, intermediateCert, intermediatePrivK, err := c.loadState()
 	if err != nil {
-		if err != ErrEncryptionKey {
+		if !errors.Is(err, ErrEncryptionKey) {
 			return nil, err
 		}
-		c.zaplogger.Error("Failed to decrypt sealed state. Processing with a new state. Use the /recover API endpoint to load an old state, or submit a new manifest to overwrite the old state. Look up the documentation for more information on how to proceed.")
-		rootCert, rootPrivK, err = generateCert(dnsNames, coordinatorName, nil, nil)
+		c.zaplogger.Error("Failed to decrypt sealed state. Processing with a new state. Use the /recover API endpoint to load an old state, or submit a new manifest to overwrite the old state. Look up the documentation for more information on how to proceed.", zap.Error(err))
+		rootCert, rootPrivK, err = generateCert(dnsNames, ipAddrs, coordinatorName, nil, nil)
 		if err != nil {
 			return nil, err
 		}
-		intermediateCert, intermediatePrivK, err = generateCert(dnsNames, coordinatorIntermediateName, rootCert, rootPrivK)
+		intermediateCert, intermediatePrivK, err = generateCert(dnsNames, ipAddrs, coordinatorIntermediateName, rootCert, rootPrivK)
 		if err != nil {
 			return nil, err
 		}
+		if c.derivationKey, err = generateDerivationKey(); err != nil {
+			return nil, err
+		}
 		c.advanceState(stateRecovery)
 	} else if rootCert == nil {
 		c.zaplogger.Info("No sealed state found. Proceeding with new state.")
-		rootCert, rootPrivK, err = generateCert(dnsNames, coordinatorName, nil, nil)
+		rootCert, rootPrivK, err = generateCert(dnsNames, ipAddrs, coordinatorName, nil, nil)
 		if err != nil {
 			return nil, err
 		}
-		intermediateCert, intermediatePrivK, err = generateCert(dnsNames, coordinatorIntermediateName, rootCert, rootPrivK)
+		intermediateCert, intermediatePrivK, err = generateCert(dnsNames, ipAddrs, coordinatorIntermediateName, rootCert, rootPrivK)
 		if err != nil {
 			return nil, err
 		}
+		if c.derivationKey, err = generateDerivationKey(); err != nil {
+			return nil, err
+		}
 		c.advanceState(stateAcceptingManifest)
 	}
 
-	c.rootCert = rootCert
-	c.rootPrivK = rootPrivK
-	c.intermediateCert = intermediateCert
-	c.intermediatePrivK = intermediatePrivK
+	c.setCertificates(rootCert, rootPrivK, intermediateCert, intermediatePrivK)
 	c.quote = c.generateQuote()
 
 	return c, nil
 }
 
+// generateDerivationKey generates a random master secret to derive the keys of marbles from
+func generateDerivationKey() ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
 // NewCoreWithMocks creates a new core object with quote and seal mocks for testing.
 func NewCoreWithMocks() *Core {
 	zapLogger, err := zap.NewDevelopment()
@@ -190,18 +290,31 @@ func (c *Core) GetTLSConfig() (*tls.Config, error) {
 
 // GetTLSRootCertificate creates a TLS certificate for the Coordinators self-signed x509 certificate
 func (c *Core) GetTLSRootCertificate(clientHello *tls.ClientHelloInfo) (*tls.Certificate, error) {
-	if c.state == stateUninitialized {
+	certs, ok := c.tlsCerts.Load().(*tlsCertificates)
+	if !ok {
 		return nil, errors.New("don't have a cert yet")
 	}
-	return util.TLSCertFromDER(c.rootCert.Raw, c.rootPrivK), nil
+	return certs.root, nil
 }
 
 // GetTLSIntermediateCertificate creates a TLS certificate for the Coordinator's x509 intermediate certificate based on the self-signed x509 root certificate
 func (c *Core) GetTLSIntermediateCertificate(clientHello *tls.ClientHelloInfo) (*tls.Certificate, error) {
-	if c.state == stateUninitialized {
+	certs, ok := c.tlsCerts.Load().(*tlsCertificates)
+	if !ok {
 		return nil, errors.New("don't have a cert yet")
 	}
-	return util.TLSCertFromDER(c.intermediateCert.Raw, c.intermediatePrivK), nil
+	return certs.intermediate, nil
+}
+
+// setCertificates replaces the Coordinator's certificates and private keys.
+// TLS handshakes see either the previous or the new certificates, but never a certificate with the key of another one.
+func (c *Core) setCertificates(rootCert *x509.Certificate, rootPrivK *ecdsa.PrivateKey, intermediateCert *x509.Certificate, intermediatePrivK *ecdsa.PrivateKey) {
+	c.rootCert, c.rootPrivK = rootCert, rootPrivK
+	c.intermediateCert, c.intermediatePrivK = intermediateCert, intermediatePrivK
+	c.tlsCerts.Store(&tlsCertificates{
+		root:         util.TLSCertFromDER(rootCert.Raw, rootPrivK),
+		intermediate: util.TLSCertFromDER(intermediateCert.Raw, intermediatePrivK),
+	})
 }
 
 func (c *Core) loadState() (*x509.Certificate, *ecdsa.PrivateKey, *x509.Certificate, *ecdsa.PrivateKey, error) {
@@ -267,6 +380,30 @@ func (c *Core) loadState() (*x509.Certificate, *ecdsa.PrivateKey, *x509.Certific
 
 	c.state = loadedState.State
 	c.activations = loadedState.Activations
+	c.manifestHistory = loadedState.ManifestHistory
+	c.activationLog = newActivationLog(activationLogSize, loadedState.ActivationLog)
+	c.marbleProperties = loadedState.MarbleProperties
+	if c.marbleProperties == nil {
+		c.marbleProperties = make(map[string]MarbleProperties)
+	}
+	c.leases = loadedState.Leases
+	if c.leases == nil {
+		c.leases = make(map[string]MarbleLease)
+	}
+	c.marbleCerts = loadedState.MarbleCertificates
+	if c.marbleCerts == nil {
+		c.marbleCerts = make(map[string][]byte)
+	}
+	c.secretVersions = loadedState.SecretVersions
+	if c.secretVersions == nil {
+		c.secretVersions = make(map[string]uint64)
+	}
+	c.rootRotation = loadedState.RootRotation
+	// states sealed before the derivation key was introduced derived the keys from the root private key
+	c.derivationKey = loadedState.DerivationKey
+	if len(c.derivationKey) == 0 {
+		c.derivationKey = rootPrivk.D.Bytes()
+	}
 	c.secrets = loadedState.Secrets
 	c.adminCerts = adminCerts
 
@@ -297,15 +434,77 @@ func (c *Core) sealState(recoveryData []byte) error {
 		State:               c.state,
 		Secrets:             c.secrets,
 		Activations:         c.activations,
+		ManifestHistory:     c.manifestHistory,
+		ActivationLog:       c.activationLog.list(),
+		MarbleProperties:    c.marbleProperties,
+		Leases:              c.leases,
+		MarbleCertificates:  c.marbleCerts,
+		SecretVersions:      c.secretVersions,
+		RootRotation:        c.rootRotation,
+		DerivationKey:       c.derivationKey,
 	}
 	stateRaw, err := json.Marshal(state)
 	if err != nil {
 		return err
 	}
-	return c.sealer.Seal(recoveryData, stateRaw)
+	if err := c.sealer.Seal(recoveryData, stateRaw); err != nil {
+		return err
+	}
+	c.activationLog.unsealed = 0
+	return nil
+}
+
+// parseSubjectAltNames separates IP addresses from DNS names and validates the DNS names. A DNS name may start with a "*." wildcard label.
+func parseSubjectAltNames(names []string) ([]string, []net.IP, error) {
+	var dnsNames []string
+	var ipAddrs []net.IP
+
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if ip := net.ParseIP(name); ip != nil {
+			ipAddrs = append(ipAddrs, ip)
+			continue
+		}
+		if err := validateDNSName(name); err != nil {
+			return nil, nil, err
+		}
+		dnsNames = append(dnsNames, name)
+	}
+
+	return dnsNames, ipAddrs, nil
+}
+
+func validateDNSName(name string) error {
+	if len(name) == 0 || len(name) > 253 {
+		return fmt.Errorf("invalid dns name %q: length must be between 1 and 253", name)
+	}
+
+	labels := strings.Split(strings.TrimSuffix(name, "."), ".")
+	if labels[0] == "*" {
+		if len(labels) < 2 {
+			return fmt.Errorf("invalid dns name %q: wildcard must be followed by a domain", name)
+		}
+		labels = labels[1:]
+	}
+
+	for _, label := range labels {
+		if len(label) == 0 || len(label) > 63 {
+			return fmt.Errorf("invalid dns name %q: label length must be between 1 and 63", name)
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return fmt.Errorf("invalid dns name %q: label must not start or end with a hyphen", name)
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+				return fmt.Errorf("invalid dns name %q: label contains invalid character %q", name, r)
+			}
+		}
+	}
+
+	return nil
 }
 
-func generateCert(dnsNames []string, commonName string, parentCertificate *x509.Certificate, parentPrivateKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+func generateCert(dnsNames []string, ipAddrs []net.IP, commonName string, parentCertificate *x509.Certificate, parentPrivateKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, error) {
 	// Generate private key
 	privk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	if err != nil {
@@ -329,7 +528,7 @@ func generateCert(dnsNames []string, commonName string, parentCertificate *x509.
 			CommonName: commonName,
 		},
 		DNSNames:    dnsNames,
-		IPAddresses: util.DefaultCertificateIPAddresses,
+		IPAddresses: ipAddrs,
 		NotBefore:   notBefore,
 		NotAfter:    notAfter,
 
@@ -392,6 +591,12 @@ func (c *Core) getStatus(ctx context.Context) (int, string, error) {
 		status = "Coordinator is ready to accept a manifest."
 	case stateAcceptingMarbles:
 		status = "Coordinator is running correctly and ready to accept marbles."
+		if c.maintenance {
+			status = "Coordinator is in maintenance mode. Activated marbles keep working, but new marbles are refused until the maintenance mode is left."
+		}
+		if c.rootRotation != nil && c.rootRotation.InTransition(time.Now()) {
+			status += " The root certificate was rotated, certificates issued before the rotation remain valid until " + c.rootRotation.TransitionEnd.Format(time.RFC3339) + "."
+		}
 	default:
 		return -1, "Cannot determine coordinator status.", errors.New("cannot determine coordinator status")
 	}
@@ -421,7 +626,7 @@ func (c *Core) generateSecrets(ctx context.Context, secrets map[string]manifest.
 			}
 
 			var generatedValue []byte
-			// If a secret is shared, we generate a completely random key. If a secret is constrained to a marble, we derive a key from the core's private key.
+			// If a secret is shared, we generate a completely random key. If a secret is constrained to a marble, we derive a key from the core's derivation key.
 			if secret.Shared {
 				generatedValue = make([]byte, secret.Size/8)
 				_, err := rand.Read(generatedValue)
@@ -430,9 +635,8 @@ func (c *Core) generateSecrets(ctx context.Context, secrets map[string]manifest.
 				}
 			} else {
 				salt := id.String() + name
-				secretKeyDerive := c.rootPrivK.D.Bytes()
 				var err error
-				generatedValue, err = util.DeriveKey(secretKeyDerive, []byte(salt), secret.Size/8)
+				generatedValue, err = util.DeriveKey(c.derivationKey, []byte(salt), secret.Size/8)
 				if err != nil {
 					return nil, err
 				}
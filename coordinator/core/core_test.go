@@ -8,6 +8,8 @@ package core
 
 import (
 	"context"
+	"net"
+	"strings"
 	"testing"
 
 	"github.com/edgelesssys/marblerun/coordinator/manifest"
@@ -47,6 +49,36 @@ func TestCore(t *testing.T) {
 	assert.Error(err)
 }
 
+func TestSubjectAltNames(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dnsNames, ipAddrs, err := parseSubjectAltNames([]string{"localhost", "*.example.com", " coordinator.marblerun ", "192.0.2.1", "2001:db8::1"})
+	require.NoError(err)
+	assert.Equal([]string{"localhost", "*.example.com", "coordinator.marblerun"}, dnsNames)
+	assert.Equal([]net.IP{net.ParseIP("192.0.2.1"), net.ParseIP("2001:db8::1")}, ipAddrs)
+
+	malformed := []string{"", "*", "foo.*.example.com", "*.*.example.com", "-foo.example.com", "foo-.example.com", "foo..example.com", "foo_bar.example.com", "192.0.2.1:4433", strings.Repeat("a", 64) + ".com"}
+	for _, name := range malformed {
+		_, _, err := parseSubjectAltNames([]string{"localhost", name})
+		assert.Error(err, name)
+	}
+
+	// the coordinator certificate covers wildcards and IP addresses
+	zapLogger, err := zap.NewDevelopment()
+	require.NoError(err)
+	c, err := NewCore([]string{"*.example.com", "192.0.2.1"}, quote.NewMockValidator(), quote.NewMockIssuer(), &MockSealer{}, recovery.NewSinglePartyRecovery(), zapLogger)
+	require.NoError(err)
+	assert.NoError(c.rootCert.VerifyHostname("coordinator.example.com"))
+	assert.NoError(c.rootCert.VerifyHostname("192.0.2.1"))
+	assert.NoError(c.intermediateCert.VerifyHostname("192.0.2.1"))
+	assert.NoError(c.rootCert.VerifyHostname("127.0.0.1"))
+	assert.Error(c.rootCert.VerifyHostname("example.org"))
+
+	_, err = NewCore([]string{"foo_bar"}, quote.NewMockValidator(), quote.NewMockIssuer(), &MockSealer{}, recovery.NewSinglePartyRecovery(), zapLogger)
+	assert.Error(err)
+}
+
 func TestSeal(t *testing.T) {
 	assert := assert.New(t)
 	require := require.New(t)
@@ -0,0 +1,104 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// eventBufferSize is the number of recent events kept in memory
+const eventBufferSize = 1000
+
+// Event is an entry of the Coordinator's in-memory event log, e.g., a marble activation or an error
+type Event struct {
+	Timestamp  time.Time
+	Level      string
+	Message    string
+	MarbleType string `json:",omitempty"`
+	UUID       string `json:",omitempty"`
+}
+
+// The levels of events
+const (
+	EventLevelInfo  = "info"
+	EventLevelError = "error"
+)
+
+// eventBuffer is a ring buffer holding the most recent events, which are also passed to subscribers as they arrive
+type eventBuffer struct {
+	mux         sync.Mutex
+	events      []Event
+	next        int
+	full        bool
+	subscribers map[chan Event]struct{}
+}
+
+func newEventBuffer(capacity int) *eventBuffer {
+	return &eventBuffer{
+		events:      make([]Event, capacity),
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// add stores an event, evicting the oldest one if the buffer is full.
+// Subscribers which are not ready to receive the event miss it, so a slow client can't block the Coordinator.
+func (b *eventBuffer) add(event Event) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.events[b.next] = event
+	b.next = (b.next + 1) % len(b.events)
+	if b.next == 0 {
+		b.full = true
+	}
+
+	for subscriber := range b.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+		}
+	}
+}
+
+// recent returns the buffered events, oldest first. The caller must hold the lock.
+func (b *eventBuffer) recent() []Event {
+	if !b.full {
+		return append([]Event(nil), b.events[:b.next]...)
+	}
+	return append(append([]Event(nil), b.events[b.next:]...), b.events[:b.next]...)
+}
+
+// subscribe returns the buffered events and a channel receiving the events added afterwards
+func (b *eventBuffer) subscribe() ([]Event, chan Event) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	live := make(chan Event, eventBufferSize)
+	b.subscribers[live] = struct{}{}
+	return b.recent(), live
+}
+
+// unsubscribe stops passing events to the channel and closes it
+func (b *eventBuffer) unsubscribe(live chan Event) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	delete(b.subscribers, live)
+	close(live)
+}
+
+// SubscribeEvents returns the recent events of the Coordinator and a channel receiving new events until ctx is done
+func (c *Core) SubscribeEvents(ctx context.Context) ([]Event, <-chan Event) {
+	buffered, live := c.events.subscribe()
+	go func() {
+		<-ctx.Done()
+		c.events.unsubscribe(live)
+	}()
+	return buffered, live
+}
@@ -0,0 +1,57 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventBufferEviction(t *testing.T) {
+	assert := assert.New(t)
+
+	b := newEventBuffer(3)
+	buffered, _ := b.subscribe()
+	assert.Empty(buffered)
+
+	b.add(Event{Message: "0"})
+	b.add(Event{Message: "1"})
+	buffered, _ = b.subscribe()
+	assert.Equal([]Event{{Message: "0"}, {Message: "1"}}, buffered)
+
+	// the oldest events are evicted once the buffer is full
+	for i := 2; i < 5; i++ {
+		b.add(Event{Message: strconv.Itoa(i)})
+	}
+	buffered, _ = b.subscribe()
+	assert.Equal([]Event{{Message: "2"}, {Message: "3"}, {Message: "4"}}, buffered)
+}
+
+func TestSubscribeEvents(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	c := NewCoreWithMocks()
+	c.events.add(Event{Message: "old"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	buffered, live := c.SubscribeEvents(ctx)
+	assert.Equal([]Event{{Message: "old"}}, buffered)
+
+	c.events.add(Event{Message: "new"})
+	assert.Equal(Event{Message: "new"}, <-live)
+
+	// the channel is closed once the context is done
+	cancel()
+	_, ok := <-live
+	require.False(ok)
+	c.events.add(Event{Message: "after"})
+}
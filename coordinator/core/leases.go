@@ -0,0 +1,150 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/edgelesssys/marblerun/coordinator/rpc"
+	"go.uber.org/zap"
+)
+
+// ErrUnknownMarble is returned if no marble was activated with the given UUID
+var ErrUnknownMarble = errors.New("no marble was activated with the given UUID")
+
+// MarbleLease is the identity of an activated marble. A revoked lease can't be used to activate again.
+// A lease expires with the certificate issued to the marble.
+type MarbleLease struct {
+	MarbleType  string
+	UUID        string
+	ActivatedAt time.Time
+	ExpiresAt   time.Time
+	Revoked     bool
+	RevokedAt   *time.Time `json:",omitempty"`
+}
+
+// expired checks if the marble's certificate expired at the given time. Leases recorded without an expiry don't expire.
+func (l MarbleLease) expired(now time.Time) bool {
+	return !l.ExpiresAt.IsZero() && now.After(l.ExpiresAt)
+}
+
+// recordLease records the lease of a successfully activated marble and the certificate issued to it,
+// and prunes the leases which expired meanwhile. The caller must hold the lock.
+func (c *Core) recordLease(req *rpc.ActivationReq, cert *x509.Certificate) {
+	now := time.Now()
+	c.leases[req.GetUUID()] = MarbleLease{
+		MarbleType:  req.GetMarbleType(),
+		UUID:        req.GetUUID(),
+		ActivatedAt: now.UTC(),
+		ExpiresAt:   cert.NotAfter.UTC(),
+	}
+	c.marbleCerts[req.GetUUID()] = cert.Raw
+	c.pruneLeases(now)
+}
+
+// pruneLeases drops the expired leases together with the certificates and properties recorded for the marbles.
+// Revoked leases are kept, so the marbles still can't activate again. The caller must hold the lock.
+func (c *Core) pruneLeases(now time.Time) {
+	for marbleUUID, lease := range c.leases {
+		if lease.Revoked || !lease.expired(now) {
+			continue
+		}
+		delete(c.leases, marbleUUID)
+		delete(c.marbleCerts, marbleUUID)
+		delete(c.marbleProperties, marbleUUID)
+	}
+}
+
+// isRevoked checks if the lease of the marble with the given UUID was revoked. The caller must hold the lock.
+func (c *Core) isRevoked(marbleUUID string) bool {
+	lease, ok := c.leases[marbleUUID]
+	return ok && lease.Revoked
+}
+
+// GetMarbleLeases returns the leases of all activated marbles which haven't expired, including revoked ones
+func (c *Core) GetMarbleLeases(ctx context.Context) []MarbleLease {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	now := time.Now()
+	leases := make([]MarbleLease, 0, len(c.leases))
+	for _, lease := range c.leases {
+		if !lease.expired(now) {
+			leases = append(leases, lease)
+		}
+	}
+	sort.Slice(leases, func(i, j int) bool {
+		if leases[i].MarbleType != leases[j].MarbleType {
+			return leases[i].MarbleType < leases[j].MarbleType
+		}
+		return leases[i].UUID < leases[j].UUID
+	})
+	return leases
+}
+
+// GetMarbleCertificate returns the certificate issued to the marble with the given UUID during its latest activation.
+// ErrUnknownMarble is returned if no marble was activated with the UUID, or its lease was revoked or expired.
+func (c *Core) GetMarbleCertificate(ctx context.Context, marbleUUID string) (*x509.Certificate, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	lease, ok := c.leases[marbleUUID]
+	if !ok || lease.Revoked || lease.expired(time.Now()) {
+		return nil, ErrUnknownMarble
+	}
+	// marbles activated by a previous version of the coordinator have no recorded certificate
+	certRaw, ok := c.marbleCerts[marbleUUID]
+	if !ok {
+		return nil, ErrUnknownMarble
+	}
+	return x509.ParseCertificate(certRaw)
+}
+
+// RevokeMarble revokes the lease of the marble with the given UUID, so future activations with it are denied
+func (c *Core) RevokeMarble(ctx context.Context, marbleUUID string) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	lease, ok := c.leases[marbleUUID]
+	if !ok {
+		return ErrUnknownMarble
+	}
+	if lease.Revoked {
+		return nil
+	}
+
+	revokedAt := time.Now().UTC()
+	lease.Revoked = true
+	lease.RevokedAt = &revokedAt
+	c.leases[marbleUUID] = lease
+	// a revoked marble can't activate again, so it is not considered when evaluating manifests
+	delete(c.marbleProperties, marbleUUID)
+	delete(c.marbleCerts, marbleUUID)
+
+	recoveryData, err := c.recovery.GetRecoveryData()
+	if err != nil {
+		return err
+	}
+	if err := c.sealState(recoveryData); err != nil {
+		c.zaplogger.Error("Could not seal the revoked marble lease.", zap.Error(err))
+		return err
+	}
+
+	c.zaplogger.Info("Revoked marble lease", zap.String("MarbleType", lease.MarbleType), zap.String("UUID", marbleUUID))
+	c.events.add(Event{
+		Timestamp:  revokedAt,
+		Level:      EventLevelInfo,
+		Message:    "marble lease revoked",
+		MarbleType: lease.MarbleType,
+		UUID:       marbleUUID,
+	})
+	return nil
+}
@@ -0,0 +1,214 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	libMarble "github.com/edgelesssys/ego/marble"
+	"github.com/edgelesssys/marblerun/coordinator/manifest"
+	"github.com/edgelesssys/marblerun/coordinator/quote"
+	"github.com/edgelesssys/marblerun/coordinator/recovery"
+	"github.com/edgelesssys/marblerun/coordinator/rpc"
+	"github.com/edgelesssys/marblerun/test"
+	"github.com/edgelesssys/marblerun/util"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestMarbleLeases(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var mnf manifest.Manifest
+	require.NoError(json.Unmarshal([]byte(test.ManifestJSON), &mnf))
+
+	validator := quote.NewMockValidator()
+	issuer := quote.NewMockIssuer()
+	sealer := &MockSealer{}
+	coreServer, err := NewCore([]string{"localhost"}, validator, issuer, sealer, recovery.NewSinglePartyRecovery(), zap.NewNop())
+	require.NoError(err)
+	_, err = coreServer.SetManifest(context.TODO(), []byte(test.ManifestJSON))
+	require.NoError(err)
+
+	activate := func(marbleType string, marbleUUID string) error {
+		cert, csr, _ := util.MustGenerateTestMarbleCredentials()
+		marbleQuote, err := issuer.Issue(cert.Raw)
+		require.NoError(err)
+		pkg := mnf.Packages[mnf.Marbles[marbleType].Package]
+		validator.AddValidQuote(marbleQuote, cert.Raw, pkg, mnf.Infrastructures["Azure"])
+
+		ctx := peer.NewContext(context.TODO(), &peer.Peer{
+			AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}},
+		})
+		_, err = coreServer.Activate(ctx, &rpc.ActivationReq{
+			CSR:        csr,
+			MarbleType: marbleType,
+			Quote:      marbleQuote,
+			UUID:       marbleUUID,
+		})
+		return err
+	}
+
+	frontendUUID := uuid.New().String()
+	backendUUID := uuid.New().String()
+	require.NoError(activate("frontend", frontendUUID))
+	require.NoError(activate("backend_first", backendUUID))
+
+	// listing returns the active marbles
+	leases := coreServer.GetMarbleLeases(context.TODO())
+	require.Len(leases, 2)
+	assert.True(leases[0].ExpiresAt.After(time.Now()))
+	assert.Equal("backend_first", leases[0].MarbleType)
+	assert.Equal(backendUUID, leases[0].UUID)
+	assert.Equal("frontend", leases[1].MarbleType)
+	assert.Equal(frontendUUID, leases[1].UUID)
+	assert.False(leases[1].Revoked)
+
+	// revoking a UUID denies future activations with it
+	require.NoError(coreServer.RevokeMarble(context.TODO(), frontendUUID))
+	err = activate("frontend", frontendUUID)
+	assert.Equal(codes.PermissionDenied, status.Code(err))
+	assert.NoError(activate("frontend", uuid.New().String()))
+
+	leases = coreServer.GetMarbleLeases(context.TODO())
+	require.Len(leases, 3)
+	for _, lease := range leases {
+		assert.Equal(lease.UUID == frontendUUID, lease.Revoked)
+	}
+
+	// unknown UUIDs can't be revoked
+	assert.Equal(ErrUnknownMarble, coreServer.RevokeMarble(context.TODO(), uuid.New().String()))
+
+	// revocations are persisted
+	coreServer2, err := NewCore([]string{"localhost"}, validator, issuer, sealer, recovery.NewSinglePartyRecovery(), zap.NewNop())
+	require.NoError(err)
+	assert.True(coreServer2.isRevoked(frontendUUID))
+	assert.False(coreServer2.isRevoked(backendUUID))
+}
+
+func TestGetMarbleCertificate(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var mnf manifest.Manifest
+	require.NoError(json.Unmarshal([]byte(test.ManifestJSON), &mnf))
+
+	validator := quote.NewMockValidator()
+	issuer := quote.NewMockIssuer()
+	sealer := &MockSealer{}
+	coreServer, err := NewCore([]string{"localhost"}, validator, issuer, sealer, recovery.NewSinglePartyRecovery(), zap.NewNop())
+	require.NoError(err)
+	_, err = coreServer.SetManifest(context.TODO(), []byte(test.ManifestJSON))
+	require.NoError(err)
+
+	cert, csr, _ := util.MustGenerateTestMarbleCredentials()
+	marbleQuote, err := issuer.Issue(cert.Raw)
+	require.NoError(err)
+	validator.AddValidQuote(marbleQuote, cert.Raw, mnf.Packages["frontend"], mnf.Infrastructures["Azure"])
+	ctx := peer.NewContext(context.TODO(), &peer.Peer{
+		AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}},
+	})
+	marbleUUID := uuid.New().String()
+	resp, err := coreServer.Activate(ctx, &rpc.ActivationReq{CSR: csr, MarbleType: "frontend", Quote: marbleQuote, UUID: marbleUUID})
+	require.NoError(err)
+
+	// the certificate issued during the activation is returned
+	marbleCert, err := coreServer.GetMarbleCertificate(context.TODO(), marbleUUID)
+	require.NoError(err)
+	assert.Equal(marbleUUID, marbleCert.Subject.CommonName)
+	assert.Contains(resp.GetParameters().Env[libMarble.MarbleEnvironmentCertificateChain], string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: marbleCert.Raw})))
+	assert.NoError(marbleCert.CheckSignatureFrom(coreServer.intermediateCert))
+
+	// the certificate is persisted
+	coreServer2, err := NewCore([]string{"localhost"}, validator, issuer, sealer, recovery.NewSinglePartyRecovery(), zap.NewNop())
+	require.NoError(err)
+	persistedCert, err := coreServer2.GetMarbleCertificate(context.TODO(), marbleUUID)
+	require.NoError(err)
+	assert.Equal(marbleCert.Raw, persistedCert.Raw)
+
+	// unknown UUIDs have no certificate
+	_, err = coreServer.GetMarbleCertificate(context.TODO(), uuid.New().String())
+	assert.Equal(ErrUnknownMarble, err)
+
+	// neither have revoked ones
+	require.NoError(coreServer.RevokeMarble(context.TODO(), marbleUUID))
+	_, err = coreServer.GetMarbleCertificate(context.TODO(), marbleUUID)
+	assert.Equal(ErrUnknownMarble, err)
+}
+
+func TestPruneMarbleLeases(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var mnf manifest.Manifest
+	require.NoError(json.Unmarshal([]byte(test.ManifestJSON), &mnf))
+
+	validator := quote.NewMockValidator()
+	issuer := quote.NewMockIssuer()
+	coreServer, err := NewCore([]string{"localhost"}, validator, issuer, &MockSealer{}, recovery.NewSinglePartyRecovery(), zap.NewNop())
+	require.NoError(err)
+	_, err = coreServer.SetManifest(context.TODO(), []byte(test.ManifestJSON))
+	require.NoError(err)
+
+	activate := func(marbleUUID string) {
+		cert, csr, _ := util.MustGenerateTestMarbleCredentials()
+		marbleQuote, err := issuer.Issue(cert.Raw)
+		require.NoError(err)
+		validator.AddValidQuote(marbleQuote, cert.Raw, mnf.Packages["frontend"], mnf.Infrastructures["Azure"])
+		ctx := peer.NewContext(context.TODO(), &peer.Peer{
+			AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}},
+		})
+		_, err = coreServer.Activate(ctx, &rpc.ActivationReq{CSR: csr, MarbleType: "frontend", Quote: marbleQuote, UUID: marbleUUID})
+		require.NoError(err)
+	}
+	expire := func(marbleUUID string) {
+		lease := coreServer.leases[marbleUUID]
+		lease.ExpiresAt = time.Now().Add(-time.Minute)
+		coreServer.leases[marbleUUID] = lease
+		coreServer.marbleProperties[marbleUUID] = MarbleProperties{MarbleType: lease.MarbleType, UUID: marbleUUID}
+	}
+
+	expiredUUID := uuid.New().String()
+	revokedUUID := uuid.New().String()
+	liveUUID := uuid.New().String()
+	activate(expiredUUID)
+	activate(revokedUUID)
+	activate(liveUUID)
+	require.NoError(coreServer.RevokeMarble(context.TODO(), revokedUUID))
+	expire(expiredUUID)
+	expire(revokedUUID)
+
+	// expired leases are neither listed nor have a certificate
+	leases := coreServer.GetMarbleLeases(context.TODO())
+	require.Len(leases, 1)
+	assert.Equal(liveUUID, leases[0].UUID)
+	_, err = coreServer.GetMarbleCertificate(context.TODO(), expiredUUID)
+	assert.Equal(ErrUnknownMarble, err)
+
+	// the next activation prunes expired leases with their certificates and properties, but keeps revoked ones
+	activate(uuid.New().String())
+	assert.NotContains(coreServer.leases, expiredUUID)
+	assert.NotContains(coreServer.marbleCerts, expiredUUID)
+	assert.NotContains(coreServer.marbleProperties, expiredUUID)
+	assert.True(coreServer.isRevoked(revokedUUID))
+	assert.Contains(coreServer.leases, liveUUID)
+	assert.Contains(coreServer.marbleCerts, liveUUID)
+	assert.Len(coreServer.GetMarbleLeases(context.TODO()), 2)
+}
@@ -0,0 +1,40 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"context"
+	"time"
+)
+
+// SetMaintenance enters or leaves the maintenance mode, e.g., to drain the Coordinator before an upgrade.
+//
+// In maintenance mode, activations are refused, while marbles which are already activated and the client API are not affected.
+// The mode is not sealed, so a restarted Coordinator accepts activations again.
+func (c *Core) SetMaintenance(ctx context.Context, enabled bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if c.maintenance == enabled {
+		return
+	}
+	c.maintenance = enabled
+
+	message := "left maintenance mode"
+	if enabled {
+		message = "entered maintenance mode"
+	}
+	c.zaplogger.Info("Coordinator " + message)
+	c.events.add(Event{Timestamp: time.Now().UTC(), Level: EventLevelInfo, Message: message})
+}
+
+// InMaintenance checks if the Coordinator is in maintenance mode
+func (c *Core) InMaintenance(ctx context.Context) bool {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.maintenance
+}
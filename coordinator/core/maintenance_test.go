@@ -0,0 +1,82 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"testing"
+
+	"github.com/edgelesssys/marblerun/coordinator/manifest"
+	"github.com/edgelesssys/marblerun/coordinator/quote"
+	"github.com/edgelesssys/marblerun/coordinator/recovery"
+	"github.com/edgelesssys/marblerun/coordinator/rpc"
+	"github.com/edgelesssys/marblerun/test"
+	"github.com/edgelesssys/marblerun/util"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestMaintenance(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var mnf manifest.Manifest
+	require.NoError(json.Unmarshal([]byte(test.ManifestJSON), &mnf))
+
+	validator := quote.NewMockValidator()
+	issuer := quote.NewMockIssuer()
+	coreServer, err := NewCore([]string{"localhost"}, validator, issuer, &MockSealer{}, recovery.NewSinglePartyRecovery(), zap.NewNop())
+	require.NoError(err)
+	_, err = coreServer.SetManifest(context.TODO(), []byte(test.ManifestJSON))
+	require.NoError(err)
+
+	activate := func() error {
+		cert, csr, _ := util.MustGenerateTestMarbleCredentials()
+		marbleQuote, err := issuer.Issue(cert.Raw)
+		require.NoError(err)
+		validator.AddValidQuote(marbleQuote, cert.Raw, mnf.Packages["frontend"], mnf.Infrastructures["Azure"])
+		ctx := peer.NewContext(context.TODO(), &peer.Peer{
+			AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}},
+		})
+		_, err = coreServer.Activate(ctx, &rpc.ActivationReq{CSR: csr, MarbleType: "frontend", Quote: marbleQuote, UUID: uuid.New().String()})
+		return err
+	}
+
+	require.NoError(activate())
+	assert.False(coreServer.InMaintenance(context.TODO()))
+
+	// activations are refused in maintenance mode
+	coreServer.SetMaintenance(context.TODO(), true)
+	assert.True(coreServer.InMaintenance(context.TODO()))
+	err = activate()
+	require.Error(err)
+	assert.Equal(codes.Unavailable, status.Code(err))
+
+	// the client API keeps working
+	statusCode, statusMessage, err := coreServer.GetStatus(context.TODO())
+	require.NoError(err)
+	assert.Equal(int(stateAcceptingMarbles), statusCode)
+	assert.Contains(statusMessage, "maintenance mode")
+	assert.Len(coreServer.GetMarbleLeases(context.TODO()), 1)
+
+	// activations are accepted again after leaving the maintenance mode
+	coreServer.SetMaintenance(context.TODO(), false)
+	assert.False(coreServer.InMaintenance(context.TODO()))
+	require.NoError(activate())
+	_, statusMessage, err = coreServer.GetStatus(context.TODO())
+	require.NoError(err)
+	assert.NotContains(statusMessage, "maintenance mode")
+}
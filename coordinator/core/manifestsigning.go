@@ -0,0 +1,83 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// ErrManifestNotSigned is returned when setting a manifest without signature on a Coordinator pinned to a manifest signing key
+var ErrManifestNotSigned = errors.New("the coordinator only accepts manifests signed by its manifest signing key, but the manifest is not signed")
+
+// ErrInvalidManifestSignature is returned when the signature of a manifest does not verify against the manifest signing key
+var ErrInvalidManifestSignature = errors.New("manifest signature does not match the manifest signing key of the coordinator")
+
+// ParseManifestSigningKey parses a base64 encoded public key in PKIX, ASN.1 DER form.
+// RSA, ECDSA, and Ed25519 keys are supported.
+func ParseManifestSigningKey(encodedKey string) (crypto.PublicKey, error) {
+	rawKey, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest signing key: %v", err)
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(rawKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest signing key: %v", err)
+	}
+	switch publicKey.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+		return publicKey, nil
+	default:
+		return nil, fmt.Errorf("unsupported type of manifest signing key: %T", publicKey)
+	}
+}
+
+// SetManifestSigningKey pins the Coordinator to a manifest signing key.
+// Afterwards, SetManifest only accepts manifests with a signature created with the corresponding private key.
+// It must be called before the Coordinator starts serving clients.
+func (c *Core) SetManifestSigningKey(publicKey crypto.PublicKey) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.manifestSigningKey = publicKey
+}
+
+// verifyManifestSignature checks the signature of a manifest if the Coordinator is pinned to a manifest signing key. The caller must hold the lock.
+func (c *Core) verifyManifestSignature(rawManifest []byte, signature []byte) error {
+	if c.manifestSigningKey == nil {
+		return nil
+	}
+	if len(signature) == 0 {
+		return ErrManifestNotSigned
+	}
+	if checkSignature(c.manifestSigningKey, rawManifest, signature) != nil {
+		return ErrInvalidManifestSignature
+	}
+	return nil
+}
+
+// checkSignature verifies a signature over the SHA-256 hash of data (or over data itself for Ed25519), created with the private key of publicKey
+func checkSignature(publicKey crypto.PublicKey, data []byte, signature []byte) error {
+	var algorithm x509.SignatureAlgorithm
+	switch publicKey.(type) {
+	case *rsa.PublicKey:
+		algorithm = x509.SHA256WithRSA
+	case *ecdsa.PublicKey:
+		algorithm = x509.ECDSAWithSHA256
+	case ed25519.PublicKey:
+		algorithm = x509.PureEd25519
+	default:
+		return x509.ErrUnsupportedAlgorithm
+	}
+	cert := &x509.Certificate{PublicKey: publicKey}
+	return cert.CheckSignature(algorithm, data, signature)
+}
@@ -0,0 +1,85 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"testing"
+
+	"github.com/edgelesssys/marblerun/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetSignedManifest(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	privKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(err)
+	sign := func(key crypto.Signer, data []byte) []byte {
+		hash := sha256.Sum256(data)
+		signature, err := key.Sign(rand.Reader, hash[:], crypto.SHA256)
+		require.NoError(err)
+		return signature
+	}
+
+	c := NewCoreWithMocks()
+	c.SetManifestSigningKey(privKey.Public())
+
+	// unsigned manifest
+	_, err = c.SetManifest(context.TODO(), []byte(test.ManifestJSON))
+	assert.Equal(ErrManifestNotSigned, err)
+
+	// signed by another key
+	_, err = c.SetSignedManifest(context.TODO(), []byte(test.ManifestJSON), sign(otherKey, []byte(test.ManifestJSON)))
+	assert.Equal(ErrInvalidManifestSignature, err)
+
+	// signature over another manifest
+	_, err = c.SetSignedManifest(context.TODO(), []byte(test.ManifestJSON), sign(privKey, []byte(test.ManifestJSONWithRecoveryKey)))
+	assert.Equal(ErrInvalidManifestSignature, err)
+	assert.Empty(c.GetManifestSignature(context.TODO()))
+
+	// correctly signed manifest
+	_, err = c.SetSignedManifest(context.TODO(), []byte(test.ManifestJSON), sign(privKey, []byte(test.ManifestJSON)))
+	require.NoError(err)
+	assert.Equal([]byte(test.ManifestJSON), c.GetManifest(context.TODO()))
+
+	// any manifest is accepted without a manifest signing key
+	c = NewCoreWithMocks()
+	_, err = c.SetManifest(context.TODO(), []byte(test.ManifestJSON))
+	assert.NoError(err)
+}
+
+func TestParseManifestSigningKey(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	pubKey, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(err)
+	rawKey, err := x509.MarshalPKIXPublicKey(pubKey)
+	require.NoError(err)
+
+	parsed, err := ParseManifestSigningKey(base64.StdEncoding.EncodeToString(rawKey))
+	require.NoError(err)
+	assert.Equal(pubKey, parsed)
+
+	_, err = ParseManifestSigningKey("invalid")
+	assert.Error(err)
+	_, err = ParseManifestSigningKey(base64.StdEncoding.EncodeToString([]byte("invalid")))
+	assert.Error(err)
+}
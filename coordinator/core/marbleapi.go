@@ -15,7 +15,10 @@ import (
 	"crypto/x509"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
+	"fmt"
 	"math"
+	"os"
 	"text/template"
 	"time"
 
@@ -25,11 +28,17 @@ import (
 	"github.com/edgelesssys/marblerun/coordinator/rpc"
 	"github.com/edgelesssys/marblerun/util"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
+const tracerName = "github.com/edgelesssys/marblerun/coordinator/core"
+
 type reservedSecrets struct {
 	RootCA     manifest.Secret
 	MarbleCert manifest.Secret
@@ -48,23 +57,40 @@ type secretsWrapper struct {
 //
 // req needs to contain a MarbleType present in the Coordinator's manifest and a CSR with the Subject and DNSNames set with desired values.
 //
+// A marble activating again with the UUID of a previous activation, e.g., after the Coordinator restarted,
+// must present a fresh quote as well, but does not consume another activation of its marble type (see isReactivation).
+//
 // Returns a signed certificate-key-pair and the application's parameters if the authentication was successful.
 // Returns an error if the authentication failed.
-func (c *Core) Activate(ctx context.Context, req *rpc.ActivationReq) (*rpc.ActivationResp, error) {
+func (c *Core) Activate(ctx context.Context, req *rpc.ActivationReq) (resp *rpc.ActivationResp, err error) {
 	c.zaplogger.Info("Received activation request", zap.String("MarbleType", req.MarbleType))
+	defer c.observeActivationDuration(ctx, time.Now())
 	defer c.mux.Unlock()
 	if err := c.requireState(stateAcceptingMarbles); err != nil {
 		return nil, status.Error(codes.FailedPrecondition, "cannot accept marbles in current state")
 	}
+	// runs before the lock is released
+	authenticated := false
+	defer func() { c.recordActivation(req, err, authenticated) }()
+
+	if c.maintenance {
+		return nil, status.Error(codes.Unavailable, "coordinator is in maintenance mode and refuses activations, retry after the maintenance")
+	}
+	if c.isRevoked(req.GetUUID()) {
+		return nil, status.Error(codes.PermissionDenied, "marble UUID has been revoked")
+	}
 
 	// get the marble's TLS cert (used in this connection) and check corresponding quote
 	tlsCert := getClientTLSCert(ctx)
 	if tlsCert == nil {
 		return nil, status.Error(codes.Unauthenticated, "couldn't get marble TLS certificate")
 	}
-	if err := c.verifyManifestRequirement(tlsCert, req.GetQuote(), req.GetMarbleType()); err != nil {
+	// marbles re-activating with the UUID of a previous activation don't consume another activation
+	reactivation := c.isReactivation(req)
+	if err := c.verifyManifestRequirement(ctx, tlsCert, req.GetQuote(), req.GetMarbleType(), reactivation); err != nil {
 		return nil, err
 	}
+	authenticated = true
 
 	marbleUUID, err := uuid.Parse(req.GetUUID())
 	if err != nil {
@@ -101,19 +127,37 @@ func (c *Core) Activate(ctx context.Context, req *rpc.ActivationReq) (*rpc.Activ
 		c.zaplogger.Error("Could not customize parameters.", zap.Error(err))
 		return nil, err
 	}
+	if c.rootRotation != nil && c.rootRotation.InTransition(time.Now()) {
+		c.rootRotation.addMarbleTrust(params)
+	}
+
+	// The activation only takes effect if the client is still waiting for it. Otherwise, it is discarded without changing the state.
+	if err := ctx.Err(); err != nil {
+		c.zaplogger.Warn("Activation aborted", zap.String("MarbleType", req.MarbleType), zap.Error(err))
+		if err == context.DeadlineExceeded {
+			return nil, status.Error(codes.DeadlineExceeded, "activation timed out")
+		}
+		return nil, status.Error(codes.Canceled, "activation canceled")
+	}
 
 	// write response
-	resp := &rpc.ActivationResp{
+	resp = &rpc.ActivationResp{
 		Parameters: params,
 	}
 
-	c.zaplogger.Info("Successfully activated new Marble", zap.String("MarbleType", req.MarbleType), zap.String("UUID", marbleUUID.String()))
-	c.activations[req.GetMarbleType()]++
+	if reactivation {
+		c.zaplogger.Info("Successfully re-activated Marble", zap.String("MarbleType", req.MarbleType), zap.String("UUID", marbleUUID.String()))
+	} else {
+		c.zaplogger.Info("Successfully activated new Marble", zap.String("MarbleType", req.MarbleType), zap.String("UUID", marbleUUID.String()))
+		c.activations[req.GetMarbleType()]++
+	}
+	c.recordMarbleProperties(req)
+	c.recordLease(req, (*x509.Certificate)(&authSecrets.MarbleCert.Cert))
 	return resp, nil
 }
 
-// verifyManifestRequirement verifies marble attempting to register with respect to manifest
-func (c *Core) verifyManifestRequirement(tlsCert *x509.Certificate, certQuote []byte, marbleType string) error {
+// verifyManifestRequirement verifies marble attempting to register with respect to manifest. The activation budget is not checked for re-activations.
+func (c *Core) verifyManifestRequirement(ctx context.Context, tlsCert *x509.Certificate, certQuote []byte, marbleType string, reactivation bool) error {
 	marble, ok := c.manifest.Marbles[marbleType]
 	if !ok {
 		return status.Error(codes.InvalidArgument, "unknown marble type requested")
@@ -131,32 +175,58 @@ func (c *Core) verifyManifestRequirement(tlsCert *x509.Certificate, certQuote []
 	}
 
 	if !c.inSimulationMode() {
-		if len(c.manifest.Infrastructures) == 0 {
-			if err := c.qv.Validate(certQuote, tlsCert.Raw, pkg, quote.InfrastructureProperties{}); err != nil {
-				return status.Errorf(codes.Unauthenticated, "invalid quote: %v", err)
-			}
-		} else {
-			infraMatch := false
-			for _, infra := range c.manifest.Infrastructures {
-				if c.qv.Validate(certQuote, tlsCert.Raw, pkg, infra) == nil {
-					infraMatch = true
-					break
-				}
-			}
-			if !infraMatch {
-				return status.Error(codes.Unauthenticated, "invalid quote")
-			}
+		if err := c.validateQuote(ctx, tlsCert, certQuote, marble.Package, pkg); err != nil {
+			return err
 		}
 	}
 
 	// check activation budget (MaxActivations == 0 means infinite budget)
 	activations := c.activations[marbleType]
-	if marble.MaxActivations > 0 && activations >= marble.MaxActivations {
+	if !reactivation && marble.MaxActivations > 0 && activations >= marble.MaxActivations {
 		return status.Error(codes.ResourceExhausted, "reached max activations count for marble type")
 	}
 	return nil
 }
 
+// validateQuote validates the marble's quote against the package and, if defined, the infrastructures in the manifest
+func (c *Core) validateQuote(ctx context.Context, tlsCert *x509.Certificate, certQuote []byte, pkgName string, pkg quote.PackageProperties) error {
+	_, span := otel.Tracer(tracerName).Start(ctx, "ValidateQuote", trace.WithAttributes(attribute.String("marblerun.package", pkgName)))
+	defer span.End()
+
+	// an empty quote is sent by marbles in simulation mode, it is never valid outside of simulation mode
+	if len(certQuote) == 0 {
+		span.SetStatus(otelcodes.Error, "empty quote")
+		return status.Error(codes.Unauthenticated, "empty quote: marble runs in simulation mode, but the coordinator does not")
+	}
+
+	if len(c.manifest.Infrastructures) == 0 {
+		if err := c.qv.Validate(certQuote, tlsCert.Raw, pkg, quote.InfrastructureProperties{}); err != nil {
+			span.RecordError(err)
+			span.SetStatus(otelcodes.Error, "invalid quote")
+			return status.Errorf(codes.Unauthenticated, "invalid quote: %v", err)
+		}
+		return nil
+	}
+
+	var err error
+	for _, infra := range c.manifest.Infrastructures {
+		err = c.qv.Validate(certQuote, tlsCert.Raw, pkg, infra)
+		if err == nil {
+			return nil
+		}
+		// only the infrastructure can match another entry, other failures are independent of it
+		if !errors.Is(err, quote.ErrInfrastructureNonCompliant) {
+			break
+		}
+	}
+	span.RecordError(err)
+	span.SetStatus(otelcodes.Error, "invalid quote")
+	return status.Errorf(codes.Unauthenticated, "invalid quote: %v", err)
+}
+
+// defaultMarbleDNSName is the DNS name of marbles for which neither the marble nor the manifest defines any
+const defaultMarbleDNSName = "localhost"
+
 // generateCertFromCSR signs the CSR from marble attempting to register
 func (c *Core) generateCertFromCSR(csrReq []byte, pubk ecdsa.PublicKey, marbleType string, marbleUUID string) ([]byte, error) {
 	// parse and verify CSR
@@ -173,12 +243,27 @@ func (c *Core) generateCertFromCSR(csrReq []byte, pubk ecdsa.PublicKey, marbleTy
 		return nil, status.Error(codes.Internal, "failed to generate serial")
 	}
 
+	// marbles which don't request DNS names get those assigned to their type in the manifest
+	dnsNames := csr.DNSNames
+	if len(dnsNames) == 0 {
+		dnsNames = c.manifest.Marbles[marbleType].DNSNames
+	}
+	if len(dnsNames) == 0 {
+		dnsNames = []string{defaultMarbleDNSName}
+	}
+
 	// create certificate
 	csr.Subject.CommonName = marbleUUID
 	csr.Subject.Organization = c.intermediateCert.Issuer.Organization
 	notBefore := time.Now()
-	// TODO: produce shorter lived certificates
 	notAfter := notBefore.Add(math.MaxInt64)
+	validity, _ := c.manifest.Marbles[marbleType].GetCertificateValidity() // validity has been checked in Manifest.Check
+	if validity > 0 {
+		notAfter = notBefore.Add(validity)
+		if notAfter.After(c.intermediateCert.NotAfter) {
+			return nil, status.Error(codes.FailedPrecondition, "certificate validity of the marble type exceeds the remaining validity of the coordinator's certificate")
+		}
+	}
 	template := x509.Certificate{
 		SerialNumber: serialNumber,
 		Subject:      csr.Subject,
@@ -189,7 +274,7 @@ func (c *Core) generateCertFromCSR(csrReq []byte, pubk ecdsa.PublicKey, marbleTy
 		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
 		BasicConstraintsValid: true,
 		IsCA:                  false,
-		DNSNames:              csr.DNSNames,
+		DNSNames:              dnsNames,
 		IPAddresses:           csr.IPAddresses,
 	}
 
@@ -204,9 +289,10 @@ func (c *Core) generateCertFromCSR(csrReq []byte, pubk ecdsa.PublicKey, marbleTy
 // customizeParameters replaces the placeholders in the manifest's parameters with the actual values
 func customizeParameters(params *rpc.Parameters, specialSecrets reservedSecrets, userSecrets map[string]manifest.Secret) (*rpc.Parameters, error) {
 	customParams := rpc.Parameters{
-		Argv:  params.Argv,
-		Files: make(map[string]string),
-		Env:   make(map[string]string),
+		Argv:      params.Argv,
+		Files:     make(map[string]string),
+		Env:       make(map[string]string),
+		FileModes: make(map[string]uint32),
 	}
 
 	// Wrap the authentication secrets to have the "Marblerun" prefix in front of them when mentioned in a manifest
@@ -223,6 +309,12 @@ func customizeParameters(params *rpc.Parameters, specialSecrets reservedSecrets,
 		}
 
 		customParams.Files[path] = newValue
+
+		mode, err := secretsFileMode(data, userSecrets)
+		if err != nil {
+			return nil, err
+		}
+		customParams.FileModes[path] = uint32(mode)
 	}
 
 	for name, data := range params.Env {
@@ -234,6 +326,19 @@ func customizeParameters(params *rpc.Parameters, specialSecrets reservedSecrets,
 		customParams.Env[name] = newValue
 	}
 
+	// inject secrets marked for the environment, but only those the marble's parameters reference
+	referenced := referencedSecrets(params)
+	for name, secret := range userSecrets {
+		if secret.Env == "" || !referenced[name] {
+			continue
+		}
+		newValue, err := parseSecrets(fmt.Sprintf("{{ hex (index .Secrets %q) }}", name), secretsWrapped)
+		if err != nil {
+			return nil, err
+		}
+		customParams.Env[secret.Env] = newValue
+	}
+
 	// Set as environment variables
 	intermediateCaPem, err := manifest.EncodeSecretDataToPem(specialSecrets.RootCA.Cert)
 	if err != nil {
@@ -270,6 +375,56 @@ func parseSecrets(data string, secretsWrapped secretsWrapper) (string, error) {
 	return templateResult.String(), nil
 }
 
+// secretsFileMode returns the permissions of a file generated from the template data.
+// If the file contains multiple secrets, only the permissions granted by all of them are kept.
+func secretsFileMode(data string, userSecrets map[string]manifest.Secret) (os.FileMode, error) {
+	tpl, err := template.New("data").Funcs(manifest.ManifestTemplateFuncMap).Parse(data)
+	if err != nil {
+		return 0, err
+	}
+
+	names := manifest.ReferencedSecrets(tpl.Root)
+	if len(names) == 0 {
+		return manifest.DefaultSecretFileMode, nil
+	}
+
+	mode := os.ModePerm
+	for _, name := range names {
+		secretMode, err := userSecrets[name].GetFileMode()
+		if err != nil {
+			return 0, fmt.Errorf("secret %s: %v", name, err)
+		}
+		mode &= secretMode
+	}
+	return mode, nil
+}
+
+// referencedSecrets returns the names of the user-defined secrets referenced in the templates of the parameters
+func referencedSecrets(params *rpc.Parameters) map[string]bool {
+	names := make(map[string]bool)
+	if params == nil {
+		return names
+	}
+	templates := make([]string, 0, len(params.Files)+len(params.Env))
+	for _, data := range params.Files {
+		templates = append(templates, data)
+	}
+	for _, data := range params.Env {
+		templates = append(templates, data)
+	}
+	for _, data := range templates {
+		tpl, err := template.New("data").Funcs(manifest.ManifestTemplateFuncMap).Parse(data)
+		if err != nil {
+			// invalid templates fail the activation of the marble and don't reference anything
+			continue
+		}
+		for _, name := range manifest.ReferencedSecrets(tpl.Root) {
+			names[name] = true
+		}
+	}
+	return names
+}
+
 func (c *Core) generateMarbleAuthSecrets(req *rpc.ActivationReq, marbleUUID uuid.UUID) (reservedSecrets, error) {
 	// generate key-pair for marble
 	privk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
@@ -290,7 +445,7 @@ func (c *Core) generateMarbleAuthSecrets(req *rpc.ActivationReq, marbleUUID uuid
 	if err != nil {
 		return reservedSecrets{}, err
 	}
-	sealKey, err := util.DeriveKey(c.rootPrivK.D.Bytes(), uuidBytes, 32)
+	sealKey, err := util.DeriveKey(c.derivationKey, uuidBytes, 32)
 	if err != nil {
 		return reservedSecrets{}, err
 	}
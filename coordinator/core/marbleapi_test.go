@@ -10,12 +10,15 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/hex"
 	"encoding/json"
 	"encoding/pem"
 	"math/big"
+	"os"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
@@ -30,9 +33,16 @@ import (
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 )
 
 func TestActivate(t *testing.T) {
@@ -416,6 +426,83 @@ func TestParseSecrets(t *testing.T) {
 	assert.Error(err)
 }
 
+func TestSecretsFileMode(t *testing.T) {
+	secrets := map[string]manifest.Secret{
+		"readonly":  {FileMode: "0400"},
+		"shared":    {FileMode: "0640"},
+		"default":   {},
+		"malformed": {FileMode: "rw-------"},
+	}
+
+	testCases := map[string]struct {
+		data    string
+		mode    os.FileMode
+		wantErr bool
+	}{
+		"no secret":          {data: "some config", mode: 0600},
+		"reserved secret":    {data: "{{ pem .Marblerun.MarbleCert.Private }}", mode: 0600},
+		"default mode":       {data: "{{ raw .Secrets.default }}", mode: 0600},
+		"read-only":          {data: "{{ raw .Secrets.readonly }}", mode: 0400},
+		"group-readable":     {data: "{{ pem .Secrets.shared.Cert }}", mode: 0640},
+		"most restrictive":   {data: "{{ raw .Secrets.shared }}\n{{ if true }}{{ raw .Secrets.readonly }}{{ end }}", mode: 0400},
+		"invalid mode":       {data: "{{ raw .Secrets.malformed }}", wantErr: true},
+		"invalid template":   {data: "{{ raw .Secrets.default", wantErr: true},
+		"not a secret field": {data: "{{ .Secrets }}", mode: 0600},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			mode, err := secretsFileMode(tc.data, secrets)
+			if tc.wantErr {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tc.mode, mode)
+		})
+	}
+}
+
+func TestCustomizeParametersEnvSecrets(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	params := &rpc.Parameters{
+		Files: map[string]string{"/db/password": "{{ raw .Secrets.dbpassword }}"},
+		Env:   map[string]string{"OTHER": "value"},
+	}
+	userSecrets := map[string]manifest.Secret{
+		"dbpassword":  {Type: "symmetric-key", Size: 32, Private: []byte{0xde, 0xad, 0xbe, 0xef}, Public: []byte{0xde, 0xad, 0xbe, 0xef}, Env: "DATABASE_PASSWORD"},
+		"filesecret":  {Type: "symmetric-key", Size: 32, Private: []byte{1, 2, 3, 4}, Public: []byte{1, 2, 3, 4}},
+		"othermarble": {Type: "symmetric-key", Size: 32, Private: []byte{5, 6, 7, 8}, Public: []byte{5, 6, 7, 8}, Env: "OTHER_PASSWORD"},
+	}
+	authSecrets := reservedSecrets{
+		RootCA:     manifest.Secret{Cert: manifest.Certificate{Raw: []byte{1}}},
+		MarbleCert: manifest.Secret{Cert: manifest.Certificate{Raw: []byte{2}}, Private: []byte{3}},
+	}
+
+	customParams, err := customizeParameters(params, authSecrets, userSecrets)
+	require.NoError(err)
+	assert.Equal("deadbeef", customParams.Env["DATABASE_PASSWORD"])
+	assert.Equal("value", customParams.Env["OTHER"])
+	for _, value := range customParams.Env {
+		assert.NotEqual("01020304", value)
+	}
+	// secrets the marble's parameters don't reference are not injected
+	assert.NotContains(customParams.Env, "OTHER_PASSWORD")
+
+	// the manifest must not assign an environment variable twice
+	var mf manifest.Manifest
+	require.NoError(json.Unmarshal([]byte(test.ManifestJSON), &mf))
+	mf.Secrets["symmetric_key_shared"] = manifest.Secret{Type: "symmetric-key", Size: 128, Shared: true, Env: "TEST_SECRET_SYMMETRIC_KEY"}
+	assert.Error(mf.Check(context.TODO(), zap.NewNop()))
+	mf.Secrets["symmetric_key_shared"] = manifest.Secret{Type: "symmetric-key", Size: 128, Shared: true, Env: "DATABASE_PASSWORD"}
+	assert.NoError(mf.Check(context.TODO(), zap.NewNop()))
+	mf.Secrets["cert_shared"] = manifest.Secret{Type: "cert-ed25519", Shared: true, Env: "CERT"}
+	assert.Error(mf.Check(context.TODO(), zap.NewNop()))
+}
+
 func TestSecurityLevelUpdate(t *testing.T) {
 	assert := assert.New(t)
 	require := require.New(t)
@@ -556,3 +643,374 @@ func TestActivateWithMissingParameters(t *testing.T) {
 
 	spawner.shortMarbleActivation("frontend", "Azure", true)
 }
+
+func TestActivateTracing(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+
+	var manifest manifest.Manifest
+	require.NoError(json.Unmarshal([]byte(test.ManifestJSON), &manifest))
+
+	validator := quote.NewMockValidator()
+	issuer := quote.NewMockIssuer()
+	coreServer, err := NewCore([]string{"localhost"}, validator, issuer, &MockSealer{}, recovery.NewSinglePartyRecovery(), zap.NewNop())
+	require.NoError(err)
+	_, err = coreServer.SetManifest(context.TODO(), []byte(test.ManifestJSON))
+	require.NoError(err)
+
+	spawner := marbleSpawner{
+		assert:     assert,
+		require:    require,
+		issuer:     issuer,
+		validator:  validator,
+		manifest:   manifest,
+		coreServer: coreServer,
+	}
+
+	// a valid quote results in a successful validation span
+	spawner.newMarble("frontend", "Azure", true)
+	spans := exporter.GetSpans()
+	require.Len(spans, 1)
+	assert.Equal("ValidateQuote", spans[0].Name)
+	assert.Equal(otelcodes.Unset, spans[0].StatusCode)
+	assert.Contains(spans[0].Attributes, attribute.String("marblerun.package", manifest.Marbles["frontend"].Package))
+
+	// an invalid quote is recorded as an error
+	exporter.Reset()
+	cert, csr, _ := util.MustGenerateTestMarbleCredentials()
+	ctx := peer.NewContext(context.TODO(), &peer.Peer{
+		AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}},
+	})
+	_, err = coreServer.Activate(ctx, &rpc.ActivationReq{
+		CSR:        csr,
+		MarbleType: "frontend",
+		Quote:      []byte("invalid"),
+		UUID:       uuid.New().String(),
+	})
+	assert.Error(err)
+	spans = exporter.GetSpans()
+	require.Len(spans, 1)
+	assert.Equal(otelcodes.Error, spans[0].StatusCode)
+}
+
+// slowValidator delays the validation of quotes
+type slowValidator struct {
+	quote.Validator
+	delay time.Duration
+}
+
+func (v slowValidator) Validate(quote []byte, message []byte, pp quote.PackageProperties, ip quote.InfrastructureProperties) error {
+	time.Sleep(v.delay)
+	return v.Validator.Validate(quote, message, pp, ip)
+}
+
+func TestActivateTimeout(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var manifest manifest.Manifest
+	require.NoError(json.Unmarshal([]byte(test.ManifestJSON), &manifest))
+
+	validator := quote.NewMockValidator()
+	issuer := quote.NewMockIssuer()
+	coreServer, err := NewCore([]string{"localhost"}, slowValidator{validator, 100 * time.Millisecond}, issuer, &MockSealer{}, recovery.NewSinglePartyRecovery(), zap.NewNop())
+	require.NoError(err)
+	_, err = coreServer.SetManifest(context.TODO(), []byte(test.ManifestJSON))
+	require.NoError(err)
+
+	cert, csr, _ := util.MustGenerateTestMarbleCredentials()
+	quote, err := issuer.Issue(cert.Raw)
+	require.NoError(err)
+	validator.AddValidQuote(quote, cert.Raw, manifest.Packages["frontend"], manifest.Infrastructures["Azure"])
+	req := &rpc.ActivationReq{
+		CSR:        csr,
+		MarbleType: "frontend",
+		Quote:      quote,
+		UUID:       uuid.New().String(),
+	}
+	peerCtx := peer.NewContext(context.TODO(), &peer.Peer{
+		AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}},
+	})
+
+	// the validation exceeds the deadline, so the activation is rolled back
+	ctx, cancel := context.WithTimeout(peerCtx, 10*time.Millisecond)
+	defer cancel()
+	resp, err := coreServer.Activate(ctx, req)
+	assert.Equal(codes.DeadlineExceeded, status.Code(err))
+	assert.Nil(resp)
+	assert.Zero(coreServer.activations["frontend"])
+
+	// the same request succeeds with enough time
+	ctx, cancel = context.WithTimeout(peerCtx, 10*time.Second)
+	defer cancel()
+	resp, err = coreServer.Activate(ctx, req)
+	assert.NoError(err)
+	assert.NotNil(resp)
+	assert.EqualValues(1, coreServer.activations["frontend"])
+}
+
+func TestActivateEmptyQuote(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	activate := func(coreServer *Core, validator *quote.MockValidator) error {
+		_, err := coreServer.SetManifest(context.TODO(), []byte(test.ManifestJSON))
+		require.NoError(err)
+
+		var manifest manifest.Manifest
+		require.NoError(json.Unmarshal([]byte(test.ManifestJSON), &manifest))
+		cert, csr, _ := util.MustGenerateTestMarbleCredentials()
+		pkg := manifest.Packages[manifest.Marbles["frontend"].Package]
+		validator.AddValidQuote([]byte{}, cert.Raw, pkg, manifest.Infrastructures["Azure"])
+
+		ctx := peer.NewContext(context.TODO(), &peer.Peer{
+			AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}},
+		})
+		_, err = coreServer.Activate(ctx, &rpc.ActivationReq{
+			CSR:        csr,
+			MarbleType: "frontend",
+			Quote:      []byte{},
+			UUID:       uuid.New().String(),
+		})
+		return err
+	}
+
+	// a coordinator running in an enclave rejects empty quotes, even if the validator would accept them
+	validator := quote.NewMockValidator()
+	coreServer, err := NewCore([]string{"localhost"}, validator, quote.NewMockIssuer(), &MockSealer{}, recovery.NewSinglePartyRecovery(), zap.NewNop())
+	require.NoError(err)
+	err = activate(coreServer, validator)
+	assert.Error(err)
+	assert.Equal(codes.Unauthenticated, status.Code(err))
+
+	// a coordinator in simulation mode accepts them
+	validator = quote.NewMockValidator()
+	coreServer, err = NewCore([]string{"localhost"}, validator, quote.NewSimulationIssuer(), &MockSealer{}, recovery.NewSinglePartyRecovery(), zap.NewNop())
+	require.NoError(err)
+	assert.NoError(activate(coreServer, validator))
+}
+
+func TestActivationLog(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var manifest manifest.Manifest
+	require.NoError(json.Unmarshal([]byte(test.ManifestJSON), &manifest))
+
+	validator := quote.NewMockValidator()
+	issuer := quote.NewMockIssuer()
+	sealer := &MockSealer{}
+	coreServer, err := NewCore([]string{"localhost"}, validator, issuer, sealer, recovery.NewSinglePartyRecovery(), zap.NewNop())
+	require.NoError(err)
+	_, err = coreServer.SetManifest(context.TODO(), []byte(test.ManifestJSON))
+	require.NoError(err)
+
+	spawner := marbleSpawner{
+		assert:     assert,
+		require:    require,
+		issuer:     issuer,
+		validator:  validator,
+		manifest:   manifest,
+		coreServer: coreServer,
+	}
+
+	// successful activation
+	spawner.newMarble("frontend", "Azure", true)
+
+	// failed activation
+	cert, csr, _ := util.MustGenerateTestMarbleCredentials()
+	ctx := peer.NewContext(context.TODO(), &peer.Peer{
+		AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}},
+	})
+	failedUUID := uuid.New().String()
+	_, err = coreServer.Activate(ctx, &rpc.ActivationReq{
+		CSR:        csr,
+		MarbleType: "frontend",
+		Quote:      []byte("invalid"),
+		UUID:       failedUUID,
+	})
+	require.Error(err)
+
+	records, total := coreServer.GetActivationLog(context.TODO(), 0, 10)
+	assert.Equal(2, total)
+	require.Len(records, 2)
+	assert.Equal("frontend", records[0].MarbleType)
+	assert.True(records[0].Success)
+	assert.Empty(records[0].Error)
+	assert.NotEmpty(records[0].UUID)
+	assert.False(records[0].Timestamp.IsZero())
+
+	invalidQuoteHash := sha256.Sum256([]byte("invalid"))
+	assert.Equal("frontend", records[1].MarbleType)
+	assert.Equal(failedUUID, records[1].UUID)
+	assert.Equal(hex.EncodeToString(invalidQuoteHash[:]), records[1].QuoteHash)
+	assert.False(records[1].Success)
+	assert.Contains(records[1].Error, "invalid quote")
+
+	// pagination
+	records, total = coreServer.GetActivationLog(context.TODO(), 1, 10)
+	assert.Equal(2, total)
+	require.Len(records, 1)
+	assert.Equal(failedUUID, records[0].UUID)
+	records, _ = coreServer.GetActivationLog(context.TODO(), 0, 1)
+	assert.Len(records, 1)
+	records, _ = coreServer.GetActivationLog(context.TODO(), 2, 10)
+	assert.Empty(records)
+
+	// failures of unauthenticated marbles are rate-limited
+	for i := 0; i < 2*unauthenticatedRecordBurst; i++ {
+		_, err = coreServer.Activate(ctx, &rpc.ActivationReq{CSR: csr, MarbleType: "frontend", Quote: []byte("invalid"), UUID: failedUUID})
+		require.Error(err)
+	}
+	_, total = coreServer.GetActivationLog(context.TODO(), 0, 0)
+	assert.Less(total, 2+2*unauthenticatedRecordBurst)
+
+	// failed activations are sealed with the next successful one
+	restartedCore, err := NewCore([]string{"localhost"}, validator, issuer, sealer, recovery.NewSinglePartyRecovery(), zap.NewNop())
+	require.NoError(err)
+	_, restartedTotal := restartedCore.GetActivationLog(context.TODO(), 0, 0)
+	assert.Equal(1, restartedTotal)
+	spawner.newMarble("frontend", "Azure", true)
+	restartedCore, err = NewCore([]string{"localhost"}, validator, issuer, sealer, recovery.NewSinglePartyRecovery(), zap.NewNop())
+	require.NoError(err)
+	_, restartedTotal = restartedCore.GetActivationLog(context.TODO(), 0, 0)
+	assert.Equal(total+1, restartedTotal)
+}
+
+func TestActivationLogEviction(t *testing.T) {
+	assert := assert.New(t)
+
+	log := newActivationLog(3, []ActivationRecord{{UUID: "0"}, {UUID: "1"}})
+	assert.Equal([]ActivationRecord{{UUID: "0"}, {UUID: "1"}}, log.list())
+	assert.Zero(log.unsealed)
+
+	// the oldest records are evicted once the log is full
+	for i := 2; i < 5; i++ {
+		log.add(ActivationRecord{UUID: strconv.Itoa(i)})
+	}
+	assert.Equal([]ActivationRecord{{UUID: "2"}, {UUID: "3"}, {UUID: "4"}}, log.list())
+	assert.Equal(3, log.unsealed)
+
+	// restoring a log larger than the capacity keeps the most recent records
+	log = newActivationLog(2, []ActivationRecord{{UUID: "0"}, {UUID: "1"}, {UUID: "2"}})
+	assert.Equal([]ActivationRecord{{UUID: "1"}, {UUID: "2"}}, log.list())
+}
+
+func TestActivateAssignedDNSNames(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var mnf manifest.Manifest
+	require.NoError(json.Unmarshal([]byte(test.ManifestJSON), &mnf))
+	frontend := mnf.Marbles["frontend"]
+	frontend.DNSNames = []string{"frontend.example.com", "frontend"}
+	mnf.Marbles["frontend"] = frontend
+	rawManifest, err := json.Marshal(mnf)
+	require.NoError(err)
+
+	validator := quote.NewMockValidator()
+	issuer := quote.NewMockIssuer()
+	coreServer, err := NewCore([]string{"localhost"}, validator, issuer, &MockSealer{}, recovery.NewSinglePartyRecovery(), zap.NewNop())
+	require.NoError(err)
+	_, err = coreServer.SetManifest(context.TODO(), rawManifest)
+	require.NoError(err)
+
+	// activates a marble with a CSR for dnsNames and returns the DNS names of the issued certificate
+	activate := func(marbleType string, dnsNames []string) []string {
+		cert, _, privk := util.MustGenerateTestMarbleCredentials()
+		csr, err := util.GenerateCSR(dnsNames, privk)
+		require.NoError(err)
+		marbleQuote, err := issuer.Issue(cert.Raw)
+		require.NoError(err)
+		validator.AddValidQuote(marbleQuote, cert.Raw, mnf.Packages[mnf.Marbles[marbleType].Package], mnf.Infrastructures["Azure"])
+
+		ctx := peer.NewContext(context.TODO(), &peer.Peer{
+			AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}},
+		})
+		resp, err := coreServer.Activate(ctx, &rpc.ActivationReq{CSR: csr.Raw, MarbleType: marbleType, Quote: marbleQuote, UUID: uuid.New().String()})
+		require.NoError(err)
+		block, _ := pem.Decode([]byte(resp.GetParameters().Env[libMarble.MarbleEnvironmentCertificateChain]))
+		require.NotNil(block)
+		marbleCert, err := x509.ParseCertificate(block.Bytes)
+		require.NoError(err)
+		return marbleCert.DNSNames
+	}
+
+	// the DNS names of the marble type are assigned if the marble requests none
+	assert.Equal([]string{"frontend.example.com", "frontend"}, activate("frontend", nil))
+	// the DNS names requested by the marble take precedence
+	assert.Equal([]string{"dns1", "dns2"}, activate("frontend", []string{"dns1", "dns2"}))
+	// marble types without DNS names fall back to localhost
+	assert.Equal([]string{"localhost"}, activate("backend_first", nil))
+}
+
+func TestActivateCertificateValidity(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var mnf manifest.Manifest
+	require.NoError(json.Unmarshal([]byte(test.ManifestJSON), &mnf))
+	frontend := mnf.Marbles["frontend"]
+	frontend.CertificateValidity = "720h"
+	mnf.Marbles["frontend"] = frontend
+	rawManifest, err := json.Marshal(mnf)
+	require.NoError(err)
+
+	validator := quote.NewMockValidator()
+	issuer := quote.NewMockIssuer()
+	coreServer, err := NewCore([]string{"localhost"}, validator, issuer, &MockSealer{}, recovery.NewSinglePartyRecovery(), zap.NewNop())
+	require.NoError(err)
+	_, err = coreServer.SetManifest(context.TODO(), rawManifest)
+	require.NoError(err)
+
+	// activates a marble and returns the issued certificate
+	activate := func(marbleType string) (*x509.Certificate, error) {
+		cert, csr, _ := util.MustGenerateTestMarbleCredentials()
+		marbleQuote, err := issuer.Issue(cert.Raw)
+		require.NoError(err)
+		validator.AddValidQuote(marbleQuote, cert.Raw, mnf.Packages[mnf.Marbles[marbleType].Package], mnf.Infrastructures["Azure"])
+
+		ctx := peer.NewContext(context.TODO(), &peer.Peer{
+			AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}},
+		})
+		resp, err := coreServer.Activate(ctx, &rpc.ActivationReq{CSR: csr, MarbleType: marbleType, Quote: marbleQuote, UUID: uuid.New().String()})
+		if err != nil {
+			return nil, err
+		}
+		block, _ := pem.Decode([]byte(resp.GetParameters().Env[libMarble.MarbleEnvironmentCertificateChain]))
+		require.NotNil(block)
+		return x509.ParseCertificate(block.Bytes)
+	}
+
+	marbleCert, err := activate("frontend")
+	require.NoError(err)
+	assert.Equal(720*time.Hour, marbleCert.NotAfter.Sub(marbleCert.NotBefore))
+
+	// marble types without a validity get certificates as long-lived as the coordinator's
+	marbleCert, err = activate("backend_first")
+	require.NoError(err)
+	assert.False(marbleCert.NotAfter.Before(coreServer.intermediateCert.NotAfter))
+
+	// certificates must not outlive the coordinator's certificate
+	coreServer.intermediateCert.NotAfter = time.Now().Add(time.Hour)
+	_, err = activate("frontend")
+	assert.Equal(codes.FailedPrecondition, status.Code(err))
+	coreServer, err = NewCore([]string{"localhost"}, validator, issuer, &MockSealer{}, recovery.NewSinglePartyRecovery(), zap.NewNop())
+	require.NoError(err)
+	coreServer.intermediateCert.NotAfter = time.Now().Add(time.Hour)
+	_, err = coreServer.SetManifest(context.TODO(), rawManifest)
+	assert.Error(err)
+
+	// the validity must be a positive duration
+	frontend.CertificateValidity = "-1h"
+	mnf.Marbles["frontend"] = frontend
+	rawManifest, err = json.Marshal(mnf)
+	require.NoError(err)
+	coreServer, err = NewCore([]string{"localhost"}, validator, issuer, &MockSealer{}, recovery.NewSinglePartyRecovery(), zap.NewNop())
+	require.NoError(err)
+	_, err = coreServer.SetManifest(context.TODO(), rawManifest)
+	assert.Error(err)
+}
@@ -0,0 +1,79 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// activationDurationBuckets are the buckets of the activation duration histogram in seconds.
+// Activations take a few milliseconds in simulation mode or when re-activating, and up to several seconds if the collateral of a quote must be fetched.
+var activationDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// Metrics returns the Prometheus collectors exposing the Coordinator's state, which must be registered to be scraped:
+//
+// coordinator_state is the state of the Coordinator, encoded like the StatusCode of the /status endpoint:
+// 0 uninitialized, 1 recovery, 2 accepting a manifest, 3 accepting marbles (running).
+//
+// coordinator_manifest_generation is 0 until a manifest is set and incremented with each applied update manifest.
+//
+// coordinator_activation_duration_seconds is a histogram of the durations of activations, including failed ones.
+// Observations of traced activations carry the trace ID as exemplar, which is exported in the OpenMetrics format.
+func (c *Core) Metrics() []prometheus.Collector {
+	return []prometheus.Collector{
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "coordinator_state",
+			Help: "State of the Coordinator: 0 uninitialized, 1 recovery, 2 accepting manifest, 3 accepting marbles.",
+		}, func() float64 {
+			c.mux.Lock()
+			defer c.mux.Unlock()
+			return float64(c.state)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "coordinator_manifest_generation",
+			Help: "Generation of the Coordinator's manifest, incremented with each applied update manifest. 0 if no manifest is set.",
+		}, func() float64 {
+			c.mux.Lock()
+			defer c.mux.Unlock()
+			return float64(c.manifestGeneration())
+		}),
+		c.activationDuration,
+	}
+}
+
+// newActivationDurationHistogram creates the histogram of the activation durations
+func newActivationDurationHistogram() prometheus.Histogram {
+	return prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "coordinator_activation_duration_seconds",
+		Help:    "Duration of marble activations in seconds, including failed ones.",
+		Buckets: activationDurationBuckets,
+	})
+}
+
+// observeActivationDuration records the duration of an activation which started at start.
+// If ctx carries a trace, its ID is attached as exemplar, so slow activations can be correlated with their traces.
+func (c *Core) observeActivationDuration(ctx context.Context, start time.Time) {
+	duration := time.Since(start).Seconds()
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.HasTraceID() {
+		c.activationDuration.Observe(duration)
+		return
+	}
+	c.activationDuration.(prometheus.ExemplarObserver).ObserveWithExemplar(duration, prometheus.Labels{"trace_id": spanContext.TraceID().String()})
+}
+
+// manifestGeneration returns 0 if no manifest is set, and 1 plus the number of applied update manifests otherwise. The caller must hold the lock.
+func (c *Core) manifestGeneration() int {
+	if c.rawManifest == nil {
+		return 0
+	}
+	return 1 + len(c.manifestHistory)
+}
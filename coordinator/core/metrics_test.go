@@ -0,0 +1,120 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/edgelesssys/marblerun/coordinator/manifest"
+	"github.com/edgelesssys/marblerun/coordinator/quote"
+	"github.com/edgelesssys/marblerun/coordinator/recovery"
+	"github.com/edgelesssys/marblerun/coordinator/rpc"
+	"github.com/edgelesssys/marblerun/test"
+	"github.com/edgelesssys/marblerun/util"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+func TestMetrics(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	c, _ := mustSetup()
+	metrics := c.Metrics()
+	require.Len(metrics, 3)
+	state, generation := metrics[0], metrics[1]
+
+	assert.EqualValues(stateAcceptingManifest, testutil.ToFloat64(state))
+	assert.EqualValues(0, testutil.ToFloat64(generation))
+
+	_, err := c.SetManifest(context.TODO(), []byte(test.ManifestJSON))
+	require.NoError(err)
+	assert.EqualValues(stateAcceptingMarbles, testutil.ToFloat64(state))
+	assert.EqualValues(1, testutil.ToFloat64(generation))
+
+	require.NoError(c.UpdateManifest(context.TODO(), []byte(test.UpdateManifest)))
+	assert.EqualValues(stateAcceptingMarbles, testutil.ToFloat64(state))
+	assert.EqualValues(2, testutil.ToFloat64(generation))
+
+	// a Coordinator which can't unseal its state is in recovery mode
+	sealer := &MockSealer{unsealError: ErrEncryptionKey}
+	c, err = NewCore([]string{"localhost"}, quote.NewMockValidator(), quote.NewMockIssuer(), sealer, recovery.NewSinglePartyRecovery(), zap.NewNop())
+	require.NoError(err)
+	assert.EqualValues(stateRecovery, testutil.ToFloat64(c.Metrics()[0]))
+	assert.EqualValues(0, testutil.ToFloat64(c.Metrics()[1]))
+}
+
+func TestActivationDurationMetric(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var mnf manifest.Manifest
+	require.NoError(json.Unmarshal([]byte(test.ManifestJSON), &mnf))
+
+	validator := quote.NewMockValidator()
+	issuer := quote.NewMockIssuer()
+	c, err := NewCore([]string{"localhost"}, validator, issuer, &MockSealer{}, recovery.NewSinglePartyRecovery(), zap.NewNop())
+	require.NoError(err)
+	_, err = c.SetManifest(context.TODO(), []byte(test.ManifestJSON))
+	require.NoError(err)
+
+	activate := func(ctx context.Context, validQuote bool) {
+		cert, csr, _ := util.MustGenerateTestMarbleCredentials()
+		marbleQuote, err := issuer.Issue(cert.Raw)
+		require.NoError(err)
+		if validQuote {
+			validator.AddValidQuote(marbleQuote, cert.Raw, mnf.Packages["frontend"], mnf.Infrastructures["Azure"])
+		}
+		ctx = peer.NewContext(ctx, &peer.Peer{
+			AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}},
+		})
+		_, err = c.Activate(ctx, &rpc.ActivationReq{CSR: csr, MarbleType: "frontend", Quote: marbleQuote, UUID: uuid.New().String()})
+		assert.Equal(validQuote, err == nil)
+	}
+
+	traceID := trace.TraceID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10}
+	tracedContext := trace.ContextWithSpanContext(context.TODO(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     trace.SpanID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	// failed activations are observed as well
+	activate(context.TODO(), true)
+	activate(context.TODO(), false)
+	activate(tracedContext, true)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(c.Metrics()...)
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=0.0.1")
+	resp := httptest.NewRecorder()
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{EnableOpenMetrics: true}).ServeHTTP(resp, req)
+	require.Equal(http.StatusOK, resp.Code)
+
+	metrics := resp.Body.String()
+	assert.Contains(metrics, "coordinator_activation_duration_seconds_count 3\n")
+	assert.Contains(metrics, `coordinator_activation_duration_seconds_bucket{le="30.0"} 3`)
+	// only the traced activation carries an exemplar
+	assert.Contains(metrics, `# {trace_id="`+traceID.String()+`"}`)
+	assert.Equal(1, strings.Count(metrics, "trace_id="))
+}
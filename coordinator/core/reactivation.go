@@ -0,0 +1,20 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"github.com/edgelesssys/marblerun/coordinator/rpc"
+)
+
+// isReactivation checks if a marble activates again with the UUID of a previous activation, e.g., after it or the coordinator restarted. The caller must hold the lock.
+//
+// This is the case if the UUID holds a lease which is not revoked and was issued to the same marble type.
+// A re-activating marble must still present a fresh quote, the lease only keeps it from consuming another activation of its marble type.
+func (c *Core) isReactivation(req *rpc.ActivationReq) bool {
+	lease, ok := c.leases[req.GetUUID()]
+	return ok && !lease.Revoked && lease.MarbleType == req.GetMarbleType()
+}
@@ -0,0 +1,103 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+
+	libMarble "github.com/edgelesssys/ego/marble"
+	"github.com/edgelesssys/marblerun/coordinator/manifest"
+	"github.com/edgelesssys/marblerun/coordinator/quote"
+	"github.com/edgelesssys/marblerun/coordinator/recovery"
+	"github.com/edgelesssys/marblerun/coordinator/rpc"
+	"github.com/edgelesssys/marblerun/test"
+	"github.com/edgelesssys/marblerun/util"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestReactivate(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var mnf manifest.Manifest
+	require.NoError(json.Unmarshal([]byte(test.ManifestJSON), &mnf))
+
+	validator := quote.NewMockValidator()
+	issuer := quote.NewMockIssuer()
+	sealer := &MockSealer{}
+	coreServer, err := NewCore([]string{"localhost"}, validator, issuer, sealer, recovery.NewSinglePartyRecovery(), zap.NewNop())
+	require.NoError(err)
+	_, err = coreServer.SetManifest(context.TODO(), []byte(test.ManifestJSON))
+	require.NoError(err)
+
+	peerContext := func(cert *x509.Certificate) context.Context {
+		return peer.NewContext(context.TODO(), &peer.Peer{
+			AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}},
+		})
+	}
+	// activate creates fresh credentials and a valid quote for them, like premain does on each start
+	activate := func(marbleType string, marbleUUID string) (*rpc.ActivationResp, error) {
+		cert, csr, _ := util.MustGenerateTestMarbleCredentials()
+		marbleQuote, err := issuer.Issue(cert.Raw)
+		require.NoError(err)
+		validator.AddValidQuote(marbleQuote, cert.Raw, mnf.Packages[mnf.Marbles[marbleType].Package], mnf.Infrastructures["Azure"])
+		return coreServer.Activate(peerContext(cert), &rpc.ActivationReq{CSR: csr, MarbleType: marbleType, Quote: marbleQuote, UUID: marbleUUID})
+	}
+
+	// backend_first may only be activated once
+	marbleUUID := uuid.New().String()
+	resp, err := activate("backend_first", marbleUUID)
+	require.NoError(err)
+	block, _ := pem.Decode([]byte(resp.GetParameters().Env[libMarble.MarbleEnvironmentCertificateChain]))
+	require.NotNil(block)
+	marbleCert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(err)
+
+	// simulate a restart of the coordinator, which restores its state from the sealed data
+	coreServer, err = NewCore([]string{"localhost"}, validator, issuer, sealer, recovery.NewSinglePartyRecovery(), zap.NewNop())
+	require.NoError(err)
+	activations := coreServer.activations["backend_first"]
+
+	// the marble re-activates with its UUID and a fresh quote, without consuming an activation
+	resp, err = activate("backend_first", marbleUUID)
+	require.NoError(err)
+	assert.NotEmpty(resp.GetParameters().Env[libMarble.MarbleEnvironmentCertificateChain])
+	assert.Equal(activations, coreServer.activations["backend_first"], "re-activation consumed an activation")
+
+	// a fresh marble unknown to the coordinator exceeds the activations of the marble type
+	_, err = activate("backend_first", uuid.New().String())
+	assert.Equal(codes.ResourceExhausted, status.Code(err))
+
+	// the certificate issued during the previous activation does not replace a fresh quote
+	_, newCSR, _ := util.MustGenerateTestMarbleCredentials()
+	_, err = coreServer.Activate(peerContext(marbleCert), &rpc.ActivationReq{CSR: newCSR, MarbleType: "backend_first", UUID: marbleUUID})
+	assert.Equal(codes.Unauthenticated, status.Code(err))
+
+	// a marble which no longer complies with its package can't re-activate
+	securityVersion := uint(100)
+	coreServer.updateManifest.Packages = map[string]quote.PackageProperties{"backend": {SecurityVersion: &securityVersion}}
+	_, err = activate("backend_first", marbleUUID)
+	assert.Equal(codes.Unauthenticated, status.Code(err))
+	coreServer.updateManifest.Packages = nil
+
+	// revoked marbles can't re-activate
+	require.NoError(coreServer.RevokeMarble(context.TODO(), marbleUUID))
+	_, err = activate("backend_first", marbleUUID)
+	assert.Equal(codes.PermissionDenied, status.Code(err))
+}
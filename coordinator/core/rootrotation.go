@@ -0,0 +1,194 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"time"
+
+	"github.com/edgelesssys/ego/marble"
+	"github.com/edgelesssys/marblerun/coordinator/manifest"
+	"github.com/edgelesssys/marblerun/coordinator/rpc"
+	"github.com/edgelesssys/marblerun/util"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// DefaultRootRotationTransition is the transition period of a root certificate rotation if none is given
+const DefaultRootRotationTransition = 24 * time.Hour
+
+// RootRotation records the last rotation of the Coordinator's root certificate.
+//
+// During the transition period, cross-signed certificates link the previous and the new root certificate:
+// Certificates issued before the rotation, e.g., those of activated marbles, remain valid under the new root certificate,
+// and clients which still trust the previous root certificate can verify the new one.
+// Marbles trust the intermediate certificate instead of the root certificate,
+// so marbles activated during the transition period also trust the previous intermediate certificate,
+// and their certificate chain links the new intermediate certificate to the previous one.
+type RootRotation struct {
+	Timestamp time.Time
+	// TransitionEnd is when the cross-signed certificates expire
+	TransitionEnd time.Time
+	// PreviousRootCert is the DER encoded root certificate before the rotation
+	PreviousRootCert []byte
+	// CrossSignedRootCert is the DER encoded new root certificate signed by the previous root certificate
+	CrossSignedRootCert []byte
+	// CrossSignedIntermediateCert is the DER encoded previous intermediate certificate signed by the new root certificate
+	CrossSignedIntermediateCert []byte
+	// PreviousIntermediateCert is the DER encoded intermediate certificate before the rotation
+	PreviousIntermediateCert []byte
+	// CrossSignedMarbleIntermediateCert is the DER encoded new intermediate certificate signed by the previous intermediate certificate
+	CrossSignedMarbleIntermediateCert []byte
+}
+
+// InTransition checks if the cross-signed certificates are still valid at the given time
+func (r RootRotation) InTransition(now time.Time) bool {
+	return now.Before(r.TransitionEnd)
+}
+
+// addMarbleTrust adds the previous intermediate certificate to the marble's trusted certificates
+// and the cross-signed new intermediate certificate to the marble's certificate chain,
+// so the marble and those activated before the rotation accept each other.
+func (r RootRotation) addMarbleTrust(params *rpc.Parameters) {
+	previousIntermediatePem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: r.PreviousIntermediateCert})
+	crossSignedPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: r.CrossSignedMarbleIntermediateCert})
+	params.Env[marble.MarbleEnvironmentIntermediateCA] += string(previousIntermediatePem)
+	params.Env[marble.MarbleEnvironmentCertificateChain] += string(crossSignedPem)
+}
+
+// RotateRootCertificate generates a new root and intermediate certificate and returns the record of the rotation.
+//
+// Until the transition period elapsed, certificates issued before the rotation remain valid under the new root certificate.
+// Marbles activated afterwards receive certificates issued by the new intermediate certificate.
+// During the transition period, they and the marbles activated before the rotation trust each other.
+// Shared certificate secrets are issued again by the new intermediate certificate, their versions are incremented like on a rotation of the secret.
+// The seal keys and private secrets of marbles are derived from a separate key and don't change.
+// Marbles running a certificate watchdog terminate on the rotation, so they are activated again.
+func (c *Core) RotateRootCertificate(ctx context.Context, transition time.Duration) (RootRotation, error) {
+	defer c.mux.Unlock()
+	if err := c.requireState(stateAcceptingMarbles); err != nil {
+		return RootRotation{}, err
+	}
+	if transition <= 0 {
+		return RootRotation{}, errors.New("the transition period must be positive")
+	}
+
+	rootCert, rootPrivK, err := generateCert(c.rootCert.DNSNames, c.rootCert.IPAddresses, coordinatorName, nil, nil)
+	if err != nil {
+		return RootRotation{}, err
+	}
+	intermediateCert, intermediatePrivK, err := generateCert(c.rootCert.DNSNames, c.rootCert.IPAddresses, coordinatorIntermediateName, rootCert, rootPrivK)
+	if err != nil {
+		return RootRotation{}, err
+	}
+
+	// shared certificate secrets are issued by the intermediate certificate and must chain to the new root certificate
+	secretsToRegenerate := make(map[string]manifest.Secret)
+	for name, secret := range c.manifest.Secrets {
+		if secret.Shared && secret.Type != "symmetric-key" {
+			secretsToRegenerate[name] = secret
+		}
+	}
+	regeneratedSecrets, err := c.generateSecrets(ctx, secretsToRegenerate, uuid.Nil, intermediateCert, intermediatePrivK)
+	if err != nil {
+		c.zaplogger.Error("Could not regenerate the shared secrets for the rotated root certificate.", zap.Error(err))
+		return RootRotation{}, err
+	}
+
+	now := time.Now()
+	rotation := RootRotation{
+		Timestamp:                now.UTC(),
+		TransitionEnd:            now.Add(transition).UTC(),
+		PreviousRootCert:         c.rootCert.Raw,
+		PreviousIntermediateCert: c.intermediateCert.Raw,
+	}
+	rotation.CrossSignedRootCert, err = crossSign(rootCert, c.rootCert, c.rootPrivK, now, rotation.TransitionEnd)
+	if err != nil {
+		return RootRotation{}, err
+	}
+	rotation.CrossSignedIntermediateCert, err = crossSign(c.intermediateCert, rootCert, rootPrivK, now, rotation.TransitionEnd)
+	if err != nil {
+		return RootRotation{}, err
+	}
+	rotation.CrossSignedMarbleIntermediateCert, err = crossSign(intermediateCert, c.intermediateCert, c.intermediatePrivK, now, rotation.TransitionEnd)
+	if err != nil {
+		return RootRotation{}, err
+	}
+
+	oldRootCert, oldRootPrivK := c.rootCert, c.rootPrivK
+	oldIntermediateCert, oldIntermediatePrivK := c.intermediateCert, c.intermediatePrivK
+	oldRotation := c.rootRotation
+	oldSecrets := make(map[string]manifest.Secret, len(regeneratedSecrets))
+	oldVersions := make(map[string]uint64, len(regeneratedSecrets))
+	c.setCertificates(rootCert, rootPrivK, intermediateCert, intermediatePrivK)
+	c.rootRotation = &rotation
+	for name, secret := range regeneratedSecrets {
+		oldSecrets[name] = c.secrets[name]
+		oldVersions[name] = c.secretVersion(name)
+		c.secrets[name] = secret
+		c.secretVersions[name] = oldVersions[name] + 1
+	}
+
+	recoveryData, err := c.recovery.GetRecoveryData()
+	if err == nil {
+		err = c.sealState(recoveryData)
+	}
+	if err != nil {
+		c.zaplogger.Error("Could not seal the rotated root certificate.", zap.Error(err))
+		c.setCertificates(oldRootCert, oldRootPrivK, oldIntermediateCert, oldIntermediatePrivK)
+		c.rootRotation = oldRotation
+		for name := range regeneratedSecrets {
+			c.secrets[name] = oldSecrets[name]
+			c.secretVersions[name] = oldVersions[name]
+		}
+		return RootRotation{}, err
+	}
+	c.quote = c.generateQuote()
+
+	c.zaplogger.Info("Rotated root certificate", zap.Time("transitionEnd", rotation.TransitionEnd))
+	c.events.add(Event{Timestamp: rotation.Timestamp, Level: EventLevelInfo, Message: "root certificate rotated, the previous root certificate is cross-signed until " + rotation.TransitionEnd.Format(time.RFC3339)})
+	return rotation, nil
+}
+
+// GetRootRotation returns the record of the last root certificate rotation, or nil if the root certificate was never rotated
+func (c *Core) GetRootRotation(ctx context.Context) *RootRotation {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.rootRotation == nil {
+		return nil
+	}
+	rotation := *c.rootRotation
+	return &rotation
+}
+
+// crossSign issues a certificate with the subject and public key of cert, signed by parent and valid until notAfter.
+// It keeps the subject key ID of cert, so chains are built with either of both certificates.
+func crossSign(cert *x509.Certificate, parent *x509.Certificate, parentPrivK *ecdsa.PrivateKey, notBefore time.Time, notAfter time.Time) ([]byte, error) {
+	serialNumber, err := util.GenerateCertificateSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+	template := x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               cert.Subject,
+		SubjectKeyId:          cert.SubjectKeyId,
+		DNSNames:              cert.DNSNames,
+		IPAddresses:           cert.IPAddresses,
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              cert.KeyUsage,
+		ExtKeyUsage:           cert.ExtKeyUsage,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	return x509.CreateCertificate(rand.Reader, &template, parent, cert.PublicKey, parentPrivK)
+}
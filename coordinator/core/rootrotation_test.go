@@ -0,0 +1,237 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	libMarble "github.com/edgelesssys/ego/marble"
+	"github.com/edgelesssys/marblerun/coordinator/manifest"
+	"github.com/edgelesssys/marblerun/coordinator/quote"
+	"github.com/edgelesssys/marblerun/coordinator/recovery"
+	"github.com/edgelesssys/marblerun/coordinator/rpc"
+	"github.com/edgelesssys/marblerun/test"
+	"github.com/edgelesssys/marblerun/util"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+func TestRotateRootCertificate(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var mnf manifest.Manifest
+	require.NoError(json.Unmarshal([]byte(test.ManifestJSON), &mnf))
+
+	validator := quote.NewMockValidator()
+	issuer := quote.NewMockIssuer()
+	sealer := &MockSealer{}
+	coreServer, err := NewCore([]string{"localhost"}, validator, issuer, sealer, recovery.NewSinglePartyRecovery(), zap.NewNop())
+	require.NoError(err)
+
+	// rotation requires a manifest
+	_, err = coreServer.RotateRootCertificate(context.TODO(), time.Hour)
+	assert.Error(err)
+	assert.Nil(coreServer.GetRootRotation(context.TODO()))
+
+	_, err = coreServer.SetManifest(context.TODO(), []byte(test.ManifestJSON))
+	require.NoError(err)
+
+	activate := func(marbleUUID string) *rpc.ActivationResp {
+		cert, csr, _ := util.MustGenerateTestMarbleCredentials()
+		marbleQuote, err := issuer.Issue(cert.Raw)
+		require.NoError(err)
+		validator.AddValidQuote(marbleQuote, cert.Raw, mnf.Packages["frontend"], mnf.Infrastructures["Azure"])
+		ctx := peer.NewContext(context.TODO(), &peer.Peer{
+			AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}},
+		})
+		resp, err := coreServer.Activate(ctx, &rpc.ActivationReq{CSR: csr, MarbleType: "frontend", Quote: marbleQuote, UUID: marbleUUID})
+		require.NoError(err)
+		return resp
+	}
+
+	// activate a marble before the rotation
+	marbleUUID := uuid.New().String()
+	resp := activate(marbleUUID)
+	block, _ := pem.Decode([]byte(resp.GetParameters().Env[libMarble.MarbleEnvironmentCertificateChain]))
+	require.NotNil(block)
+	marbleCert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(err)
+
+	oldRootCert := coreServer.rootCert
+	oldIntermediateCert := coreServer.intermediateCert
+	oldQuote := coreServer.quote
+	oldSharedCert := coreServer.secrets["cert_shared"].Cert.Raw
+	oldSymmetricKey := coreServer.secrets["symmetric_key_shared"].Private
+
+	_, err = coreServer.RotateRootCertificate(context.TODO(), 0)
+	assert.Error(err)
+
+	rotation, err := coreServer.RotateRootCertificate(context.TODO(), time.Hour)
+	require.NoError(err)
+	assert.NotEqual(oldRootCert.Raw, coreServer.rootCert.Raw)
+	assert.NotEqual(oldIntermediateCert.Raw, coreServer.intermediateCert.Raw)
+	assert.NotEqual(oldQuote, coreServer.quote)
+	assert.Equal(oldRootCert.Raw, rotation.PreviousRootCert)
+	assert.True(rotation.InTransition(time.Now()))
+	require.NotNil(coreServer.GetRootRotation(context.TODO()))
+	assert.Equal(rotation, *coreServer.GetRootRotation(context.TODO()))
+	require.NoError(coreServer.intermediateCert.CheckSignatureFrom(coreServer.rootCert))
+
+	// TLS handshakes are served with the new certificates
+	tlsRootCert, err := coreServer.GetTLSRootCertificate(nil)
+	require.NoError(err)
+	assert.Equal(coreServer.rootCert.Raw, tlsRootCert.Certificate[0])
+	tlsIntermediateCert, err := coreServer.GetTLSIntermediateCertificate(nil)
+	require.NoError(err)
+	assert.Equal(coreServer.intermediateCert.Raw, tlsIntermediateCert.Certificate[0])
+
+	crossSignedRootCert, err := x509.ParseCertificate(rotation.CrossSignedRootCert)
+	require.NoError(err)
+	crossSignedIntermediateCert, err := x509.ParseCertificate(rotation.CrossSignedIntermediateCert)
+	require.NoError(err)
+
+	verify := func(cert *x509.Certificate, root *x509.Certificate, intermediates []*x509.Certificate, now time.Time) error {
+		opts := x509.VerifyOptions{
+			Roots:         x509.NewCertPool(),
+			Intermediates: x509.NewCertPool(),
+			CurrentTime:   now,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		}
+		opts.Roots.AddCert(root)
+		for _, intermediate := range intermediates {
+			opts.Intermediates.AddCert(intermediate)
+		}
+		_, err := cert.Verify(opts)
+		return err
+	}
+
+	// the pre-rotation marble certificate is valid under the new root certificate during the transition period
+	assert.Error(verify(marbleCert, coreServer.rootCert, []*x509.Certificate{oldIntermediateCert}, time.Now()))
+	assert.NoError(verify(marbleCert, coreServer.rootCert, []*x509.Certificate{crossSignedIntermediateCert}, time.Now()))
+	assert.Error(verify(marbleCert, coreServer.rootCert, []*x509.Certificate{crossSignedIntermediateCert}, rotation.TransitionEnd.Add(time.Minute)))
+
+	// the new intermediate certificate is valid under the previous root certificate during the transition period
+	assert.Error(verify(coreServer.intermediateCert, oldRootCert, nil, time.Now()))
+	assert.NoError(verify(coreServer.intermediateCert, oldRootCert, []*x509.Certificate{crossSignedRootCert}, time.Now()))
+	assert.Error(verify(coreServer.intermediateCert, oldRootCert, []*x509.Certificate{crossSignedRootCert}, rotation.TransitionEnd.Add(time.Minute)))
+
+	// marbles activated before and after the rotation trust each other during the transition period
+	rotatedResp := activate(uuid.New().String())
+	verifyMarble := func(chainPem string, trustPem string) error {
+		var chain []*x509.Certificate
+		for rest := []byte(chainPem); ; {
+			var block *pem.Block
+			if block, rest = pem.Decode(rest); block == nil {
+				break
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			require.NoError(err)
+			chain = append(chain, cert)
+		}
+		require.NotEmpty(chain)
+		opts := x509.VerifyOptions{
+			Roots:         x509.NewCertPool(),
+			Intermediates: x509.NewCertPool(),
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		}
+		require.True(opts.Roots.AppendCertsFromPEM([]byte(trustPem)))
+		for _, intermediate := range chain[1:] {
+			opts.Intermediates.AddCert(intermediate)
+		}
+		_, err := chain[0].Verify(opts)
+		return err
+	}
+	oldEnv := resp.GetParameters().Env
+	rotatedEnv := rotatedResp.GetParameters().Env
+	assert.NoError(verifyMarble(oldEnv[libMarble.MarbleEnvironmentCertificateChain], rotatedEnv[libMarble.MarbleEnvironmentIntermediateCA]))
+	assert.NoError(verifyMarble(rotatedEnv[libMarble.MarbleEnvironmentCertificateChain], oldEnv[libMarble.MarbleEnvironmentIntermediateCA]))
+	assert.NoError(verifyMarble(rotatedEnv[libMarble.MarbleEnvironmentCertificateChain], rotatedEnv[libMarble.MarbleEnvironmentIntermediateCA]))
+
+	// the marble's seal key is derived independently of the root certificate
+	rotatedResp = activate(marbleUUID)
+	assert.NotEmpty(resp.GetParameters().Env["SEAL_KEY"])
+	assert.Equal(resp.GetParameters().Env["SEAL_KEY"], rotatedResp.GetParameters().Env["SEAL_KEY"])
+
+	// shared certificate secrets are issued by the new intermediate certificate, symmetric keys are kept
+	sharedCert := x509.Certificate(coreServer.secrets["cert_shared"].Cert)
+	assert.NotEqual(oldSharedCert, sharedCert.Raw)
+	assert.NoError(sharedCert.CheckSignatureFrom(coreServer.intermediateCert))
+	assert.EqualValues(2, coreServer.secretVersion("cert_shared"))
+	assert.Equal(oldSymmetricKey, coreServer.secrets["symmetric_key_shared"].Private)
+	assert.EqualValues(1, coreServer.secretVersion("symmetric_key_shared"))
+
+	// the transition is reported in the status
+	_, status, err := coreServer.GetStatus(context.TODO())
+	require.NoError(err)
+	assert.Contains(status, "root certificate was rotated")
+
+	// the rotation is sealed
+	restarted, err := NewCore([]string{"localhost"}, validator, issuer, sealer, recovery.NewSinglePartyRecovery(), zap.NewNop())
+	require.NoError(err)
+	assert.Equal(coreServer.rootCert.Raw, restarted.rootCert.Raw)
+	assert.Equal(coreServer.intermediateCert.Raw, restarted.intermediateCert.Raw)
+	require.NotNil(restarted.GetRootRotation(context.TODO()))
+	assert.Equal(rotation.CrossSignedIntermediateCert, restarted.GetRootRotation(context.TODO()).CrossSignedIntermediateCert)
+	assert.Equal(rotation.CrossSignedMarbleIntermediateCert, restarted.GetRootRotation(context.TODO()).CrossSignedMarbleIntermediateCert)
+	assert.True(rotation.TransitionEnd.Equal(restarted.GetRootRotation(context.TODO()).TransitionEnd))
+	assert.Equal(coreServer.derivationKey, restarted.derivationKey)
+}
+
+func TestRotateRootCertificateDuringHandshakes(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	coreServer, err := NewCore([]string{"localhost"}, quote.NewMockValidator(), quote.NewMockIssuer(), &MockSealer{}, recovery.NewSinglePartyRecovery(), zap.NewNop())
+	require.NoError(err)
+	_, err = coreServer.SetManifest(context.TODO(), []byte(test.ManifestJSON))
+	require.NoError(err)
+
+	// every certificate served on a handshake must come with its own private key
+	done := make(chan struct{})
+	mismatches := make(chan int, 1)
+	go func() {
+		count := 0
+		for {
+			select {
+			case <-done:
+				mismatches <- count
+				return
+			default:
+			}
+			for _, getCert := range []func(*tls.ClientHelloInfo) (*tls.Certificate, error){coreServer.GetTLSRootCertificate, coreServer.GetTLSIntermediateCertificate} {
+				tlsCert, err := getCert(nil)
+				if err != nil {
+					count++
+					continue
+				}
+				cert, err := x509.ParseCertificate(tlsCert.Certificate[0])
+				if err != nil || !cert.PublicKey.(*ecdsa.PublicKey).Equal(tlsCert.PrivateKey.(*ecdsa.PrivateKey).Public()) {
+					count++
+				}
+			}
+		}
+	}()
+
+	for i := 0; i < 5; i++ {
+		_, err := coreServer.RotateRootCertificate(context.TODO(), time.Hour)
+		require.NoError(err)
+	}
+	close(done)
+	assert.Zero(<-mismatches)
+}
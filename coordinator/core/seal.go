@@ -10,6 +10,7 @@ import (
 	"crypto/rand"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -24,25 +25,85 @@ const SealedDataFname string = "sealed_data"
 // SealedKeyFname contains the file name in which the key is sealed with the seal key on disk in seal_dir
 const SealedKeyFname string = "sealed_key"
 
+// SealModeFname contains the file name in which the seal mode of the sealed key is stored on disk in seal_dir
+const SealModeFname string = "sealed_key_mode"
+
 // ErrEncryptionKey occurs if unsealing the encryption key failed.
 var ErrEncryptionKey = errors.New("cannot unseal encryption key")
 
+// SealMode defines which SGX seal key is used to seal the state encryption key
+type SealMode int
+
+const (
+	// SealModeProductKey seals with the product key, which is derived from MRSIGNER and the product ID.
+	// The sealed key can still be unsealed after the coordinator was updated to a newer version.
+	SealModeProductKey SealMode = iota
+	// SealModeUniqueKey seals with the unique key, which is derived from MRENCLAVE.
+	// The sealed key can't be unsealed by any other version of the coordinator.
+	SealModeUniqueKey
+)
+
+// ParseSealMode parses a seal mode, which is either "product" or "unique"
+func ParseSealMode(mode string) (SealMode, error) {
+	switch mode {
+	case "product":
+		return SealModeProductKey, nil
+	case "unique":
+		return SealModeUniqueKey, nil
+	}
+	return 0, fmt.Errorf("unknown seal mode: %v", mode)
+}
+
+func (m SealMode) String() string {
+	if m == SealModeUniqueKey {
+		return "unique"
+	}
+	return "product"
+}
+
+// SealModeMismatchError occurs if the encryption key was sealed with another seal mode than the configured one and can't be unsealed anymore
+type SealModeMismatchError struct {
+	Sealed     SealMode
+	Configured SealMode
+}
+
+func (e *SealModeMismatchError) Error() string {
+	return fmt.Sprintf("%v: it was sealed with the %v key, but the %v key is configured", ErrEncryptionKey, e.Sealed, e.Configured)
+}
+
+// Unwrap returns ErrEncryptionKey
+func (e *SealModeMismatchError) Unwrap() error {
+	return ErrEncryptionKey
+}
+
 // Sealer is an interface for the Core object to seal information to the filesystem for persistence
 type Sealer interface {
 	Seal(unencryptedData []byte, toBeEncrypted []byte) error
 	Unseal() (unencryptedData []byte, decryptedData []byte, err error)
 	SetEncryptionKey(key []byte) error
+	GetEncryptionKey() ([]byte, error)
 }
 
 // AESGCMSealer implements the Sealer interface using AES-GCM for confidentiallity and authentication
 type AESGCMSealer struct {
 	sealDir       string
+	mode          SealMode
 	encryptionKey []byte
+	sealKey       func(mode SealMode, plaintext []byte) ([]byte, error)
+	unsealKey     func(ciphertext []byte) ([]byte, error)
 }
 
-// NewAESGCMSealer creates and initializes a new AESGCMSealer object
-func NewAESGCMSealer(sealDir string) *AESGCMSealer {
-	return &AESGCMSealer{sealDir: sealDir}
+// NewAESGCMSealer creates and initializes a new AESGCMSealer object, which seals the encryption key with the seal key selected by mode
+func NewAESGCMSealer(sealDir string, mode SealMode) *AESGCMSealer {
+	return &AESGCMSealer{sealDir: sealDir, mode: mode, sealKey: sealWithMode, unsealKey: ecrypto.Unseal}
+}
+
+// sealWithMode seals plaintext with the enclave's seal key selected by mode
+func sealWithMode(mode SealMode, plaintext []byte) ([]byte, error) {
+	if mode == SealModeUniqueKey {
+		return ecrypto.SealWithUniqueKey(plaintext)
+	}
+	return ecrypto.SealWithProductKey(plaintext)
 }
 
 // Unseal reads and decrypts stored information from the fs
@@ -56,26 +117,16 @@ func (s *AESGCMSealer) Unseal() ([]byte, []byte, error) {
 		return nil, nil, err
 	}
 
-	if len(sealedData) <= 4 {
-		return nil, nil, errors.New("sealed state is missing data")
-	}
-
-	// Retrieve recovery secret hash map
-	encodedUnencryptDataLength := binary.LittleEndian.Uint32(sealedData[:4])
-
-	// Check if we do not go out of bounds
-	if 4+encodedUnencryptDataLength > uint32(len(sealedData)) {
-		return nil, nil, errors.New("sealed state is corrupted, embedded length does not fit the data")
-	}
-
-	var unencryptedData []byte
-	if encodedUnencryptDataLength != 0 {
-		unencryptedData = sealedData[4 : 4+encodedUnencryptDataLength]
+	unencryptedData, ciphertext, err := splitSealedData(sealedData)
+	if err != nil {
+		return nil, nil, err
 	}
-	ciphertext := sealedData[4+encodedUnencryptDataLength:]
 
 	// Decrypt generated encryption key with seal key, if needed
 	if err = s.unsealEncryptionKey(); err != nil {
+		if errors.Is(err, ErrEncryptionKey) {
+			return unencryptedData, nil, err
+		}
 		return unencryptedData, nil, ErrEncryptionKey
 	}
 
@@ -106,15 +157,8 @@ func (s *AESGCMSealer) Seal(unencryptedData []byte, toBeEncrypted []byte) error
 		return err
 	}
 
-	unencryptDataLength := make([]byte, 4)
-	binary.LittleEndian.PutUint32(unencryptDataLength, uint32(len(unencryptedData)))
-	unencryptedData = append(unencryptDataLength, unencryptedData...)
-
-	// Append unencrypted data with encrypted data
-	encryptedData = append(unencryptedData, encryptedData...)
-
 	// store to fs
-	if err := ioutil.WriteFile(s.getFname(SealedDataFname), encryptedData, 0600); err != nil {
+	if err := ioutil.WriteFile(s.getFname(SealedDataFname), joinSealedData(unencryptedData, encryptedData), 0600); err != nil {
 		return err
 	}
 
@@ -136,18 +180,43 @@ func (s *AESGCMSealer) unsealEncryptionKey() error {
 		return err
 	}
 
+	sealedMode, err := s.readSealMode()
+	if err != nil {
+		return err
+	}
+
 	// Decrypt stored encryption key with seal key
-	encryptionKey, err := ecrypto.Unseal(sealedKeyData)
+	encryptionKey, err := s.unsealKey(sealedKeyData)
 	if err != nil {
+		if sealedMode != s.mode {
+			return &SealModeMismatchError{Sealed: sealedMode, Configured: s.mode}
+		}
 		return err
 	}
 
+	// Key was sealed with another seal mode, but can still be unsealed: migrate it to the configured one
+	if sealedMode != s.mode {
+		return s.SetEncryptionKey(encryptionKey)
+	}
+
 	// Restore encryption key
 	s.encryptionKey = encryptionKey
 
 	return nil
 }
 
+// readSealMode reads the seal mode the encryption key was sealed with.
+// Keys sealed before the seal mode was stored were sealed with the product key.
+func (s *AESGCMSealer) readSealMode() (SealMode, error) {
+	mode, err := ioutil.ReadFile(s.getFname(SealModeFname))
+	if os.IsNotExist(err) {
+		return SealModeProductKey, nil
+	} else if err != nil {
+		return 0, err
+	}
+	return ParseSealMode(string(mode))
+}
+
 // generateNewEncryptionKey generates a random 128 Bit (16 Byte) key to encrypt the state
 func (s *AESGCMSealer) generateNewEncryptionKey() error {
 	encryptionKey := make([]byte, 16)
@@ -170,25 +239,68 @@ func (s *AESGCMSealer) SetEncryptionKey(encryptionKey []byte) error {
 	}
 
 	// Encrypt encryption key with seal key
-	encryptedKeyData, err := ecrypto.SealWithProductKey(encryptionKey)
+	encryptedKeyData, err := s.sealKey(s.mode, encryptionKey)
 	if err != nil {
 		return err
 	}
 
-	// Write the sealed encryption key to disk
+	// Write the sealed encryption key and its seal mode to disk
 	if err = ioutil.WriteFile(s.getFname(SealedKeyFname), encryptedKeyData, 0600); err != nil {
 		return err
 	}
+	if err = ioutil.WriteFile(s.getFname(SealModeFname), []byte(s.mode.String()), 0600); err != nil {
+		return err
+	}
 
 	s.encryptionKey = encryptionKey
 
 	return nil
 }
 
+// GetEncryptionKey returns the key used to encrypt the state
+func (s *AESGCMSealer) GetEncryptionKey() ([]byte, error) {
+	if err := s.unsealEncryptionKey(); err != nil {
+		return nil, err
+	}
+	return s.encryptionKey, nil
+}
+
+// joinSealedData prepends the unencrypted data and its length to the encrypted data
+func joinSealedData(unencryptedData []byte, encryptedData []byte) []byte {
+	unencryptDataLength := make([]byte, 4)
+	binary.LittleEndian.PutUint32(unencryptDataLength, uint32(len(unencryptedData)))
+	sealedData := append(unencryptDataLength, unencryptedData...)
+
+	// Append unencrypted data with encrypted data
+	return append(sealedData, encryptedData...)
+}
+
+// splitSealedData splits sealed data into the unencrypted data and the ciphertext
+func splitSealedData(sealedData []byte) (unencryptedData []byte, ciphertext []byte, err error) {
+	if len(sealedData) <= 4 {
+		return nil, nil, errors.New("sealed state is missing data")
+	}
+
+	// Retrieve recovery secret hash map
+	encodedUnencryptDataLength := binary.LittleEndian.Uint32(sealedData[:4])
+
+	// Check if we do not go out of bounds
+	if 4+uint64(encodedUnencryptDataLength) > uint64(len(sealedData)) {
+		return nil, nil, errors.New("sealed state is corrupted, embedded length does not fit the data")
+	}
+
+	if encodedUnencryptDataLength != 0 {
+		unencryptedData = sealedData[4 : 4+encodedUnencryptDataLength]
+	}
+	ciphertext = sealedData[4+encodedUnencryptDataLength:]
+	return unencryptedData, ciphertext, nil
+}
+
 // MockSealer is a mockup sealer
 type MockSealer struct {
 	data            []byte
 	unencryptedData []byte
+	encryptionKey   []byte
 	unsealError     error
 }
 
@@ -206,9 +318,18 @@ func (s *MockSealer) Seal(unencryptedData []byte, toBeEncrypted []byte) error {
 
 // SetEncryptionKey implements the Sealer interface
 func (s *MockSealer) SetEncryptionKey(key []byte) error {
+	s.encryptionKey = key
 	return nil
 }
 
+// GetEncryptionKey implements the Sealer interface
+func (s *MockSealer) GetEncryptionKey() ([]byte, error) {
+	if s.encryptionKey == nil {
+		return nil, errors.New("no encryption key set")
+	}
+	return s.encryptionKey, nil
+}
+
 // NoEnclaveSealer is a sealed for a -noenclave instance and does perform encryption with a fixed key
 type NoEnclaveSealer struct {
 	sealDir       string
@@ -228,15 +349,8 @@ func (s *NoEnclaveSealer) Seal(unencryptedData []byte, toBeEncrypted []byte) err
 		return err
 	}
 
-	unencryptDataLength := make([]byte, 4)
-	binary.LittleEndian.PutUint32(unencryptDataLength, uint32(len(unencryptedData)))
-	unencryptedData = append(unencryptDataLength, unencryptedData...)
-
-	// Append unencrypted data with encrypted data
-	sealedData = append(unencryptedData, sealedData...)
-
 	// Write encrypted data to disk
-	if err := ioutil.WriteFile(s.getFname(SealedDataFname), sealedData, 0600); err != nil {
+	if err := ioutil.WriteFile(s.getFname(SealedDataFname), joinSealedData(unencryptedData, sealedData), 0600); err != nil {
 		return err
 	}
 
@@ -263,19 +377,10 @@ func (s *NoEnclaveSealer) Unseal() ([]byte, []byte, error) {
 		return nil, nil, err
 	}
 
-	// Retrieve recovery secret hash map
-	encodedUnencryptDataLength := binary.LittleEndian.Uint32(sealedData[:4])
-
-	// Check if we do not go out of bounds
-	if 4+int(encodedUnencryptDataLength) > len(sealedData) {
-		return nil, nil, errors.New("sealed state is corrupted, embedded length does not fit the data")
-	}
-
-	var unencryptedData []byte
-	if encodedUnencryptDataLength != 0 {
-		unencryptedData = sealedData[4 : 4+encodedUnencryptDataLength]
+	unencryptedData, ciphertext, err := splitSealedData(sealedData)
+	if err != nil {
+		return nil, nil, err
 	}
-	ciphertext := sealedData[4+encodedUnencryptDataLength:]
 
 	// Decrypt data with key from disk
 	decryptedData, err := ecrypto.Decrypt(ciphertext, keyData)
@@ -292,6 +397,14 @@ func (s *NoEnclaveSealer) SetEncryptionKey(key []byte) error {
 	return ioutil.WriteFile(s.getFname(SealedKeyFname), s.encryptionKey, 0600)
 }
 
+// GetEncryptionKey implements the Sealer interface
+func (s *NoEnclaveSealer) GetEncryptionKey() ([]byte, error) {
+	if s.encryptionKey != nil {
+		return s.encryptionKey, nil
+	}
+	return ioutil.ReadFile(s.getFname(SealedKeyFname))
+}
+
 func (s *NoEnclaveSealer) getFname(basename string) string {
 	return filepath.Join(s.sealDir, basename)
 }
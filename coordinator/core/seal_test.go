@@ -0,0 +1,144 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/edgelesssys/ego/ecrypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSealKeys emulates the seal keys of an enclave: the unique key changes with each version, the product key doesn't
+type fakeSealKeys struct {
+	uniqueKey  []byte
+	productKey []byte
+}
+
+func (k *fakeSealKeys) update() {
+	k.uniqueKey = []byte("0123456789abcdeg")
+}
+
+func (k *fakeSealKeys) seal(mode SealMode, plaintext []byte) ([]byte, error) {
+	key := k.productKey
+	if mode == SealModeUniqueKey {
+		key = k.uniqueKey
+	}
+	ciphertext, err := ecrypto.Encrypt(plaintext, key)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{byte(mode)}, ciphertext...), nil
+}
+
+func (k *fakeSealKeys) unseal(ciphertext []byte) ([]byte, error) {
+	key := k.productKey
+	if SealMode(ciphertext[0]) == SealModeUniqueKey {
+		key = k.uniqueKey
+	}
+	return ecrypto.Decrypt(ciphertext[1:], key)
+}
+
+func newFakeAESGCMSealer(sealDir string, mode SealMode, keys *fakeSealKeys) *AESGCMSealer {
+	sealer := NewAESGCMSealer(sealDir, mode)
+	sealer.sealKey = keys.seal
+	sealer.unsealKey = keys.unseal
+	return sealer
+}
+
+func TestParseSealMode(t *testing.T) {
+	assert := assert.New(t)
+
+	mode, err := ParseSealMode("product")
+	assert.NoError(err)
+	assert.Equal(SealModeProductKey, mode)
+	mode, err = ParseSealMode("unique")
+	assert.NoError(err)
+	assert.Equal(SealModeUniqueKey, mode)
+	_, err = ParseSealMode("mrenclave")
+	assert.Error(err)
+}
+
+func TestAESGCMSealerSealModes(t *testing.T) {
+	for _, mode := range []SealMode{SealModeProductKey, SealModeUniqueKey} {
+		t.Run(mode.String(), func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			sealDir, err := ioutil.TempDir("", "")
+			require.NoError(err)
+			defer os.RemoveAll(sealDir)
+
+			keys := &fakeSealKeys{uniqueKey: []byte("0123456789abcdef"), productKey: []byte("fedcba9876543210")}
+			sealer := newFakeAESGCMSealer(sealDir, mode, keys)
+			require.NoError(sealer.Seal([]byte("recovery"), []byte("state")))
+
+			storedMode, err := ioutil.ReadFile(sealer.getFname(SealModeFname))
+			require.NoError(err)
+			assert.Equal(mode.String(), string(storedMode))
+
+			// a restarted coordinator can unseal the state
+			unencryptedData, decryptedData, err := newFakeAESGCMSealer(sealDir, mode, keys).Unseal()
+			require.NoError(err)
+			assert.Equal([]byte("recovery"), unencryptedData)
+			assert.Equal([]byte("state"), decryptedData)
+
+			// only a state sealed with the product key survives an update
+			keys.update()
+			unencryptedData, decryptedData, err = newFakeAESGCMSealer(sealDir, mode, keys).Unseal()
+			assert.Equal([]byte("recovery"), unencryptedData)
+			if mode == SealModeProductKey {
+				require.NoError(err)
+				assert.Equal([]byte("state"), decryptedData)
+			} else {
+				assert.Equal(ErrEncryptionKey, err)
+			}
+		})
+	}
+}
+
+func TestAESGCMSealerSwitchSealMode(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	sealDir, err := ioutil.TempDir("", "")
+	require.NoError(err)
+	defer os.RemoveAll(sealDir)
+
+	keys := &fakeSealKeys{uniqueKey: []byte("0123456789abcdef"), productKey: []byte("fedcba9876543210")}
+	require.NoError(newFakeAESGCMSealer(sealDir, SealModeUniqueKey, keys).Seal(nil, []byte("state")))
+
+	// the same version of the coordinator migrates the key to the new seal mode
+	_, decryptedData, err := newFakeAESGCMSealer(sealDir, SealModeProductKey, keys).Unseal()
+	require.NoError(err)
+	assert.Equal([]byte("state"), decryptedData)
+	storedMode, err := ioutil.ReadFile(filepath.Join(sealDir, SealModeFname))
+	require.NoError(err)
+	assert.Equal("product", string(storedMode))
+
+	// the migrated key survives an update
+	keys.update()
+	_, decryptedData, err = newFakeAESGCMSealer(sealDir, SealModeProductKey, keys).Unseal()
+	require.NoError(err)
+	assert.Equal([]byte("state"), decryptedData)
+
+	// switching the seal mode together with an update is reported
+	require.NoError(newFakeAESGCMSealer(sealDir, SealModeUniqueKey, keys).SetEncryptionKey([]byte("0123456789abcdef")))
+	require.NoError(newFakeAESGCMSealer(sealDir, SealModeUniqueKey, keys).Seal(nil, []byte("state")))
+	keys.uniqueKey = []byte("0123456789abcdeh")
+	_, _, err = newFakeAESGCMSealer(sealDir, SealModeProductKey, keys).Unseal()
+	var mismatchErr *SealModeMismatchError
+	require.True(errors.As(err, &mismatchErr))
+	assert.Equal(SealModeUniqueKey, mismatchErr.Sealed)
+	assert.Equal(SealModeProductKey, mismatchErr.Configured)
+	assert.True(errors.Is(err, ErrEncryptionKey))
+}
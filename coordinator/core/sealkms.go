@@ -0,0 +1,213 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+// KMSClient wraps and unwraps data using a key of AWS Key Management Service
+type KMSClient interface {
+	Encrypt(plaintext []byte) (ciphertext []byte, err error)
+	Decrypt(ciphertext []byte) (plaintext []byte, err error)
+}
+
+// KMSSealer implements the Sealer interface. The state is encrypted with AES-GCM and the encryption key is wrapped by AWS KMS.
+type KMSSealer struct {
+	keyWrappingSealer
+}
+
+// NewKMSSealer creates and initializes a new KMSSealer object
+func NewKMSSealer(sealDir string, client KMSClient) *KMSSealer {
+	return &KMSSealer{keyWrappingSealer{sealDir: sealDir, wrapper: client, service: "aws kms"}}
+}
+
+// KMSError is an error returned by the AWS KMS API, e.g., AccessDeniedException
+type KMSError struct {
+	Type    string
+	Message string
+}
+
+func (e *KMSError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Type, e.Message)
+}
+
+// KMSAPIClient implements the KMSClient interface using the AWS KMS JSON API
+type KMSAPIClient struct {
+	keyARN          string
+	region          string
+	endpoint        string
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+	httpClient      *http.Client
+}
+
+// NewKMSAPIClient creates a client for the KMS key keyARN that authenticates with the given AWS credentials
+func NewKMSAPIClient(keyARN string, accessKeyID string, secretAccessKey string, sessionToken string) (*KMSAPIClient, error) {
+	// arn:aws:kms:<region>:<account>:key/<id>
+	arn := strings.Split(keyARN, ":")
+	if len(arn) != 6 || arn[0] != "arn" || arn[2] != "kms" || arn[3] == "" {
+		return nil, fmt.Errorf("invalid kms key arn: %v", keyARN)
+	}
+	region := arn[3]
+
+	return &KMSAPIClient{
+		keyARN:          keyARN,
+		region:          region,
+		endpoint:        "https://kms." + region + ".amazonaws.com/",
+		accessKeyID:     accessKeyID,
+		secretAccessKey: secretAccessKey,
+		sessionToken:    sessionToken,
+		httpClient:      &http.Client{},
+	}, nil
+}
+
+// Encrypt implements the KMSClient interface
+func (c *KMSAPIClient) Encrypt(plaintext []byte) ([]byte, error) {
+	resp, err := c.post("Encrypt", map[string]interface{}{"KeyId": c.keyARN, "Plaintext": plaintext})
+	if err != nil {
+		return nil, err
+	}
+	var result struct{ CiphertextBlob []byte }
+	if err := json.Unmarshal(resp, &result); err != nil {
+		return nil, err
+	}
+	if result.CiphertextBlob == nil {
+		return nil, fmt.Errorf("kms response is missing ciphertext")
+	}
+	return result.CiphertextBlob, nil
+}
+
+// Decrypt implements the KMSClient interface
+func (c *KMSAPIClient) Decrypt(ciphertext []byte) ([]byte, error) {
+	resp, err := c.post("Decrypt", map[string]interface{}{"KeyId": c.keyARN, "CiphertextBlob": ciphertext})
+	if err != nil {
+		return nil, err
+	}
+	var result struct{ Plaintext []byte }
+	if err := json.Unmarshal(resp, &result); err != nil {
+		return nil, err
+	}
+	if result.Plaintext == nil {
+		return nil, fmt.Errorf("kms response is missing plaintext")
+	}
+	return result.Plaintext, nil
+}
+
+func (c *KMSAPIClient) post(operation string, body map[string]interface{}) ([]byte, error) {
+	reqBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.endpoint, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService."+operation)
+	c.sign(req, reqBody, time.Now())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		kmsErr := &KMSError{
+			Type:    gjson.GetBytes(respBody, "__type").String(),
+			Message: gjson.GetBytes(respBody, "message").String(),
+		}
+		// the type may be prefixed with a namespace, e.g., com.amazonaws.kms#AccessDeniedException
+		if i := strings.LastIndex(kmsErr.Type, "#"); i >= 0 {
+			kmsErr.Type = kmsErr.Type[i+1:]
+		}
+		if kmsErr.Type == "" {
+			kmsErr.Type = http.StatusText(resp.StatusCode)
+		}
+		if kmsErr.Message == "" {
+			kmsErr.Message = gjson.GetBytes(respBody, "Message").String()
+		}
+		return nil, kmsErr
+	}
+	return respBody, nil
+}
+
+// sign adds an AWS Signature Version 4 to the request
+func (c *KMSAPIClient) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if c.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.sessionToken)
+	}
+
+	headers := []string{"content-type", "host", "x-amz-date"}
+	if c.sessionToken != "" {
+		headers = append(headers, "x-amz-security-token")
+	}
+	headers = append(headers, "x-amz-target")
+
+	var canonicalHeaders strings.Builder
+	for _, h := range headers {
+		value := req.Header.Get(h)
+		if h == "host" {
+			value = req.URL.Host
+		}
+		canonicalHeaders.WriteString(h + ":" + strings.TrimSpace(value) + "\n")
+	}
+	signedHeaders := strings.Join(headers, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	bodyHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+
+	scope := date + "/" + c.region + "/kms/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+c.secretAccessKey), date)
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, "kms")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%v/%v, SignedHeaders=%v, Signature=%v", c.accessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
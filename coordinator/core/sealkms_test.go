@@ -0,0 +1,125 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeKMSClient struct {
+	denied bool
+}
+
+func (c *fakeKMSClient) Encrypt(plaintext []byte) ([]byte, error) {
+	if c.denied {
+		return nil, &KMSError{Type: "AccessDeniedException", Message: "not authorized to perform kms:Encrypt"}
+	}
+	return append([]byte("kms:"), plaintext...), nil
+}
+
+func (c *fakeKMSClient) Decrypt(ciphertext []byte) ([]byte, error) {
+	if c.denied {
+		return nil, &KMSError{Type: "AccessDeniedException", Message: "not authorized to perform kms:Decrypt"}
+	}
+	return bytes.TrimPrefix(ciphertext, []byte("kms:")), nil
+}
+
+func TestKMSSealer(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	sealDir, err := ioutil.TempDir("", "")
+	require.NoError(err)
+	defer os.RemoveAll(sealDir)
+
+	sealer := NewKMSSealer(sealDir, &fakeKMSClient{})
+	require.NoError(sealer.Seal([]byte("recovery"), []byte("state")))
+
+	sealer = NewKMSSealer(sealDir, &fakeKMSClient{})
+	unencryptedData, decryptedData, err := sealer.Unseal()
+	require.NoError(err)
+	assert.Equal([]byte("recovery"), unencryptedData)
+	assert.Equal([]byte("state"), decryptedData)
+}
+
+func TestKMSSealerAccessDenied(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	sealDir, err := ioutil.TempDir("", "")
+	require.NoError(err)
+	defer os.RemoveAll(sealDir)
+
+	sealer := NewKMSSealer(sealDir, &fakeKMSClient{})
+	require.NoError(sealer.Seal(nil, []byte("state")))
+
+	sealer = NewKMSSealer(sealDir, &fakeKMSClient{denied: true})
+	_, _, err = sealer.Unseal()
+	assert.Error(err)
+	assert.NotEqual(ErrEncryptionKey, err)
+	assert.Contains(err.Error(), "AccessDeniedException")
+
+	err = sealer.SetEncryptionKey([]byte("key"))
+	assert.Error(err)
+	assert.Contains(err.Error(), "AccessDeniedException")
+}
+
+func TestKMSAPIClient(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	_, err := NewKMSAPIClient("invalid", "id", "secret", "")
+	assert.Error(err)
+
+	client, err := NewKMSAPIClient("arn:aws:kms:eu-central-1:123456789012:key/1234abcd", "id", "secret", "token")
+	require.NoError(err)
+	assert.Equal("https://kms.eu-central-1.amazonaws.com/", client.endpoint)
+
+	denied := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.True(strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=id/"))
+		assert.Contains(r.Header.Get("Authorization"), "/eu-central-1/kms/aws4_request")
+		assert.Equal("token", r.Header.Get("X-Amz-Security-Token"))
+		if denied {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"AccessDeniedException","message":"not authorized"}`))
+			return
+		}
+		switch r.Header.Get("X-Amz-Target") {
+		case "TrentService.Encrypt":
+			w.Write([]byte(`{"CiphertextBlob":"Y2lwaGVy"}`))
+		case "TrentService.Decrypt":
+			w.Write([]byte(`{"Plaintext":"a2V5"}`))
+		}
+	}))
+	defer server.Close()
+	client.endpoint = server.URL
+
+	ciphertext, err := client.Encrypt([]byte("key"))
+	require.NoError(err)
+	assert.Equal([]byte("cipher"), ciphertext)
+	plaintext, err := client.Decrypt(ciphertext)
+	require.NoError(err)
+	assert.Equal([]byte("key"), plaintext)
+
+	denied = true
+	_, err = client.Decrypt(ciphertext)
+	require.Error(err)
+	kmsErr, ok := err.(*KMSError)
+	require.True(ok)
+	assert.Equal("AccessDeniedException", kmsErr.Type)
+	assert.Equal("not authorized", kmsErr.Message)
+}
@@ -0,0 +1,114 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/tidwall/gjson"
+)
+
+// VaultClient wraps and unwraps data using a key of HashiCorp Vault's transit secrets engine
+type VaultClient interface {
+	Encrypt(plaintext []byte) (ciphertext []byte, err error)
+	Decrypt(ciphertext []byte) (plaintext []byte, err error)
+}
+
+// VaultSealer implements the Sealer interface. The state is encrypted with AES-GCM and the encryption key is wrapped by Vault.
+type VaultSealer struct {
+	keyWrappingSealer
+}
+
+// NewVaultSealer creates and initializes a new VaultSealer object
+func NewVaultSealer(sealDir string, client VaultClient) *VaultSealer {
+	return &VaultSealer{keyWrappingSealer{sealDir: sealDir, wrapper: client, service: "vault"}}
+}
+
+// VaultTransitClient implements the VaultClient interface using Vault's HTTP API
+type VaultTransitClient struct {
+	addr       string
+	token      string
+	keyName    string
+	httpClient *http.Client
+}
+
+// NewVaultTransitClient creates a client for the transit key keyName of the Vault server at addr
+func NewVaultTransitClient(addr string, token string, keyName string) *VaultTransitClient {
+	return &VaultTransitClient{
+		addr:       addr,
+		token:      token,
+		keyName:    keyName,
+		httpClient: &http.Client{},
+	}
+}
+
+// Encrypt implements the VaultClient interface
+func (c *VaultTransitClient) Encrypt(plaintext []byte) ([]byte, error) {
+	resp, err := c.post("encrypt", map[string]string{"plaintext": base64.StdEncoding.EncodeToString(plaintext)})
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := gjson.GetBytes(resp, "data.ciphertext")
+	if !ciphertext.Exists() {
+		return nil, fmt.Errorf("vault response is missing ciphertext")
+	}
+	return []byte(ciphertext.String()), nil
+}
+
+// Decrypt implements the VaultClient interface
+func (c *VaultTransitClient) Decrypt(ciphertext []byte) ([]byte, error) {
+	resp, err := c.post("decrypt", map[string]string{"ciphertext": string(ciphertext)})
+	if err != nil {
+		return nil, err
+	}
+	plaintext := gjson.GetBytes(resp, "data.plaintext")
+	if !plaintext.Exists() {
+		return nil, fmt.Errorf("vault response is missing plaintext")
+	}
+	return base64.StdEncoding.DecodeString(plaintext.String())
+}
+
+func (c *VaultTransitClient) post(operation string, body map[string]string) ([]byte, error) {
+	reqBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	reqURL, err := url.Parse(c.addr)
+	if err != nil {
+		return nil, err
+	}
+	reqURL.Path = fmt.Sprintf("/v1/transit/%s/%s", operation, c.keyName)
+
+	req, err := http.NewRequest(http.MethodPost, reqURL.String(), bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", c.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault returned %d %s: %s", resp.StatusCode, http.StatusText(resp.StatusCode), gjson.GetBytes(respBody, "errors").String())
+	}
+	return respBody, nil
+}
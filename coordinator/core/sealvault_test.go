@@ -0,0 +1,97 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeVault emulates the encrypt and decrypt endpoints of Vault's transit secrets engine
+func fakeVault(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "token", r.Header.Get("X-Vault-Token"))
+		var body map[string]string
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		switch r.URL.Path {
+		case "/v1/transit/encrypt/key":
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]string{"ciphertext": "vault:v1:" + body["plaintext"]}})
+		case "/v1/transit/decrypt/key":
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]string{"plaintext": strings.TrimPrefix(body["ciphertext"], "vault:v1:")}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{"errors": []string{"no handler for route"}})
+		}
+	}))
+}
+
+func TestVaultSealer(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	sealDir, err := ioutil.TempDir("", "")
+	require.NoError(err)
+	defer os.RemoveAll(sealDir)
+
+	vault := fakeVault(t)
+	defer vault.Close()
+
+	// nothing sealed yet
+	sealer := NewVaultSealer(sealDir, NewVaultTransitClient(vault.URL, "token", "key"))
+	unencryptedData, decryptedData, err := sealer.Unseal()
+	require.NoError(err)
+	assert.Nil(unencryptedData)
+	assert.Nil(decryptedData)
+
+	require.NoError(sealer.Seal([]byte("recovery"), []byte("state")))
+
+	// the encryption key must not be stored in plaintext
+	wrappedKey, err := ioutil.ReadFile(sealer.getFname(SealedKeyFname))
+	require.NoError(err)
+	assert.True(strings.HasPrefix(string(wrappedKey), "vault:v1:"))
+
+	// round trip with a fresh sealer, which needs to unwrap the key with vault
+	sealer = NewVaultSealer(sealDir, NewVaultTransitClient(vault.URL, "token", "key"))
+	unencryptedData, decryptedData, err = sealer.Unseal()
+	require.NoError(err)
+	assert.Equal([]byte("recovery"), unencryptedData)
+	assert.Equal([]byte("state"), decryptedData)
+
+	// wrong transit key
+	sealer = NewVaultSealer(sealDir, NewVaultTransitClient(vault.URL, "token", "other"))
+	_, _, err = sealer.Unseal()
+	assert.Error(err)
+}
+
+func TestVaultSealerUnreachable(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	sealDir, err := ioutil.TempDir("", "")
+	require.NoError(err)
+	defer os.RemoveAll(sealDir)
+
+	vault := fakeVault(t)
+	sealer := NewVaultSealer(sealDir, NewVaultTransitClient(vault.URL, "token", "key"))
+	require.NoError(sealer.Seal(nil, []byte("state")))
+	vault.Close()
+
+	sealer = NewVaultSealer(sealDir, NewVaultTransitClient(vault.URL, "token", "key"))
+	_, _, err = sealer.Unseal()
+	assert.Error(err)
+	assert.NotEqual(ErrEncryptionKey, err)
+	assert.Error(sealer.SetEncryptionKey([]byte("key")))
+}
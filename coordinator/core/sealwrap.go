@@ -0,0 +1,146 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/edgelesssys/ego/ecrypto"
+)
+
+// keyWrapper wraps and unwraps the state encryption key using an external key management service
+type keyWrapper interface {
+	Encrypt(plaintext []byte) (ciphertext []byte, err error)
+	Decrypt(ciphertext []byte) (plaintext []byte, err error)
+}
+
+// ConnectSealer checks that the key management service of a sealer wrapping its encryption key, e.g., the VaultSealer, is reachable.
+// Sealers without such a backend are always connected.
+func ConnectSealer(sealer Sealer) error {
+	if s, ok := sealer.(interface{ connect() error }); ok {
+		return s.connect()
+	}
+	return nil
+}
+
+// keyWrappingSealer encrypts the state with AES-GCM and stores the encryption key wrapped by a keyWrapper
+type keyWrappingSealer struct {
+	sealDir       string
+	wrapper       keyWrapper
+	service       string
+	encryptionKey []byte
+}
+
+// Unseal reads the stored information from the fs and decrypts it using the unwrapped encryption key
+func (s *keyWrappingSealer) Unseal() ([]byte, []byte, error) {
+	sealedData, err := ioutil.ReadFile(s.getFname(SealedDataFname))
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	unencryptedData, ciphertext, err := splitSealedData(sealedData)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := s.unwrapEncryptionKey(); err != nil {
+		if os.IsNotExist(err) {
+			return unencryptedData, nil, ErrEncryptionKey
+		}
+		return unencryptedData, nil, err
+	}
+
+	decryptedData, err := ecrypto.Decrypt(ciphertext, s.encryptionKey)
+	if err != nil {
+		return unencryptedData, nil, ErrEncryptionKey
+	}
+
+	return unencryptedData, decryptedData, nil
+}
+
+// Seal encrypts and stores information to the fs
+func (s *keyWrappingSealer) Seal(unencryptedData []byte, toBeEncrypted []byte) error {
+	// If we don't have an encryption key yet, generate one
+	if err := s.unwrapEncryptionKey(); err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		encryptionKey := make([]byte, 16)
+		if _, err := rand.Read(encryptionKey); err != nil {
+			return err
+		}
+		if err := s.SetEncryptionKey(encryptionKey); err != nil {
+			return err
+		}
+	}
+
+	encryptedData, err := ecrypto.Encrypt(toBeEncrypted, s.encryptionKey)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(s.getFname(SealedDataFname), joinSealedData(unencryptedData, encryptedData), 0600)
+}
+
+// SetEncryptionKey wraps the encryption key and stores it to the fs
+func (s *keyWrappingSealer) SetEncryptionKey(encryptionKey []byte) error {
+	wrappedKey, err := s.wrapper.Encrypt(encryptionKey)
+	if err != nil {
+		return fmt.Errorf("wrapping encryption key with %v failed: %v", s.service, err)
+	}
+	if err := ioutil.WriteFile(s.getFname(SealedKeyFname), wrappedKey, 0600); err != nil {
+		return err
+	}
+	s.encryptionKey = encryptionKey
+	return nil
+}
+
+// GetEncryptionKey returns the unwrapped key used to encrypt the state
+func (s *keyWrappingSealer) GetEncryptionKey() ([]byte, error) {
+	if err := s.unwrapEncryptionKey(); err != nil {
+		return nil, err
+	}
+	return s.encryptionKey, nil
+}
+
+// connect unwraps the stored encryption key to check that the key management service is reachable.
+// A missing key isn't an error, it is generated on the first seal.
+func (s *keyWrappingSealer) connect() error {
+	if err := s.unwrapEncryptionKey(); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *keyWrappingSealer) unwrapEncryptionKey() error {
+	if s.encryptionKey != nil {
+		return nil
+	}
+
+	wrappedKey, err := ioutil.ReadFile(s.getFname(SealedKeyFname))
+	if err != nil {
+		return err
+	}
+
+	encryptionKey, err := s.wrapper.Decrypt(wrappedKey)
+	if err != nil {
+		return fmt.Errorf("unwrapping encryption key with %v failed: %v", s.service, err)
+	}
+
+	s.encryptionKey = encryptionKey
+	return nil
+}
+
+func (s *keyWrappingSealer) getFname(basename string) string {
+	return filepath.Join(s.sealDir, basename)
+}
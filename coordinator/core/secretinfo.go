@@ -0,0 +1,65 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SecretInfo describes a secret declared in the manifest. It never holds the value of the secret.
+type SecretInfo struct {
+	Name string
+	Type string
+	Size uint `json:",omitempty"`
+	// Shared secrets are generated once by the Coordinator and delivered to all marbles.
+	// The others are generated by the Coordinator for each marble during its activation.
+	Shared bool
+	// Version is the version of a shared secret, which is incremented on each rotation
+	Version uint64 `json:",omitempty"`
+	// Certificate is set for secrets of the types cert-*
+	Certificate bool
+	// ValidFor is the validity in days of certificates declared with a relative validity
+	ValidFor uint `json:",omitempty"`
+	// NotBefore and NotAfter are the validity of the certificate of a shared secret
+	NotBefore *time.Time `json:",omitempty"`
+	NotAfter  *time.Time `json:",omitempty"`
+	FileMode  string     `json:",omitempty"`
+	Env       string     `json:",omitempty"`
+}
+
+// GetSecrets returns the secrets declared in the manifest, sorted by name, without their values
+func (c *Core) GetSecrets(ctx context.Context) []SecretInfo {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	infos := make([]SecretInfo, 0, len(c.manifest.Secrets))
+	for name, secret := range c.manifest.Secrets {
+		info := SecretInfo{
+			Name:        name,
+			Type:        secret.Type,
+			Size:        secret.Size,
+			Shared:      secret.Shared,
+			Certificate: strings.HasPrefix(secret.Type, "cert-"),
+			ValidFor:    secret.ValidFor,
+			FileMode:    secret.FileMode,
+			Env:         secret.Env,
+		}
+		if secret.Shared {
+			info.Version = c.secretVersion(name)
+			if generated, ok := c.secrets[name]; ok && info.Certificate {
+				notBefore, notAfter := generated.Cert.NotBefore, generated.Cert.NotAfter
+				info.NotBefore, info.NotAfter = &notBefore, &notAfter
+			}
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
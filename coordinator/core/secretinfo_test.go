@@ -0,0 +1,84 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/edgelesssys/marblerun/coordinator/quote"
+	"github.com/edgelesssys/marblerun/coordinator/recovery"
+	"github.com/edgelesssys/marblerun/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestGetSecrets(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	c, err := NewCore([]string{"localhost"}, quote.NewMockValidator(), quote.NewMockIssuer(), &MockSealer{}, recovery.NewSinglePartyRecovery(), zap.NewNop())
+	require.NoError(err)
+	assert.Empty(c.GetSecrets(context.TODO()))
+
+	_, err = c.SetManifest(context.TODO(), []byte(test.ManifestJSON))
+	require.NoError(err)
+	_, err = c.RotateSecret(context.TODO(), "symmetric_key_shared")
+	require.NoError(err)
+
+	secrets := c.GetSecrets(context.TODO())
+	require.Len(secrets, 4)
+
+	certPrivate := secrets[0]
+	assert.Equal("cert_private", certPrivate.Name)
+	assert.Equal("cert-rsa", certPrivate.Type)
+	assert.EqualValues(2048, certPrivate.Size)
+	assert.False(certPrivate.Shared)
+	assert.True(certPrivate.Certificate)
+	assert.EqualValues(7, certPrivate.ValidFor)
+	assert.Zero(certPrivate.Version)
+	assert.Nil(certPrivate.NotAfter)
+
+	certShared := secrets[1]
+	assert.Equal("cert_shared", certShared.Name)
+	assert.Equal("cert-ed25519", certShared.Type)
+	assert.True(certShared.Shared)
+	assert.True(certShared.Certificate)
+	assert.EqualValues(1, certShared.Version)
+	require.NotNil(certShared.NotBefore)
+	require.NotNil(certShared.NotAfter)
+	assert.Equal(c.secrets["cert_shared"].Cert.NotAfter, *certShared.NotAfter)
+
+	keyPrivate := secrets[2]
+	assert.Equal("symmetric_key_private", keyPrivate.Name)
+	assert.Equal("symmetric-key", keyPrivate.Type)
+	assert.False(keyPrivate.Shared)
+	assert.False(keyPrivate.Certificate)
+
+	keyShared := secrets[3]
+	assert.Equal("symmetric_key_shared", keyShared.Name)
+	assert.True(keyShared.Shared)
+	assert.EqualValues(2, keyShared.Version)
+	assert.Nil(keyShared.NotAfter)
+
+	// the values of the secrets are never exposed
+	rawSecrets, err := json.Marshal(secrets)
+	require.NoError(err)
+	for _, secret := range c.secrets {
+		for _, value := range [][]byte{secret.Private, secret.Public, secret.Cert.Raw} {
+			if len(value) == 0 {
+				continue
+			}
+			assert.NotContains(string(rawSecrets), base64.StdEncoding.EncodeToString(value))
+			assert.NotContains(string(rawSecrets), hex.EncodeToString(value))
+		}
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/edgelesssys/marblerun/coordinator/manifest"
+	"github.com/edgelesssys/marblerun/coordinator/rpc"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrUnknownSecret is returned if the manifest does not define a secret with the given name
+var ErrUnknownSecret = errors.New("no secret with the given name is defined in the manifest")
+
+// ErrSecretNotShared is returned when rotating a secret which is unique to each marble
+var ErrSecretNotShared = errors.New("only shared secrets can be rotated")
+
+// secretVersion returns the current version of a shared secret. The caller must hold the lock.
+func (c *Core) secretVersion(name string) uint64 {
+	if version, ok := c.secretVersions[name]; ok {
+		return version
+	}
+	return 1
+}
+
+// RotateSecret generates a new value for a shared secret and returns its version.
+// Marbles activated afterwards receive the new value, active marbles can fetch it using GetSecret.
+func (c *Core) RotateSecret(ctx context.Context, name string) (uint64, error) {
+	defer c.mux.Unlock()
+	if err := c.requireState(stateAcceptingMarbles); err != nil {
+		return 0, err
+	}
+
+	secret, ok := c.manifest.Secrets[name]
+	if !ok {
+		return 0, ErrUnknownSecret
+	}
+	if !secret.Shared {
+		return 0, ErrSecretNotShared
+	}
+
+	newSecrets, err := c.generateSecrets(ctx, map[string]manifest.Secret{name: secret}, uuid.Nil, c.intermediateCert, c.intermediatePrivK)
+	if err != nil {
+		c.zaplogger.Error("Could not generate the rotated secret.", zap.String("name", name), zap.Error(err))
+		return 0, err
+	}
+
+	oldSecret := c.secrets[name]
+	oldVersion := c.secretVersion(name)
+	c.secrets[name] = newSecrets[name]
+	c.secretVersions[name] = oldVersion + 1
+
+	recoveryData, err := c.recovery.GetRecoveryData()
+	if err == nil {
+		err = c.sealState(recoveryData)
+	}
+	if err != nil {
+		c.zaplogger.Error("Could not seal the rotated secret.", zap.Error(err))
+		c.secrets[name] = oldSecret
+		c.secretVersions[name] = oldVersion
+		return 0, err
+	}
+
+	c.zaplogger.Info("Rotated secret", zap.String("name", name), zap.Uint64("version", oldVersion+1))
+	c.events.add(Event{Timestamp: time.Now().UTC(), Level: EventLevelInfo, Message: "secret " + name + " rotated"})
+	return oldVersion + 1, nil
+}
+
+// GetSecret implements the MarbleAPI function to deliver the current version of a shared secret (implements the MarbleServer interface)
+//
+// The marble must authenticate with the certificate it received during activation. Its lease must not be revoked.
+// Only secrets referenced in the parameters of the marble's type are delivered.
+// A request for an older version than the current one is rejected, so marbles notice that the secret was rotated.
+func (c *Core) GetSecret(ctx context.Context, req *rpc.SecretReq) (*rpc.SecretResp, error) {
+	defer c.mux.Unlock()
+	if err := c.requireState(stateAcceptingMarbles); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, "cannot serve secrets in current state")
+	}
+
+	tlsCert := getClientTLSCert(ctx)
+	if tlsCert == nil || tlsCert.CheckSignatureFrom(c.intermediateCert) != nil {
+		return nil, status.Error(codes.Unauthenticated, "marble certificate was not issued by the coordinator")
+	}
+	lease, ok := c.leases[tlsCert.Subject.CommonName]
+	if !ok || lease.Revoked {
+		return nil, status.Error(codes.PermissionDenied, "marble is not active")
+	}
+
+	secret, ok := c.secrets[req.GetName()]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "unknown shared secret")
+	}
+	var params *rpc.Parameters
+	if marble, ok := c.manifest.Marbles[lease.MarbleType]; ok {
+		params = marble.Parameters
+	}
+	if !referencedSecrets(params)[req.GetName()] {
+		return nil, status.Errorf(codes.PermissionDenied, "secret %s is not referenced by marble type %s", req.GetName(), lease.MarbleType)
+	}
+	version := c.secretVersion(req.GetName())
+	if req.GetVersion() != 0 && req.GetVersion() < version {
+		return nil, status.Errorf(codes.FailedPrecondition, "secret version %d has been rotated, the current version is %d", req.GetVersion(), version)
+	}
+	if req.GetVersion() > version {
+		return nil, status.Errorf(codes.NotFound, "secret version %d does not exist", req.GetVersion())
+	}
+
+	return &rpc.SecretResp{
+		Name:    req.GetName(),
+		Version: version,
+		Cert:    secret.Cert.Raw,
+		Private: secret.Private,
+		Public:  secret.Public,
+	}, nil
+}
@@ -0,0 +1,130 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+
+	libMarble "github.com/edgelesssys/ego/marble"
+	"github.com/edgelesssys/marblerun/coordinator/manifest"
+	"github.com/edgelesssys/marblerun/coordinator/quote"
+	"github.com/edgelesssys/marblerun/coordinator/recovery"
+	"github.com/edgelesssys/marblerun/coordinator/rpc"
+	"github.com/edgelesssys/marblerun/test"
+	"github.com/edgelesssys/marblerun/util"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestRotateSecret(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var mnf manifest.Manifest
+	require.NoError(json.Unmarshal([]byte(test.ManifestJSON), &mnf))
+
+	validator := quote.NewMockValidator()
+	issuer := quote.NewMockIssuer()
+	sealer := &MockSealer{}
+	coreServer, err := NewCore([]string{"localhost"}, validator, issuer, sealer, recovery.NewSinglePartyRecovery(), zap.NewNop())
+	require.NoError(err)
+	_, err = coreServer.SetManifest(context.TODO(), []byte(test.ManifestJSON))
+	require.NoError(err)
+
+	peerContext := func(cert *x509.Certificate) context.Context {
+		return peer.NewContext(context.TODO(), &peer.Peer{
+			AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}},
+		})
+	}
+
+	// activate creates a marble and returns the certificate it received to fetch secrets
+	activate := func(marbleType string, marbleUUID string) *x509.Certificate {
+		cert, csr, _ := util.MustGenerateTestMarbleCredentials()
+		marbleQuote, err := issuer.Issue(cert.Raw)
+		require.NoError(err)
+		validator.AddValidQuote(marbleQuote, cert.Raw, mnf.Packages[mnf.Marbles[marbleType].Package], mnf.Infrastructures["Azure"])
+		resp, err := coreServer.Activate(peerContext(cert), &rpc.ActivationReq{CSR: csr, MarbleType: marbleType, Quote: marbleQuote, UUID: marbleUUID})
+		require.NoError(err)
+		block, _ := pem.Decode([]byte(resp.GetParameters().Env[libMarble.MarbleEnvironmentCertificateChain]))
+		require.NotNil(block)
+		marbleCert, err := x509.ParseCertificate(block.Bytes)
+		require.NoError(err)
+		return marbleCert
+	}
+
+	// backend_first references all shared secrets
+	marbleUUID := uuid.New().String()
+	ctx := peerContext(activate("backend_first", marbleUUID))
+
+	initial, err := coreServer.GetSecret(ctx, &rpc.SecretReq{Name: "symmetric_key_shared"})
+	require.NoError(err)
+	assert.EqualValues(1, initial.Version)
+	assert.Len(initial.Private, 16)
+
+	// rotate the secret
+	version, err := coreServer.RotateSecret(context.TODO(), "symmetric_key_shared")
+	require.NoError(err)
+	assert.EqualValues(2, version)
+
+	// the marble fetches the new version, the old one is rejected
+	rotated, err := coreServer.GetSecret(ctx, &rpc.SecretReq{Name: "symmetric_key_shared"})
+	require.NoError(err)
+	assert.EqualValues(2, rotated.Version)
+	assert.NotEqual(initial.Private, rotated.Private)
+	_, err = coreServer.GetSecret(ctx, &rpc.SecretReq{Name: "symmetric_key_shared", Version: 1})
+	assert.Equal(codes.FailedPrecondition, status.Code(err))
+	_, err = coreServer.GetSecret(ctx, &rpc.SecretReq{Name: "symmetric_key_shared", Version: 3})
+	assert.Equal(codes.NotFound, status.Code(err))
+
+	// certificates are rotated as well
+	version, err = coreServer.RotateSecret(context.TODO(), "cert_shared")
+	require.NoError(err)
+	assert.EqualValues(2, version)
+	rotated, err = coreServer.GetSecret(ctx, &rpc.SecretReq{Name: "cert_shared", Version: 2})
+	require.NoError(err)
+	assert.NotEmpty(rotated.Cert)
+
+	// only shared secrets defined in the manifest can be rotated
+	_, err = coreServer.RotateSecret(context.TODO(), "symmetric_key_private")
+	assert.Equal(ErrSecretNotShared, err)
+	_, err = coreServer.RotateSecret(context.TODO(), "unknown")
+	assert.Equal(ErrUnknownSecret, err)
+
+	// marbles only receive the secrets their type references
+	otherCtx := peerContext(activate("backend_other", uuid.New().String()))
+	_, err = coreServer.GetSecret(otherCtx, &rpc.SecretReq{Name: "cert_shared"})
+	assert.NoError(err)
+	_, err = coreServer.GetSecret(otherCtx, &rpc.SecretReq{Name: "symmetric_key_shared"})
+	assert.Equal(codes.PermissionDenied, status.Code(err))
+
+	// certificates not issued by the coordinator, like the one used for activation, are not accepted
+	cert, _, _ := util.MustGenerateTestMarbleCredentials()
+	_, err = coreServer.GetSecret(peerContext(cert), &rpc.SecretReq{Name: "symmetric_key_shared"})
+	assert.Equal(codes.Unauthenticated, status.Code(err))
+
+	// revoked marbles can't fetch secrets
+	require.NoError(coreServer.RevokeMarble(context.TODO(), marbleUUID))
+	_, err = coreServer.GetSecret(ctx, &rpc.SecretReq{Name: "symmetric_key_shared"})
+	assert.Equal(codes.PermissionDenied, status.Code(err))
+
+	// rotated secrets and their versions are persisted
+	coreServer2, err := NewCore([]string{"localhost"}, validator, issuer, sealer, recovery.NewSinglePartyRecovery(), zap.NewNop())
+	require.NoError(err)
+	assert.EqualValues(2, coreServer2.secretVersion("symmetric_key_shared"))
+	assert.Equal(coreServer.secrets["symmetric_key_shared"].Private, coreServer2.secrets["symmetric_key_shared"].Private)
+}
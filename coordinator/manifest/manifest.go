@@ -15,7 +15,12 @@ import (
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"os"
+	"strconv"
+	"strings"
 	"text/template"
+	"text/template/parse"
+	"time"
 
 	"github.com/edgelesssys/marblerun/coordinator/quote"
 	"github.com/edgelesssys/marblerun/coordinator/rpc"
@@ -53,6 +58,26 @@ type Marble struct {
 	Parameters *rpc.Parameters
 	// TLS holds a list of tags which are specified in the manifest
 	TLS []string
+	// DNSNames are the alternative DNS names of the certificates issued to marbles of this type.
+	// They are only used if a marble does not request DNS names itself, e.g., because EDG_MARBLE_DNS_NAMES is not set outside of Kubernetes.
+	DNSNames []string `json:",omitempty"`
+	// CertificateValidity is the validity of the certificates issued to marbles of this type as duration, e.g., "720h".
+	// It must not exceed the remaining validity of the Coordinator's certificate. If empty, the certificates are valid as long as the Coordinator's certificate.
+	CertificateValidity string `json:",omitempty"`
+	// Kubernetes holds settings the marble-injector applies to pods of this marble, e.g., additional volumes. The Coordinator does not interpret them.
+	Kubernetes json.RawMessage `json:",omitempty"`
+}
+
+// GetCertificateValidity returns the validity of the certificates issued to marbles of this type, or 0 if it is not set
+func (m Marble) GetCertificateValidity() (time.Duration, error) {
+	if m.CertificateValidity == "" {
+		return 0, nil
+	}
+	validity, err := time.ParseDuration(m.CertificateValidity)
+	if err != nil || validity <= 0 {
+		return 0, fmt.Errorf("invalid certificate validity: %v", m.CertificateValidity)
+	}
+	return validity, nil
 }
 
 // TLStag describes which entries should be used to determine the ttls connections of a marble
@@ -80,11 +105,43 @@ func (m Manifest) Check(ctx context.Context, zaplogger *zap.Logger) error {
 	// if len(m.Infrastructures) <= 0 {
 	// 	return errors.New("no allowed infrastructures defined")
 	// }
+	envSecrets := make(map[string]string)
+	for name, secret := range m.Secrets {
+		if _, err := secret.GetFileMode(); err != nil {
+			return fmt.Errorf("secret %s: %v", name, err)
+		}
+		if secret.Env == "" {
+			continue
+		}
+		if secret.Type != "symmetric-key" {
+			return fmt.Errorf("secret %s: only symmetric keys can be injected as environment variables", name)
+		}
+		if strings.HasPrefix(secret.Env, "EDG_") || strings.ContainsAny(secret.Env, "=\x00") {
+			return fmt.Errorf("secret %s: invalid environment variable name: %v", name, secret.Env)
+		}
+		if other, ok := envSecrets[secret.Env]; ok {
+			return fmt.Errorf("secrets %s and %s are injected into the same environment variable %s", other, name, secret.Env)
+		}
+		envSecrets[secret.Env] = name
+	}
 	for idx, marble := range m.Marbles {
 		if marble.Parameters == nil {
 			marble.Parameters = &rpc.Parameters{}
 			m.Marbles[idx] = marble
 		}
+		for env := range marble.Parameters.Env {
+			if secretName, ok := envSecrets[env]; ok {
+				return fmt.Errorf("marble %s defines the environment variable %s, which is reserved for secret %s", idx, env, secretName)
+			}
+		}
+		for _, name := range marble.DNSNames {
+			if strings.TrimSpace(name) == "" {
+				return fmt.Errorf("marble %s defines an empty DNS name", idx)
+			}
+		}
+		if _, err := marble.GetCertificateValidity(); err != nil {
+			return fmt.Errorf("marble %s: %v", idx, err)
+		}
 		singlePackage, ok := m.Packages[marble.Package]
 		if !ok {
 			return errors.New("manifest does not contain marble package " + marble.Package)
@@ -133,6 +190,25 @@ type Secret struct {
 	ValidFor uint
 	Private  PrivateKey
 	Public   PublicKey
+	// FileMode holds the octal permissions (e.g. "0400") of the files the secret is written to by a marble. Defaults to 0600.
+	FileMode string `json:",omitempty"`
+	// Env holds the name of an environment variable the hex-encoded secret is injected into. Only marbles whose parameters reference the secret receive it. Only supported for symmetric keys.
+	Env string `json:",omitempty"`
+}
+
+// DefaultSecretFileMode are the permissions of files containing secrets if no FileMode is set
+const DefaultSecretFileMode os.FileMode = 0600
+
+// GetFileMode returns the permissions of files containing the secret
+func (s Secret) GetFileMode() (os.FileMode, error) {
+	if s.FileMode == "" {
+		return DefaultSecretFileMode, nil
+	}
+	mode, err := strconv.ParseUint(s.FileMode, 8, 32)
+	if err != nil || mode > uint64(os.ModePerm) {
+		return 0, fmt.Errorf("invalid file mode: %v", s.FileMode)
+	}
+	return os.FileMode(mode), nil
 }
 
 // Certificate is an x509.Certificate
@@ -234,6 +310,11 @@ func (m Manifest) CheckUpdate(ctx context.Context, originalPackages map[string]q
 		return errors.New("no packages defined")
 	}
 
+	// Only package settings can be updated, everything else is immutable
+	if len(m.Infrastructures) > 0 || len(m.Marbles) > 0 || len(m.Admins) > 0 || len(m.Clients) > 0 || len(m.Secrets) > 0 || len(m.RecoveryKeys) > 0 || len(m.TLS) > 0 {
+		return errors.New("update manifest contains fields which cannot be updated")
+	}
+
 	// Check if manifest update contains values which we normally should not update
 	for packageName, singlePackage := range m.Packages {
 		// Check if the original manifest does even contain the package we want to update
@@ -280,3 +361,44 @@ func warnOrFailForMissingValue(debugMode bool, parameter string, packageName str
 
 	return fmt.Errorf("manifest misses value for %s in package %s", parameter, packageName)
 }
+
+// ReferencedSecrets returns the names of the user-defined secrets referenced as .Secrets.<name> in a template of the marble parameters
+func ReferencedSecrets(node parse.Node) []string {
+	var names []string
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			names = append(names, ReferencedSecrets(child)...)
+		}
+	case *parse.ActionNode:
+		names = ReferencedSecrets(n.Pipe)
+	case *parse.PipeNode:
+		if n == nil {
+			return nil
+		}
+		for _, cmd := range n.Cmds {
+			names = append(names, ReferencedSecrets(cmd)...)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			names = append(names, ReferencedSecrets(arg)...)
+		}
+	case *parse.FieldNode:
+		if len(n.Ident) >= 2 && n.Ident[0] == "Secrets" {
+			names = append(names, n.Ident[1])
+		}
+	case *parse.IfNode:
+		names = ReferencedSecrets(&n.BranchNode)
+	case *parse.RangeNode:
+		names = ReferencedSecrets(&n.BranchNode)
+	case *parse.WithNode:
+		names = ReferencedSecrets(&n.BranchNode)
+	case *parse.BranchNode:
+		names = append(ReferencedSecrets(n.Pipe), ReferencedSecrets(n.List)...)
+		names = append(names, ReferencedSecrets(n.ElseList)...)
+	}
+	return names
+}
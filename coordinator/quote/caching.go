@@ -0,0 +1,117 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package quote
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrPropertiesUnsupported is returned by CachingValidator.PackageProperties if the wrapped validator can't read package properties
+var ErrPropertiesUnsupported = errors.New("the validator can't read the package properties of quotes")
+
+// CachingValidator wraps a Validator and caches its results for a TTL, so identical quotes are not validated again, e.g., on retried activations.
+// A cached result does not reflect changes of the collateral within the TTL, e.g., a revoked TCB.
+// Only successful validations are cached, unless failures are cached with SetCacheFailures.
+type CachingValidator struct {
+	validator     Validator
+	ttl           time.Duration
+	cacheFailures bool
+	now           func() time.Time
+
+	mutex   sync.Mutex
+	entries map[[sha256.Size]byte]cachedResult
+}
+
+// cachedResult is the result of a validation and its expiry
+type cachedResult struct {
+	err     error
+	expires time.Time
+}
+
+// NewCachingValidator returns a CachingValidator caching the results of validator for ttl
+func NewCachingValidator(validator Validator, ttl time.Duration) *CachingValidator {
+	return &CachingValidator{
+		validator: validator,
+		ttl:       ttl,
+		now:       time.Now,
+		entries:   make(map[[sha256.Size]byte]cachedResult),
+	}
+}
+
+// SetCacheFailures sets whether failed validations are cached as well
+func (v *CachingValidator) SetCacheFailures(cacheFailures bool) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.cacheFailures = cacheFailures
+}
+
+// Validate implements the Validator interface
+func (v *CachingValidator) Validate(quote []byte, message []byte, pp PackageProperties, ip InfrastructureProperties) error {
+	key, err := cacheKey(quote, message, pp, ip)
+	if err != nil {
+		return err
+	}
+
+	v.mutex.Lock()
+	result, ok := v.entries[key]
+	v.mutex.Unlock()
+	if ok && v.now().Before(result.expires) {
+		return result.err
+	}
+
+	err = v.validator.Validate(quote, message, pp, ip)
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	now := v.now()
+	for key, result := range v.entries {
+		if !now.Before(result.expires) {
+			delete(v.entries, key)
+		}
+	}
+	if err == nil || v.cacheFailures {
+		v.entries[key] = cachedResult{err: err, expires: now.Add(v.ttl)}
+	}
+	return err
+}
+
+// PackageProperties implements the PropertiesReader interface if the wrapped validator implements it
+func (v *CachingValidator) PackageProperties(quote []byte) (PackageProperties, error) {
+	reader, ok := v.validator.(PropertiesReader)
+	if !ok {
+		return PackageProperties{}, ErrPropertiesUnsupported
+	}
+	return reader.PackageProperties(quote)
+}
+
+// cacheKey returns the hash of the arguments of a validation
+func cacheKey(quote []byte, message []byte, pp PackageProperties, ip InfrastructureProperties) ([sha256.Size]byte, error) {
+	properties, err := json.Marshal(struct {
+		Package        PackageProperties
+		Infrastructure InfrastructureProperties
+	}{pp, ip})
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+
+	// length-prefix the variable-length fields, so different splits of the same bytes don't collide
+	hash := sha256.New()
+	for _, field := range [][]byte{quote, message, properties} {
+		length := make([]byte, 8)
+		binary.BigEndian.PutUint64(length, uint64(len(field)))
+		hash.Write(length)
+		hash.Write(field)
+	}
+	var key [sha256.Size]byte
+	copy(key[:], hash.Sum(nil))
+	return key, nil
+}
@@ -0,0 +1,82 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package quote
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingValidator counts the validations passed to the wrapped validator
+type countingValidator struct {
+	Validator
+	calls int
+}
+
+func (v *countingValidator) Validate(quote []byte, message []byte, pp PackageProperties, ip InfrastructureProperties) error {
+	v.calls++
+	return v.Validator.Validate(quote, message, pp, ip)
+}
+
+func TestCachingValidator(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	message := []byte("message")
+	pp := PackageProperties{SignerID: "signer"}
+	ip := InfrastructureProperties{RootCA: []byte("ca")}
+	issuer := NewMockIssuer()
+	quote, err := issuer.Issue(message)
+	require.NoError(err)
+	mock := NewMockValidator()
+	mock.AddValidQuote(quote, message, pp, ip)
+
+	wrapped := &countingValidator{Validator: mock}
+	validator := NewCachingValidator(wrapped, time.Minute)
+	now := time.Now()
+	validator.now = func() time.Time { return now }
+
+	// a cache hit skips the wrapped validator
+	assert.NoError(validator.Validate(quote, message, pp, ip))
+	assert.NoError(validator.Validate(quote, message, pp, ip))
+	assert.Equal(1, wrapped.calls)
+
+	// different properties are validated again
+	otherPP := PackageProperties{SignerID: "other"}
+	assert.Error(validator.Validate(quote, message, otherPP, ip))
+	assert.Equal(2, wrapped.calls)
+
+	// failures are not cached by default
+	assert.Error(validator.Validate(quote, message, otherPP, ip))
+	assert.Equal(3, wrapped.calls)
+
+	// entries expire after the TTL
+	now = now.Add(time.Minute)
+	assert.NoError(validator.Validate(quote, message, pp, ip))
+	assert.Equal(4, wrapped.calls)
+	assert.NoError(validator.Validate(quote, message, pp, ip))
+	assert.Equal(4, wrapped.calls)
+
+	// failures are cached if enabled
+	validator.SetCacheFailures(true)
+	err = validator.Validate(quote, []byte("other message"), pp, ip)
+	assert.True(errors.Is(err, ErrMessageMismatch), err)
+	err = validator.Validate(quote, []byte("other message"), pp, ip)
+	assert.True(errors.Is(err, ErrMessageMismatch), err)
+	assert.Equal(5, wrapped.calls)
+
+	// package properties are read by the wrapped validator
+	properties, err := NewCachingValidator(mock, time.Minute).PackageProperties(quote)
+	require.NoError(err)
+	assert.Equal(pp, properties)
+	_, err = NewCachingValidator(NewFailValidator(), time.Minute).PackageProperties(quote)
+	assert.Equal(ErrPropertiesUnsupported, err)
+}
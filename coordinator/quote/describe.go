@@ -0,0 +1,109 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package quote
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// layout of an SGX DCAP quote, see the Intel SGX ECDSA QuoteLibReference
+const (
+	oeHeaderSize        = 16
+	oeReportTypeRemote  = 2
+	quoteHeaderSize     = 48
+	reportBodySize      = 384
+	sgxFlagsDebug       = 0x02
+	signatureLengthSize = 4
+)
+
+// QuoteInfo holds the fields of an SGX quote, e.g., to inspect why its attestation failed.
+// The quote is parsed without verifying its signature, so the fields must not be trusted.
+type QuoteInfo struct {
+	// Version of the quote format
+	Version uint16
+	// AttestationKeyType is the type of the key signing the quote, 2 is ECDSA-256 with P-256
+	AttestationKeyType uint16
+	// QESVN is the security version number of the Quoting Enclave
+	QESVN uint16
+	// PCESVN is the security version number of the Provisioning Certification Enclave
+	PCESVN uint16
+	// QEVendorID identifies the vendor of the Quoting Enclave
+	QEVendorID string
+	// CPUSVN is the processor model and firmware security version number
+	CPUSVN string
+	// MiscSelect is the extended feature set of the enclave
+	MiscSelect uint32
+	// Attributes are the flags and XFRM of the enclave
+	Attributes string
+	// Debug is set if the enclave is debuggable
+	Debug bool
+	// UniqueID is the hash of the enclave (MRENCLAVE)
+	UniqueID string
+	// SignerID is the hash of the enclave signer's public key (MRSIGNER)
+	SignerID string
+	// ProductID is the product ID of the enclave (ISVProdID)
+	ProductID uint16
+	// SecurityVersion is the security version number of the enclave (ISVSVN)
+	SecurityVersion uint16
+	// ReportData is the data the enclave bound to the quote, e.g., the hash of its certificate
+	ReportData string
+	// SignatureSize is the size of the signature data, including the certification data of the attestation key
+	SignatureSize uint32
+}
+
+// DescribeQuote parses an SGX quote as issued by marbles and the coordinator, i.e., prefixed with an OpenEnclave report header, or a raw SGX quote.
+// It does not verify the quote.
+func DescribeQuote(quote []byte) (QuoteInfo, error) {
+	quote = stripOEHeader(quote)
+	if len(quote) < quoteHeaderSize+reportBodySize+signatureLengthSize {
+		return QuoteInfo{}, fmt.Errorf("quote too short: %d bytes", len(quote))
+	}
+
+	header := quote[:quoteHeaderSize]
+	body := quote[quoteHeaderSize : quoteHeaderSize+reportBodySize]
+	info := QuoteInfo{
+		Version:            binary.LittleEndian.Uint16(header[0:]),
+		AttestationKeyType: binary.LittleEndian.Uint16(header[2:]),
+		QESVN:              binary.LittleEndian.Uint16(header[8:]),
+		PCESVN:             binary.LittleEndian.Uint16(header[10:]),
+		QEVendorID:         hex.EncodeToString(header[12:28]),
+		CPUSVN:             hex.EncodeToString(body[0:16]),
+		MiscSelect:         binary.LittleEndian.Uint32(body[16:]),
+		Attributes:         hex.EncodeToString(body[48:64]),
+		Debug:              binary.LittleEndian.Uint64(body[48:])&sgxFlagsDebug != 0,
+		UniqueID:           hex.EncodeToString(body[64:96]),
+		SignerID:           hex.EncodeToString(body[128:160]),
+		ProductID:          binary.LittleEndian.Uint16(body[256:]),
+		SecurityVersion:    binary.LittleEndian.Uint16(body[258:]),
+		ReportData:         hex.EncodeToString(body[320:384]),
+		SignatureSize:      binary.LittleEndian.Uint32(quote[quoteHeaderSize+reportBodySize:]),
+	}
+	if info.Version != 3 {
+		return QuoteInfo{}, fmt.Errorf("unsupported quote version: %d", info.Version)
+	}
+	if uint64(info.SignatureSize) > uint64(len(quote)-quoteHeaderSize-reportBodySize-signatureLengthSize) {
+		return QuoteInfo{}, errors.New("quote too short: signature data is truncated")
+	}
+	return info, nil
+}
+
+// stripOEHeader removes the OpenEnclave report header of a remote report, if present
+func stripOEHeader(quote []byte) []byte {
+	if len(quote) < oeHeaderSize {
+		return quote
+	}
+	version := binary.LittleEndian.Uint32(quote[0:])
+	reportType := binary.LittleEndian.Uint32(quote[4:])
+	reportSize := binary.LittleEndian.Uint64(quote[8:])
+	if version != 1 || reportType != oeReportTypeRemote || reportSize != uint64(len(quote)-oeHeaderSize) {
+		return quote
+	}
+	return quote[oeHeaderSize:]
+}
@@ -0,0 +1,71 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package quote
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loadQuoteFixture reads the hex encoded quote of a debug enclave, prefixed with an OpenEnclave report header
+func loadQuoteFixture(t *testing.T) []byte {
+	rawHex, err := ioutil.ReadFile("testdata/quote.hex")
+	require.NoError(t, err)
+	quote, err := hex.DecodeString(strings.Join(strings.Fields(string(rawHex)), ""))
+	require.NoError(t, err)
+	return quote
+}
+
+func TestDescribeQuote(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	quote := loadQuoteFixture(t)
+	want := QuoteInfo{
+		Version:            3,
+		AttestationKeyType: 2,
+		QESVN:              6,
+		PCESVN:             11,
+		QEVendorID:         "939a7233f79c4ca9940a0db3957f0607",
+		CPUSVN:             "0f0f0205ff8003000000000000000000",
+		MiscSelect:         0,
+		Attributes:         "0700000000000000e700000000000000",
+		Debug:              true,
+		UniqueID:           "4a3ddd19d5e3e8b63b5de0fe8b4aa1ce9f0a79e0b6f1e6d3a7e1b7a3e4c1f2d5",
+		SignerID:           "43361affedeb75affee9baec7e054a5e14883213e5a121b67d74a0e12e9d2b7a",
+		ProductID:          3,
+		SecurityVersion:    2,
+		ReportData:         "b5d4a1ba3f9a94e8b4bc1e5d24ae5e0e33b2fdcf8b1c3b0e2a48e1d0e4f5c6a70000000000000000000000000000000000000000000000000000000000000000",
+		SignatureSize:      64,
+	}
+
+	info, err := DescribeQuote(quote)
+	require.NoError(err)
+	assert.Equal(want, info)
+
+	// raw SGX quotes without the OpenEnclave header are parsed as well
+	info, err = DescribeQuote(quote[16:])
+	require.NoError(err)
+	assert.Equal(want, info)
+
+	// truncated quotes
+	_, err = DescribeQuote(quote[:100])
+	assert.Error(err)
+	_, err = DescribeQuote(quote[16 : len(quote)-1])
+	assert.Error(err)
+
+	// unsupported version
+	invalid := append([]byte{}, quote[16:]...)
+	invalid[0] = 4
+	_, err = DescribeQuote(invalid)
+	assert.Error(err)
+}
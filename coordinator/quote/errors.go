@@ -0,0 +1,24 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package quote
+
+import "errors"
+
+// Errors returned by validators. Validators may wrap them with further details, so use errors.Is to check for them.
+var (
+	// ErrQuoteMismatch is returned if the quote is not valid
+	ErrQuoteMismatch = errors.New("wrong quote")
+	// ErrMessageMismatch is returned if the quote is valid, but was issued for another message
+	ErrMessageMismatch = errors.New("wrong message")
+	// ErrPackageNonCompliant is returned if the quoted package does not comply with the required package properties
+	ErrPackageNonCompliant = errors.New("package does not comply")
+	// ErrInfrastructureNonCompliant is returned if the quoted infrastructure does not comply with the required infrastructure properties
+	ErrInfrastructureNonCompliant = errors.New("infrastructure does not comply")
+	// ErrCollateralUnavailable is returned if the collateral required to validate the quote could not be fetched, e.g., because of a network error.
+	// Unlike the other errors, it does not tell anything about the quote, so validating it again may succeed.
+	ErrCollateralUnavailable = errors.New("collateral unavailable")
+)
@@ -7,9 +7,14 @@
 package quote
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
 	"strings"
 
-	"github.com/google/go-cmp/cmp"
+	"github.com/edgelesssys/ego/attestation"
 )
 
 // PackageProperties contains the enclave package-specific properties of an OpenEnclave quote.
@@ -30,7 +35,7 @@ type PackageProperties struct {
 // InfrastructureProperties contains the infrastructure-specific properties of a SGX DCAP quote.
 type InfrastructureProperties struct {
 	// Processor model and firmware security version number
-	// NOTE: the Intel manual states that CPUSVN "cannot be compared mathematically"
+	// NOTE: the Intel manual states that CPUSVN "cannot be compared mathematically", thus its components are compared one by one
 	CPUSVN []byte
 	// Quoting Enclave security version number
 	QESVN *uint16
@@ -51,17 +56,85 @@ func (required PackageProperties) IsCompliant(given PackageProperties) bool {
 	if len(required.SignerID) > 0 && !strings.EqualFold(required.SignerID, given.SignerID) {
 		return false
 	}
-	if required.ProductID != nil && *required.ProductID != *given.ProductID {
+	if required.ProductID != nil && (given.ProductID == nil || *required.ProductID != *given.ProductID) {
 		return false
 	}
-	if required.SecurityVersion != nil && *required.SecurityVersion > *given.SecurityVersion {
+	if required.SecurityVersion != nil && (given.SecurityVersion == nil || *required.SecurityVersion > *given.SecurityVersion) {
 		return false
 	}
 	return true
 }
 
 // IsCompliant checks if the given infrastructure properties comply with the requirements
+//
+// Security version numbers are treated as minimums, so a given infrastructure with a newer TCB is accepted.
 func (required InfrastructureProperties) IsCompliant(given InfrastructureProperties) bool {
-	// TODO: implement proper logic including SVN comparison
-	return cmp.Equal(required, given)
+	if len(required.CPUSVN) > 0 && !cpusvnIsCompliant(required.CPUSVN, given.CPUSVN) {
+		return false
+	}
+	if required.QESVN != nil && (given.QESVN == nil || *required.QESVN > *given.QESVN) {
+		return false
+	}
+	if required.PCESVN != nil && (given.PCESVN == nil || *required.PCESVN > *given.PCESVN) {
+		return false
+	}
+	if len(required.RootCA) > 0 && !bytes.Equal(required.RootCA, given.RootCA) {
+		return false
+	}
+	return true
+}
+
+// cpusvnIsCompliant checks if every component of the given CPUSVN is equal to or higher than the required one
+func cpusvnIsCompliant(required, given []byte) bool {
+	if len(required) != len(given) {
+		return false
+	}
+	for i := range required {
+		if required[i] > given[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// collateralErrors are the results of verifying a report which indicate that the collateral, e.g., the CRLs and TCB info, could not be fetched.
+// The SDK only exposes the result as error message, which may be prefixed with context, so the message is searched for them.
+var collateralErrors = []string{
+	"OE_QUOTE_PROVIDER_CALL_ERROR",
+}
+
+// ReportVerificationError wraps an error of verifying a report with ErrCollateralUnavailable if the collateral could not be fetched, or ErrQuoteMismatch otherwise
+func ReportVerificationError(err error) error {
+	for _, collateralErr := range collateralErrors {
+		if strings.Contains(err.Error(), collateralErr) {
+			return fmt.Errorf("%w: verifying quote failed: %v", ErrCollateralUnavailable, err)
+		}
+	}
+	return fmt.Errorf("%w: verifying quote failed: %v", ErrQuoteMismatch, err)
+}
+
+// ReportPackageProperties returns the package properties of a verified report
+func ReportPackageProperties(report attestation.Report) PackageProperties {
+	productID := binary.LittleEndian.Uint64(report.ProductID)
+	return PackageProperties{
+		UniqueID:        hex.EncodeToString(report.UniqueID),
+		SignerID:        hex.EncodeToString(report.SignerID),
+		Debug:           report.Debug,
+		ProductID:       &productID,
+		SecurityVersion: &report.SecurityVersion,
+	}
+}
+
+// CheckReport checks that a verified report was issued for cert and that the reported package complies with pp.
+// The returned error wraps ErrMessageMismatch or ErrPackageNonCompliant.
+func CheckReport(report attestation.Report, cert []byte, pp PackageProperties) error {
+	hash := sha256.Sum256(cert)
+	if len(report.Data) < len(hash) || !bytes.Equal(report.Data[:len(hash)], hash[:]) {
+		return fmt.Errorf("%w: hash(cert) != report.Data: %v != %v", ErrMessageMismatch, hash, report.Data)
+	}
+	reportedProps := ReportPackageProperties(report)
+	if !pp.IsCompliant(reportedProps) {
+		return fmt.Errorf("%w:\n%v\n%v", ErrPackageNonCompliant, reportedProps, pp)
+	}
+	return nil
 }
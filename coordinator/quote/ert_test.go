@@ -0,0 +1,207 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package quote
+
+import (
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/edgelesssys/ego/attestation"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInfrastructurePropertiesIsCompliant(t *testing.T) {
+	assert := assert.New(t)
+
+	qesvn := uint16(2)
+	pcesvn := uint16(3)
+	required := InfrastructureProperties{
+		CPUSVN: []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+		QESVN:  &qesvn,
+		PCESVN: &pcesvn,
+		RootCA: []byte{3, 3, 3},
+	}
+
+	// equal
+	given := InfrastructureProperties{
+		CPUSVN: []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+		QESVN:  &qesvn,
+		PCESVN: &pcesvn,
+		RootCA: []byte{3, 3, 3},
+	}
+	assert.True(required.IsCompliant(given))
+
+	// higher CPUSVN
+	given.CPUSVN = []byte{1, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 16}
+	assert.True(required.IsCompliant(given))
+
+	// lower CPUSVN
+	given.CPUSVN = []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 14}
+	assert.False(required.IsCompliant(given))
+
+	// mixed CPUSVN: one component higher, another one lower
+	given.CPUSVN = []byte{15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1, 0}
+	assert.False(required.IsCompliant(given))
+
+	// CPUSVN of different length
+	given.CPUSVN = []byte{0, 1, 2, 3}
+	assert.False(required.IsCompliant(given))
+	given.CPUSVN = required.CPUSVN
+
+	// higher QESVN
+	higherQESVN := uint16(3)
+	given.QESVN = &higherQESVN
+	assert.True(required.IsCompliant(given))
+
+	// lower QESVN
+	lowerQESVN := uint16(1)
+	given.QESVN = &lowerQESVN
+	assert.False(required.IsCompliant(given))
+
+	// missing QESVN
+	given.QESVN = nil
+	assert.False(required.IsCompliant(given))
+	given.QESVN = &qesvn
+
+	// lower PCESVN
+	lowerPCESVN := uint16(2)
+	given.PCESVN = &lowerPCESVN
+	assert.False(required.IsCompliant(given))
+	given.PCESVN = &pcesvn
+
+	// different RootCA
+	given.RootCA = []byte{4, 4, 4}
+	assert.False(required.IsCompliant(given))
+
+	// empty requirements accept everything
+	assert.True(InfrastructureProperties{}.IsCompliant(given))
+}
+
+func TestPackagePropertiesIsCompliant(t *testing.T) {
+	assert := assert.New(t)
+
+	productID := uint64(44)
+	securityVersion := uint(3)
+	required := PackageProperties{
+		SignerID:        "1f1e1d1c",
+		ProductID:       &productID,
+		SecurityVersion: &securityVersion,
+	}
+
+	given := PackageProperties{
+		SignerID:        "1F1E1D1C",
+		ProductID:       &productID,
+		SecurityVersion: &securityVersion,
+	}
+	assert.True(required.IsCompliant(given))
+
+	// higher security version
+	higherSecurityVersion := uint(4)
+	given.SecurityVersion = &higherSecurityVersion
+	assert.True(required.IsCompliant(given))
+
+	// missing properties don't comply
+	assert.False(required.IsCompliant(PackageProperties{SignerID: "1f1e1d1c", SecurityVersion: &securityVersion}))
+	assert.False(required.IsCompliant(PackageProperties{SignerID: "1f1e1d1c", ProductID: &productID}))
+}
+
+func TestReportVerificationError(t *testing.T) {
+	testCases := map[string]struct {
+		err     error
+		wantErr error
+	}{
+		"collateral unavailable": {
+			err:     errors.New("OE_QUOTE_PROVIDER_CALL_ERROR"),
+			wantErr: ErrCollateralUnavailable,
+		},
+		"collateral unavailable with context": {
+			err:     errors.New("oe_verify_remote_report failed: OE_QUOTE_PROVIDER_CALL_ERROR (oe_result_t=0x41)"),
+			wantErr: ErrCollateralUnavailable,
+		},
+		"invalid quote": {
+			err:     errors.New("OE_REPORT_PARSE_ERROR"),
+			wantErr: ErrQuoteMismatch,
+		},
+		"outdated TCB": {
+			err:     errors.New("OE_TCB_LEVEL_INVALID"),
+			wantErr: ErrQuoteMismatch,
+		},
+		"empty": {
+			err:     errors.New(""),
+			wantErr: ErrQuoteMismatch,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			err := ReportVerificationError(tc.err)
+			assert.ErrorIs(err, tc.wantErr)
+			assert.Contains(err.Error(), tc.err.Error())
+		})
+	}
+}
+
+func TestCheckReport(t *testing.T) {
+	cert := []byte("cert")
+	hash := sha256.Sum256(cert)
+	report := attestation.Report{
+		Data:            append(hash[:], make([]byte, 32)...),
+		SecurityVersion: 2,
+		UniqueID:        []byte{0xab, 0xcd},
+		SignerID:        []byte{0x12, 0x34},
+		ProductID:       []byte{3, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+	}
+	productID := uint64(3)
+	securityVersion := uint(2)
+	newerSecurityVersion := uint(3)
+
+	testCases := map[string]struct {
+		cert    []byte
+		pp      PackageProperties
+		wantErr error
+	}{
+		"compliant": {
+			cert: cert,
+			pp:   PackageProperties{SignerID: "1234", ProductID: &productID, SecurityVersion: &securityVersion},
+		},
+		"compliant unique ID": {
+			cert: cert,
+			pp:   PackageProperties{UniqueID: "ABCD"},
+		},
+		"other cert": {
+			cert:    []byte("other"),
+			pp:      PackageProperties{UniqueID: "abcd"},
+			wantErr: ErrMessageMismatch,
+		},
+		"outdated security version": {
+			cert:    cert,
+			pp:      PackageProperties{SignerID: "1234", ProductID: &productID, SecurityVersion: &newerSecurityVersion},
+			wantErr: ErrPackageNonCompliant,
+		},
+		"debug required": {
+			cert:    cert,
+			pp:      PackageProperties{UniqueID: "abcd", Debug: true},
+			wantErr: ErrPackageNonCompliant,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			err := CheckReport(report, tc.cert, tc.pp)
+			if tc.wantErr == nil {
+				assert.NoError(err)
+				return
+			}
+			assert.ErrorIs(err, tc.wantErr)
+		})
+	}
+}
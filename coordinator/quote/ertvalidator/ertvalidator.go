@@ -7,11 +7,7 @@
 package ertvalidator
 
 import (
-	"bytes"
 	"crypto/sha256"
-	"encoding/binary"
-	"encoding/hex"
-	"fmt"
 
 	"github.com/edgelesssys/ego/enclave"
 	"github.com/edgelesssys/marblerun/coordinator/quote"
@@ -31,30 +27,21 @@ func (m *ERTValidator) Validate(givenQuote []byte, cert []byte, pp quote.Package
 	// Verify Quote
 	report, err := enclave.VerifyRemoteReport(givenQuote)
 	if err != nil {
-		return fmt.Errorf("verifying quote failed: %v", err)
+		return quote.ReportVerificationError(err)
 	}
 
-	// Check that cert is equal
-	hash := sha256.Sum256(cert)
-	if !bytes.Equal(report.Data[:len(hash)], hash[:]) {
-		return fmt.Errorf("hash(cert) != report.Data: %v != %v", hash, report.Data)
-	}
+	// Check that cert is equal and verify PackageProperties
+	// TODO Verify InfrastructureProperties with information from OE Quote
+	return quote.CheckReport(report, cert, pp)
+}
 
-	// Verify PackageProperties
-	productID := binary.LittleEndian.Uint64(report.ProductID)
-	reportedProps := quote.PackageProperties{
-		UniqueID:        hex.EncodeToString(report.UniqueID),
-		SignerID:        hex.EncodeToString(report.SignerID),
-		Debug:           report.Debug,
-		ProductID:       &productID,
-		SecurityVersion: &report.SecurityVersion,
-	}
-	if !pp.IsCompliant(reportedProps) {
-		return fmt.Errorf("PackageProperties not compliant:\n%v\n%v", reportedProps, pp)
+// PackageProperties implements the PropertiesReader interface for ERTValidator
+func (m *ERTValidator) PackageProperties(givenQuote []byte) (quote.PackageProperties, error) {
+	report, err := enclave.VerifyRemoteReport(givenQuote)
+	if err != nil {
+		return quote.PackageProperties{}, quote.ReportVerificationError(err)
 	}
-
-	// TODO Verify InfrastructureProperties with information from OE Quote
-	return nil
+	return quote.ReportPackageProperties(report), nil
 }
 
 // ERTIssuer is a Quote issuer based on EdgelessRT
@@ -70,3 +57,16 @@ func (m *ERTIssuer) Issue(cert []byte) ([]byte, error) {
 	hash := sha256.Sum256(cert)
 	return enclave.GetRemoteReport(hash[:])
 }
+
+// Measurement implements the MeasurementReporter interface
+func (m *ERTIssuer) Measurement() ([]byte, error) {
+	reportBytes, err := enclave.GetRemoteReport(nil)
+	if err != nil {
+		return nil, err
+	}
+	report, err := enclave.VerifyRemoteReport(reportBytes)
+	if err != nil {
+		return nil, err
+	}
+	return report.UniqueID, nil
+}
@@ -21,7 +21,7 @@ func NewFailValidator() *FailValidator {
 
 // Validate implements the Validator interface for FailValidator
 func (m *FailValidator) Validate(quote []byte, cert []byte, pp PackageProperties, ip InfrastructureProperties) error {
-	return fmt.Errorf("cannot validate quote")
+	return fmt.Errorf("%w: cannot validate quote", ErrQuoteMismatch)
 }
 
 // FailIssuer always fails
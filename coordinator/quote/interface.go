@@ -13,8 +13,20 @@ type Validator interface {
 	Validate(quote []byte, cert []byte, pp PackageProperties, ip InfrastructureProperties) error
 }
 
+// PropertiesReader is implemented by validators which can read the package properties reported in a quote
+type PropertiesReader interface {
+	// PackageProperties returns the package properties reported in a valid quote
+	PackageProperties(quote []byte) (PackageProperties, error)
+}
+
 // Issuer issues quotes
 type Issuer interface {
 	// Issue issues a quote for remote attestation for a given message
 	Issue(cert []byte) (quote []byte, err error)
 }
+
+// MeasurementReporter is implemented by issuers which can report the measurement of the enclave they run in
+type MeasurementReporter interface {
+	// Measurement returns the UniqueID (MRENCLAVE) of the enclave
+	Measurement() ([]byte, error)
+}
@@ -9,7 +9,6 @@ package quote
 import (
 	"bytes"
 	"crypto/sha256"
-	"errors"
 	"sync"
 )
 
@@ -38,20 +37,31 @@ func (m *MockValidator) Validate(quote []byte, message []byte, pp PackagePropert
 	entry, found := m.valid[string(quote)]
 	m.mutex.Unlock()
 	if !found {
-		return errors.New("wrong quote")
+		return ErrQuoteMismatch
 	}
 	if !bytes.Equal(entry.message, message) {
-		return errors.New("wrong message")
+		return ErrMessageMismatch
 	}
 	if !pp.IsCompliant(entry.pp) {
-		return errors.New("package does not comply")
+		return ErrPackageNonCompliant
 	}
 	if !ip.IsCompliant(entry.ip) {
-		return errors.New("infrastructure does not comply")
+		return ErrInfrastructureNonCompliant
 	}
 	return nil
 }
 
+// PackageProperties implements the PropertiesReader interface
+func (m *MockValidator) PackageProperties(quote []byte) (PackageProperties, error) {
+	m.mutex.Lock()
+	entry, found := m.valid[string(quote)]
+	m.mutex.Unlock()
+	if !found {
+		return PackageProperties{}, ErrQuoteMismatch
+	}
+	return entry.pp, nil
+}
+
 // AddValidQuote adds a valid quote
 func (m *MockValidator) AddValidQuote(quote []byte, message []byte, pp PackageProperties, ip InfrastructureProperties) {
 	m.mutex.Lock()
@@ -60,15 +70,27 @@ func (m *MockValidator) AddValidQuote(quote []byte, message []byte, pp PackagePr
 }
 
 // MockIssuer is a mockup quote issuer
-type MockIssuer struct{}
+type MockIssuer struct {
+	measurement []byte
+}
 
 // NewMockIssuer returns a new MockIssuer object
 func NewMockIssuer() *MockIssuer {
 	return &MockIssuer{}
 }
 
+// NewMockIssuerWithMeasurement returns a new MockIssuer object reporting the given measurement
+func NewMockIssuerWithMeasurement(measurement []byte) *MockIssuer {
+	return &MockIssuer{measurement: measurement}
+}
+
 // Issue implements the Issuer interface
 func (m *MockIssuer) Issue(message []byte) ([]byte, error) {
 	quote := sha256.Sum256(message)
 	return quote[:], nil
 }
+
+// Measurement implements the MeasurementReporter interface
+func (m *MockIssuer) Measurement() ([]byte, error) {
+	return m.measurement, nil
+}
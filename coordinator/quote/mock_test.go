@@ -0,0 +1,50 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package quote
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMockValidatorErrors(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	message := []byte("message")
+	productID := uint64(3)
+	securityVersion := uint(2)
+	pp := PackageProperties{SignerID: "signer", ProductID: &productID, SecurityVersion: &securityVersion}
+	ip := InfrastructureProperties{RootCA: []byte("ca")}
+
+	issuer := NewMockIssuer()
+	validator := NewMockValidator()
+	quote, err := issuer.Issue(message)
+	require.NoError(err)
+	validator.AddValidQuote(quote, message, pp, ip)
+
+	assert.NoError(validator.Validate(quote, message, pp, ip))
+
+	err = validator.Validate([]byte("other quote"), message, pp, ip)
+	assert.True(errors.Is(err, ErrQuoteMismatch), err)
+
+	err = validator.Validate(quote, []byte("other message"), pp, ip)
+	assert.True(errors.Is(err, ErrMessageMismatch), err)
+
+	err = validator.Validate(quote, message, PackageProperties{SignerID: "other signer"}, ip)
+	assert.True(errors.Is(err, ErrPackageNonCompliant), err)
+
+	err = validator.Validate(quote, message, pp, InfrastructureProperties{RootCA: []byte("other ca")})
+	assert.True(errors.Is(err, ErrInfrastructureNonCompliant), err)
+
+	err = NewFailValidator().Validate(quote, message, pp, ip)
+	assert.True(errors.Is(err, ErrQuoteMismatch), err)
+	assert.Equal("wrong quote: cannot validate quote", err.Error())
+}
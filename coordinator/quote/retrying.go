@@ -0,0 +1,111 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package quote
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/edgelesssys/marblerun/coordinator/config"
+	"github.com/edgelesssys/marblerun/util"
+)
+
+// RetryBackoff configures how often a quote validation is retried if the collateral could not be fetched
+type RetryBackoff struct {
+	// Retries is the number of retries after the first attempt, 0 disables retries
+	Retries int
+	// Interval is the wait before the first retry, it is doubled after each retry up to MaxInterval
+	Interval    time.Duration
+	MaxInterval time.Duration
+}
+
+// LoadRetryBackoff reads the backoff of quote validations from the environment, falling back to the defaults
+func LoadRetryBackoff() (RetryBackoff, error) {
+	var backoff RetryBackoff
+	var err error
+
+	value := util.Getenv(config.QuoteValidationRetries, config.QuoteValidationRetriesDefault)
+	if backoff.Retries, err = strconv.Atoi(value); err != nil || backoff.Retries < 0 {
+		return RetryBackoff{}, fmt.Errorf("invalid value for %s: %s", config.QuoteValidationRetries, value)
+	}
+	value = util.Getenv(config.QuoteValidationBackoff, config.QuoteValidationBackoffDefault)
+	if backoff.Interval, err = time.ParseDuration(value); err != nil {
+		return RetryBackoff{}, fmt.Errorf("invalid duration for %s: %v", config.QuoteValidationBackoff, value)
+	}
+	value = util.Getenv(config.QuoteValidationMaxBackoff, config.QuoteValidationMaxBackoffDefault)
+	if backoff.MaxInterval, err = time.ParseDuration(value); err != nil {
+		return RetryBackoff{}, fmt.Errorf("invalid duration for %s: %v", config.QuoteValidationMaxBackoff, value)
+	}
+	if backoff.Interval <= 0 || backoff.MaxInterval < backoff.Interval {
+		return RetryBackoff{}, fmt.Errorf("invalid value for %s or %s: the backoff must be positive and not exceed the maximum", config.QuoteValidationBackoff, config.QuoteValidationMaxBackoff)
+	}
+
+	return backoff, nil
+}
+
+// RetryingValidator wraps a Validator and retries validations with an exponential backoff if the collateral could not be fetched.
+// Validations failing for other reasons, e.g., a quote of a non-compliant package, are not retried.
+type RetryingValidator struct {
+	validator Validator
+	backoff   RetryBackoff
+	sleep     func(time.Duration)
+}
+
+// NewRetryingValidator returns a RetryingValidator retrying the validations of validator according to backoff
+func NewRetryingValidator(validator Validator, backoff RetryBackoff) *RetryingValidator {
+	return &RetryingValidator{
+		validator: validator,
+		backoff:   backoff,
+		sleep:     time.Sleep,
+	}
+}
+
+// Validate implements the Validator interface
+func (v *RetryingValidator) Validate(quote []byte, message []byte, pp PackageProperties, ip InfrastructureProperties) error {
+	return v.retry(func() error {
+		return v.validator.Validate(quote, message, pp, ip)
+	})
+}
+
+// PackageProperties implements the PropertiesReader interface if the wrapped validator implements it
+func (v *RetryingValidator) PackageProperties(quote []byte) (PackageProperties, error) {
+	reader, ok := v.validator.(PropertiesReader)
+	if !ok {
+		return PackageProperties{}, ErrPropertiesUnsupported
+	}
+	var properties PackageProperties
+	err := v.retry(func() error {
+		var err error
+		properties, err = reader.PackageProperties(quote)
+		return err
+	})
+	return properties, err
+}
+
+// retry calls validate until it does not fail because of unavailable collateral or the retries are exhausted
+func (v *RetryingValidator) retry(validate func() error) error {
+	interval := v.backoff.Interval
+	for retry := 0; ; retry++ {
+		err := validate()
+		if err == nil || !errors.Is(err, ErrCollateralUnavailable) {
+			return err
+		}
+		if retry >= v.backoff.Retries {
+			if retry > 0 {
+				return fmt.Errorf("%w (gave up after %d retries)", err, retry)
+			}
+			return err
+		}
+
+		v.sleep(interval)
+		if interval *= 2; interval > v.backoff.MaxInterval {
+			interval = v.backoff.MaxInterval
+		}
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package quote
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/edgelesssys/marblerun/coordinator/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyValidator fails with unavailable collateral until the wrapped validator is called after the given number of failures
+type flakyValidator struct {
+	*MockValidator
+	failures int
+	calls    int
+}
+
+func (v *flakyValidator) Validate(quote []byte, message []byte, pp PackageProperties, ip InfrastructureProperties) error {
+	v.calls++
+	if v.calls <= v.failures {
+		return fmt.Errorf("%w: OE_QUOTE_PROVIDER_CALL_ERROR", ErrCollateralUnavailable)
+	}
+	return v.MockValidator.Validate(quote, message, pp, ip)
+}
+
+func (v *flakyValidator) PackageProperties(quote []byte) (PackageProperties, error) {
+	v.calls++
+	if v.calls <= v.failures {
+		return PackageProperties{}, fmt.Errorf("%w: OE_QUOTE_PROVIDER_CALL_ERROR", ErrCollateralUnavailable)
+	}
+	return v.MockValidator.PackageProperties(quote)
+}
+
+func TestRetryingValidator(t *testing.T) {
+	message := []byte("message")
+	pp := PackageProperties{SignerID: "signer"}
+	ip := InfrastructureProperties{RootCA: []byte("ca")}
+	issuer := NewMockIssuer()
+	quote, err := issuer.Issue(message)
+	require.NoError(t, err)
+	mock := NewMockValidator()
+	mock.AddValidQuote(quote, message, pp, ip)
+
+	backoff := RetryBackoff{Retries: 3, Interval: time.Second, MaxInterval: 3 * time.Second}
+
+	testCases := map[string]struct {
+		failures    int
+		pp          PackageProperties
+		wantErr     error
+		wantCalls   int
+		wantBackoff []time.Duration
+	}{
+		"valid quote": {
+			pp:        pp,
+			wantCalls: 1,
+		},
+		"transient failure is retried": {
+			failures:    2,
+			pp:          pp,
+			wantCalls:   3,
+			wantBackoff: []time.Duration{time.Second, 2 * time.Second},
+		},
+		"retries are exhausted": {
+			failures:    5,
+			pp:          pp,
+			wantErr:     ErrCollateralUnavailable,
+			wantCalls:   4,
+			wantBackoff: []time.Duration{time.Second, 2 * time.Second, 3 * time.Second},
+		},
+		"mismatch is not retried": {
+			pp:        PackageProperties{SignerID: "other"},
+			wantErr:   ErrPackageNonCompliant,
+			wantCalls: 1,
+		},
+		"mismatch after transient failure": {
+			failures:    1,
+			pp:          PackageProperties{SignerID: "other"},
+			wantErr:     ErrPackageNonCompliant,
+			wantCalls:   2,
+			wantBackoff: []time.Duration{time.Second},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			wrapped := &flakyValidator{MockValidator: mock, failures: tc.failures}
+			validator := NewRetryingValidator(wrapped, backoff)
+			var waits []time.Duration
+			validator.sleep = func(d time.Duration) { waits = append(waits, d) }
+
+			err := validator.Validate(quote, message, tc.pp, ip)
+			if tc.wantErr != nil {
+				assert.ErrorIs(err, tc.wantErr)
+			} else {
+				assert.NoError(err)
+			}
+			assert.Equal(tc.wantCalls, wrapped.calls)
+			assert.Equal(tc.wantBackoff, waits)
+		})
+	}
+}
+
+func TestRetryingValidatorPackageProperties(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	message := []byte("message")
+	pp := PackageProperties{SignerID: "signer"}
+	issuer := NewMockIssuer()
+	quote, err := issuer.Issue(message)
+	require.NoError(err)
+	mock := NewMockValidator()
+	mock.AddValidQuote(quote, message, pp, InfrastructureProperties{})
+
+	wrapped := &flakyValidator{MockValidator: mock, failures: 1}
+	validator := NewRetryingValidator(wrapped, RetryBackoff{Retries: 1, Interval: time.Second, MaxInterval: time.Second})
+	validator.sleep = func(time.Duration) {}
+	properties, err := validator.PackageProperties(quote)
+	require.NoError(err)
+	assert.Equal(pp, properties)
+	assert.Equal(2, wrapped.calls)
+
+	// an invalid quote is not retried
+	wrapped.calls, wrapped.failures = 0, 0
+	_, err = validator.PackageProperties([]byte("invalid"))
+	assert.ErrorIs(err, ErrQuoteMismatch)
+	assert.Equal(1, wrapped.calls)
+
+	// the wrapped validator must read package properties
+	_, err = NewRetryingValidator(NewFailValidator(), RetryBackoff{}).PackageProperties(quote)
+	assert.Equal(ErrPropertiesUnsupported, err)
+}
+
+func TestLoadRetryBackoff(t *testing.T) {
+	testCases := map[string]struct {
+		env         map[string]string
+		wantBackoff RetryBackoff
+		wantErr     bool
+	}{
+		"defaults": {
+			wantBackoff: RetryBackoff{Retries: 0, Interval: 500 * time.Millisecond, MaxInterval: 5 * time.Second},
+		},
+		"custom": {
+			env:         map[string]string{config.QuoteValidationRetries: "3", config.QuoteValidationBackoff: "1s", config.QuoteValidationMaxBackoff: "4s"},
+			wantBackoff: RetryBackoff{Retries: 3, Interval: time.Second, MaxInterval: 4 * time.Second},
+		},
+		"negative retries": {
+			env:     map[string]string{config.QuoteValidationRetries: "-1"},
+			wantErr: true,
+		},
+		"invalid backoff": {
+			env:     map[string]string{config.QuoteValidationBackoff: "invalid"},
+			wantErr: true,
+		},
+		"backoff exceeds maximum": {
+			env:     map[string]string{config.QuoteValidationBackoff: "10s", config.QuoteValidationMaxBackoff: "1s"},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			for key, value := range tc.env {
+				require.NoError(os.Setenv(key, value))
+				defer os.Unsetenv(key)
+			}
+
+			backoff, err := LoadRetryBackoff()
+			if tc.wantErr {
+				assert.Error(err)
+				return
+			}
+			require.NoError(err)
+			assert.Equal(tc.wantBackoff, backoff)
+		})
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package recovery
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/edgelesssys/marblerun/util"
+)
+
+// MultiPartyRecovery is a recoverer which splits the encryption key into shares using Shamir's secret sharing.
+// Each recovery key specified in the manifest receives one share, and any threshold shares can recover the Coordinator.
+type MultiPartyRecovery struct {
+	threshold     int
+	encryptionKey []byte
+	shares        [][]byte
+	recoveryData  multiPartyRecoveryData
+	collected     [][]byte
+	mux           sync.Mutex
+}
+
+// multiPartyRecoveryData is stored unencrypted alongside the sealed state, so shares can be verified while the Coordinator is sealed
+type multiPartyRecoveryData struct {
+	Threshold   int
+	ShareHashes []string
+}
+
+// NewMultiPartyRecovery generates a multi-party recoverer which requires threshold shares to recover the encryption key
+func NewMultiPartyRecovery(threshold int) *MultiPartyRecovery {
+	return &MultiPartyRecovery{threshold: threshold}
+}
+
+// GenerateEncryptionKey generates an encryption key and splits it into one share per recovery key
+func (r *MultiPartyRecovery) GenerateEncryptionKey(recoveryKeys map[string]string) ([]byte, error) {
+	if r.threshold < 1 {
+		return nil, errors.New("recovery threshold must be at least 1")
+	}
+	if len(recoveryKeys) > 0 && len(recoveryKeys) < r.threshold {
+		return nil, fmt.Errorf("manifest specifies %d recovery keys, but the recovery threshold is %d", len(recoveryKeys), r.threshold)
+	}
+
+	encryptionKey, err := generateRandomKey()
+	if err != nil {
+		return nil, err
+	}
+
+	var shares [][]byte
+	if len(recoveryKeys) > 0 {
+		shares, err = splitSecret(encryptionKey, len(recoveryKeys), r.threshold)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	r.encryptionKey = encryptionKey
+	r.shares = shares
+	return r.encryptionKey, nil
+}
+
+// GenerateRecoveryData encrypts each share with its recovery key and returns the shares to the user alongside the data needed to verify them later on
+func (r *MultiPartyRecovery) GenerateRecoveryData(recoveryKeys map[string]string) (map[string][]byte, []byte, error) {
+	if len(recoveryKeys) != len(r.shares) {
+		return nil, nil, errors.New("number of recovery keys does not match the generated shares")
+	}
+
+	// Sort names to assign the shares deterministically
+	names := make([]string, 0, len(recoveryKeys))
+	for name := range recoveryKeys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	secretMap := make(map[string][]byte, len(recoveryKeys))
+	recoveryData := multiPartyRecoveryData{Threshold: r.threshold}
+	for i, name := range names {
+		recoveryk, err := ParseRSAPublicKeyFromPEM(recoveryKeys[name])
+		if err != nil {
+			return nil, nil, err
+		}
+
+		secretMap[name], err = util.EncryptOAEP(recoveryk, r.shares[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		recoveryData.ShareHashes = append(recoveryData.ShareHashes, hash(r.shares[i]))
+	}
+
+	recoveryDataRaw, err := json.Marshal(recoveryData)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r.mux.Lock()
+	r.recoveryData = recoveryData
+	r.mux.Unlock()
+
+	return secretMap, recoveryDataRaw, nil
+}
+
+// RecoverKey collects the uploaded shares and reconstructs the encryption key once enough shares were uploaded. It returns the number of remaining shares.
+func (r *MultiPartyRecovery) RecoverKey(secret []byte) (int, []byte, error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if r.recoveryData.Threshold < 1 {
+		return -1, nil, errors.New("no recovery data available for multi-party recovery")
+	}
+
+	// Verify the share is one of the shares generated for the sealed state
+	shareHash := hash(secret)
+	known := false
+	for _, h := range r.recoveryData.ShareHashes {
+		if h == shareHash {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return r.remaining(), nil, errors.New("share does not belong to the sealed state")
+	}
+	for _, share := range r.collected {
+		if share[0] == secret[0] {
+			return r.remaining(), nil, errors.New("share was already uploaded")
+		}
+	}
+
+	r.collected = append(r.collected, secret)
+	if remaining := r.remaining(); remaining > 0 {
+		return remaining, nil, nil
+	}
+
+	encryptionKey, err := combineShares(r.collected)
+	r.collected = nil
+	if err != nil {
+		return -1, nil, err
+	}
+	return 0, encryptionKey, nil
+}
+
+// GetRecoveryData returns the threshold and the hashes of the shares
+func (r *MultiPartyRecovery) GetRecoveryData() ([]byte, error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.recoveryData.Threshold < 1 {
+		return nil, nil
+	}
+	return json.Marshal(r.recoveryData)
+}
+
+// SetRecoveryData sets the threshold and the hashes of the shares retrieved from the sealer
+func (r *MultiPartyRecovery) SetRecoveryData(data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	var recoveryData multiPartyRecoveryData
+	if err := json.Unmarshal(data, &recoveryData); err != nil {
+		return err
+	}
+	r.mux.Lock()
+	r.recoveryData = recoveryData
+	r.mux.Unlock()
+	return nil
+}
+
+func (r *MultiPartyRecovery) remaining() int {
+	return r.recoveryData.Threshold - len(r.collected)
+}
@@ -0,0 +1,156 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package recovery
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/edgelesssys/marblerun/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func generateRecoveryKeys(t *testing.T, names ...string) (map[string]string, map[string]*rsa.PrivateKey) {
+	publicKeys := make(map[string]string, len(names))
+	privateKeys := make(map[string]*rsa.PrivateKey, len(names))
+	for _, name := range names {
+		privk, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		pubk, err := x509.MarshalPKIXPublicKey(&privk.PublicKey)
+		require.NoError(t, err)
+		publicKeys[name] = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubk}))
+		privateKeys[name] = privk
+	}
+	return publicKeys, privateKeys
+}
+
+func TestMultiPartyRecovery(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	publicKeys, privateKeys := generateRecoveryKeys(t, "alice", "bob", "carol")
+
+	r := NewMultiPartyRecovery(2)
+	encryptionKey, err := r.GenerateEncryptionKey(publicKeys)
+	require.NoError(err)
+	secretMap, recoveryData, err := r.GenerateRecoveryData(publicKeys)
+	require.NoError(err)
+	require.Len(secretMap, 3)
+
+	shares := make(map[string][]byte, len(secretMap))
+	for name, encryptedShare := range secretMap {
+		shares[name], err = util.DecryptOAEP(privateKeys[name], encryptedShare)
+		require.NoError(err)
+	}
+
+	// Every combination of exactly two shares recovers the key on a freshly started Coordinator
+	for _, pair := range [][2]string{{"alice", "bob"}, {"bob", "carol"}, {"carol", "alice"}} {
+		r := NewMultiPartyRecovery(2)
+		require.NoError(r.SetRecoveryData(recoveryData))
+
+		// Threshold - 1 shares are not enough
+		remaining, key, err := r.RecoverKey(shares[pair[0]])
+		require.NoError(err)
+		assert.Equal(1, remaining)
+		assert.Nil(key)
+
+		remaining, key, err = r.RecoverKey(shares[pair[1]])
+		require.NoError(err)
+		assert.Equal(0, remaining)
+		assert.Equal(encryptionKey, key)
+	}
+
+	// The threshold is taken from the sealed recovery data, not from the current configuration
+	r = NewMultiPartyRecovery(3)
+	require.NoError(r.SetRecoveryData(recoveryData))
+	remaining, _, err := r.RecoverKey(shares["alice"])
+	require.NoError(err)
+	assert.Equal(1, remaining)
+
+	// Uploading the same share twice is rejected
+	_, _, err = r.RecoverKey(shares["alice"])
+	assert.Error(err)
+}
+
+func TestMultiPartyRecoveryMismatchedShares(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	publicKeys, privateKeys := generateRecoveryKeys(t, "alice", "bob")
+
+	// Two independent setups, shares of one must not be accepted by the other
+	r := NewMultiPartyRecovery(2)
+	_, err := r.GenerateEncryptionKey(publicKeys)
+	require.NoError(err)
+	_, recoveryData, err := r.GenerateRecoveryData(publicKeys)
+	require.NoError(err)
+
+	other := NewMultiPartyRecovery(2)
+	_, err = other.GenerateEncryptionKey(publicKeys)
+	require.NoError(err)
+	otherSecretMap, _, err := other.GenerateRecoveryData(publicKeys)
+	require.NoError(err)
+	otherShare, err := util.DecryptOAEP(privateKeys["alice"], otherSecretMap["alice"])
+	require.NoError(err)
+
+	r = NewMultiPartyRecovery(2)
+	require.NoError(r.SetRecoveryData(recoveryData))
+	_, key, err := r.RecoverKey(otherShare)
+	assert.Error(err)
+	assert.Nil(key)
+
+	// Without recovery data, shares cannot be verified
+	r = NewMultiPartyRecovery(2)
+	_, _, err = r.RecoverKey(otherShare)
+	assert.Error(err)
+}
+
+func TestMultiPartyRecoveryInvalidThreshold(t *testing.T) {
+	assert := assert.New(t)
+
+	publicKeys, _ := generateRecoveryKeys(t, "alice", "bob")
+
+	_, err := NewMultiPartyRecovery(3).GenerateEncryptionKey(publicKeys)
+	assert.Error(err)
+	_, err = NewMultiPartyRecovery(0).GenerateEncryptionKey(publicKeys)
+	assert.Error(err)
+}
+
+func TestShamir(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	secret := []byte("0123456789abcdef")
+	shares, err := splitSecret(secret, 5, 3)
+	require.NoError(err)
+	require.Len(shares, 5)
+
+	combined, err := combineShares([][]byte{shares[4], shares[0], shares[2]})
+	require.NoError(err)
+	assert.Equal(secret, combined)
+	combined, err = combineShares(shares)
+	require.NoError(err)
+	assert.Equal(secret, combined)
+
+	// Threshold - 1 shares do not reveal the secret
+	combined, err = combineShares(shares[:2])
+	require.NoError(err)
+	assert.NotEqual(secret, combined)
+
+	// Mismatched or duplicate shares are rejected
+	_, err = combineShares([][]byte{shares[0], shares[1][:5], shares[2]})
+	assert.Error(err)
+	_, err = combineShares([][]byte{shares[0], shares[0], shares[2]})
+	assert.Error(err)
+
+	_, err = splitSecret(secret, 2, 3)
+	assert.Error(err)
+}
@@ -0,0 +1,115 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package recovery
+
+import (
+	"crypto/rand"
+	"errors"
+)
+
+// splitSecret splits the secret into n shares using Shamir's secret sharing over GF(2^8), such that any threshold shares can reconstruct it.
+// Each share consists of its x-coordinate followed by one y-coordinate per byte of the secret.
+func splitSecret(secret []byte, n int, threshold int) ([][]byte, error) {
+	if threshold < 1 || threshold > n {
+		return nil, errors.New("threshold must be between 1 and the number of shares")
+	}
+	if n > 255 {
+		return nil, errors.New("cannot split a secret into more than 255 shares")
+	}
+
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret)+1)
+		shares[i][0] = byte(i + 1)
+	}
+
+	// Generate a random polynomial of degree threshold-1 for each byte of the secret, with the byte as constant term
+	coefficients := make([]byte, threshold)
+	for idx, secretByte := range secret {
+		coefficients[0] = secretByte
+		if _, err := rand.Read(coefficients[1:]); err != nil {
+			return nil, err
+		}
+		for _, share := range shares {
+			share[idx+1] = evaluatePolynomial(coefficients, share[0])
+		}
+	}
+
+	return shares, nil
+}
+
+// combineShares reconstructs the secret from the given shares using Lagrange interpolation at x = 0
+func combineShares(shares [][]byte) ([]byte, error) {
+	if len(shares) < 1 {
+		return nil, errors.New("no shares to combine")
+	}
+
+	secretLen := len(shares[0]) - 1
+	seen := make(map[byte]bool, len(shares))
+	for _, share := range shares {
+		if len(share) != secretLen+1 || secretLen < 1 {
+			return nil, errors.New("shares have mismatching lengths")
+		}
+		if share[0] == 0 || seen[share[0]] {
+			return nil, errors.New("shares have invalid or duplicate x-coordinates")
+		}
+		seen[share[0]] = true
+	}
+
+	secret := make([]byte, secretLen)
+	for i, share := range shares {
+		// basis = prod_{j != i} x_j / (x_j - x_i); subtraction is XOR in GF(2^8)
+		basis := byte(1)
+		for j, other := range shares {
+			if i == j {
+				continue
+			}
+			basis = gfMul(basis, gfDiv(other[0], other[0]^share[0]))
+		}
+		for idx := range secret {
+			secret[idx] ^= gfMul(share[idx+1], basis)
+		}
+	}
+
+	return secret, nil
+}
+
+// evaluatePolynomial evaluates the polynomial with the given coefficients at x using Horner's method
+func evaluatePolynomial(coefficients []byte, x byte) byte {
+	result := byte(0)
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		result = gfMul(result, x) ^ coefficients[i]
+	}
+	return result
+}
+
+// gfMul multiplies two elements of GF(2^8) with the AES reduction polynomial x^8 + x^4 + x^3 + x + 1
+func gfMul(a, b byte) byte {
+	var product byte
+	for b > 0 {
+		if b&1 != 0 {
+			product ^= a
+		}
+		carry := a & 0x80
+		a <<= 1
+		if carry != 0 {
+			a ^= 0x1b
+		}
+		b >>= 1
+	}
+	return product
+}
+
+// gfDiv divides a by b in GF(2^8), b must not be 0
+func gfDiv(a, b byte) byte {
+	// b^-1 = b^254, since b^255 = 1 for all b != 0
+	inverse := byte(1)
+	for i := 0; i < 254; i++ {
+		inverse = gfMul(inverse, b)
+	}
+	return gfMul(a, inverse)
+}
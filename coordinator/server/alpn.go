@@ -0,0 +1,35 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/edgelesssys/marblerun/coordinator/config"
+	"github.com/edgelesssys/marblerun/util"
+)
+
+// LoadMeshALPN reads the ALPN protocols advertised by the marble server from the environment, falling back to the default
+func LoadMeshALPN() ([]string, error) {
+	var protocols []string
+	hasH2 := false
+	for _, protocol := range strings.Split(util.Getenv(config.MeshALPN, config.MeshALPNDefault), ",") {
+		protocol = strings.TrimSpace(protocol)
+		if protocol == "" {
+			return nil, fmt.Errorf("invalid value for %s: empty protocol", config.MeshALPN)
+		}
+		if protocol == "h2" {
+			hasH2 = true
+		}
+		protocols = append(protocols, protocol)
+	}
+	if !hasH2 {
+		return nil, fmt.Errorf("invalid value for %s: gRPC requires the protocol h2", config.MeshALPN)
+	}
+	return protocols, nil
+}
@@ -0,0 +1,79 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package server
+
+import (
+	"crypto/tls"
+	"os"
+	"testing"
+
+	"github.com/edgelesssys/marblerun/coordinator/config"
+	"github.com/edgelesssys/marblerun/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMeshALPN(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	defer os.Unsetenv(config.MeshALPN)
+
+	alpn, err := LoadMeshALPN()
+	require.NoError(err)
+	assert.Equal([]string{"h2"}, alpn)
+
+	require.NoError(os.Setenv(config.MeshALPN, "grpc-exp, h2"))
+	alpn, err = LoadMeshALPN()
+	require.NoError(err)
+	assert.Equal([]string{"grpc-exp", "h2"}, alpn)
+
+	for _, value := range []string{"http/1.1", "h2,", "grpc-exp"} {
+		require.NoError(os.Setenv(config.MeshALPN, value))
+		_, err = LoadMeshALPN()
+		assert.Error(err, value)
+	}
+}
+
+func TestMarbleServerALPN(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cert, _, privk := util.MustGenerateTestMarbleCredentials()
+	tlsCert := &tls.Certificate{Certificate: [][]byte{cert.Raw}, PrivateKey: privk}
+	getCertificate := func(*tls.ClientHelloInfo) (*tls.Certificate, error) { return tlsCert, nil }
+
+	alpn := []string{"grpc-exp", "h2"}
+	tlsConfig := marbleServerTLSConfig(getCertificate, TLSOptions{MinVersion: tls.VersionTLS12}, alpn)
+	assert.Equal(alpn, tlsConfig.NextProtos)
+	assert.Equal(tls.RequireAnyClientCert, tlsConfig.ClientAuth)
+	assert.EqualValues(tls.VersionTLS12, tlsConfig.MinVersion)
+
+	// the configured protocols are negotiated in the handshake
+	listener, err := tls.Listen("tcp", "localhost:0", tlsConfig)
+	require.NoError(err)
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.(*tls.Conn).Handshake()
+	}()
+
+	negotiate := func(protocol string) string {
+		conn, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{
+			InsecureSkipVerify: true,
+			Certificates:       []tls.Certificate{*tlsCert},
+			NextProtos:         []string{protocol},
+		})
+		require.NoError(err)
+		defer conn.Close()
+		return conn.ConnectionState().NegotiatedProtocol
+	}
+	assert.Equal("grpc-exp", negotiate("grpc-exp"))
+}
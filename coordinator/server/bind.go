@@ -0,0 +1,69 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package server
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/edgelesssys/marblerun/coordinator/config"
+	"github.com/edgelesssys/marblerun/util"
+	"go.uber.org/zap"
+)
+
+// BindBackoff configures how often binding the address of the marble server is retried, e.g., while the address is still in use during a rolling restart
+type BindBackoff struct {
+	// Retries is the number of retries after the first attempt, 0 disables retries
+	Retries int
+	// Interval is the wait before the first retry, it is doubled after each retry up to MaxInterval
+	Interval    time.Duration
+	MaxInterval time.Duration
+}
+
+// LoadBindBackoff reads the backoff of binding the marble server from the environment, falling back to the defaults
+func LoadBindBackoff() (BindBackoff, error) {
+	var backoff BindBackoff
+	var err error
+
+	value := util.Getenv(config.MeshBindRetries, config.MeshBindRetriesDefault)
+	if backoff.Retries, err = strconv.Atoi(value); err != nil || backoff.Retries < 0 {
+		return BindBackoff{}, fmt.Errorf("invalid value for %s: %s", config.MeshBindRetries, value)
+	}
+	if backoff.Interval, err = getDurationEnv(config.MeshBindBackoff, config.MeshBindBackoffDefault); err != nil {
+		return BindBackoff{}, err
+	}
+	if backoff.MaxInterval, err = getDurationEnv(config.MeshBindMaxBackoff, config.MeshBindMaxBackoffDefault); err != nil {
+		return BindBackoff{}, err
+	}
+	if backoff.Interval <= 0 || backoff.MaxInterval < backoff.Interval {
+		return BindBackoff{}, fmt.Errorf("invalid value for %s or %s: the backoff must be positive and not exceed the maximum", config.MeshBindBackoff, config.MeshBindMaxBackoff)
+	}
+
+	return backoff, nil
+}
+
+// listen binds the address using listenFunc and retries with an exponential backoff on failure
+func (b BindBackoff) listen(listenFunc func(network, address string) (net.Listener, error), address string, zapLogger *zap.Logger) (net.Listener, error) {
+	interval := b.Interval
+	for retry := 0; ; retry++ {
+		listener, err := listenFunc("tcp", address)
+		if err == nil {
+			return listener, nil
+		}
+		if retry >= b.Retries {
+			return nil, err
+		}
+
+		zapLogger.Warn("Could not bind the marble server, retrying", zap.String("address", address), zap.Duration("backoff", interval), zap.Error(err))
+		time.Sleep(interval)
+		if interval *= 2; interval > b.MaxInterval {
+			interval = b.MaxInterval
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package server
+
+import (
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/edgelesssys/marblerun/coordinator/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+func TestLoadBindBackoff(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	defer os.Unsetenv(config.MeshBindRetries)
+	defer os.Unsetenv(config.MeshBindBackoff)
+	defer os.Unsetenv(config.MeshBindMaxBackoff)
+
+	backoff, err := LoadBindBackoff()
+	require.NoError(err)
+	assert.Equal(BindBackoff{Retries: 5, Interval: time.Second, MaxInterval: 10 * time.Second}, backoff)
+
+	require.NoError(os.Setenv(config.MeshBindRetries, "0"))
+	require.NoError(os.Setenv(config.MeshBindBackoff, "100ms"))
+	require.NoError(os.Setenv(config.MeshBindMaxBackoff, "100ms"))
+	backoff, err = LoadBindBackoff()
+	require.NoError(err)
+	assert.Equal(BindBackoff{Retries: 0, Interval: 100 * time.Millisecond, MaxInterval: 100 * time.Millisecond}, backoff)
+
+	for _, env := range []struct{ name, value string }{
+		{config.MeshBindRetries, "-1"},
+		{config.MeshBindRetries, "many"},
+		{config.MeshBindBackoff, "0s"},
+		{config.MeshBindBackoff, "1s"},
+	} {
+		require.NoError(os.Setenv(env.name, env.value))
+		_, err = LoadBindBackoff()
+		assert.Error(err, env)
+		require.NoError(os.Unsetenv(env.name))
+	}
+}
+
+func TestBindBackoff(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	backoff := BindBackoff{Retries: 3, Interval: time.Millisecond, MaxInterval: 2 * time.Millisecond}
+	errInUse := errors.New("address already in use")
+
+	// transient bind errors are retried
+	attempts := 0
+	listenFunc := func(network, address string) (net.Listener, error) {
+		attempts++
+		if attempts <= 2 {
+			return nil, errInUse
+		}
+		return net.Listen(network, address)
+	}
+	listener, err := backoff.listen(listenFunc, "localhost:0", zap.NewNop())
+	require.NoError(err)
+	listener.Close()
+	assert.Equal(3, attempts)
+
+	// persistent bind errors are returned once the retries are exhausted
+	attempts = 0
+	_, err = backoff.listen(func(network, address string) (net.Listener, error) {
+		attempts++
+		return nil, errInUse
+	}, "localhost:0", zap.NewNop())
+	assert.Equal(errInUse, err)
+	assert.Equal(4, attempts)
+}
+
+func TestRunMarbleServerRetriesBind(t *testing.T) {
+	require := require.New(t)
+
+	// occupy the address as a terminating coordinator would during a rolling restart
+	occupied, err := net.Listen("tcp", "localhost:0")
+	require.NoError(err)
+	addr := occupied.Addr().String()
+
+	grpcServer := grpc.NewServer()
+	defer grpcServer.Stop()
+	addrChan := make(chan string)
+	errChan := make(chan error)
+	go RunMarbleServer(grpcServer, addr, BindBackoff{Retries: 50, Interval: 10 * time.Millisecond, MaxInterval: 10 * time.Millisecond}, zap.NewNop(), addrChan, errChan)
+
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(occupied.Close())
+
+	select {
+	case boundAddr := <-addrChan:
+		require.Equal(addr, boundAddr)
+	case err := <-errChan:
+		require.NoError(err)
+	case <-time.After(5 * time.Second):
+		require.Fail("marble server was not started")
+	}
+}
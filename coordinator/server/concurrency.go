@@ -0,0 +1,73 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/edgelesssys/marblerun/coordinator/config"
+	"github.com/edgelesssys/marblerun/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ConcurrencyConfig limits the number of activation requests handled concurrently by the marble server
+type ConcurrencyConfig struct {
+	// Max is the number of concurrent requests, 0 disables the limit
+	Max int
+	// Reject rejects excess requests instead of queueing them
+	Reject bool
+}
+
+// LoadConcurrencyConfig reads the concurrency limit of the marble server from the environment, falling back to the defaults
+func LoadConcurrencyConfig() (ConcurrencyConfig, error) {
+	value := util.Getenv(config.MaxConcurrentActivations, config.MaxConcurrentActivationsDefault)
+	max, err := strconv.Atoi(value)
+	if err != nil || max < 0 {
+		return ConcurrencyConfig{}, fmt.Errorf("invalid value for %s: %v", config.MaxConcurrentActivations, value)
+	}
+
+	var reject bool
+	switch policy := util.Getenv(config.ConcurrentActivationsPolicy, config.ConcurrentActivationsPolicyDefault); policy {
+	case "queue":
+	case "reject":
+		reject = true
+	default:
+		return ConcurrencyConfig{}, fmt.Errorf("invalid value for %s: %v", config.ConcurrentActivationsPolicy, policy)
+	}
+
+	return ConcurrencyConfig{Max: max, Reject: reject}, nil
+}
+
+// UnaryServerInterceptor returns a grpc.UnaryServerInterceptor which lets at most Max requests pass at once.
+// Excess requests either wait for a free slot until their context is done or are rejected with codes.ResourceExhausted.
+func (cc ConcurrencyConfig) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	slots := make(chan struct{}, cc.Max)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if cc.Reject {
+			select {
+			case slots <- struct{}{}:
+			default:
+				return nil, status.Error(codes.ResourceExhausted, "too many concurrent activations, retry later")
+			}
+		} else {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				if ctx.Err() == context.DeadlineExceeded {
+					return nil, status.Error(codes.DeadlineExceeded, "timed out waiting for a free activation slot")
+				}
+				return nil, status.Error(codes.Canceled, "canceled while waiting for a free activation slot")
+			}
+		}
+		defer func() { <-slots }()
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package server
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edgelesssys/marblerun/coordinator/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestLoadConcurrencyConfig(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	defer os.Unsetenv(config.MaxConcurrentActivations)
+	defer os.Unsetenv(config.ConcurrentActivationsPolicy)
+
+	// defaults
+	cc, err := LoadConcurrencyConfig()
+	require.NoError(err)
+	assert.Equal(ConcurrencyConfig{Max: 0, Reject: false}, cc)
+
+	require.NoError(os.Setenv(config.MaxConcurrentActivations, "2"))
+	require.NoError(os.Setenv(config.ConcurrentActivationsPolicy, "reject"))
+	cc, err = LoadConcurrencyConfig()
+	require.NoError(err)
+	assert.Equal(ConcurrencyConfig{Max: 2, Reject: true}, cc)
+
+	require.NoError(os.Setenv(config.ConcurrentActivationsPolicy, "drop"))
+	_, err = LoadConcurrencyConfig()
+	assert.Error(err)
+
+	require.NoError(os.Setenv(config.ConcurrentActivationsPolicy, "queue"))
+	require.NoError(os.Setenv(config.MaxConcurrentActivations, "-1"))
+	_, err = LoadConcurrencyConfig()
+	assert.Error(err)
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	testCases := map[string]struct {
+		reject bool
+	}{
+		"queue":  {reject: false},
+		"reject": {reject: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			interceptor := ConcurrencyConfig{Max: 2, Reject: tc.reject}.UnaryServerInterceptor()
+
+			// the handler simulates a slow validation which blocks until released
+			release := make(chan struct{})
+			started := make(chan struct{}, 3)
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				started <- struct{}{}
+				<-release
+				return "ok", nil
+			}
+			call := func(ctx context.Context) error {
+				_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/rpc.Marble/Activate"}, handler)
+				return err
+			}
+
+			var wg sync.WaitGroup
+			for i := 0; i < 2; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					assert.NoError(call(context.Background()))
+				}()
+			}
+			<-started
+			<-started
+
+			if tc.reject {
+				err := call(context.Background())
+				assert.Equal(codes.ResourceExhausted, status.Code(err))
+			} else {
+				// the third activation waits until its deadline
+				ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+				defer cancel()
+				err := call(ctx)
+				assert.Equal(codes.DeadlineExceeded, status.Code(err))
+
+				// and is handled once a slot is free
+				thirdDone := make(chan error)
+				go func() { thirdDone <- call(context.Background()) }()
+				select {
+				case <-started:
+					t.Fatal("third activation started while the limit was reached")
+				case <-time.After(50 * time.Millisecond):
+				}
+				release <- struct{}{}
+				<-started
+				close(release)
+				assert.NoError(<-thirdDone)
+				wg.Wait()
+				return
+			}
+
+			close(release)
+			wg.Wait()
+		})
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/edgelesssys/marblerun/coordinator/core"
+)
+
+// eventsHandler streams the Coordinator's events as server-sent events.
+// The buffered events are sent first. If the follow query parameter is true, new events are streamed until the client disconnects.
+func eventsHandler(cc core.ClientCore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Abort if no admin client certificate was provided
+		if !verifyAdmin(w, r, cc) {
+			return
+		}
+		if r.Method != http.MethodGet {
+			writeJSONError(w, "", http.StatusMethodNotAllowed)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			writeJSONError(w, "streaming not supported", http.StatusInternalServerError)
+			return
+		}
+		follow := r.URL.Query().Get("follow") == "true"
+
+		buffered, live := cc.SubscribeEvents(r.Context())
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		for _, event := range buffered {
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+		if !follow {
+			return
+		}
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case event, ok := <-live:
+				if !ok {
+					return
+				}
+				if err := writeEvent(w, event); err != nil {
+					return
+				}
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// writeEvent writes an event in the server-sent events format
+func writeEvent(w io.Writer, event core.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "data: %s\n\n", data)
+	return err
+}
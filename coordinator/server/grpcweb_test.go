@@ -0,0 +1,101 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package server
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/edgelesssys/marblerun/coordinator/rpc"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestGRPCWeb(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	grpcServer := grpc.NewServer()
+	rpc.RegisterMarbleServer(grpcServer, &echoMarbleServer{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("rest"))
+	})
+	handler := WrapGRPCWeb(grpcServer, mux, []string{"https://dashboard.example.com"})
+
+	// send a gRPC-web request consisting of a single length-prefixed message
+	message, err := proto.Marshal(&rpc.ActivationReq{Quote: []byte("quote")})
+	require.NoError(err)
+	body := append([]byte{0, 0, 0, 0, 0}, message...)
+	binary.BigEndian.PutUint32(body[1:5], uint32(len(message)))
+	req := httptest.NewRequest(http.MethodPost, "/rpc.Marble/Activate", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/grpc-web+proto")
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	require.Equal(http.StatusOK, resp.Code)
+	assert.Equal("application/grpc-web+proto", resp.Header().Get("Content-Type"))
+
+	// the response envelope holds a data frame and a trailer frame
+	readFrame := func(r io.Reader) (byte, []byte) {
+		header := make([]byte, 5)
+		_, err := io.ReadFull(r, header)
+		require.NoError(err)
+		payload := make([]byte, binary.BigEndian.Uint32(header[1:]))
+		_, err = io.ReadFull(r, payload)
+		require.NoError(err)
+		return header[0], payload
+	}
+	flags, payload := readFrame(resp.Body)
+	assert.EqualValues(0, flags)
+	var activationResp rpc.ActivationResp
+	require.NoError(proto.Unmarshal(payload, &activationResp))
+	assert.Equal("quote", activationResp.GetParameters().Files["/payload"])
+
+	flags, payload = readFrame(resp.Body)
+	assert.EqualValues(0x80, flags)
+	assert.Contains(string(payload), "grpc-status: 0\r\n")
+	assert.Zero(resp.Body.Len())
+
+	// other requests are served by the REST API
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/status", nil))
+	assert.Equal("rest", resp.Body.String())
+
+	// cross-origin requests are only allowed from the configured origins
+	preflight := func(origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, "/rpc.Marble/Activate", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		req.Header.Set("Access-Control-Request-Headers", "content-type,x-grpc-web")
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp
+	}
+	assert.Equal("https://dashboard.example.com", preflight("https://dashboard.example.com").Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(preflight("https://evil.example.com").Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestEnableHTTP2(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(enableHTTP2(nil))
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	enabled := enableHTTP2(tlsConfig)
+	assert.Equal([]string{"h2", "http/1.1"}, enabled.NextProtos)
+	assert.EqualValues(tls.VersionTLS12, enabled.MinVersion)
+	assert.Empty(tlsConfig.NextProtos)
+
+	assert.Equal([]string{"http/1.1", "h2"}, enableHTTP2(&tls.Config{NextProtos: []string{"http/1.1"}}).NextProtos)
+}
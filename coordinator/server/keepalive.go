@@ -0,0 +1,62 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/edgelesssys/marblerun/coordinator/config"
+	"github.com/edgelesssys/marblerun/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+)
+
+// KeepaliveConfig holds the gRPC keepalive settings of the marble server
+type KeepaliveConfig struct {
+	Parameters keepalive.ServerParameters
+	Policy     keepalive.EnforcementPolicy
+}
+
+// LoadKeepaliveConfig reads the keepalive settings of the marble server from the environment, falling back to the defaults
+func LoadKeepaliveConfig() (KeepaliveConfig, error) {
+	var kc KeepaliveConfig
+	var err error
+
+	if kc.Parameters.MaxConnectionIdle, err = getDurationEnv(config.KeepaliveMaxConnectionIdle, config.KeepaliveMaxConnectionIdleDefault); err != nil {
+		return KeepaliveConfig{}, err
+	}
+	if kc.Parameters.Time, err = getDurationEnv(config.KeepaliveTime, config.KeepaliveTimeDefault); err != nil {
+		return KeepaliveConfig{}, err
+	}
+	if kc.Parameters.Timeout, err = getDurationEnv(config.KeepaliveTimeout, config.KeepaliveTimeoutDefault); err != nil {
+		return KeepaliveConfig{}, err
+	}
+	if kc.Policy.MinTime, err = getDurationEnv(config.KeepaliveMinTime, config.KeepaliveMinTimeDefault); err != nil {
+		return KeepaliveConfig{}, err
+	}
+	kc.Policy.PermitWithoutStream = util.Getenv(config.KeepalivePermitWithoutStream, config.KeepalivePermitWithoutStreamDefault) == "1"
+
+	return kc, nil
+}
+
+// serverOptions returns the grpc.ServerOptions applying the keepalive settings
+func (kc KeepaliveConfig) serverOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.KeepaliveParams(kc.Parameters),
+		grpc.KeepaliveEnforcementPolicy(kc.Policy),
+	}
+}
+
+func getDurationEnv(name string, fallback string) (time.Duration, error) {
+	value := util.Getenv(name, fallback)
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration for %s: %v", name, value)
+	}
+	return duration, nil
+}
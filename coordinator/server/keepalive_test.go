@@ -0,0 +1,52 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package server
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/edgelesssys/marblerun/coordinator/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/keepalive"
+)
+
+func TestLoadKeepaliveConfig(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// defaults
+	kc, err := LoadKeepaliveConfig()
+	require.NoError(err)
+	assert.Equal(keepalive.ServerParameters{MaxConnectionIdle: 5 * time.Minute, Time: time.Minute, Timeout: 20 * time.Second}, kc.Parameters)
+	assert.Equal(keepalive.EnforcementPolicy{MinTime: 30 * time.Second, PermitWithoutStream: true}, kc.Policy)
+	assert.Len(kc.serverOptions(), 2)
+
+	// values from the environment
+	env := map[string]string{
+		config.KeepaliveMaxConnectionIdle:   "350s",
+		config.KeepaliveTime:                "2m",
+		config.KeepaliveTimeout:             "5s",
+		config.KeepaliveMinTime:             "10s",
+		config.KeepalivePermitWithoutStream: "0",
+	}
+	for name, value := range env {
+		require.NoError(os.Setenv(name, value))
+		defer os.Unsetenv(name)
+	}
+	kc, err = LoadKeepaliveConfig()
+	require.NoError(err)
+	assert.Equal(keepalive.ServerParameters{MaxConnectionIdle: 350 * time.Second, Time: 2 * time.Minute, Timeout: 5 * time.Second}, kc.Parameters)
+	assert.Equal(keepalive.EnforcementPolicy{MinTime: 10 * time.Second, PermitWithoutStream: false}, kc.Policy)
+
+	// invalid duration
+	require.NoError(os.Setenv(config.KeepaliveTime, "often"))
+	_, err = LoadKeepaliveConfig()
+	assert.Error(err)
+}
@@ -0,0 +1,34 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package server
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/edgelesssys/marblerun/coordinator/config"
+	"github.com/edgelesssys/marblerun/util"
+	"google.golang.org/grpc"
+)
+
+// LoadMaxMsgSize reads the maximum size of gRPC messages of the marble server from the environment, falling back to the default
+func LoadMaxMsgSize() (int, error) {
+	value := util.Getenv(config.MaxMsgSize, config.MaxMsgSizeDefault)
+	size, err := strconv.Atoi(value)
+	if err != nil || size <= 0 {
+		return 0, fmt.Errorf("invalid message size for %s: %v", config.MaxMsgSize, value)
+	}
+	return size, nil
+}
+
+// maxMsgSizeServerOptions returns the grpc.ServerOptions limiting the size of sent and received messages
+func maxMsgSizeServerOptions(maxMsgSize int) []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(maxMsgSize),
+		grpc.MaxSendMsgSize(maxMsgSize),
+	}
+}
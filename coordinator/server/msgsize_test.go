@@ -0,0 +1,85 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package server
+
+import (
+	"context"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/edgelesssys/marblerun/coordinator/config"
+	"github.com/edgelesssys/marblerun/coordinator/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestLoadMaxMsgSize(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	defer os.Unsetenv(config.MaxMsgSize)
+
+	size, err := LoadMaxMsgSize()
+	require.NoError(err)
+	assert.Equal(16<<20, size)
+
+	require.NoError(os.Setenv(config.MaxMsgSize, "1024"))
+	size, err = LoadMaxMsgSize()
+	require.NoError(err)
+	assert.Equal(1024, size)
+
+	for _, value := range []string{"0", "-1", "large"} {
+		require.NoError(os.Setenv(config.MaxMsgSize, value))
+		_, err = LoadMaxMsgSize()
+		assert.Error(err, value)
+	}
+}
+
+// echoMarbleServer responds to activations with a file as large as the quote
+type echoMarbleServer struct {
+	rpc.UnimplementedMarbleServer
+}
+
+func (*echoMarbleServer) Activate(ctx context.Context, req *rpc.ActivationReq) (*rpc.ActivationResp, error) {
+	return &rpc.ActivationResp{Parameters: &rpc.Parameters{Files: map[string]string{"/payload": string(req.GetQuote())}}}, nil
+}
+
+func TestMaxMsgSize(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	const defaultMaxMsgSize = 4 << 20
+	payload := make([]byte, defaultMaxMsgSize+1024)
+
+	activate := func(maxMsgSize int) error {
+		grpcServer := grpc.NewServer(maxMsgSizeServerOptions(maxMsgSize)...)
+		rpc.RegisterMarbleServer(grpcServer, &echoMarbleServer{})
+		listener, err := net.Listen("tcp", "localhost:0")
+		require.NoError(err)
+		go grpcServer.Serve(listener)
+		defer grpcServer.Stop()
+
+		conn, err := grpc.Dial(listener.Addr().String(), grpc.WithInsecure(), grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(maxMsgSize)))
+		require.NoError(err)
+		defer conn.Close()
+		resp, err := rpc.NewMarbleClient(conn).Activate(context.Background(), &rpc.ActivationReq{Quote: payload})
+		if err != nil {
+			return err
+		}
+		assert.Len(resp.GetParameters().Files["/payload"], len(payload))
+		return nil
+	}
+
+	// a payload larger than gRPC's default is accepted with the configured size
+	assert.NoError(activate(2 * defaultMaxMsgSize))
+
+	// and rejected with gRPC's default
+	assert.Equal(codes.ResourceExhausted, status.Code(activate(defaultMaxMsgSize)))
+}
@@ -0,0 +1,94 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package server
+
+import (
+	"context"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// rateLimiter limits the rate of requests per source address using a token bucket for each source
+type rateLimiter struct {
+	limit    rate.Limit
+	burst    int
+	limiters map[string]*sourceLimiter
+	now      func() time.Time
+	mux      sync.Mutex
+}
+
+type sourceLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// newRateLimiter creates a rateLimiter which allows limit requests per second and bursts of the same size for each source
+func newRateLimiter(limit float64) *rateLimiter {
+	return &rateLimiter{
+		limit:    rate.Limit(limit),
+		burst:    int(math.Max(1, math.Ceil(limit))),
+		limiters: make(map[string]*sourceLimiter),
+		now:      time.Now,
+	}
+}
+
+// allow reports whether a request from source may be processed now
+func (l *rateLimiter) allow(source string) bool {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	now := l.now()
+	s, ok := l.limiters[source]
+	if !ok {
+		l.removeIdle(now)
+		s = &sourceLimiter{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.limiters[source] = s
+	}
+	s.lastSeen = now
+	return s.limiter.AllowN(now, 1)
+}
+
+// removeIdle removes the limiters of sources whose bucket has been refilled completely, as they are equal to new ones
+func (l *rateLimiter) removeIdle(now time.Time) {
+	refillDuration := time.Duration(float64(l.burst) / float64(l.limit) * float64(time.Second))
+	for source, s := range l.limiters {
+		if now.Sub(s.lastSeen) > refillDuration {
+			delete(l.limiters, source)
+		}
+	}
+}
+
+// UnaryServerInterceptor returns a grpc.UnaryServerInterceptor which rejects requests exceeding the rate limit with codes.ResourceExhausted
+func (l *rateLimiter) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !l.allow(sourceOf(ctx)) {
+			return nil, status.Errorf(codes.ResourceExhausted, "activation rate limit exceeded, retry later")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// sourceOf returns the IP address of the peer sending the request
+func sourceOf(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
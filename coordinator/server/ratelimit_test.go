@@ -0,0 +1,62 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestRateLimiter(t *testing.T) {
+	assert := assert.New(t)
+
+	limiter := newRateLimiter(10)
+	now := time.Unix(0, 0)
+	limiter.now = func() time.Time { return now }
+	interceptor := limiter.UnaryServerInterceptor()
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+	call := func(ip string) error {
+		ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 12345}})
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/rpc.Marble/Activate"}, handler)
+		return err
+	}
+
+	// A burst of requests exceeds the bucket
+	rejected := 0
+	for i := 0; i < 30; i++ {
+		if err := call("192.0.2.1"); err != nil {
+			assert.Equal(codes.ResourceExhausted, status.Code(err))
+			rejected++
+		}
+	}
+	assert.Equal(20, rejected)
+
+	// Other sources have their own bucket
+	assert.NoError(call("192.0.2.2"))
+
+	// A sustained rate below the limit passes
+	for i := 0; i < 50; i++ {
+		now = now.Add(150 * time.Millisecond)
+		assert.NoError(call("192.0.2.1"))
+	}
+
+	// Idle sources are removed once their bucket is refilled
+	now = now.Add(time.Minute)
+	assert.NoError(call("192.0.2.3"))
+	assert.Len(limiter.limiters, 1)
+}
@@ -0,0 +1,86 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package server
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+// LoadSCTFile reads the signed certificate timestamps from a file holding a TLS-encoded SignedCertificateTimestampList (RFC 6962, section 3.3)
+func LoadSCTFile(filename string) ([][]byte, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	scts, err := parseSCTList(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SCT file %s: %v", filename, err)
+	}
+	return scts, nil
+}
+
+// parseSCTList splits a SignedCertificateTimestampList into the serialized SCTs
+func parseSCTList(data []byte) ([][]byte, error) {
+	if len(data) < 2 {
+		return nil, errors.New("missing list length")
+	}
+	listLen := int(binary.BigEndian.Uint16(data))
+	data = data[2:]
+	if listLen != len(data) {
+		return nil, errors.New("list length does not match the data")
+	}
+
+	var scts [][]byte
+	for len(data) > 0 {
+		if len(data) < 2 {
+			return nil, errors.New("missing SCT length")
+		}
+		sctLen := int(binary.BigEndian.Uint16(data))
+		data = data[2:]
+		if sctLen == 0 || sctLen > len(data) {
+			return nil, errors.New("invalid SCT length")
+		}
+		scts = append(scts, data[:sctLen])
+		data = data[sctLen:]
+	}
+	if len(scts) == 0 {
+		return nil, errors.New("empty list")
+	}
+	return scts, nil
+}
+
+// StapleSCTs returns a copy of tlsConfig which attaches the signed certificate timestamps to the served certificates
+func StapleSCTs(tlsConfig *tls.Config, scts [][]byte) *tls.Config {
+	stapled := tlsConfig.Clone()
+
+	if len(tlsConfig.Certificates) > 0 {
+		stapled.Certificates = make([]tls.Certificate, len(tlsConfig.Certificates))
+		for i, cert := range tlsConfig.Certificates {
+			cert.SignedCertificateTimestamps = scts
+			stapled.Certificates[i] = cert
+		}
+	}
+
+	if getCertificate := tlsConfig.GetCertificate; getCertificate != nil {
+		stapled.GetCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			cert, err := getCertificate(hello)
+			if err != nil || cert == nil {
+				return cert, err
+			}
+			// don't modify the certificate owned by the callback
+			stapledCert := *cert
+			stapledCert.SignedCertificateTimestamps = scts
+			return &stapledCert, nil
+		}
+	}
+
+	return stapled
+}
@@ -0,0 +1,98 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package server
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/edgelesssys/marblerun/coordinator/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadSCTFile(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tempDir, err := ioutil.TempDir("", "")
+	require.NoError(err)
+	defer os.RemoveAll(tempDir)
+
+	testCases := map[string]struct {
+		data    []byte
+		want    [][]byte
+		wantErr bool
+	}{
+		"single SCT":       {data: []byte{0, 5, 0, 3, 1, 2, 3}, want: [][]byte{{1, 2, 3}}},
+		"multiple SCTs":    {data: []byte{0, 7, 0, 2, 1, 2, 0, 1, 3}, want: [][]byte{{1, 2}, {3}}},
+		"empty file":       {data: []byte{}, wantErr: true},
+		"empty list":       {data: []byte{0, 0}, wantErr: true},
+		"list too long":    {data: []byte{0, 6, 0, 3, 1, 2, 3}, wantErr: true},
+		"SCT too long":     {data: []byte{0, 5, 0, 4, 1, 2, 3}, wantErr: true},
+		"empty SCT":        {data: []byte{0, 2, 0, 0}, wantErr: true},
+		"truncated length": {data: []byte{0, 1, 0}, wantErr: true},
+	}
+
+	for name, tc := range testCases {
+		filename := filepath.Join(tempDir, "scts")
+		require.NoError(ioutil.WriteFile(filename, tc.data, 0600))
+		scts, err := LoadSCTFile(filename)
+		if tc.wantErr {
+			assert.Error(err, name)
+			continue
+		}
+		assert.NoError(err, name)
+		assert.Equal(tc.want, scts, name)
+	}
+
+	_, err = LoadSCTFile(filepath.Join(tempDir, "missing"))
+	assert.Error(err)
+}
+
+func TestStapleSCTs(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	scts := [][]byte{[]byte("sct1"), []byte("sct2")}
+
+	tlsConfig, err := core.NewCoreWithMocks().GetTLSConfig()
+	require.NoError(err)
+	stapledConfig := StapleSCTs(tlsConfig, scts)
+
+	listener, err := tls.Listen("tcp", "localhost:0", stapledConfig)
+	require.NoError(err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	conn, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	require.NoError(err)
+	defer conn.Close()
+	assert.Equal(scts, conn.ConnectionState().SignedCertificateTimestamps)
+
+	// the original config is unchanged
+	cert, err := tlsConfig.GetCertificate(&tls.ClientHelloInfo{})
+	require.NoError(err)
+	assert.Empty(cert.SignedCertificateTimestamps)
+
+	// static certificates are stapled as well
+	stapledConfig = StapleSCTs(&tls.Config{Certificates: []tls.Certificate{*cert}}, scts)
+	assert.Equal(scts, stapledConfig.Certificates[0].SignedCertificateTimestamps)
+	assert.Empty(cert.SignedCertificateTimestamps)
+}
@@ -8,15 +8,21 @@
 package server
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"io/ioutil"
 	"net"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/edgelesssys/marblerun/coordinator/core"
 	"github.com/edgelesssys/marblerun/coordinator/rpc"
@@ -25,12 +31,17 @@ import (
 	grpc_zap "github.com/grpc-ecosystem/go-grpc-middleware/logging/zap"
 	grpc_ctxtags "github.com/grpc-ecosystem/go-grpc-middleware/tags"
 	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 )
 
+// ManifestSignatureHeader holds the base64 encoded signature of a manifest set with a POST request to /manifest.
+// It is required if the Coordinator is pinned to a manifest signing key.
+const ManifestSignatureHeader = "Marblerun-Manifest-Signature"
+
 // GeneralResponse is a wrapper for all our REST API responses to follow the JSend style: https://github.com/omniti-labs/jsend
 type GeneralResponse struct {
 	Status  string      `json:"status"`
@@ -41,12 +52,18 @@ type certQuoteResp struct {
 	Cert  string
 	Quote []byte
 }
+type certChainResp struct {
+	CertChain string
+}
 type statusResp struct {
 	StatusCode    int
 	StatusMessage string
+	State         core.Phase
+	ManifestHash  string `json:",omitempty"`
 }
 type manifestSignatureResp struct {
 	ManifestSignature string
+	Manifest          []byte
 }
 
 // Contains RSA-encrypted AES state sealing key with public key specified by user in manifest
@@ -58,36 +75,128 @@ type recoveryStatusResp struct {
 	StatusMessage string
 }
 
-// RunMarbleServer starts a gRPC with the given Coordinator core.
-// `address` is the desired TCP address like "localhost:0".
-// The effective TCP address is returned via `addrChan`.
-func RunMarbleServer(core *core.Core, addr string, addrChan chan string, errChan chan error, zapLogger *zap.Logger) {
-	tlsConfig := tls.Config{
-		GetCertificate: core.GetTLSIntermediateCertificate,
-		// NOTE: we'll verify the cert later using the given quote
-		ClientAuth: tls.RequireAnyClientCert,
-	}
-	creds := credentials.NewTLS(&tlsConfig)
+// Contains the RSA-encrypted recovery key with the public key supplied by the admin
+type recoveryKeyResp struct {
+	EncryptedRecoveryKey []byte
+}
+
+type activationLogResp struct {
+	Activations []core.ActivationRecord
+	Total       int
+}
+
+// evaluateResp lists the activated marbles which would fail to activate with the evaluated manifest
+type evaluateResp struct {
+	Violations []core.ManifestViolation
+}
+
+// marbleLeasesResp lists the leases of the activated marbles
+type marbleLeasesResp struct {
+	Marbles []core.MarbleLease
+}
+
+// marbleCertificateResp holds the certificate issued to a marble in PEM format and its validity
+type marbleCertificateResp struct {
+	UUID        string
+	Certificate string
+	NotBefore   time.Time
+	NotAfter    time.Time
+}
+
+// maintenanceResp holds whether the Coordinator is in maintenance mode
+type maintenanceResp struct {
+	Maintenance bool
+}
+
+// secretRotationResp holds the version of a rotated secret
+type secretRotationResp struct {
+	Name    string
+	Version uint64
+}
+
+// secretsResp lists the secrets declared in the manifest without their values
+type secretsResp struct {
+	Secrets []core.SecretInfo
+}
+
+// rootRotationResp holds the last rotation of the root certificate and the involved certificates in PEM format
+type rootRotationResp struct {
+	Timestamp                   time.Time
+	TransitionEnd               time.Time
+	InTransition                bool
+	PreviousRootCert            string
+	CrossSignedRootCert         string
+	CrossSignedIntermediateCert string
+}
+
+// activationLogDefaultLimit is the number of activation log entries returned if the request does not set a limit
+const activationLogDefaultLimit = 100
+
+// NewMarbleServer creates the gRPC server of the Marble API of the given Coordinator core.
+// `activationRateLimit` is the number of activations per second accepted from a single source address, 0 disables rate limiting.
+// `activationTimeout` is the deadline for handling a single activation, 0 disables the timeout.
+// `keepaliveConfig` tunes the keepalive behavior of the connections, e.g., to align it with the idle timeout of a load balancer.
+// `concurrencyConfig` limits the number of activations handled at once, e.g., to protect quote validation when many marbles restart.
+// `maxMsgSize` is the maximum size in bytes of sent and received messages, e.g., activation responses holding large secrets.
+// `tlsOptions` restricts the accepted TLS versions and cipher suites.
+// `alpn` are the protocols advertised in the TLS handshake, e.g., for load balancers routing by ALPN.
+func NewMarbleServer(core *core.Core, zapLogger *zap.Logger, activationRateLimit float64, activationTimeout time.Duration, keepaliveConfig KeepaliveConfig, concurrencyConfig ConcurrencyConfig, maxMsgSize int, tlsOptions TLSOptions, alpn []string) *grpc.Server {
+	creds := credentials.NewTLS(marbleServerTLSConfig(core.GetTLSIntermediateCertificate, tlsOptions, alpn))
 
 	// Make sure that log statements internal to gRPC library are logged using the zapLogger as well.
 	grpc_zap.ReplaceGrpcLoggerV2(zapLogger)
 
-	grpcServer := grpc.NewServer(
+	unaryInterceptors := []grpc.UnaryServerInterceptor{
+		grpc_ctxtags.UnaryServerInterceptor(),
+		grpc_zap.UnaryServerInterceptor(zapLogger),
+		grpc_prometheus.UnaryServerInterceptor,
+		tracingUnaryServerInterceptor(),
+	}
+	// the limits only apply to activations, other RPCs like GetSecret don't use up their budget
+	if activationRateLimit > 0 {
+		unaryInterceptors = append(unaryInterceptors, activationOnly(newRateLimiter(activationRateLimit).UnaryServerInterceptor()))
+	}
+	if activationTimeout > 0 {
+		unaryInterceptors = append(unaryInterceptors, activationOnly(timeoutUnaryServerInterceptor(activationTimeout)))
+	}
+	// queued requests are subject to the activation timeout
+	if concurrencyConfig.Max > 0 {
+		unaryInterceptors = append(unaryInterceptors, activationOnly(concurrencyConfig.UnaryServerInterceptor()))
+	}
+
+	serverOptions := append([]grpc.ServerOption{
 		grpc.Creds(creds),
 		grpc.StreamInterceptor(grpc_middleware.ChainStreamServer(
 			grpc_ctxtags.StreamServerInterceptor(),
 			grpc_zap.StreamServerInterceptor(zapLogger),
 			grpc_prometheus.StreamServerInterceptor,
 		)),
-		grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(
-			grpc_ctxtags.UnaryServerInterceptor(),
-			grpc_zap.UnaryServerInterceptor(zapLogger),
-			grpc_prometheus.UnaryServerInterceptor,
-		)),
-	)
+		grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(unaryInterceptors...)),
+	}, keepaliveConfig.serverOptions()...)
+	serverOptions = append(serverOptions, maxMsgSizeServerOptions(maxMsgSize)...)
 
+	grpcServer := grpc.NewServer(serverOptions...)
 	rpc.RegisterMarbleServer(grpcServer, core)
-	socket, err := net.Listen("tcp", addr)
+	return grpcServer
+}
+
+// marbleServerTLSConfig returns the TLS config of the marble server
+func marbleServerTLSConfig(getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error), tlsOptions TLSOptions, alpn []string) *tls.Config {
+	tlsConfig := tlsOptions.Apply(&tls.Config{
+		GetCertificate: getCertificate,
+		// NOTE: we'll verify the cert later using the given quote
+		ClientAuth: tls.RequireAnyClientCert,
+	})
+	tlsConfig.NextProtos = append([]string(nil), alpn...)
+	return tlsConfig
+}
+
+// RunMarbleServer serves the gRPC server created by NewMarbleServer.
+// `address` is the desired TCP address like "localhost:0".
+// Binding the address is retried according to `bindBackoff`, only the last error is returned via `errChan`.
+// The effective TCP address is returned via `addrChan`.
+func RunMarbleServer(grpcServer *grpc.Server, addr string, bindBackoff BindBackoff, zapLogger *zap.Logger, addrChan chan string, errChan chan error) {
+	socket, err := bindBackoff.listen(net.Listen, addr, zapLogger)
 	if err != nil {
 		errChan <- err
 		return
@@ -99,6 +208,28 @@ func RunMarbleServer(core *core.Core, addr string, addrChan chan string, errChan
 	}
 }
 
+// activateMethod is the full gRPC method name of marble activations
+const activateMethod = "/rpc.Marble/Activate"
+
+// activationOnly applies interceptor to activations only, other RPCs are passed to their handler directly
+func activationOnly(interceptor grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if info.FullMethod != activateMethod {
+			return handler(ctx, req)
+		}
+		return interceptor(ctx, req, info, handler)
+	}
+}
+
+// timeoutUnaryServerInterceptor returns a grpc.UnaryServerInterceptor which sets a deadline on the context passed to the handler
+func timeoutUnaryServerInterceptor(timeout time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return handler(ctx, req)
+	}
+}
+
 // CreateServeMux creates a mux that serves the client API.
 func CreateServeMux(cc core.ClientCore) *http.ServeMux {
 	mux := http.NewServeMux()
@@ -111,7 +242,7 @@ func CreateServeMux(cc core.ClientCore) *http.ServeMux {
 				writeJSONError(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
-			writeJSON(w, statusResp{statusCode, status})
+			writeJSON(w, statusResp{statusCode, status, cc.GetPhase(r.Context()), hex.EncodeToString(cc.GetManifestSignature(r.Context()))})
 		default:
 			writeJSONError(w, "", http.StatusMethodNotAllowed)
 		}
@@ -121,14 +252,19 @@ func CreateServeMux(cc core.ClientCore) *http.ServeMux {
 		switch r.Method {
 		case http.MethodGet:
 			signature := cc.GetManifestSignature(r.Context())
-			writeJSON(w, manifestSignatureResp{hex.EncodeToString(signature)})
+			writeJSON(w, manifestSignatureResp{hex.EncodeToString(signature), cc.GetManifest(r.Context())})
 		case http.MethodPost:
 			manifest, err := ioutil.ReadAll(r.Body)
 			if err != nil {
 				writeJSONError(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
-			recoverySecretMap, err := cc.SetManifest(r.Context(), manifest)
+			signature, err := base64.StdEncoding.DecodeString(r.Header.Get(ManifestSignatureHeader))
+			if err != nil {
+				writeJSONError(w, "invalid manifest signature: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			recoverySecretMap, err := cc.SetSignedManifest(r.Context(), manifest, signature)
 
 			if err != nil {
 				writeJSONError(w, err.Error(), http.StatusBadRequest)
@@ -151,6 +287,20 @@ func CreateServeMux(cc core.ClientCore) *http.ServeMux {
 		}
 	})
 
+	mux.HandleFunc("/certchain", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			certChain, err := cc.GetCertChain(r.Context())
+			if err != nil {
+				writeJSONError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, certChainResp{certChain})
+		default:
+			writeJSONError(w, "", http.StatusMethodNotAllowed)
+		}
+	})
+
 	mux.HandleFunc("/quote", func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
@@ -197,10 +347,33 @@ func CreateServeMux(cc core.ClientCore) *http.ServeMux {
 		}
 	})
 
+	mux.HandleFunc("/recovery", func(w http.ResponseWriter, r *http.Request) {
+		// Abort if no admin client certificate was provided
+		if !verifyAdmin(w, r, cc) {
+			return
+		}
+
+		switch r.Method {
+		case http.MethodPost:
+			publicKey, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				writeJSONError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			encryptedRecoveryKey, err := cc.ExportRecoveryKey(r.Context(), publicKey)
+			if err != nil {
+				writeJSONError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			writeJSON(w, recoveryKeyResp{encryptedRecoveryKey})
+		default:
+			writeJSONError(w, "", http.StatusMethodNotAllowed)
+		}
+	})
+
 	mux.HandleFunc("/update", func(w http.ResponseWriter, r *http.Request) {
 		// Abort if no admin client certificate was provided
-		if r.TLS == nil || !cc.VerifyAdmin(r.Context(), r.TLS.PeerCertificates) {
-			writeJSONError(w, "unauthorized user", http.StatusUnauthorized)
+		if !verifyAdmin(w, r, cc) {
 			return
 		}
 
@@ -222,9 +395,257 @@ func CreateServeMux(cc core.ClientCore) *http.ServeMux {
 		}
 	})
 
+	mux.HandleFunc("/evaluate", func(w http.ResponseWriter, r *http.Request) {
+		// Abort if no admin client certificate was provided
+		if !verifyAdmin(w, r, cc) {
+			return
+		}
+
+		switch r.Method {
+		case http.MethodPost:
+			manifest, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				writeJSONError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			violations, err := cc.EvaluateManifest(r.Context(), manifest)
+			if err != nil {
+				writeJSONError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			writeJSON(w, evaluateResp{violations})
+		default:
+			writeJSONError(w, "", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/activations", func(w http.ResponseWriter, r *http.Request) {
+		// Abort if no admin client certificate was provided
+		if !verifyAdmin(w, r, cc) {
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			offset, err := queryInt(r, "offset", 0)
+			if err != nil {
+				writeJSONError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			limit, err := queryInt(r, "limit", activationLogDefaultLimit)
+			if err != nil {
+				writeJSONError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			records, total := cc.GetActivationLog(r.Context(), offset, limit)
+			writeJSON(w, activationLogResp{records, total})
+		default:
+			writeJSONError(w, "", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/marbles", func(w http.ResponseWriter, r *http.Request) {
+		// Abort if no admin client certificate was provided
+		if !verifyAdmin(w, r, cc) {
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, marbleLeasesResp{cc.GetMarbleLeases(r.Context())})
+		default:
+			writeJSONError(w, "", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/marbles/revoke", func(w http.ResponseWriter, r *http.Request) {
+		// Abort if no admin client certificate was provided
+		if !verifyAdmin(w, r, cc) {
+			return
+		}
+
+		switch r.Method {
+		case http.MethodPost:
+			marbleUUID := r.URL.Query().Get("uuid")
+			if marbleUUID == "" {
+				writeJSONError(w, "missing uuid", http.StatusBadRequest)
+				return
+			}
+			if err := cc.RevokeMarble(r.Context(), marbleUUID); err != nil {
+				if err == core.ErrUnknownMarble {
+					writeJSONError(w, err.Error(), http.StatusNotFound)
+					return
+				}
+				writeJSONError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, nil)
+		default:
+			writeJSONError(w, "", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/marbles/certificate", func(w http.ResponseWriter, r *http.Request) {
+		// Abort if no admin client certificate was provided
+		if !verifyAdmin(w, r, cc) {
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			marbleUUID := r.URL.Query().Get("uuid")
+			if marbleUUID == "" {
+				writeJSONError(w, "missing uuid", http.StatusBadRequest)
+				return
+			}
+			cert, err := cc.GetMarbleCertificate(r.Context(), marbleUUID)
+			if err != nil {
+				if err == core.ErrUnknownMarble {
+					writeJSONError(w, err.Error(), http.StatusNotFound)
+					return
+				}
+				writeJSONError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+			writeJSON(w, marbleCertificateResp{marbleUUID, string(certPEM), cert.NotBefore, cert.NotAfter})
+		default:
+			writeJSONError(w, "", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/secrets", func(w http.ResponseWriter, r *http.Request) {
+		// Abort if no admin client certificate was provided
+		if !verifyAdmin(w, r, cc) {
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, secretsResp{cc.GetSecrets(r.Context())})
+		default:
+			writeJSONError(w, "", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/secrets/rotate", func(w http.ResponseWriter, r *http.Request) {
+		// Abort if no admin client certificate was provided
+		if !verifyAdmin(w, r, cc) {
+			return
+		}
+
+		switch r.Method {
+		case http.MethodPost:
+			name := r.URL.Query().Get("name")
+			if name == "" {
+				writeJSONError(w, "missing name", http.StatusBadRequest)
+				return
+			}
+			version, err := cc.RotateSecret(r.Context(), name)
+			if err != nil {
+				if err == core.ErrUnknownSecret {
+					writeJSONError(w, err.Error(), http.StatusNotFound)
+					return
+				}
+				writeJSONError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			writeJSON(w, secretRotationResp{name, version})
+		default:
+			writeJSONError(w, "", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/maintenance", func(w http.ResponseWriter, r *http.Request) {
+		// Abort if no admin client certificate was provided
+		if !verifyAdmin(w, r, cc) {
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, maintenanceResp{cc.InMaintenance(r.Context())})
+		case http.MethodPost:
+			enabled, err := strconv.ParseBool(r.URL.Query().Get("enabled"))
+			if err != nil {
+				writeJSONError(w, "invalid value for enabled: must be true or false", http.StatusBadRequest)
+				return
+			}
+			cc.SetMaintenance(r.Context(), enabled)
+			writeJSON(w, maintenanceResp{enabled})
+		default:
+			writeJSONError(w, "", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/rootcert/rotate", func(w http.ResponseWriter, r *http.Request) {
+		// Abort if no admin client certificate was provided
+		if !verifyAdmin(w, r, cc) {
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			rotation := cc.GetRootRotation(r.Context())
+			if rotation == nil {
+				writeJSON(w, nil)
+				return
+			}
+			writeJSON(w, newRootRotationResp(*rotation))
+		case http.MethodPost:
+			transition := core.DefaultRootRotationTransition
+			if value := r.URL.Query().Get("transition"); value != "" {
+				var err error
+				transition, err = time.ParseDuration(value)
+				if err != nil || transition <= 0 {
+					writeJSONError(w, "invalid value for transition: must be a positive duration", http.StatusBadRequest)
+					return
+				}
+			}
+			rotation, err := cc.RotateRootCertificate(r.Context(), transition)
+			if err != nil {
+				writeJSONError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, newRootRotationResp(rotation))
+		default:
+			writeJSONError(w, "", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/events", eventsHandler(cc))
+
 	return mux
 }
 
+// newRootRotationResp encodes the certificates of a root certificate rotation in PEM format
+func newRootRotationResp(rotation core.RootRotation) rootRotationResp {
+	encode := func(raw []byte) string {
+		return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: raw}))
+	}
+	return rootRotationResp{
+		Timestamp:                   rotation.Timestamp,
+		TransitionEnd:               rotation.TransitionEnd,
+		InTransition:                rotation.InTransition(time.Now()),
+		PreviousRootCert:            encode(rotation.PreviousRootCert),
+		CrossSignedRootCert:         encode(rotation.CrossSignedRootCert),
+		CrossSignedIntermediateCert: encode(rotation.CrossSignedIntermediateCert),
+	}
+}
+
+// queryInt parses a non-negative integer query parameter, returning fallback if it is not set
+func queryInt(r *http.Request, name string, fallback int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 {
+		return 0, fmt.Errorf("invalid value for %s: %s", name, value)
+	}
+	return parsed, nil
+}
+
 func writeJSON(w http.ResponseWriter, v interface{}) {
 	dataToReturn := GeneralResponse{Status: "success", Data: v}
 	if err := json.NewEncoder(w).Encode(dataToReturn); err != nil {
@@ -241,24 +662,80 @@ func writeJSONError(w http.ResponseWriter, errorString string, httpErrorCode int
 	http.Error(w, string(marshalledJSON), httpErrorCode)
 }
 
-// RunClientServer runs a HTTP server serving mux.
-func RunClientServer(mux *http.ServeMux, address string, tlsConfig *tls.Config, zapLogger *zap.Logger) {
-	loggedRouter := handlers.LoggingHandler(os.Stdout, mux)
+// verifyAdmin checks that the request was made with an admin's client certificate, otherwise it writes an error.
+// Requests without TLS, i.e., over the Unix domain socket, carry no client certificate, so admin endpoints aren't available there.
+func verifyAdmin(w http.ResponseWriter, r *http.Request, cc core.ClientCore) bool {
+	if r.TLS == nil {
+		writeJSONError(w, "admin endpoints require a TLS client certificate and aren't available without TLS, e.g., on the Unix socket", http.StatusUnauthorized)
+		return false
+	}
+	if !cc.VerifyAdmin(r.Context(), r.TLS.PeerCertificates) {
+		writeJSONError(w, "unauthorized user", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// RunClientServer runs a HTTP server serving handler, e.g., the mux created by CreateServeMux. Clients may use HTTP/2 on TLS connections.
+// If address is prefixed with "unix://", the server listens on the Unix domain socket at the given path without TLS.
+// Only the current user can connect to the socket, and admin endpoints aren't available on it because they authenticate admins by their TLS client certificate.
+func RunClientServer(handler http.Handler, address string, tlsConfig *tls.Config, zapLogger *zap.Logger) {
+	loggedRouter := handlers.LoggingHandler(os.Stdout, handler)
 	server := http.Server{
 		Addr:      address,
 		Handler:   loggedRouter,
-		TLSConfig: tlsConfig,
+		TLSConfig: enableHTTP2(tlsConfig),
+	}
+
+	// Serve plain HTTP on a Unix domain socket, access is restricted by the socket's file permissions instead
+	if strings.HasPrefix(address, unixSocketPrefix) {
+		socketPath := strings.TrimPrefix(address, unixSocketPrefix)
+		zapLogger.Info("starting client http server on unix socket", zap.String("path", socketPath))
+		listener, err := listenUnix(socketPath)
+		if err != nil {
+			zapLogger.Warn(err.Error())
+			return
+		}
+		err = server.Serve(listener)
+		zapLogger.Warn(err.Error())
+		return
 	}
+
 	zapLogger.Info("starting client https server", zap.String("address", address))
 	err := server.ListenAndServeTLS("", "")
 	zapLogger.Warn(err.Error())
 }
 
-// RunPrometheusServer runs a HTTP server handling the prometheus metrics endpoint
-func RunPrometheusServer(address string, zapLogger *zap.Logger) {
+// unixSocketPrefix marks a client server address as a Unix domain socket path
+const unixSocketPrefix = "unix://"
+
+// listenUnix listens on a Unix domain socket which is only accessible by the current user
+func listenUnix(socketPath string) (net.Listener, error) {
+	// Remove a stale socket of a previous run
+	if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	// The socket is created with the permissions of the umask, restrict them before it becomes reachable
+	oldMask := syscall.Umask(0177)
+	listener, err := net.Listen("unix", socketPath)
+	syscall.Umask(oldMask)
+	return listener, err
+}
+
+// RunPrometheusServer runs a HTTP server handling the prometheus metrics endpoint.
+// It exposes the metrics of the default registry, e.g., of the gRPC server, and the given collectors.
+func RunPrometheusServer(address string, zapLogger *zap.Logger, collectors ...prometheus.Collector) {
 	mux := http.NewServeMux()
-	mux.Handle("/metrics", promhttp.Handler())
+	mux.Handle("/metrics", metricsHandler(collectors...))
 	zapLogger.Info("starting prometheus /metrics endpoint", zap.String("address", address))
 	err := http.ListenAndServe(address, mux)
 	zapLogger.Warn(err.Error())
 }
+
+// metricsHandler serves the metrics of the default registry and of the given collectors.
+// Scrapers negotiating the OpenMetrics format also receive the exemplars of the metrics.
+func metricsHandler(collectors ...prometheus.Collector) http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collectors...)
+	return promhttp.HandlerFor(prometheus.Gatherers{prometheus.DefaultGatherer, registry}, promhttp.HandlerOpts{EnableOpenMetrics: true})
+}
@@ -7,24 +7,44 @@
 package server
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
+	"syscall"
 	"testing"
+	"time"
 
+	libMarble "github.com/edgelesssys/ego/marble"
 	"github.com/edgelesssys/marblerun/coordinator/core"
+	"github.com/edgelesssys/marblerun/coordinator/manifest"
+	"github.com/edgelesssys/marblerun/coordinator/quote"
+	"github.com/edgelesssys/marblerun/coordinator/recovery"
+	"github.com/edgelesssys/marblerun/coordinator/rpc"
 	"github.com/edgelesssys/marblerun/test"
 	"github.com/edgelesssys/marblerun/util"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/tidwall/gjson"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
 )
 
 func TestQuote(t *testing.T) {
@@ -38,6 +58,96 @@ func TestQuote(t *testing.T) {
 	assert.Equal(http.StatusOK, resp.Code)
 }
 
+func TestCertChain(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	c := core.NewCoreWithMocks()
+	mux := CreateServeMux(c)
+
+	getChain := func() []*pem.Block {
+		req := httptest.NewRequest(http.MethodGet, "/certchain", nil)
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, req)
+		require.Equal(http.StatusOK, resp.Code)
+
+		var blocks []*pem.Block
+		rest := []byte(gjson.Get(resp.Body.String(), "data.CertChain").String())
+		for {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			assert.Equal("CERTIFICATE", block.Type)
+			blocks = append(blocks, block)
+		}
+		return blocks
+	}
+
+	// before and after a manifest is set
+	assert.Len(getChain(), 2)
+	_, err := c.SetManifest(context.TODO(), []byte(test.ManifestJSON))
+	require.NoError(err)
+	assert.Len(getChain(), 2)
+
+	req := httptest.NewRequest(http.MethodPost, "/certchain", nil)
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	assert.Equal(http.StatusMethodNotAllowed, resp.Code)
+}
+
+func TestStatus(t *testing.T) {
+	require := require.New(t)
+
+	// a core which can't decrypt its sealed state is in recovery
+	sealDir, err := ioutil.TempDir("", "")
+	require.NoError(err)
+	defer os.RemoveAll(sealDir)
+	sealer := core.NewNoEnclaveSealer(sealDir)
+	recoveryCore, err := core.NewCore([]string{"localhost"}, quote.NewMockValidator(), quote.NewMockIssuer(), sealer, recovery.NewSinglePartyRecovery(), zap.NewNop())
+	require.NoError(err)
+	_, err = recoveryCore.SetManifest(context.TODO(), []byte(test.ManifestJSON))
+	require.NoError(err)
+	require.NoError(ioutil.WriteFile(filepath.Join(sealDir, core.SealedKeyFname), make([]byte, 16), 0600))
+	recoveryCore, err = core.NewCore([]string{"localhost"}, quote.NewMockValidator(), quote.NewMockIssuer(), sealer, recovery.NewSinglePartyRecovery(), zap.NewNop())
+	require.NoError(err)
+
+	marblesCore := core.NewCoreWithMocks()
+	_, err = marblesCore.SetManifest(context.TODO(), []byte(test.ManifestJSON))
+	require.NoError(err)
+
+	testCases := []struct {
+		core         *core.Core
+		phase        core.Phase
+		manifestHash string
+	}{
+		{core: recoveryCore, phase: core.PhaseRecovery},
+		{core: core.NewCoreWithMocks(), phase: core.PhaseAcceptingManifest},
+		{core: marblesCore, phase: core.PhaseAcceptingMarbles, manifestHash: hex.EncodeToString(marblesCore.GetManifestSignature(context.TODO()))},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.phase), func(t *testing.T) {
+			assert := assert.New(t)
+
+			mux := CreateServeMux(tc.core)
+			req := httptest.NewRequest(http.MethodGet, "/status", nil)
+			resp := httptest.NewRecorder()
+			mux.ServeHTTP(resp, req)
+			assert.Equal(http.StatusOK, resp.Code)
+
+			assert.Equal(string(tc.phase), gjson.Get(resp.Body.String(), "data.State").String())
+			manifestHash := gjson.Get(resp.Body.String(), "data.ManifestHash")
+			if tc.manifestHash == "" {
+				assert.False(manifestHash.Exists())
+			} else {
+				assert.Equal(tc.manifestHash, manifestHash.String())
+			}
+		})
+	}
+}
+
 func TestManifest(t *testing.T) {
 	assert := assert.New(t)
 	require := require.New(t)
@@ -58,7 +168,8 @@ func TestManifest(t *testing.T) {
 	require.Equal(http.StatusOK, resp.Code)
 
 	sig := hex.EncodeToString(c.GetManifestSignature(context.TODO()))
-	assert.JSONEq(`{"status":"success","data":{"ManifestSignature":"`+sig+`"}}`, resp.Body.String())
+	manifest := base64.StdEncoding.EncodeToString([]byte(test.ManifestJSON))
+	assert.JSONEq(`{"status":"success","data":{"ManifestSignature":"`+sig+`","Manifest":"`+manifest+`"}}`, resp.Body.String())
 
 	// try setting manifest again, should fail
 	req = httptest.NewRequest(http.MethodPost, "/manifest", strings.NewReader(test.ManifestJSON))
@@ -133,6 +244,98 @@ func TestUpdate(t *testing.T) {
 	assert.Equal(http.StatusOK, resp.Code)
 }
 
+func TestRecovery(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	c := core.NewCoreWithMocks()
+	_, err := c.SetManifest(context.TODO(), []byte(test.ManifestJSONWithRecoveryKey))
+	require.NoError(err)
+	mux := CreateServeMux(c)
+
+	// Export without admin certificate should be unauthenticated
+	req := httptest.NewRequest(http.MethodPost, "/recovery", bytes.NewReader(test.RecoveryPublicKey))
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	assert.Equal(http.StatusUnauthorized, resp.Code)
+
+	adminTestCert, _ := test.MustSetupTestCerts(test.RecoveryPrivateKey)
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{adminTestCert}}
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	require.Equal(http.StatusOK, resp.Code)
+
+	encryptedRecoveryKey, err := base64.StdEncoding.DecodeString(gjson.Get(resp.Body.String(), "data.EncryptedRecoveryKey").String())
+	require.NoError(err)
+	_, err = util.DecryptOAEP(test.RecoveryPrivateKey, encryptedRecoveryKey)
+	assert.NoError(err)
+}
+
+func TestClientServerUnixSocket(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tempDir, err := ioutil.TempDir("", "")
+	require.NoError(err)
+	defer os.RemoveAll(tempDir)
+	socketPath := filepath.Join(tempDir, "client.sock")
+
+	go RunClientServer(CreateServeMux(core.NewCoreWithMocks()), "unix://"+socketPath, nil, zap.NewNop())
+
+	// Wait for the socket to be created, it must only be accessible by the current user
+	require.Eventually(func() bool {
+		info, err := os.Stat(socketPath)
+		return err == nil && info.Mode().Perm() == 0600
+	}, 5*time.Second, 10*time.Millisecond)
+
+	client := http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+			},
+		},
+	}
+	resp, err := client.Get("http://unix/status")
+	require.NoError(err)
+	defer resp.Body.Close()
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(err)
+	assert.Equal(string(core.PhaseAcceptingManifest), gjson.GetBytes(body, "data.State").String())
+
+	// admin endpoints require a TLS client certificate
+	resp, err = client.Get("http://unix/events")
+	require.NoError(err)
+	defer resp.Body.Close()
+	assert.Equal(http.StatusUnauthorized, resp.StatusCode)
+	body, err = ioutil.ReadAll(resp.Body)
+	require.NoError(err)
+	assert.Contains(gjson.GetBytes(body, "message").String(), "Unix socket")
+}
+
+func TestListenUnix(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tempDir, err := ioutil.TempDir("", "")
+	require.NoError(err)
+	defer os.RemoveAll(tempDir)
+	socketPath := filepath.Join(tempDir, "client.sock")
+
+	// the socket is restricted to the current user from its creation on, regardless of the umask
+	oldMask := syscall.Umask(0)
+	defer syscall.Umask(oldMask)
+	require.NoError(ioutil.WriteFile(socketPath, nil, 0600))
+	listener, err := listenUnix(socketPath)
+	require.NoError(err)
+	defer listener.Close()
+	info, err := os.Stat(socketPath)
+	require.NoError(err)
+	assert.Equal(os.ModeSocket, info.Mode().Type())
+	assert.Equal(os.FileMode(0600), info.Mode().Perm())
+	assert.Equal(0, syscall.Umask(0))
+}
+
 func TestConcurrent(t *testing.T) {
 	// This test is used to detect data races when run with -race
 
@@ -173,3 +376,476 @@ func TestConcurrent(t *testing.T) {
 	go postManifest()
 	wg.Wait()
 }
+
+func TestTimeoutUnaryServerInterceptor(t *testing.T) {
+	assert := assert.New(t)
+
+	interceptor := timeoutUnaryServerInterceptor(time.Minute)
+	_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		deadline, ok := ctx.Deadline()
+		assert.True(ok)
+		assert.WithinDuration(time.Now().Add(time.Minute), deadline, time.Second)
+		return nil, nil
+	})
+	assert.NoError(err)
+}
+
+func TestActivationOnly(t *testing.T) {
+	assert := assert.New(t)
+
+	intercepted := 0
+	interceptor := activationOnly(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		intercepted++
+		return handler(ctx, req)
+	})
+	handled := 0
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		handled++
+		return nil, nil
+	}
+
+	_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/rpc.Marble/Activate"}, handler)
+	assert.NoError(err)
+	assert.Equal(1, intercepted)
+	assert.Equal(1, handled)
+
+	_, err = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/rpc.Marble/GetSecret"}, handler)
+	assert.NoError(err)
+	assert.Equal(1, intercepted)
+	assert.Equal(2, handled)
+}
+
+func TestActivationLog(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	c := core.NewCoreWithMocks()
+	_, err := c.SetManifest(context.TODO(), []byte(test.ManifestJSONWithRecoveryKey))
+	require.NoError(err)
+	mux := CreateServeMux(c)
+
+	// only admins may read the log
+	req := httptest.NewRequest(http.MethodGet, "/activations", nil)
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	assert.Equal(http.StatusUnauthorized, resp.Code)
+
+	adminTestCert, _ := test.MustSetupTestCerts(test.RecoveryPrivateKey)
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{adminTestCert}}
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	require.Equal(http.StatusOK, resp.Code)
+	assert.Equal(int64(0), gjson.Get(resp.Body.String(), "data.Total").Int())
+	assert.True(gjson.Get(resp.Body.String(), "data.Activations").IsArray())
+
+	// invalid pagination parameters
+	for _, query := range []string{"offset=-1", "limit=many"} {
+		req := httptest.NewRequest(http.MethodGet, "/activations?"+query, nil)
+		req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{adminTestCert}}
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, req)
+		assert.Equal(http.StatusBadRequest, resp.Code, query)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	c := core.NewCoreWithMocks()
+	_, err := c.SetManifest(context.TODO(), []byte(test.ManifestJSONWithRecoveryKey))
+	require.NoError(err)
+	mux := CreateServeMux(c)
+
+	// only admins may evaluate manifests
+	req := httptest.NewRequest(http.MethodPost, "/evaluate", strings.NewReader(test.ManifestJSON))
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	assert.Equal(http.StatusUnauthorized, resp.Code)
+
+	adminTestCert, _ := test.MustSetupTestCerts(test.RecoveryPrivateKey)
+	req = httptest.NewRequest(http.MethodPost, "/evaluate", strings.NewReader(test.ManifestJSON))
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{adminTestCert}}
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	require.Equal(http.StatusOK, resp.Code)
+	assert.True(gjson.Get(resp.Body.String(), "data.Violations").IsArray())
+
+	req = httptest.NewRequest(http.MethodPost, "/evaluate", strings.NewReader("invalid"))
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{adminTestCert}}
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	assert.Equal(http.StatusBadRequest, resp.Code)
+}
+
+func TestMarbles(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	c := core.NewCoreWithMocks()
+	_, err := c.SetManifest(context.TODO(), []byte(test.ManifestJSONWithRecoveryKey))
+	require.NoError(err)
+	mux := CreateServeMux(c)
+
+	// only admins may list and revoke marbles
+	req := httptest.NewRequest(http.MethodGet, "/marbles", nil)
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	assert.Equal(http.StatusUnauthorized, resp.Code)
+	req = httptest.NewRequest(http.MethodPost, "/marbles/revoke?uuid=1234", nil)
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	assert.Equal(http.StatusUnauthorized, resp.Code)
+
+	adminTestCert, _ := test.MustSetupTestCerts(test.RecoveryPrivateKey)
+	req = httptest.NewRequest(http.MethodGet, "/marbles", nil)
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{adminTestCert}}
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	require.Equal(http.StatusOK, resp.Code)
+	assert.True(gjson.Get(resp.Body.String(), "data.Marbles").IsArray())
+
+	req = httptest.NewRequest(http.MethodPost, "/marbles/revoke?uuid=1234", nil)
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{adminTestCert}}
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	assert.Equal(http.StatusNotFound, resp.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/marbles/revoke", nil)
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{adminTestCert}}
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	assert.Equal(http.StatusBadRequest, resp.Code)
+}
+
+func TestRotateSecret(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	c := core.NewCoreWithMocks()
+	_, err := c.SetManifest(context.TODO(), adminManifest(t))
+	require.NoError(err)
+	mux := CreateServeMux(c)
+
+	// only admins may rotate secrets
+	req := httptest.NewRequest(http.MethodPost, "/secrets/rotate?name=symmetric_key_shared", nil)
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	assert.Equal(http.StatusUnauthorized, resp.Code)
+
+	adminTestCert, _ := test.MustSetupTestCerts(test.RecoveryPrivateKey)
+	rotate := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/secrets/rotate"+query, nil)
+		req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{adminTestCert}}
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp = rotate("?name=symmetric_key_shared")
+	require.Equal(http.StatusOK, resp.Code)
+	assert.EqualValues(2, gjson.Get(resp.Body.String(), "data.Version").Int())
+
+	assert.Equal(http.StatusNotFound, rotate("?name=unknown").Code)
+	assert.Equal(http.StatusBadRequest, rotate("?name=symmetric_key_private").Code)
+	assert.Equal(http.StatusBadRequest, rotate("").Code)
+}
+
+func TestEvents(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	c := core.NewCoreWithMocks()
+	_, err := c.SetManifest(context.TODO(), []byte(test.ManifestJSONWithRecoveryKey))
+	require.NoError(err)
+	mux := CreateServeMux(c)
+
+	// only admins may read the events
+	req := httptest.NewRequest(http.MethodGet, "/events", nil)
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	assert.Equal(http.StatusUnauthorized, resp.Code)
+
+	// a failed activation is recorded as an event
+	_, err = c.Activate(context.TODO(), &rpc.ActivationReq{MarbleType: "frontend", UUID: "1234"})
+	require.Error(err)
+
+	adminTestCert, _ := test.MustSetupTestCerts(test.RecoveryPrivateKey)
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{adminTestCert}}
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	require.Equal(http.StatusOK, resp.Code)
+	assert.Equal("text/event-stream", resp.Header().Get("Content-Type"))
+	events := readEvents(t, resp.Body)
+	require.Len(events, 1)
+	assert.Equal(core.EventLevelError, events[0].Level)
+	assert.Equal("frontend", events[0].MarbleType)
+
+	// with follow, buffered events are followed by live events
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reader, writer := io.Pipe()
+	req = httptest.NewRequest(http.MethodGet, "/events?follow=true", nil).WithContext(ctx)
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{adminTestCert}}
+	done := make(chan struct{})
+	go func() {
+		mux.ServeHTTP(&streamRecorder{httptest.NewRecorder(), writer}, req)
+		writer.Close()
+		close(done)
+	}()
+
+	scanner := bufio.NewScanner(reader)
+	nextEvent := func() string {
+		for scanner.Scan() {
+			if scanner.Text() != "" {
+				return scanner.Text()
+			}
+		}
+		return ""
+	}
+	assert.Contains(nextEvent(), `"MarbleType":"frontend"`)
+
+	_, err = c.Activate(context.TODO(), &rpc.ActivationReq{MarbleType: "backend", UUID: "5678"})
+	require.Error(err)
+	assert.Contains(nextEvent(), `"MarbleType":"backend"`)
+
+	cancel()
+	go io.Copy(ioutil.Discard, reader)
+	<-done
+}
+
+// streamRecorder passes the written body to a pipe, so a test can read a streamed response while it is written
+type streamRecorder struct {
+	*httptest.ResponseRecorder
+	body io.Writer
+}
+
+func (r *streamRecorder) Write(p []byte) (int, error) {
+	return r.body.Write(p)
+}
+
+func readEvents(t *testing.T, body io.Reader) []core.Event {
+	var events []core.Event
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		data := strings.TrimPrefix(scanner.Text(), "data: ")
+		if data == "" {
+			continue
+		}
+		var event core.Event
+		require.NoError(t, json.Unmarshal([]byte(data), &event))
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestMetricsHandler(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	c := core.NewCoreWithMocks()
+	handler := metricsHandler(c.Metrics()...)
+
+	scrape := func() string {
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		require.Equal(http.StatusOK, resp.Code)
+		return resp.Body.String()
+	}
+
+	metrics := scrape()
+	assert.Contains(metrics, "coordinator_state 2\n")
+	assert.Contains(metrics, "coordinator_manifest_generation 0\n")
+
+	_, err := c.SetManifest(context.TODO(), []byte(test.ManifestJSON))
+	require.NoError(err)
+	metrics = scrape()
+	assert.Contains(metrics, "coordinator_state 3\n")
+	assert.Contains(metrics, "coordinator_manifest_generation 1\n")
+}
+
+// adminManifest returns the test manifest defining marbles and secrets, administered by the admin of the test manifest with recovery key
+func adminManifest(t *testing.T) []byte {
+	require := require.New(t)
+
+	var mnf, recoveryManifest manifest.Manifest
+	require.NoError(json.Unmarshal([]byte(test.ManifestJSON), &mnf))
+	require.NoError(json.Unmarshal([]byte(test.ManifestJSONWithRecoveryKey), &recoveryManifest))
+	mnf.Admins = recoveryManifest.Admins
+	rawManifest, err := json.Marshal(mnf)
+	require.NoError(err)
+	return rawManifest
+}
+
+// adminRequest serves a request to mux, made with clientCert if it isn't nil
+func adminRequest(mux http.Handler, method string, target string, clientCert *x509.Certificate) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	if clientCert != nil {
+		req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{clientCert}}
+	}
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	return resp
+}
+
+func TestMarbleCertificate(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var mnf manifest.Manifest
+	require.NoError(json.Unmarshal(adminManifest(t), &mnf))
+
+	// the core validates the quotes of the marbles with a validator of the test
+	validator := quote.NewMockValidator()
+	issuer := quote.NewMockIssuer()
+	c, err := core.NewCore([]string{"localhost"}, validator, issuer, &core.MockSealer{}, recovery.NewSinglePartyRecovery(), zap.NewNop())
+	require.NoError(err)
+	_, err = c.SetManifest(context.TODO(), adminManifest(t))
+	require.NoError(err)
+	mux := CreateServeMux(c)
+
+	marbleCert, csr, _ := util.MustGenerateTestMarbleCredentials()
+	marbleQuote, err := issuer.Issue(marbleCert.Raw)
+	require.NoError(err)
+	validator.AddValidQuote(marbleQuote, marbleCert.Raw, mnf.Packages["frontend"], mnf.Infrastructures["Azure"])
+	ctx := peer.NewContext(context.TODO(), &peer.Peer{
+		AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{PeerCertificates: []*x509.Certificate{marbleCert}}},
+	})
+	marbleUUID := uuid.New().String()
+	activation, err := c.Activate(ctx, &rpc.ActivationReq{CSR: csr, MarbleType: "frontend", Quote: marbleQuote, UUID: marbleUUID})
+	require.NoError(err)
+	block, _ := pem.Decode([]byte(activation.GetParameters().Env[libMarble.MarbleEnvironmentCertificateChain]))
+	require.NotNil(block)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(err)
+
+	// only admins may fetch certificates
+	assert.Equal(http.StatusUnauthorized, adminRequest(mux, http.MethodGet, "/marbles/certificate?uuid="+marbleUUID, nil).Code)
+
+	adminTestCert, _ := test.MustSetupTestCerts(test.RecoveryPrivateKey)
+	resp := adminRequest(mux, http.MethodGet, "/marbles/certificate?uuid="+marbleUUID, adminTestCert)
+	require.Equal(http.StatusOK, resp.Code)
+	assert.Equal(marbleUUID, gjson.Get(resp.Body.String(), "data.UUID").String())
+	block, _ = pem.Decode([]byte(gjson.Get(resp.Body.String(), "data.Certificate").String()))
+	require.NotNil(block)
+	assert.Equal(cert.Raw, block.Bytes)
+	assert.Equal(cert.NotAfter.Unix(), gjson.Get(resp.Body.String(), "data.NotAfter").Time().Unix())
+
+	assert.Equal(http.StatusNotFound, adminRequest(mux, http.MethodGet, "/marbles/certificate?uuid="+uuid.New().String(), adminTestCert).Code)
+	assert.Equal(http.StatusBadRequest, adminRequest(mux, http.MethodGet, "/marbles/certificate", adminTestCert).Code)
+
+	// the certificate of a revoked marble is not found
+	require.Equal(http.StatusOK, adminRequest(mux, http.MethodPost, "/marbles/revoke?uuid="+marbleUUID, adminTestCert).Code)
+	assert.Equal(http.StatusNotFound, adminRequest(mux, http.MethodGet, "/marbles/certificate?uuid="+marbleUUID, adminTestCert).Code)
+}
+
+func TestMaintenance(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	c := core.NewCoreWithMocks()
+	_, err := c.SetManifest(context.TODO(), adminManifest(t))
+	require.NoError(err)
+	mux := CreateServeMux(c)
+
+	// only admins may toggle the maintenance mode
+	assert.Equal(http.StatusUnauthorized, adminRequest(mux, http.MethodPost, "/maintenance?enabled=true", nil).Code)
+	assert.False(c.InMaintenance(context.TODO()))
+
+	adminTestCert, _ := test.MustSetupTestCerts(test.RecoveryPrivateKey)
+	resp := adminRequest(mux, http.MethodPost, "/maintenance?enabled=true", adminTestCert)
+	require.Equal(http.StatusOK, resp.Code)
+	assert.True(c.InMaintenance(context.TODO()))
+	assert.True(gjson.Get(resp.Body.String(), "data.Maintenance").Bool())
+
+	resp = adminRequest(mux, http.MethodGet, "/maintenance", adminTestCert)
+	require.Equal(http.StatusOK, resp.Code)
+	assert.True(gjson.Get(resp.Body.String(), "data.Maintenance").Bool())
+
+	require.Equal(http.StatusOK, adminRequest(mux, http.MethodPost, "/maintenance?enabled=false", adminTestCert).Code)
+	assert.False(c.InMaintenance(context.TODO()))
+
+	assert.Equal(http.StatusBadRequest, adminRequest(mux, http.MethodPost, "/maintenance", adminTestCert).Code)
+	assert.Equal(http.StatusMethodNotAllowed, adminRequest(mux, http.MethodDelete, "/maintenance", adminTestCert).Code)
+}
+
+func TestSecrets(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	c := core.NewCoreWithMocks()
+	_, err := c.SetManifest(context.TODO(), adminManifest(t))
+	require.NoError(err)
+	mux := CreateServeMux(c)
+
+	// only admins may list the secrets
+	assert.Equal(http.StatusUnauthorized, adminRequest(mux, http.MethodGet, "/secrets", nil).Code)
+
+	adminTestCert, _ := test.MustSetupTestCerts(test.RecoveryPrivateKey)
+	resp := adminRequest(mux, http.MethodGet, "/secrets", adminTestCert)
+	require.Equal(http.StatusOK, resp.Code)
+	secrets := gjson.Get(resp.Body.String(), "data.Secrets").Array()
+	require.Len(secrets, 4)
+	assert.Equal("cert_private", secrets[0].Get("Name").String())
+	assert.True(secrets[0].Get("Certificate").Bool())
+	assert.EqualValues(7, secrets[0].Get("ValidFor").Int())
+	assert.Equal("cert_shared", secrets[1].Get("Name").String())
+	assert.True(secrets[1].Get("NotAfter").Exists())
+	assert.Equal("symmetric_key_shared", secrets[3].Get("Name").String())
+	assert.True(secrets[3].Get("Shared").Bool())
+	assert.EqualValues(1, secrets[3].Get("Version").Int())
+
+	// rotations are reflected in the versions
+	require.Equal(http.StatusOK, adminRequest(mux, http.MethodPost, "/secrets/rotate?name=symmetric_key_shared", adminTestCert).Code)
+	resp = adminRequest(mux, http.MethodGet, "/secrets", adminTestCert)
+	require.Equal(http.StatusOK, resp.Code)
+	assert.EqualValues(2, gjson.Get(resp.Body.String(), "data.Secrets.3.Version").Int())
+
+	assert.Equal(http.StatusMethodNotAllowed, adminRequest(mux, http.MethodPost, "/secrets", adminTestCert).Code)
+}
+
+func TestRootRotation(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	c := core.NewCoreWithMocks()
+	_, err := c.SetManifest(context.TODO(), adminManifest(t))
+	require.NoError(err)
+	mux := CreateServeMux(c)
+
+	// only admins may rotate the root certificate
+	assert.Equal(http.StatusUnauthorized, adminRequest(mux, http.MethodPost, "/rootcert/rotate", nil).Code)
+	assert.Nil(c.GetRootRotation(context.TODO()))
+
+	adminTestCert, _ := test.MustSetupTestCerts(test.RecoveryPrivateKey)
+	resp := adminRequest(mux, http.MethodGet, "/rootcert/rotate", adminTestCert)
+	require.Equal(http.StatusOK, resp.Code)
+	assert.Equal(gjson.Null, gjson.Get(resp.Body.String(), "data").Type)
+
+	resp = adminRequest(mux, http.MethodPost, "/rootcert/rotate", adminTestCert)
+	require.Equal(http.StatusOK, resp.Code)
+	rotation := c.GetRootRotation(context.TODO())
+	require.NotNil(rotation)
+	assert.Equal(core.DefaultRootRotationTransition, rotation.TransitionEnd.Sub(rotation.Timestamp))
+	assert.True(gjson.Get(resp.Body.String(), "data.InTransition").Bool())
+	block, _ := pem.Decode([]byte(gjson.Get(resp.Body.String(), "data.CrossSignedIntermediateCert").String()))
+	require.NotNil(block)
+	assert.Equal(rotation.CrossSignedIntermediateCert, block.Bytes)
+
+	resp = adminRequest(mux, http.MethodPost, "/rootcert/rotate?transition=1h", adminTestCert)
+	require.Equal(http.StatusOK, resp.Code)
+	rotation = c.GetRootRotation(context.TODO())
+	require.NotNil(rotation)
+	assert.Equal(time.Hour, rotation.TransitionEnd.Sub(rotation.Timestamp))
+
+	resp = adminRequest(mux, http.MethodGet, "/rootcert/rotate", adminTestCert)
+	require.Equal(http.StatusOK, resp.Code)
+	assert.True(gjson.Get(resp.Body.String(), "data.InTransition").Bool())
+	block, _ = pem.Decode([]byte(gjson.Get(resp.Body.String(), "data.CrossSignedRootCert").String()))
+	require.NotNil(block)
+	assert.Equal(rotation.CrossSignedRootCert, block.Bytes)
+
+	assert.Equal(http.StatusBadRequest, adminRequest(mux, http.MethodPost, "/rootcert/rotate?transition=-1h", adminTestCert).Code)
+	assert.Equal(http.StatusBadRequest, adminRequest(mux, http.MethodPost, "/rootcert/rotate?transition=invalid", adminTestCert).Code)
+	assert.Equal(http.StatusMethodNotAllowed, adminRequest(mux, http.MethodPut, "/rootcert/rotate", adminTestCert).Code)
+}
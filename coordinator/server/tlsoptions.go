@@ -0,0 +1,49 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+
+	"github.com/edgelesssys/marblerun/coordinator/config"
+	"github.com/edgelesssys/marblerun/util"
+)
+
+// TLSOptions restricts the TLS versions and cipher suites accepted by the client and marble servers
+type TLSOptions struct {
+	MinVersion   uint16
+	CipherSuites []uint16
+}
+
+// LoadTLSOptions reads the TLS options from the environment, falling back to the defaults
+func LoadTLSOptions() (TLSOptions, error) {
+	var options TLSOptions
+	var err error
+
+	value := util.Getenv(config.TLSMinVersion, config.TLSMinVersionDefault)
+	if options.MinVersion, err = util.ParseTLSVersion(value); err != nil {
+		return TLSOptions{}, fmt.Errorf("invalid value for %s: %v", config.TLSMinVersion, err)
+	}
+	if options.MinVersion < tls.VersionTLS12 {
+		return TLSOptions{}, fmt.Errorf("invalid value for %s: TLS versions below 1.2 are insecure", config.TLSMinVersion)
+	}
+	if options.CipherSuites, err = util.ParseCipherSuites(os.Getenv(config.TLSCipherSuites)); err != nil {
+		return TLSOptions{}, fmt.Errorf("invalid value for %s: %v", config.TLSCipherSuites, err)
+	}
+
+	return options, nil
+}
+
+// Apply returns a copy of tlsConfig restricted to the TLS versions and cipher suites of the options
+func (o TLSOptions) Apply(tlsConfig *tls.Config) *tls.Config {
+	tlsConfig = tlsConfig.Clone()
+	tlsConfig.MinVersion = o.MinVersion
+	tlsConfig.CipherSuites = o.CipherSuites
+	return tlsConfig
+}
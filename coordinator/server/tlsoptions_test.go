@@ -0,0 +1,87 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package server
+
+import (
+	"crypto/tls"
+	"os"
+	"testing"
+
+	"github.com/edgelesssys/marblerun/coordinator/config"
+	"github.com/edgelesssys/marblerun/coordinator/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadTLSOptions(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	defer os.Unsetenv(config.TLSMinVersion)
+	defer os.Unsetenv(config.TLSCipherSuites)
+
+	options, err := LoadTLSOptions()
+	require.NoError(err)
+	assert.EqualValues(tls.VersionTLS13, options.MinVersion)
+	assert.Nil(options.CipherSuites)
+
+	require.NoError(os.Setenv(config.TLSMinVersion, "1.2"))
+	require.NoError(os.Setenv(config.TLSCipherSuites, "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"))
+	options, err = LoadTLSOptions()
+	require.NoError(err)
+	assert.EqualValues(tls.VersionTLS12, options.MinVersion)
+	assert.Equal([]uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384}, options.CipherSuites)
+
+	require.NoError(os.Setenv(config.TLSCipherSuites, "TLS_RSA_WITH_RC4_128_SHA"))
+	_, err = LoadTLSOptions()
+	assert.Error(err)
+	require.NoError(os.Unsetenv(config.TLSCipherSuites))
+
+	for _, value := range []string{"1.1", "1.4", "tls13"} {
+		require.NoError(os.Setenv(config.TLSMinVersion, value))
+		_, err = LoadTLSOptions()
+		assert.Error(err, value)
+	}
+}
+
+func TestTLSOptionsMinVersion(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tlsConfig, err := core.NewCoreWithMocks().GetTLSConfig()
+	require.NoError(err)
+	options := TLSOptions{MinVersion: tls.VersionTLS13}
+
+	listener, err := tls.Listen("tcp", "localhost:0", options.Apply(tlsConfig))
+	require.NoError(err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	// a TLS 1.1 client is rejected
+	_, err = tls.Dial("tcp", listener.Addr().String(), &tls.Config{InsecureSkipVerify: true, MinVersion: tls.VersionTLS11, MaxVersion: tls.VersionTLS11})
+	assert.Error(err)
+
+	// a TLS 1.2 client is rejected as well
+	_, err = tls.Dial("tcp", listener.Addr().String(), &tls.Config{InsecureSkipVerify: true, MaxVersion: tls.VersionTLS12})
+	assert.Error(err)
+
+	conn, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	require.NoError(err)
+	defer conn.Close()
+	assert.EqualValues(tls.VersionTLS13, conn.ConnectionState().Version)
+
+	// the original config is unchanged
+	assert.Zero(tlsConfig.MinVersion)
+}
@@ -0,0 +1,70 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestTracingUnaryServerInterceptor(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+
+	interceptor := tracingUnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/rpc.Marble/Activate"}
+
+	// the span is a child of the trace context sent in the metadata
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	const parentSpanID = "00f067aa0ba902b7"
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("traceparent", "00-"+traceID+"-"+parentSpanID+"-01"))
+	_, err := interceptor(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	})
+	require.NoError(err)
+
+	spans := exporter.GetSpans()
+	require.Len(spans, 1)
+	assert.Equal("/rpc.Marble/Activate", spans[0].Name)
+	assert.Equal(traceID, spans[0].SpanContext.TraceID().String())
+	assert.Equal(parentSpanID, spans[0].Parent.SpanID().String())
+	assert.True(spans[0].Parent.IsRemote())
+	assert.Equal(otelcodes.Unset, spans[0].StatusCode)
+
+	// without metadata a new trace is started and errors are recorded
+	exporter.Reset()
+	_, err = interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.Unauthenticated, "invalid quote")
+	})
+	assert.Error(err)
+
+	spans = exporter.GetSpans()
+	require.Len(spans, 1)
+	assert.False(spans[0].Parent.IsValid())
+	assert.Equal(otelcodes.Error, spans[0].StatusCode)
+	assert.Equal(codes.Unauthenticated.String(), spans[0].StatusMessage)
+}
+
+func TestInitTracingDisabled(t *testing.T) {
+	shutdown, err := InitTracing(context.Background(), "")
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+}
@@ -0,0 +1,62 @@
+package injector
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+)
+
+// LoadMarbleAnnotations reads the pod annotations of each marble type from a manifest in JSON or YAML format
+func LoadMarbleAnnotations(rawManifest []byte) (map[string]map[string]string, error) {
+	manifestJSON, err := yaml.YAMLToJSON(rawManifest)
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest: %v", err)
+	}
+
+	// only parse the parts of the manifest relevant for the injector
+	var manifest struct {
+		Marbles map[string]struct {
+			Kubernetes *struct {
+				Annotations map[string]string
+			}
+		}
+	}
+	if err := json.Unmarshal(manifestJSON, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %v", err)
+	}
+
+	marbleAnnotations := make(map[string]map[string]string)
+	for marbleType, marble := range manifest.Marbles {
+		if marble.Kubernetes == nil || len(marble.Kubernetes.Annotations) <= 0 {
+			continue
+		}
+		for key := range marble.Kubernetes.Annotations {
+			if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+				return nil, fmt.Errorf("marble %s declares invalid annotation %s: %s", marbleType, key, strings.Join(errs, ", "))
+			}
+		}
+		marbleAnnotations[marbleType] = marble.Kubernetes.Annotations
+	}
+	return marbleAnnotations, nil
+}
+
+// podAnnotations merges the annotations of the marble types of a pod. For keys declared by several marble types, the first marble type takes precedence.
+func podAnnotations(marbleAnnotations map[string]map[string]string, podMarbleTypes []string) map[string]string {
+	annotations := make(map[string]string)
+	for _, marbleType := range podMarbleTypes {
+		for key, value := range marbleAnnotations[marbleType] {
+			if _, ok := annotations[key]; !ok {
+				annotations[key] = value
+			}
+		}
+	}
+	return annotations
+}
+
+// createAnnotationPatch creates a json patch adding all annotations which are not set yet
+func createAnnotationPatch(setAnnotations map[string]string, newAnnotations map[string]string) []map[string]interface{} {
+	return createMetadataMapPatch("/metadata/annotations", setAnnotations, newAnnotations)
+}
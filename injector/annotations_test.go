@@ -0,0 +1,38 @@
+package injector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMarbleAnnotations(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// YAML manifest
+	marbleAnnotations, err := LoadMarbleAnnotations([]byte(`
+Marbles:
+  frontend:
+    Package: frontend
+  backend:
+    Package: backend
+    Kubernetes:
+      Annotations:
+        example.com/cost-center: "1234"
+        team: payments
+`))
+	require.NoError(err)
+	assert.Equal(map[string]map[string]string{
+		"backend": {"example.com/cost-center": "1234", "team": "payments"},
+	}, marbleAnnotations)
+
+	// invalid key
+	_, err = LoadMarbleAnnotations([]byte(`{"Marbles": {"backend": {"Kubernetes": {"Annotations": {"not a key": "value"}}}}}`))
+	assert.Error(err)
+
+	// non-string value
+	_, err = LoadMarbleAnnotations([]byte(`{"Marbles": {"backend": {"Kubernetes": {"Annotations": {"team": 1}}}}}`))
+	assert.Error(err)
+}
@@ -0,0 +1,67 @@
+package injector
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io/ioutil"
+	"log"
+	"sync"
+)
+
+// CertReloader serves the webhook's TLS certificate and picks up changes of the certificate files, e.g., a rotated secret, without a restart
+type CertReloader struct {
+	certFile string
+	keyFile  string
+
+	mux     sync.Mutex
+	certPEM []byte
+	keyPEM  []byte
+	cert    *tls.Certificate
+}
+
+// NewCertReloader loads the key pair from the given files
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+// The files are checked on each handshake. If they cannot be loaded, e.g., while only one of them was updated, the previous certificate is served.
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if err := r.reload(); err != nil {
+		log.Printf("Unable to reload TLS certificate, serving the previous one: %v", err)
+	}
+	return r.cert, nil
+}
+
+// reload parses the key pair if the files changed. The caller must hold the lock, unless it is called during construction.
+func (r *CertReloader) reload() error {
+	certPEM, err := ioutil.ReadFile(r.certFile)
+	if err != nil {
+		return err
+	}
+	keyPEM, err := ioutil.ReadFile(r.keyFile)
+	if err != nil {
+		return err
+	}
+	if r.cert != nil && bytes.Equal(certPEM, r.certPEM) && bytes.Equal(keyPEM, r.keyPEM) {
+		return nil
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return err
+	}
+	if r.cert != nil {
+		log.Println("Reloaded TLS certificate")
+	}
+	r.certPEM = certPEM
+	r.keyPEM = keyPEM
+	r.cert = &cert
+	return nil
+}
@@ -0,0 +1,75 @@
+package injector
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/edgelesssys/marblerun/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertReloader(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tempDir, err := ioutil.TempDir("", "")
+	require.NoError(err)
+	defer os.RemoveAll(tempDir)
+	certFile := filepath.Join(tempDir, "cert.pem")
+	keyFile := filepath.Join(tempDir, "key.pem")
+
+	writeKeyPair := func() *x509.Certificate {
+		cert, key, err := util.GenerateCert([]string{"localhost"}, util.DefaultCertificateIPAddresses, false)
+		require.NoError(err)
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		require.NoError(err)
+		require.NoError(ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0600))
+		require.NoError(ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+		return cert
+	}
+
+	// missing files
+	_, err = NewCertReloader(certFile, keyFile)
+	assert.Error(err)
+
+	firstCert := writeKeyPair()
+	reloader, err := NewCertReloader(certFile, keyFile)
+	require.NoError(err)
+
+	listener, err := tls.Listen("tcp", "localhost:0", &tls.Config{GetCertificate: reloader.GetCertificate})
+	require.NoError(err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	servedCert := func() []byte {
+		conn, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+		require.NoError(err)
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].Raw
+	}
+
+	assert.Equal(firstCert.Raw, servedCert())
+
+	// the rotated certificate is served on the next handshake
+	secondCert := writeKeyPair()
+	assert.Equal(secondCert.Raw, servedCert())
+
+	// the previous certificate is kept if the new files are invalid
+	require.NoError(ioutil.WriteFile(keyFile, []byte("invalid"), 0600))
+	assert.Equal(secondCert.Raw, servedCert())
+}
@@ -0,0 +1,113 @@
+package injector
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// DeviceResource is a resource exposed by a device plugin, e.g., a GPU, which is injected into the containers of marbles alongside the SGX resource
+type DeviceResource struct {
+	// ResourceKey is the name of the resource, e.g., "nvidia.com/gpu"
+	ResourceKey string
+	// Quantity is the amount of the resource set as limit of each container
+	Quantity resource.Quantity
+	// Toleration adds a toleration for nodes tainted with the resource key, like the one added for the SGX resource
+	Toleration bool
+}
+
+// ParseDeviceResources parses a comma-separated list of key=quantity pairs, e.g., "nvidia.com/gpu=1".
+// The suffix ":toleration" adds a toleration for the resource key, e.g., "nvidia.com/gpu=1:toleration".
+func ParseDeviceResources(value string) ([]DeviceResource, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var devices []DeviceResource
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(value, ",") {
+		keyValue := strings.SplitN(entry, "=", 2)
+		if len(keyValue) != 2 || keyValue[0] == "" {
+			return nil, fmt.Errorf("invalid device resource %s: expected key=quantity", entry)
+		}
+		if seen[keyValue[0]] {
+			return nil, fmt.Errorf("invalid device resource %s: duplicate resource key", entry)
+		}
+		seen[keyValue[0]] = true
+
+		device := DeviceResource{ResourceKey: keyValue[0]}
+		quantity := keyValue[1]
+		if strings.HasSuffix(quantity, ":toleration") {
+			quantity = strings.TrimSuffix(quantity, ":toleration")
+			device.Toleration = true
+		}
+		parsed, err := resource.ParseQuantity(quantity)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quantity of device resource %s: %v", device.ResourceKey, err)
+		}
+		if parsed.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid quantity of device resource %s: must be positive", device.ResourceKey)
+		}
+		device.Quantity = parsed
+		devices = append(devices, device)
+	}
+	return devices, nil
+}
+
+// addDeviceLimits adds the limits of the device resources the container does not set itself
+func addDeviceLimits(limits corev1.ResourceList, containerResources corev1.ResourceRequirements, devices []DeviceResource) {
+	for _, device := range devices {
+		name := corev1.ResourceName(device.ResourceKey)
+		if _, ok := containerResources.Limits[name]; ok {
+			continue
+		}
+		limits[name] = device.Quantity
+	}
+}
+
+// createTolerationPatch creates a json patch adding tolerations to a pod, each key is only added once
+func createTolerationPatch(tolerations []corev1.Toleration, newTolerations []corev1.Toleration) []map[string]interface{} {
+	var added []corev1.Toleration
+	for _, toleration := range newTolerations {
+		if !toleratesKey(added, toleration.Key) {
+			added = append(added, toleration)
+		}
+	}
+	if len(added) <= 0 {
+		return nil
+	}
+
+	// create array if these are the first tolerations of the pod
+	if len(tolerations) <= 0 {
+		return []map[string]interface{}{
+			{
+				"op":    "add",
+				"path":  "/spec/tolerations",
+				"value": added,
+			},
+		}
+	}
+
+	// append as last elements of the tolerations array otherwise
+	patch := make([]map[string]interface{}, 0, len(added))
+	for _, toleration := range added {
+		patch = append(patch, map[string]interface{}{
+			"op":    "add",
+			"path":  "/spec/tolerations/-",
+			"value": toleration,
+		})
+	}
+	return patch
+}
+
+// toleratesKey checks if the tolerations contain one with the given key
+func toleratesKey(tolerations []corev1.Toleration, key string) bool {
+	for _, toleration := range tolerations {
+		if toleration.Key == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,33 @@
+package injector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestParseDeviceResources(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	devices, err := ParseDeviceResources("")
+	require.NoError(err)
+	assert.Empty(devices)
+
+	devices, err = ParseDeviceResources("nvidia.com/gpu=1:toleration,example.com/fpga=500m")
+	require.NoError(err)
+	require.Len(devices, 2)
+	assert.Equal("nvidia.com/gpu", devices[0].ResourceKey)
+	assert.True(resource.MustParse("1").Equal(devices[0].Quantity))
+	assert.True(devices[0].Toleration)
+	assert.Equal("example.com/fpga", devices[1].ResourceKey)
+	assert.True(resource.MustParse("500m").Equal(devices[1].Quantity))
+	assert.False(devices[1].Toleration)
+
+	for _, invalid := range []string{"nvidia.com/gpu", "=1", "nvidia.com/gpu=one", "nvidia.com/gpu=0", "nvidia.com/gpu=1,nvidia.com/gpu=2"} {
+		_, err = ParseDeviceResources(invalid)
+		assert.Error(err, invalid)
+	}
+}
@@ -0,0 +1,56 @@
+package injector
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ParseDNSConfig creates a DNS config from a comma-separated list of resolver options, e.g., "ndots:2,timeout:1",
+// and a comma-separated list of search domains, e.g., "marblerun.svc.cluster.local".
+// It returns nil if both lists are empty.
+func ParseDNSConfig(options string, searches string) (*corev1.PodDNSConfig, error) {
+	if options == "" && searches == "" {
+		return nil, nil
+	}
+
+	dnsConfig := &corev1.PodDNSConfig{}
+	if options != "" {
+		for _, option := range strings.Split(options, ",") {
+			nameValue := strings.SplitN(option, ":", 2)
+			if nameValue[0] == "" {
+				return nil, fmt.Errorf("invalid DNS option %q: empty name", option)
+			}
+			dnsOption := corev1.PodDNSConfigOption{Name: nameValue[0]}
+			if len(nameValue) == 2 {
+				value := nameValue[1]
+				dnsOption.Value = &value
+			}
+			dnsConfig.Options = append(dnsConfig.Options, dnsOption)
+		}
+	}
+	if searches != "" {
+		for _, search := range strings.Split(searches, ",") {
+			if search == "" {
+				return nil, fmt.Errorf("invalid DNS search domains %q: empty domain", searches)
+			}
+			dnsConfig.Searches = append(dnsConfig.Searches, search)
+		}
+	}
+	return dnsConfig, nil
+}
+
+// createDNSConfigPatch sets the DNS config of a pod. Nothing is added if the pod already has a DNS config.
+func createDNSConfigPatch(podDNSConfig *corev1.PodDNSConfig, dnsConfig corev1.PodDNSConfig) []map[string]interface{} {
+	if podDNSConfig != nil {
+		return nil
+	}
+	return []map[string]interface{}{
+		{
+			"op":    "add",
+			"path":  "/spec/dnsConfig",
+			"value": dnsConfig,
+		},
+	}
+}
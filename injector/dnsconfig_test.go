@@ -0,0 +1,37 @@
+package injector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDNSConfig(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dnsConfig, err := ParseDNSConfig("", "")
+	require.NoError(err)
+	assert.Nil(dnsConfig)
+
+	dnsConfig, err = ParseDNSConfig("ndots:2,single-request", "")
+	require.NoError(err)
+	require.Len(dnsConfig.Options, 2)
+	assert.Equal("ndots", dnsConfig.Options[0].Name)
+	require.NotNil(dnsConfig.Options[0].Value)
+	assert.Equal("2", *dnsConfig.Options[0].Value)
+	assert.Equal("single-request", dnsConfig.Options[1].Name)
+	assert.Nil(dnsConfig.Options[1].Value)
+	assert.Empty(dnsConfig.Searches)
+
+	dnsConfig, err = ParseDNSConfig("", "marblerun.svc.cluster.local,svc.cluster.local")
+	require.NoError(err)
+	assert.Empty(dnsConfig.Options)
+	assert.Equal([]string{"marblerun.svc.cluster.local", "svc.cluster.local"}, dnsConfig.Searches)
+
+	_, err = ParseDNSConfig(":2", "")
+	assert.Error(err)
+	_, err = ParseDNSConfig("", "marblerun.svc.cluster.local,")
+	assert.Error(err)
+}
@@ -0,0 +1,36 @@
+package injector
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// Health reports the state of the webhook server to Kubernetes probes
+type Health struct {
+	ready int32
+}
+
+// SetReady marks the webhook server as ready, i.e., the TLS certificate is loaded and the server is accepting connections
+func (h *Health) SetReady(ready bool) {
+	var value int32
+	if ready {
+		value = 1
+	}
+	atomic.StoreInt32(&h.ready, value)
+}
+
+// HandleHealthz handles liveness probes. The server is alive as long as it is able to answer.
+func (h *Health) HandleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
+
+// HandleReadyz handles readiness probes
+func (h *Health) HandleReadyz(w http.ResponseWriter, r *http.Request) {
+	if atomic.LoadInt32(&h.ready) == 0 {
+		http.Error(w, "not ready", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
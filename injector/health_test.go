@@ -0,0 +1,35 @@
+package injector
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealth(t *testing.T) {
+	assert := assert.New(t)
+
+	var health Health
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", health.HandleHealthz)
+	mux.HandleFunc("/readyz", health.HandleReadyz)
+
+	get := func(path string) int {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+
+	// alive, but not ready before the server is started
+	assert.Equal(http.StatusOK, get("/healthz"))
+	assert.Equal(http.StatusServiceUnavailable, get("/readyz"))
+
+	health.SetReady(true)
+	assert.Equal(http.StatusOK, get("/healthz"))
+	assert.Equal(http.StatusOK, get("/readyz"))
+
+	health.SetReady(false)
+	assert.Equal(http.StatusServiceUnavailable, get("/readyz"))
+}
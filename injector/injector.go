@@ -7,19 +7,134 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/google/uuid"
 	v1 "k8s.io/api/admission/v1"
+	"k8s.io/api/admission/v1beta1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
+// shmSizeAnnotation sets the size of a memory backed volume which is mounted to /dev/shm of each container
+const shmSizeAnnotation = "marblerun/shm-size"
+
+// modeAnnotation set to "simulation" disables injection of sgx resources and tolerations for a pod
+const modeAnnotation = "marblerun/mode"
+
+// sgxLimitAnnotation overrides the amount of the sgx resource injected into the containers of a pod, e.g., "4". It must be a positive integer.
+const sgxLimitAnnotation = "marblerun/sgx-limit"
+
+// containerMarbleTypesAnnotation assigns marble types to individual containers of a pod running multiple marbles, e.g., "web=frontend,api=backend".
+// Containers not listed are of the marble type set in the marblerun/marbletype label.
+const containerMarbleTypesAnnotation = "marblerun/container-marbletypes"
+
+// marbleUUIDAnnotationPrefix is the prefix of the annotations holding the UUIDs of the marble types of a pod running marbles of different types, e.g., "marblerun/uuid-1".
+// The suffix is the index of the marble type, starting with 0 for the marble type of the pod.
+const marbleUUIDAnnotationPrefix = "marblerun/uuid-"
+
+// skipEnvContainersAnnotation is a comma-separated list of containers which are not marbles, e.g., a logging sidecar.
+// They are skipped by the injection of environment variables, volume mounts, resources, lifecycle hooks, and probes.
+const skipEnvContainersAnnotation = "marblerun/skip-env-containers"
+
+// supportedAdmissionVersions are the AdmissionReview API versions the webhook can handle.
+// Both versions share the same JSON layout, so requests are decoded into the v1 types and the response is sent in the version of the request.
+var supportedAdmissionVersions = map[string]bool{
+	v1.SchemeGroupVersion.String():      true,
+	v1beta1.SchemeGroupVersion.String(): true,
+}
+
+// defaultSGXQuantity is the amount of the sgx resource injected if Mutator.SGXQuantity is not set
+var defaultSGXQuantity = resource.MustParse("10")
+
+// coordinatorCAKey is the key of the coordinator's root certificate in the ConfigMap set in Mutator.CoordinatorCAConfigMap
+const coordinatorCAKey = "ca.crt"
+
 // Mutator struct
 type Mutator struct {
 	// CoordAddr contains the address of the marblerun coordinator
 	CoordAddr   string
 	DomainName  string
 	SGXResource string
+	// SGXQuantity is the amount of SGXResource set as limit (and request) of each container, e.g., "10", "500m", or "64Mi" for divisible EPC resources.
+	// Defaults to 10 if zero.
+	SGXQuantity resource.Quantity
+	// SafePatches prepends a test operation to each add operation of a patch, so the patch is rejected if another webhook modified the pod in the meantime
+	SafePatches bool
+	// Labels are added to each injected pod, e.g., to select marbles in network policies. Existing labels are not overwritten.
+	Labels map[string]string
+	// MarbleTypeLabel is the key of an additional label holding the marble type of an injected pod. No label is added if empty.
+	MarbleTypeLabel string
+	// SetSGXRequests sets the SGX resource requests in addition to the limits, so the scheduler accounts for the resources.
+	// Kubernetes does not allow overcommitting extended resources, so the requests are always equal to the limits.
+	SetSGXRequests bool
+	// ExtraVolumes holds the volumes declared in the manifest for each marble type
+	ExtraVolumes map[string]ExtraVolumes
+	// CoordinatorCAConfigMap is the name of a ConfigMap holding the coordinator's root certificate under the key "ca.crt".
+	// If set, the ConfigMap is mounted to CoordinatorCAMountPath in each container and EDG_MARBLE_COORDINATOR_CA_FILE points to the certificate.
+	CoordinatorCAConfigMap string
+	CoordinatorCAMountPath string
+	// PreStop is added as preStop lifecycle hook to each container without one, e.g., to notify the coordinator of terminating marbles. Nothing is added if nil.
+	PreStop *corev1.Handler
+	// MarbleResources holds the resource limits and requests declared in the manifest for each marble type.
+	// They are added to each container of a marble which does not set the respective resource itself.
+	MarbleResources map[string]corev1.ResourceRequirements
+	// RuntimeDirPath is the path an emptyDir volume is mounted to in each container, providing a writable directory for the runtime files of the enclave, e.g., "/graphene-tmp".
+	// No volume is added if empty. The size of the volume is limited to RuntimeDirSizeLimit, unless it is zero.
+	RuntimeDirPath      string
+	RuntimeDirSizeLimit resource.Quantity
+	// KnownMarbleTypes restricts injection to the marble types it contains, e.g., the marble types declared in the manifest.
+	// Pods of other marble types are admitted without injection and with a warning, or denied if DenyUnknownMarbleTypes is set.
+	// All marble types are injected if nil.
+	KnownMarbleTypes       map[string]bool
+	DenyUnknownMarbleTypes bool
+	// StartupProbe is added to each container without one, so liveness probes do not kill enclaves before they finished booting. Nothing is added if nil.
+	StartupProbe *corev1.Probe
+	// TopologySpread is the template of a topology spread constraint added to each pod, spreading the pods of a marble type across topology domains, e.g., zones.
+	// Its label selector is set to the marble type of the pod. Nothing is added if nil, or if the pod already spreads across the same topology key.
+	TopologySpread *corev1.TopologySpreadConstraint
+	// Sidecar is appended to the containers of each pod without a container of the same name, e.g., to ship the logs of the enclave.
+	// It is added as specified, without SGX resources or the environment of a marble. Nothing is added if nil.
+	Sidecar *corev1.Container
+	// MaxEnvSize is the maximum size in bytes of the environment injected into a container, counting each variable as NAME=value with a terminating null byte.
+	// Pods exceeding it are admitted with a warning, or denied if DenyOversizedEnv is set. The size is not checked if zero.
+	MaxEnvSize       int
+	DenyOversizedEnv bool
+	// DeviceResources are injected as limits into each container alongside the SGX resource, e.g., GPUs for confidential AI workloads.
+	// Limits set by a container itself are not overwritten. Like the SGX resource, they are not injected into pods in simulation mode.
+	DeviceResources []DeviceResource
+	// ImagePullSecret is the name of a secret added to the image pull secrets of each pod, e.g., for enclave images in a private registry.
+	// Nothing is added if empty, or if the pod already references the secret.
+	ImagePullSecret string
+	// ServiceAccountToken is mounted as projected volume into each container, providing a service account token with a custom audience, e.g., for workload identity.
+	// Containers already mounting a volume to its mount path are skipped. Nothing is added if nil.
+	ServiceAccountToken *ServiceAccountToken
+	// FSGroup is set as fsGroup in the security context of each pod, so the injected volumes are accessible by non-root users of the group.
+	// Nothing is added if nil, or if the pod already sets a fsGroup.
+	FSGroup *int64
+	// DNSConfig is set as DNS config of each pod, e.g., to lower ndots, so marbles resolving the coordinator by a short name don't query the search domains first.
+	// Nothing is added if nil, or if the pod already has a DNS config.
+	DNSConfig *corev1.PodDNSConfig
+	// UUIDEnv sets EDG_MARBLE_UUID in each container to the UID of the pod using the downward API, for runtimes reading the UUID from the environment.
+	// It is not set for pods running marbles of different types, as they must not share a UUID.
+	// SkipUUIDFile omits the volume providing the UUID file if the UUID is set in the environment.
+	UUIDEnv      bool
+	SkipUUIDFile bool
+	// MarbleAnnotations holds the pod annotations declared in the manifest for each marble type, e.g., cost centers.
+	// Annotations already set on a pod are not overwritten.
+	MarbleAnnotations map[string]map[string]string
+	// RestrictedSecurityContext sets the fields of the security contexts required by the restricted Pod Security Standard, unless the pod sets them itself:
+	// runAsNonRoot and the RuntimeDefault seccomp profile for each pod, and disallowed privilege escalation and dropped capabilities for each injected container.
+	// Access to the SGX devices is still granted through the resources of the device plugin.
+	RestrictedSecurityContext bool
 }
 
 // HandleMutate handles mutate requests and injects sgx tolerations into the request
@@ -32,7 +147,7 @@ func (m *Mutator) HandleMutate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// mutate the request and add sgx tolerations to pod
-	mutatedBody, err := mutate(body, m.CoordAddr, m.DomainName, m.SGXResource, true)
+	mutatedBody, err := m.mutate(body, true)
 	if err != nil {
 		http.Error(w, "unable to mutate request", http.StatusInternalServerError)
 		return
@@ -52,7 +167,7 @@ func (m *Mutator) HandleMutateNoSgx(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// mutate the request and add sgx tolerations to pod
-	mutatedBody, err := mutate(body, m.CoordAddr, m.DomainName, m.SGXResource, false)
+	mutatedBody, err := m.mutate(body, false)
 	if err != nil {
 		http.Error(w, "unable to mutate request", http.StatusInternalServerError)
 		return
@@ -63,13 +178,18 @@ func (m *Mutator) HandleMutateNoSgx(w http.ResponseWriter, r *http.Request) {
 }
 
 // mutate handles the creation of json patches for pods
-func mutate(body []byte, coordAddr string, domainName string, resourceKey string, injectSgx bool) ([]byte, error) {
+func (m *Mutator) mutate(body []byte, injectSgx bool) ([]byte, error) {
 	admReviewReq := v1.AdmissionReview{}
 	if err := json.Unmarshal(body, &admReviewReq); err != nil {
 		log.Println("Unable to mutate request: invalid admission review")
 		return nil, errors.New("invalid admission review")
 	}
 
+	if !supportedAdmissionVersions[admReviewReq.APIVersion] {
+		log.Printf("Unable to mutate request: unsupported admission review version %s", admReviewReq.APIVersion)
+		return nil, fmt.Errorf("unsupported admission review version %s", admReviewReq.APIVersion)
+	}
+
 	if admReviewReq.Request == nil {
 		log.Println("Unable to mutate request: empty admission review request")
 		return nil, errors.New("empty admission request")
@@ -80,12 +200,19 @@ func mutate(body []byte, coordAddr string, domainName string, resourceKey string
 		log.Println("Unable to mutate request: invalid pod")
 		return nil, errors.New("invalid pod")
 	}
+	podName := getPodName(pod)
+
+	sgxQuantity := m.SGXQuantity
+	if sgxQuantity.IsZero() {
+		sgxQuantity = defaultSGXQuantity
+	}
+	sgxQuantity = sgxQuantityFromAnnotation(pod.Annotations, sgxQuantity, podName)
 
 	// admission response
 	admReviewResponse := v1.AdmissionReview{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "AdmissionReview",
-			APIVersion: "admission.k8s.io/v1",
+			APIVersion: admReviewReq.APIVersion,
 		},
 		Response: &v1.AdmissionResponse{
 			UID: admReviewReq.Request.UID,
@@ -106,92 +233,367 @@ func mutate(body []byte, coordAddr string, domainName string, resourceKey string
 			log.Println("Error: unable to marshal admission response")
 			return nil, errors.New("unable to marshal admission response")
 		}
-		log.Println("Pod is missing [marblerun/marbletype] label, skipping injection")
+		log.Printf("Pod [%s] is missing [marblerun/marbletype] label, skipping injection", podName)
+		return bytes, nil
+	}
+
+	// marble types of containers differing from the marble type of the pod
+	containerMarbleTypes := map[string]string{}
+	if annotation, ok := pod.Annotations[containerMarbleTypesAnnotation]; ok {
+		var err error
+		if containerMarbleTypes, err = parseContainerMarbleTypes(annotation, pod.Spec.Containers); err != nil {
+			log.Printf("Unable to mutate request: invalid value for [%s] annotation: %v", containerMarbleTypesAnnotation, err)
+			return nil, fmt.Errorf("invalid value for %s annotation: %v", containerMarbleTypesAnnotation, err)
+		}
+	}
+	podMarbleTypes := []string{marbleType}
+	for _, container := range pod.Spec.Containers {
+		if containerType, ok := containerMarbleTypes[container.Name]; ok && !containsString(podMarbleTypes, containerType) {
+			podMarbleTypes = append(podMarbleTypes, containerType)
+		}
+	}
+
+	// pods of marble types unknown to the coordinator must not be equipped to reach it
+	if unknownType := unknownMarbleType(m.KnownMarbleTypes, podMarbleTypes); unknownType != "" {
+		message := fmt.Sprintf("Unknown marble type [%s], injection skipped", unknownType)
+		admReviewResponse.Response.Allowed = !m.DenyUnknownMarbleTypes
+		if m.DenyUnknownMarbleTypes {
+			message = fmt.Sprintf("Unknown marble type [%s], pod denied", unknownType)
+			admReviewResponse.Response.Result = &metav1.Status{
+				Status:  "Failure",
+				Message: message,
+				Reason:  metav1.StatusReasonForbidden,
+				Code:    http.StatusForbidden,
+			}
+		} else {
+			admReviewResponse.Response.Result = &metav1.Status{
+				Status:  "Success",
+				Message: message,
+			}
+			admReviewResponse.Response.Warnings = []string{message}
+		}
+		bytes, err := json.Marshal(admReviewResponse)
+		if err != nil {
+			log.Println("Error: unable to marshal admission response")
+			return nil, errors.New("unable to marshal admission response")
+		}
+		log.Printf("Pod [%s]: %s", podName, message)
 		return bytes, nil
 	}
 
 	pT := v1.PatchTypeJSONPatch
 	admReviewResponse.Response.PatchType = &pT
 
-	// get namespace of pod
-	namespace := pod.Namespace
-	if len(namespace) == 0 {
-		namespace = "default"
+	// pods running in simulation mode may be scheduled on nodes without sgx, so we must not request sgx resources for them
+	if injectSgx && pod.Annotations[modeAnnotation] == "simulation" {
+		log.Printf("Pod [%s] is annotated with [%s: simulation], skipping sgx injection", podName, modeAnnotation)
+		injectSgx = false
 	}
 
-	newEnvVars := []corev1.EnvVar{
-		{
-			Name:  "EDG_MARBLE_COORDINATOR_ADDR",
-			Value: coordAddr,
-		},
-		{
-			Name:  "EDG_MARBLE_TYPE",
-			Value: marbleType,
-		},
-		{
-			Name:  "EDG_MARBLE_DNS_NAMES",
-			Value: fmt.Sprintf("%s,%s.%s,%s.%s.svc.%s", marbleType, marbleType, namespace, marbleType, namespace, domainName),
-		},
+	// environment variables shared by the marbles of the pod, the marble type and DNS names are set for each container
+	var newEnvVars []corev1.EnvVar
+
+	// check if a shared memory volume was requested
+	var shmVolume *corev1.Volume
+	if shmSize, ok := pod.Annotations[shmSizeAnnotation]; ok {
+		sizeLimit, err := resource.ParseQuantity(shmSize)
+		if err != nil {
+			log.Printf("Unable to mutate request: invalid value for [%s] annotation: %s", shmSizeAnnotation, shmSize)
+			return nil, fmt.Errorf("invalid value for %s annotation: %v", shmSizeAnnotation, err)
+		}
+		shmVolume = &corev1.Volume{
+			Name: volumeName("shm", admReviewReq.Request.UID),
+			VolumeSource: corev1.VolumeSource{
+				EmptyDir: &corev1.EmptyDirVolumeSource{
+					Medium:    corev1.StorageMediumMemory,
+					SizeLimit: &sizeLimit,
+				},
+			},
+		}
+	}
+
+	// the coordinator's root certificate is mounted from a ConfigMap and its path passed to the marble
+	var coordinatorCAVolume *corev1.Volume
+	if m.CoordinatorCAConfigMap != "" {
+		coordinatorCAVolume = createCoordinatorCAVolume(admReviewReq.Request.UID, m.CoordinatorCAConfigMap)
+		newEnvVars = append(newEnvVars, corev1.EnvVar{
+			Name:  "EDG_MARBLE_COORDINATOR_CA_FILE",
+			Value: path.Join(m.CoordinatorCAMountPath, coordinatorCAKey),
+		})
+	}
+
+	// a writable directory for the runtime files of the enclave
+	var runtimeDirVolume *corev1.Volume
+	if m.RuntimeDirPath != "" {
+		runtimeDirVolume = createRuntimeDirVolume(admReviewReq.Request.UID, m.RuntimeDirSizeLimit)
+	}
+
+	// a service account token with a custom audience, e.g., for workload identity
+	var serviceAccountTokenVolume *corev1.Volume
+	if m.ServiceAccountToken != nil {
+		serviceAccountTokenVolume = createServiceAccountTokenVolume(admReviewReq.Request.UID, *m.ServiceAccountToken)
 	}
 
 	var patch []map[string]interface{}
 	var needNewVolume bool
+	var oversizedEnvs []string
+
+	skipContainers := make(map[string]bool)
+	if annotation, ok := pod.Annotations[skipEnvContainersAnnotation]; ok {
+		for _, name := range strings.Split(annotation, ",") {
+			skipContainers[strings.TrimSpace(name)] = true
+		}
+	}
 
 	// create env variable patches for each container of the pod
 	for idx, container := range pod.Spec.Containers {
-		if !envIsSet(container.Env, corev1.EnvVar{Name: "EDG_MARBLE_UUID_FILE"}) {
+		if skipContainers[container.Name] {
+			log.Printf("Pod [%s]: skipping injection for container [%s]", podName, container.Name)
+			continue
+		}
+		containerType := marbleType
+		if annotatedType, ok := containerMarbleTypes[container.Name]; ok {
+			containerType = annotatedType
+		}
+		// volumes declared in the manifest for the marble type of the container
+		marbleVolumes := m.ExtraVolumes[containerType]
+
+		containerEnvVars := append([]corev1.EnvVar{
+			{
+				Name:  "EDG_MARBLE_COORDINATOR_ADDR",
+				Value: m.CoordAddr,
+			},
+			{
+				Name:  "EDG_MARBLE_TYPE",
+				Value: containerType,
+			},
+			{
+				Name:  "EDG_MARBLE_DNS_NAMES",
+				Value: strings.Join(MarbleDNSNames(containerType, pod.Namespace, m.DomainName), ","),
+			},
+		}, newEnvVars...)
+
+		// marbles of different types in the same pod must not share the UID of the pod as UUID
+		injectUUIDEnv := m.UUIDEnv && len(podMarbleTypes) <= 1
+		if injectUUIDEnv {
+			containerEnvVars = append(containerEnvVars, createUUIDEnvVar())
+		}
+
+		mounts := len(container.VolumeMounts)
+		if !(injectUUIDEnv && m.SkipUUIDFile) && !envIsSet(container.Env, corev1.EnvVar{Name: "EDG_MARBLE_UUID_FILE"}) {
 			needNewVolume = true
 
-			newEnvVars = append(newEnvVars, corev1.EnvVar{
+			containerEnvVars = append(containerEnvVars, corev1.EnvVar{
 				Name:  "EDG_MARBLE_UUID_FILE",
-				Value: fmt.Sprintf("/%s-uid/uuid-file", marbleType),
+				Value: fmt.Sprintf("/%s-uid/uuid-file", containerType),
 			})
 
 			// If we need to set the uuid env variable we also need to create a volume mount, which the variable points to
+			uuidMount := corev1.VolumeMount{
+				Name:      volumeName("uuid-file", admReviewReq.Request.UID),
+				MountPath: fmt.Sprintf("/%s-uid", containerType),
+			}
+			// marbles of different types in the same pod must not share their UUID file
+			if len(podMarbleTypes) > 1 {
+				uuidMount.SubPath = containerType
+			}
+			patch = append(patch, createMountPatch(
+				mounts,
+				fmt.Sprintf("/spec/containers/%d/volumeMounts", idx),
+				uuidMount,
+			))
+			mounts++
+		}
+		if shmVolume != nil && !mountPathIsSet(container.VolumeMounts, "/dev/shm") {
+			patch = append(patch, createMountPatch(
+				mounts,
+				fmt.Sprintf("/spec/containers/%d/volumeMounts", idx),
+				corev1.VolumeMount{
+					Name:      shmVolume.Name,
+					MountPath: "/dev/shm",
+				},
+			))
+			mounts++
+		}
+		if coordinatorCAVolume != nil && !envIsSet(container.Env, corev1.EnvVar{Name: "EDG_MARBLE_COORDINATOR_CA_FILE"}) && !mountPathIsSet(container.VolumeMounts, m.CoordinatorCAMountPath) {
+			patch = append(patch, createMountPatch(
+				mounts,
+				fmt.Sprintf("/spec/containers/%d/volumeMounts", idx),
+				corev1.VolumeMount{
+					Name:      coordinatorCAVolume.Name,
+					MountPath: m.CoordinatorCAMountPath,
+					ReadOnly:  true,
+				},
+			))
+			mounts++
+		}
+		if runtimeDirVolume != nil && !mountPathIsSet(container.VolumeMounts, m.RuntimeDirPath) {
+			patch = append(patch, createMountPatch(
+				mounts,
+				fmt.Sprintf("/spec/containers/%d/volumeMounts", idx),
+				corev1.VolumeMount{
+					Name:      runtimeDirVolume.Name,
+					MountPath: m.RuntimeDirPath,
+				},
+			))
+			mounts++
+		}
+		if serviceAccountTokenVolume != nil && !mountPathIsSet(container.VolumeMounts, m.ServiceAccountToken.MountPath) {
 			patch = append(patch, createMountPatch(
-				len(container.VolumeMounts),
+				mounts,
 				fmt.Sprintf("/spec/containers/%d/volumeMounts", idx),
-				fmt.Sprintf("/%s-uid", marbleType),
-				string(admReviewReq.Request.UID),
+				corev1.VolumeMount{
+					Name:      serviceAccountTokenVolume.Name,
+					MountPath: m.ServiceAccountToken.MountPath,
+					ReadOnly:  true,
+				},
 			))
+			mounts++
+		}
+		for _, mount := range marbleVolumes.VolumeMounts {
+			if mountPathIsSet(container.VolumeMounts, mount.MountPath) {
+				continue
+			}
+			patch = append(patch, createMountPatch(mounts, fmt.Sprintf("/spec/containers/%d/volumeMounts", idx), mount))
+			mounts++
+		}
+		patch = append(patch, addEnvVar(container.Env, containerEnvVars, fmt.Sprintf("/spec/containers/%d/env", idx))...)
+		if size := injectedEnvSize(container.Env, containerEnvVars); m.MaxEnvSize > 0 && size > m.MaxEnvSize {
+			oversizedEnvs = append(oversizedEnvs, fmt.Sprintf("Environment injected into container [%s] has %d bytes, exceeding the limit of %d bytes", container.Name, size, m.MaxEnvSize))
 		}
-		patch = append(patch, addEnvVar(container.Env, newEnvVars, fmt.Sprintf("/spec/containers/%d/env", idx))...)
 
+		// resources declared in the manifest are only applied if the container does not set them
+		limits, requests := defaultResources(container.Resources, m.MarbleResources[containerType])
 		if injectSgx {
-			patch = append(patch, createResourcePatch(container, idx, resourceKey))
+			limits[corev1.ResourceName(m.SGXResource)] = sgxQuantity
+			if m.SetSGXRequests {
+				requests[corev1.ResourceName(m.SGXResource)] = sgxQuantity
+			}
+			addDeviceLimits(limits, container.Resources, m.DeviceResources)
+		}
+		patch = append(patch, createResourcePatch(container, idx, limits, requests)...)
+		if m.PreStop != nil {
+			patch = append(patch, createLifecyclePatch(container, idx, *m.PreStop)...)
+		}
+		if m.StartupProbe != nil {
+			patch = append(patch, createStartupProbePatch(container, idx, *m.StartupProbe)...)
+		}
+		if m.RestrictedSecurityContext {
+			patch = append(patch, createRestrictedContainerSecurityContextPatch(container, idx)...)
+		}
+	}
+
+	// the sidecar is added after the containers of the marble were patched, so it does not receive their environment and resources
+	if m.Sidecar != nil {
+		patch = append(patch, createSidecarPatch(pod.Spec.Containers, *m.Sidecar)...)
+	}
+
+	// an oversized environment lets the container fail to start, e.g., because of a misconfigured cluster domain
+	if len(oversizedEnvs) > 0 {
+		for _, message := range oversizedEnvs {
+			log.Printf("Pod [%s]: %s", podName, message)
+		}
+		if m.DenyOversizedEnv {
+			admReviewResponse.Response.PatchType = nil
+			admReviewResponse.Response.Result = &metav1.Status{
+				Status:  "Failure",
+				Message: strings.Join(oversizedEnvs, "; "),
+				Reason:  metav1.StatusReasonInvalid,
+				Code:    http.StatusUnprocessableEntity,
+			}
+			bytes, err := json.Marshal(admReviewResponse)
+			if err != nil {
+				log.Println("Error: unable to marshal admission response")
+				return nil, errors.New("unable to marshal admission response")
+			}
+			return bytes, nil
 		}
+		admReviewResponse.Response.Warnings = oversizedEnvs
 	}
 
+	podLabels := make(map[string]string, len(m.Labels)+1)
+	for key, value := range m.Labels {
+		podLabels[key] = value
+	}
+	if m.MarbleTypeLabel != "" {
+		podLabels[m.MarbleTypeLabel] = marbleType
+	}
+	patch = append(patch, createLabelPatch(pod.Labels, podLabels)...)
+	annotations := podAnnotations(m.MarbleAnnotations, podMarbleTypes)
+	// marbles of different types in the same pod get their UUID files from annotations, as they must not share the UID of the pod
+	if needNewVolume && len(podMarbleTypes) > 1 {
+		for idx, podMarbleType := range podMarbleTypes {
+			annotations[marbleUUIDAnnotation(idx)] = deriveMarbleUUID(admReviewReq.Request.UID, podMarbleType)
+		}
+	}
+	patch = append(patch, createAnnotationPatch(pod.Annotations, annotations)...)
+
+	volumes := len(pod.Spec.Volumes)
 	if needNewVolume {
-		patch = append(patch, createVolumePatch(len(pod.Spec.Volumes), string(admReviewReq.Request.UID)))
+		patch = append(patch, createVolumePatch(volumes, createUUIDVolume(admReviewReq.Request.UID, podMarbleTypes)))
+		volumes++
+	}
+	if shmVolume != nil {
+		patch = append(patch, createVolumePatch(volumes, *shmVolume))
+		volumes++
+	}
+	if coordinatorCAVolume != nil {
+		patch = append(patch, createVolumePatch(volumes, *coordinatorCAVolume))
+		volumes++
+	}
+	if runtimeDirVolume != nil {
+		patch = append(patch, createVolumePatch(volumes, *runtimeDirVolume))
+		volumes++
+	}
+	if serviceAccountTokenVolume != nil {
+		patch = append(patch, createVolumePatch(volumes, *serviceAccountTokenVolume))
+		volumes++
+	}
+	addedVolumes := make(map[string]bool)
+	for _, podMarbleType := range podMarbleTypes {
+		for _, volume := range m.ExtraVolumes[podMarbleType].Volumes {
+			if volumeIsSet(pod.Spec.Volumes, volume.Name) || addedVolumes[volume.Name] {
+				continue
+			}
+			addedVolumes[volume.Name] = true
+			patch = append(patch, createVolumePatch(volumes, volume))
+			volumes++
+		}
+	}
+
+	if m.TopologySpread != nil {
+		patch = append(patch, createTopologySpreadPatch(pod.Spec.TopologySpreadConstraints, *m.TopologySpread, marbleType)...)
+	}
+
+	if m.ImagePullSecret != "" {
+		patch = append(patch, createImagePullSecretPatch(pod.Spec.ImagePullSecrets, m.ImagePullSecret)...)
 	}
 
-	// add sgx tolerations if enabled
+	if m.FSGroup != nil || m.RestrictedSecurityContext {
+		patch = append(patch, createPodSecurityContextPatch(pod.Spec.SecurityContext, m.FSGroup, m.RestrictedSecurityContext)...)
+	}
+
+	if m.DNSConfig != nil {
+		patch = append(patch, createDNSConfigPatch(pod.Spec.DNSConfig, *m.DNSConfig)...)
+	}
+
+	// add sgx tolerations and those of device resources if enabled
 	if injectSgx {
-		if len(pod.Spec.Tolerations) <= 0 {
-			// create array if this is the first toleration of the pod
-			patch = append(patch, map[string]interface{}{
-				"op":   "add",
-				"path": "/spec/tolerations",
-				"value": []corev1.Toleration{
-					{
-						Key:      resourceKey,
-						Operator: corev1.TolerationOpExists,
-						Effect:   corev1.TaintEffectNoSchedule,
-					},
-				},
-			})
-		} else {
-			// append as last element of the tolerations array otherwise
-			patch = append(patch, map[string]interface{}{
-				"op":   "add",
-				"path": "/spec/tolerations/-",
-				"value": corev1.Toleration{
-					Key:      resourceKey,
-					Operator: corev1.TolerationOpExists,
-					Effect:   corev1.TaintEffectNoSchedule,
-				},
-			})
+		tolerations := []corev1.Toleration{SGXToleration(m.SGXResource)}
+		for _, device := range m.DeviceResources {
+			if device.Toleration {
+				tolerations = append(tolerations, SGXToleration(device.ResourceKey))
+			}
+		}
+		patch = append(patch, createTolerationPatch(pod.Spec.Tolerations, tolerations)...)
+	}
+
+	if m.SafePatches {
+		var err error
+		patch, err = addTestOperations(patch, admReviewReq.Request.Object.Raw)
+		if err != nil {
+			log.Println("Unable to mutate request: invalid pod")
+			return nil, errors.New("invalid pod")
 		}
 	}
 
@@ -209,10 +611,60 @@ func mutate(body []byte, coordAddr string, domainName string, resourceKey string
 		return nil, errors.New("unable to marshal admission response")
 	}
 
-	log.Printf("Mutation request for pod of marble type [%s] successful", marbleType)
+	log.Printf("Mutation request for pod [%s] of marble type [%s] successful", podName, marbleType)
 	return bytes, nil
 }
 
+// unknownMarbleType returns the first of marbleTypes not contained in knownMarbleTypes, or an empty string if all are known or knownMarbleTypes is nil
+func unknownMarbleType(knownMarbleTypes map[string]bool, marbleTypes []string) string {
+	if knownMarbleTypes == nil {
+		return ""
+	}
+	for _, marbleType := range marbleTypes {
+		if !knownMarbleTypes[marbleType] {
+			return marbleType
+		}
+	}
+	return ""
+}
+
+// containsString checks if a string is contained in a list of strings
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// getPodName returns a name to identify a pod in log messages
+// Pods created by controllers often only have generateName set, since their name is assigned after admission
+func getPodName(pod corev1.Pod) string {
+	if len(pod.Name) > 0 {
+		return pod.Name
+	}
+	if len(pod.GenerateName) > 0 {
+		return pod.GenerateName + "<generated>"
+	}
+	if owner := metav1.GetControllerOf(&pod); owner != nil {
+		return fmt.Sprintf("%s/%s", owner.Kind, owner.Name)
+	}
+	return "<unnamed>"
+}
+
+// invalidVolumeNameChars matches all characters not allowed in a DNS-1123 label
+var invalidVolumeNameChars = regexp.MustCompile("[^a-z0-9-]")
+
+// volumeName creates a unique volume name for a pod, which is a valid DNS-1123 label
+func volumeName(prefix string, uid types.UID) string {
+	name := invalidVolumeNameChars.ReplaceAllString(strings.ToLower(fmt.Sprintf("%s-%s", prefix, uid)), "-")
+	if len(name) > 63 {
+		name = name[:63]
+	}
+	return strings.TrimRight(name, "-")
+}
+
 // check if http was POST and not empty
 func checkRequest(w http.ResponseWriter, r *http.Request) []byte {
 	if r.Method != http.MethodPost {
@@ -248,6 +700,27 @@ func envIsSet(setVars []corev1.EnvVar, testVar corev1.EnvVar) bool {
 	return false
 }
 
+// injectedEnvSize returns the size in bytes of the environment variables of newVars which are not set yet, counting each variable as NAME=value with a terminating null byte
+func injectedEnvSize(setVars, newVars []corev1.EnvVar) int {
+	size := 0
+	for _, newVar := range newVars {
+		if !envIsSet(setVars, newVar) {
+			size += len(newVar.Name) + len(newVar.Value) + 2
+		}
+	}
+	return size
+}
+
+// mountPathIsSet checks if a volume is already mounted at the given path
+func mountPathIsSet(setMounts []corev1.VolumeMount, mountPath string) bool {
+	for _, setMount := range setMounts {
+		if setMount.MountPath == mountPath {
+			return true
+		}
+	}
+	return false
+}
+
 // addEnvVar creates a json patch setting all unset required environment variables
 func addEnvVar(setVars, newVars []corev1.EnvVar, basePath string) []map[string]interface{} {
 	var envPatch []map[string]interface{}
@@ -275,48 +748,307 @@ func addEnvVar(setVars, newVars []corev1.EnvVar, basePath string) []map[string]i
 	return envPatch
 }
 
-// createResourcePatch creates a json patch for sgx resource limits
-func createResourcePatch(container corev1.Container, idx int, resourceKey string) map[string]interface{} {
+// addTestOperations prepends a test operation to each add operation of a patch.
+// The test asserts that the modified location still holds the value of the pod in the admission request, i.e., the whole array for appends and null for new fields.
+// Each location is only tested before the first operation modifying it, as later operations see the result of the earlier ones.
+func addTestOperations(patch []map[string]interface{}, object []byte) ([]map[string]interface{}, error) {
+	var pod interface{}
+	if err := json.Unmarshal(object, &pod); err != nil {
+		return nil, err
+	}
+
+	tested := make(map[string]bool)
+	var safePatch []map[string]interface{}
+	for _, op := range patch {
+		if op["op"] == "add" {
+			path := strings.TrimSuffix(op["path"].(string), "/-")
+			if !tested[path] {
+				tested[path] = true
+				safePatch = append(safePatch, map[string]interface{}{
+					"op":    "test",
+					"path":  path,
+					"value": lookupJSONPointer(pod, path),
+				})
+			}
+		}
+		safePatch = append(safePatch, op)
+	}
+	return safePatch, nil
+}
+
+// lookupJSONPointer returns the value at the location referenced by a JSON pointer, or nil if the location does not exist
+func lookupJSONPointer(doc interface{}, pointer string) interface{} {
+	for _, token := range strings.Split(pointer, "/")[1:] {
+		token = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			doc = node[token]
+		case []interface{}:
+			idx, err := strconv.Atoi(token)
+			if err != nil || idx < 0 || idx >= len(node) {
+				return nil
+			}
+			doc = node[idx]
+		default:
+			return nil
+		}
+	}
+	return doc
+}
+
+// createLabelPatch creates a json patch adding all labels which are not set yet
+func createLabelPatch(setLabels map[string]string, newLabels map[string]string) []map[string]interface{} {
+	return createMetadataMapPatch("/metadata/labels", setLabels, newLabels)
+}
+
+// createMetadataMapPatch creates a json patch adding all entries which are not set yet to a map of the pod's metadata, e.g., its labels
+func createMetadataMapPatch(path string, setEntries map[string]string, newEntries map[string]string) []map[string]interface{} {
+	// add the entries in a deterministic order
+	keys := make([]string, 0, len(newEntries))
+	for key := range newEntries {
+		if _, ok := setEntries[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	sort.Strings(keys)
+
+	// if the pod has no entries we have to create the map
+	if len(setEntries) == 0 {
+		value := make(map[string]string, len(keys))
+		for _, key := range keys {
+			value[key] = newEntries[key]
+		}
+		return []map[string]interface{}{
+			{
+				"op":    "add",
+				"path":  path,
+				"value": value,
+			},
+		}
+	}
+
+	var patch []map[string]interface{}
+	for _, key := range keys {
+		// escape "~" and "/" in the key, so JSONPatch does not interpret it as a path
+		escapedKey := strings.Replace(strings.Replace(key, "~", "~0", -1), "/", "~1", -1)
+		patch = append(patch, map[string]interface{}{
+			"op":    "add",
+			"path":  path + "/" + escapedKey,
+			"value": newEntries[key],
+		})
+	}
+	return patch
+}
+
+// createLifecyclePatch creates a json patch adding a preStop hook to a container. An existing preStop hook is not overwritten.
+func createLifecyclePatch(container corev1.Container, idx int, preStop corev1.Handler) []map[string]interface{} {
+	if container.Lifecycle == nil {
+		return []map[string]interface{}{
+			{
+				"op":    "add",
+				"path":  fmt.Sprintf("/spec/containers/%d/lifecycle", idx),
+				"value": corev1.Lifecycle{PreStop: &preStop},
+			},
+		}
+	}
+	if container.Lifecycle.PreStop == nil {
+		return []map[string]interface{}{
+			{
+				"op":    "add",
+				"path":  fmt.Sprintf("/spec/containers/%d/lifecycle/preStop", idx),
+				"value": preStop,
+			},
+		}
+	}
+	return nil
+}
+
+// ParsePreStopURL creates a preStop hook sending an HTTP GET request to the given http or https URL
+func ParsePreStopURL(rawURL string) (*corev1.Handler, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var scheme corev1.URIScheme
+	switch u.Scheme {
+	case "http":
+		scheme = corev1.URISchemeHTTP
+	case "https":
+		scheme = corev1.URISchemeHTTPS
+	default:
+		return nil, fmt.Errorf("unsupported scheme of preStop URL: %s", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("missing host in preStop URL: %s", rawURL)
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if scheme == corev1.URISchemeHTTPS {
+			port = "443"
+		}
+	}
+	portNumber, err := strconv.Atoi(port)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port in preStop URL: %s", port)
+	}
+
+	return &corev1.Handler{
+		HTTPGet: &corev1.HTTPGetAction{
+			Scheme: scheme,
+			Host:   u.Hostname(),
+			Port:   intstr.FromInt(portNumber),
+			Path:   u.RequestURI(),
+		},
+	}, nil
+}
+
+// MarbleDNSNames returns the DNS names the injector sets in EDG_MARBLE_DNS_NAMES for a marble of the given type in the given namespace.
+// An empty namespace is treated as the default namespace.
+func MarbleDNSNames(marbleType, namespace, domain string) []string {
+	if len(namespace) == 0 {
+		namespace = "default"
+	}
+	return []string{
+		marbleType,
+		fmt.Sprintf("%s.%s", marbleType, namespace),
+		fmt.Sprintf("%s.%s.svc.%s", marbleType, namespace, domain),
+	}
+}
+
+// createResourcePatch creates a json patch adding the given limits and requests to the resources of a container
+func createResourcePatch(container corev1.Container, idx int, limits corev1.ResourceList, requests corev1.ResourceList) []map[string]interface{} {
+	if len(limits) <= 0 && len(requests) <= 0 {
+		return nil
+	}
+	basePath := fmt.Sprintf("/spec/containers/%d/resources", idx)
+
 	// first check if neither limits nor requests have been set for the container -> we need to create the complete path
 	if len(container.Resources.Limits) <= 0 && len(container.Resources.Requests) <= 0 {
-		return map[string]interface{}{
-			"op":   "add",
-			"path": fmt.Sprintf("/spec/containers/%d/resources", idx),
-			"value": map[string]interface{}{
-				"limits": map[string]int{
-					resourceKey: 10,
-				},
+		value := map[string]interface{}{}
+		if len(limits) > 0 {
+			value["limits"] = limits
+		}
+		if len(requests) > 0 {
+			value["requests"] = requests
+		}
+		return []map[string]interface{}{
+			{
+				"op":    "add",
+				"path":  basePath,
+				"value": value,
 			},
 		}
 	}
-	// next check if only requests have been set -> we only need to create the limits path
-	if len(container.Resources.Limits) <= 0 {
-		return map[string]interface{}{
-			"op":   "add",
-			"path": fmt.Sprintf("/spec/containers/%d/resources/limits", idx),
-			"value": map[string]int{
-				resourceKey: 10,
+
+	patch := createResourceListPatch(container.Resources.Limits, basePath+"/limits", limits)
+	return append(patch, createResourceListPatch(container.Resources.Requests, basePath+"/requests", requests)...)
+}
+
+// createResourceListPatch creates a json patch adding resources to a list of limits or requests
+func createResourceListPatch(resources corev1.ResourceList, path string, newResources corev1.ResourceList) []map[string]interface{} {
+	if len(newResources) <= 0 {
+		return nil
+	}
+
+	// if the list has not been set we need to create it
+	if len(resources) <= 0 {
+		return []map[string]interface{}{
+			{
+				"op":    "add",
+				"path":  path,
+				"value": newResources,
 			},
 		}
 	}
 
-	// default case: both requests and limits have been set -> we can just add a new value
-	// replace any "/" in the added key with "~1" so JSONPatch does not interpret it as a path
-	newKey := strings.Replace(resourceKey, "/", "~1", -1)
-	return map[string]interface{}{
-		"op":    "add",
-		"path":  fmt.Sprintf("/spec/containers/%d/resources/limits/%s", idx, newKey),
-		"value": 10,
+	// otherwise we can just add the new values
+	names := make([]string, 0, len(newResources))
+	for name := range newResources {
+		names = append(names, string(name))
 	}
+	sort.Strings(names)
+
+	patch := make([]map[string]interface{}, 0, len(names))
+	for _, name := range names {
+		// replace any "/" in the added key with "~1" so JSONPatch does not interpret it as a path
+		newKey := strings.Replace(name, "/", "~1", -1)
+		patch = append(patch, map[string]interface{}{
+			"op":    "add",
+			"path":  fmt.Sprintf("%s/%s", path, newKey),
+			"value": newResources[corev1.ResourceName(name)],
+		})
+	}
+	return patch
 }
 
-// createMountPatch creates a json patch to mount a volume on a pod
-func createMountPatch(mounts int, path string, mountpath string, uid string) map[string]interface{} {
-	val := corev1.VolumeMount{
-		Name:      fmt.Sprintf("uuid-file-%s", uid),
-		MountPath: mountpath,
+// defaultResources returns the limits and requests of the defaults for all resources the container does not set itself
+func defaultResources(containerResources corev1.ResourceRequirements, defaults corev1.ResourceRequirements) (corev1.ResourceList, corev1.ResourceList) {
+	isSet := func(name corev1.ResourceName) bool {
+		_, inLimits := containerResources.Limits[name]
+		_, inRequests := containerResources.Requests[name]
+		return inLimits || inRequests
+	}
+
+	limits := corev1.ResourceList{}
+	for name, quantity := range defaults.Limits {
+		if !isSet(name) {
+			limits[name] = quantity
+		}
+	}
+	requests := corev1.ResourceList{}
+	for name, quantity := range defaults.Requests {
+		if !isSet(name) {
+			requests[name] = quantity
+		}
 	}
+	return limits, requests
+}
 
+// ParseSGXQuantity parses the amount of the sgx resource to inject, e.g., "10", "500m", or "64Mi"
+func ParseSGXQuantity(value string) (resource.Quantity, error) {
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		return resource.Quantity{}, fmt.Errorf("invalid sgx resource quantity %s: %v", value, err)
+	}
+	if quantity.Sign() <= 0 {
+		return resource.Quantity{}, fmt.Errorf("invalid sgx resource quantity %s: must be positive", value)
+	}
+	return quantity, nil
+}
+
+// sgxQuantityFromAnnotation returns the amount of the sgx resource set in the sgxLimitAnnotation of a pod.
+// The fallback is returned if the annotation is missing or invalid.
+func sgxQuantityFromAnnotation(annotations map[string]string, fallback resource.Quantity, podName string) resource.Quantity {
+	value, ok := annotations[sgxLimitAnnotation]
+	if !ok {
+		return fallback
+	}
+	limit, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || limit <= 0 {
+		log.Printf("Warning: pod [%s] has invalid value for [%s] annotation: %s, must be a positive integer. Injecting %s", podName, sgxLimitAnnotation, value, fallback.String())
+		return fallback
+	}
+	return *resource.NewQuantity(limit, resource.DecimalSI)
+}
+
+// SGXToleration returns the toleration added to injected pods, which allows scheduling on nodes tainted with the SGX resource key.
+// It is also used for device resources with DeviceResource.Toleration set.
+func SGXToleration(resourceKey string) corev1.Toleration {
+	return corev1.Toleration{
+		Key:      resourceKey,
+		Operator: corev1.TolerationOpExists,
+		Effect:   corev1.TaintEffectNoSchedule,
+	}
+}
+
+// createMountPatch creates a json patch to mount a volume on a pod
+func createMountPatch(mounts int, path string, val corev1.VolumeMount) map[string]interface{} {
 	// If no other volumeMounts exist we have to created the first one as an array
 	if mounts <= 0 {
 		return map[string]interface{}{
@@ -333,24 +1065,94 @@ func createMountPatch(mounts int, path string, mountpath string, uid string) map
 
 }
 
-// createVolumePatch creates a json patch which creates a volume utilising the k8s downward api
-func createVolumePatch(volumes int, uid string) map[string]interface{} {
-	val := corev1.Volume{
-		Name: fmt.Sprintf("uuid-file-%s", uid),
+// createUUIDVolume creates a volume utilising the k8s downward api to provide the pod's uid as the marble's uuid.
+// If the pod runs marbles of different types, each type gets its own directory holding the UUID from the annotation of the type instead.
+func createUUIDVolume(uid types.UID, podMarbleTypes []string) corev1.Volume {
+	items := []corev1.DownwardAPIVolumeFile{
+		{
+			Path: "uuid-file",
+			FieldRef: &corev1.ObjectFieldSelector{
+				FieldPath: "metadata.uid",
+			},
+		},
+	}
+	if len(podMarbleTypes) > 1 {
+		items = make([]corev1.DownwardAPIVolumeFile, 0, len(podMarbleTypes))
+		for idx, marbleType := range podMarbleTypes {
+			items = append(items, corev1.DownwardAPIVolumeFile{
+				Path: path.Join(marbleType, "uuid-file"),
+				FieldRef: &corev1.ObjectFieldSelector{
+					FieldPath: fmt.Sprintf("metadata.annotations['%s']", marbleUUIDAnnotation(idx)),
+				},
+			})
+		}
+	}
+
+	return corev1.Volume{
+		Name: volumeName("uuid-file", uid),
 		VolumeSource: corev1.VolumeSource{
 			DownwardAPI: &corev1.DownwardAPIVolumeSource{
-				Items: []corev1.DownwardAPIVolumeFile{
+				Items: items,
+			},
+		},
+	}
+}
+
+// marbleUUIDAnnotation returns the annotation holding the UUID of the idx-th marble type of a pod running marbles of different types
+func marbleUUIDAnnotation(idx int) string {
+	return fmt.Sprintf("%s%d", marbleUUIDAnnotationPrefix, idx)
+}
+
+// deriveMarbleUUID derives the UUID of a marble type in a pod running marbles of different types.
+// The pod has no UID during admission, so the UUID is derived from the UID of the admission request, which is unique as well.
+func deriveMarbleUUID(uid types.UID, marbleType string) string {
+	return uuid.NewSHA1(uuid.NameSpaceOID, []byte(string(uid)+"/"+marbleType)).String()
+}
+
+// createUUIDEnvVar creates an environment variable holding the UID of the pod as the marble's UUID
+func createUUIDEnvVar() corev1.EnvVar {
+	return corev1.EnvVar{
+		Name: "EDG_MARBLE_UUID",
+		ValueFrom: &corev1.EnvVarSource{
+			FieldRef: &corev1.ObjectFieldSelector{
+				FieldPath: "metadata.uid",
+			},
+		},
+	}
+}
+
+// createCoordinatorCAVolume creates a volume providing the coordinator's root certificate from a ConfigMap
+func createCoordinatorCAVolume(uid types.UID, configMap string) *corev1.Volume {
+	return &corev1.Volume{
+		Name: volumeName("coordinator-ca", uid),
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: configMap},
+				Items: []corev1.KeyToPath{
 					{
-						Path: "uuid-file",
-						FieldRef: &corev1.ObjectFieldSelector{
-							FieldPath: "metadata.uid",
-						},
+						Key:  coordinatorCAKey,
+						Path: coordinatorCAKey,
 					},
 				},
 			},
 		},
 	}
+}
+
+// createRuntimeDirVolume creates an emptyDir volume for the runtime files of the enclave, limited to sizeLimit unless it is zero
+func createRuntimeDirVolume(uid types.UID, sizeLimit resource.Quantity) *corev1.Volume {
+	emptyDir := &corev1.EmptyDirVolumeSource{}
+	if !sizeLimit.IsZero() {
+		emptyDir.SizeLimit = &sizeLimit
+	}
+	return &corev1.Volume{
+		Name:         volumeName("runtime-dir", uid),
+		VolumeSource: corev1.VolumeSource{EmptyDir: emptyDir},
+	}
+}
 
+// createVolumePatch creates a json patch which adds a volume to a pod
+func createVolumePatch(volumes int, val corev1.Volume) map[string]interface{} {
 	// If no other volumes exist we have to created the first one as an array
 	if volumes <= 0 {
 		return map[string]interface{}{
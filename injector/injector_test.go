@@ -2,11 +2,20 @@ package injector
 
 import (
 	"encoding/json"
+	"net/http"
+	"strings"
 	"testing"
 
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
 	v1 "k8s.io/api/admission/v1"
+	"k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 func TestMutatesValidRequest(t *testing.T) {
@@ -58,13 +67,13 @@ func TestMutatesValidRequest(t *testing.T) {
 	}`
 
 	// test if patch contains all desired values
-	response, err := mutate([]byte(rawJSON), "coordinator-mesh-api.marblerun:2001", "cluster.local", "kubernetes.azure.com/sgx_epc_mem_in_MiB", true)
+	response, err := (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "kubernetes.azure.com/sgx_epc_mem_in_MiB"}).mutate([]byte(rawJSON), true)
 	require.NoError(err, "failed to mutate request")
 
 	r := v1.AdmissionReview{}
 	require.NoError(json.Unmarshal(response, &r), "failed to unmarshal response with error %s", err)
 
-	assert.Contains(string(r.Response.Patch), `{"op":"add","path":"/spec/containers/0/resources","value":{"limits":{"kubernetes.azure.com/sgx_epc_mem_in_MiB":10}}}`, "applied incorrect resource patch")
+	assert.Contains(string(r.Response.Patch), `{"op":"add","path":"/spec/containers/0/resources","value":{"limits":{"kubernetes.azure.com/sgx_epc_mem_in_MiB":"10"}}}`, "applied incorrect resource patch")
 	assert.Contains(string(r.Response.Patch), `"op":"add","path":"/spec/containers/0/env","value":[{"name":"EDG_MARBLE_COORDINATOR_ADDR","value":"coordinator-mesh-api.marblerun:2001"}]`, "failed to apply coordinator env variable patch")
 	assert.Contains(string(r.Response.Patch), `"op":"add","path":"/spec/containers/0/env/-","value":{"name":"EDG_MARBLE_TYPE","value":"test"}`, "failed to apply marble type env variable patch")
 	assert.Contains(string(r.Response.Patch), `"op":"add","path":"/spec/containers/0/env/-","value":{"name":"EDG_MARBLE_DNS_NAMES","value":"test,test.injectable,test.injectable.svc.cluster.local"}`, "failed to apply DNS name env varibale patch")
@@ -74,7 +83,7 @@ func TestMutatesValidRequest(t *testing.T) {
 	assert.Contains(string(r.Response.Patch), `"op":"add","path":"/spec/tolerations","value":[{"key":"kubernetes.azure.com/sgx_epc_mem_in_MiB"`, "failed to apply tolerations patch")
 
 	// test if patch works without sgx values
-	response, err = mutate([]byte(rawJSON), "coordinator-mesh-api.marblerun:2001", "cluster.local", "kubernetes.azure.com/sgx_epc_mem_in_MiB", false)
+	response, err = (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "kubernetes.azure.com/sgx_epc_mem_in_MiB"}).mutate([]byte(rawJSON), false)
 	require.NoError(err, "failed to mutate request")
 	require.NoError(json.Unmarshal(response, &r), "failed to unmarshal response with error %s", err)
 	assert.NotContains(string(r.Response.Patch), `{"op":"add","path":"/spec/tolerations","value":{"key":"kubernetes.azure.com/sgx_epc_mem_in_MiB"}}`, "patch contained sgx tolerations, but tolerations were not supposed to be set")
@@ -154,13 +163,13 @@ func TestPreSetValues(t *testing.T) {
 		}
 	}`
 
-	response, err := mutate([]byte(rawJSON), "coordinator-mesh-api.marblerun:2001", "cluster.local", "kubernetes.azure.com/sgx_epc_mem_in_MiB", true)
+	response, err := (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "kubernetes.azure.com/sgx_epc_mem_in_MiB"}).mutate([]byte(rawJSON), true)
 	require.NoError(err, "failed to mutate request")
 
 	r := v1.AdmissionReview{}
 	require.NoError(json.Unmarshal(response, &r), "failed to unmarshal response with error %s", err)
 
-	assert.Contains(string(r.Response.Patch), `{"op":"add","path":"/spec/containers/0/resources/limits/kubernetes.azure.com~1sgx_epc_mem_in_MiB","value":10}`, "applied incorrect resource patch")
+	assert.Contains(string(r.Response.Patch), `{"op":"add","path":"/spec/containers/0/resources/limits/kubernetes.azure.com~1sgx_epc_mem_in_MiB","value":"10"}`, "applied incorrect resource patch")
 	assert.NotContains(string(r.Response.Patch), `"op":"add","path":"/spec/containers/0/env"`, "applied coordinator env variable patch when it shouldnt have")
 	assert.NotContains(string(r.Response.Patch), `"op":"add","path":"/spec/containers/0/env/-"`, "applied marble type env variable patch when it shouldnt have")
 	assert.NotContains(string(r.Response.Patch), `"op":"add","path":"/spec/containers/0/env/-"`, "applied DNS name env varibale patch when it shouldnt have")
@@ -214,7 +223,7 @@ func TestRejectsUnsetMarbletype(t *testing.T) {
 		}
 	}`
 
-	response, err := mutate([]byte(rawJSON), "coordinator-mesh-api.marblerun:2001", "cluster.local", "kubernetes.azure.com/sgx_epc_mem_in_MiB", true)
+	response, err := (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "kubernetes.azure.com/sgx_epc_mem_in_MiB"}).mutate([]byte(rawJSON), true)
 	require.NoError(err, "failed to mutate request")
 
 	r := v1.AdmissionReview{}
@@ -223,12 +232,72 @@ func TestRejectsUnsetMarbletype(t *testing.T) {
 	assert.Equal("Missing [marblerun/marbletype] label, injection skipped", r.Response.Result.Message, "failed to skip injection on unset marbletype")
 }
 
+func TestAdmissionReviewVersions(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	rawJSON := `{
+		"apiVersion": "admission.k8s.io/v1beta1",
+		"kind": "AdmissionReview",
+		"request": {
+			"uid": "705ab4f5-6393-11e8-b7cc-42010a800002",
+			"namespace": "injectable",
+			"operation": "CREATE",
+			"object": {
+				"kind": "Pod",
+				"apiVersion": "v1",
+				"metadata": {
+					"name": "testpod",
+					"namespace": "injectable",
+					"labels": {
+						"name": "testpod",
+						"marblerun/marbletype": "test"
+					}
+				},
+				"spec": {
+					"containers": [
+						{
+							"name": "testpod",
+							"image": "test:image"
+						}
+					]
+				}
+			}
+		}
+	}`
+
+	// v1beta1 requests get a v1beta1 response with the same patch as a v1 request
+	response, err := (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "kubernetes.azure.com/sgx_epc_mem_in_MiB"}).mutate([]byte(rawJSON), true)
+	require.NoError(err, "failed to mutate request")
+
+	rBeta := v1beta1.AdmissionReview{}
+	require.NoError(json.Unmarshal(response, &rBeta), "failed to unmarshal response with error %s", err)
+	assert.Equal("admission.k8s.io/v1beta1", rBeta.APIVersion)
+	assert.Equal("AdmissionReview", rBeta.Kind)
+	assert.Equal(types.UID("705ab4f5-6393-11e8-b7cc-42010a800002"), rBeta.Response.UID)
+	assert.True(rBeta.Response.Allowed)
+	require.NotNil(rBeta.Response.PatchType)
+	assert.Equal(v1beta1.PatchTypeJSONPatch, *rBeta.Response.PatchType)
+
+	response, err = (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "kubernetes.azure.com/sgx_epc_mem_in_MiB"}).mutate([]byte(strings.Replace(rawJSON, "admission.k8s.io/v1beta1", "admission.k8s.io/v1", 1)), true)
+	require.NoError(err, "failed to mutate request")
+
+	r := v1.AdmissionReview{}
+	require.NoError(json.Unmarshal(response, &r), "failed to unmarshal response with error %s", err)
+	assert.Equal("admission.k8s.io/v1", r.APIVersion)
+	assert.Equal([]byte(r.Response.Patch), []byte(rBeta.Response.Patch))
+
+	// unknown versions are rejected
+	_, err = (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "kubernetes.azure.com/sgx_epc_mem_in_MiB"}).mutate([]byte(strings.Replace(rawJSON, "admission.k8s.io/v1beta1", "admission.k8s.io/v2", 1)), true)
+	assert.Error(err)
+}
+
 func TestErrorsOnInvalid(t *testing.T) {
 	require := require.New(t)
 
 	rawJSON := `This should return Error`
 
-	_, err := mutate([]byte(rawJSON), "coordinator-mesh-api.marblerun:2001", "cluster.local", "kubernetes.azure.com/sgx_epc_mem_in_MiB", true)
+	_, err := (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "kubernetes.azure.com/sgx_epc_mem_in_MiB"}).mutate([]byte(rawJSON), true)
 	require.Error(err, "did not fail on invalid request")
 }
 
@@ -242,6 +311,2119 @@ func TestErrorsOnInvalidPod(t *testing.T) {
 			"object": "invalid"
 		}
 	}`
-	_, err := mutate([]byte(rawJSON), "coordinator-mesh-api.marblerun:2001", "cluster.local", "kubernetes.azure.com/sgx_epc_mem_in_MiB", true)
+	_, err := (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "kubernetes.azure.com/sgx_epc_mem_in_MiB"}).mutate([]byte(rawJSON), true)
 	require.Error(err, "did not fail when sending invalid request")
 }
+
+func TestShmVolume(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	rawJSON := `{
+		"apiVersion": "admission.k8s.io/v1",
+		"kind": "AdmissionReview",
+		"request": {
+			"uid": "705ab4f5-6393-11e8-b7cc-42010a800002",
+			"namespace": "injectable",
+			"operation": "CREATE",
+			"object": {
+				"kind": "Pod",
+				"apiVersion": "v1",
+				"metadata": {
+					"name": "testpod",
+					"namespace": "injectable",
+					"labels": {
+						"name": "testpod",
+						"marblerun/marbletype": "test"
+					},
+					"annotations": {
+						"marblerun/shm-size": "512Mi"
+					}
+				},
+				"spec": {
+					"containers": [
+						{
+							"name": "testpod",
+							"image": "test:image"
+						}
+					]
+				}
+			}
+		}
+	}`
+
+	// first volume: volumes and volumeMounts arrays are created by the uuid patches, shm is appended
+	response, err := (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "kubernetes.azure.com/sgx_epc_mem_in_MiB"}).mutate([]byte(rawJSON), true)
+	require.NoError(err, "failed to mutate request")
+
+	r := v1.AdmissionReview{}
+	require.NoError(json.Unmarshal(response, &r), "failed to unmarshal response with error %s", err)
+
+	assert.Contains(string(r.Response.Patch), `{"op":"add","path":"/spec/volumes","value":[{"name":"uuid-file-705ab4f5-6393-11e8-b7cc-42010a800002"`, "failed to apply uuid volume patch")
+	assert.Contains(string(r.Response.Patch), `{"op":"add","path":"/spec/volumes/-","value":{"name":"shm-705ab4f5-6393-11e8-b7cc-42010a800002","emptyDir":{"medium":"Memory","sizeLimit":"512Mi"}}}`, "failed to apply shm volume patch")
+	assert.Contains(string(r.Response.Patch), `{"op":"add","path":"/spec/containers/0/volumeMounts/-","value":{"name":"shm-705ab4f5-6393-11e8-b7cc-42010a800002","mountPath":"/dev/shm"}}`, "failed to apply shm volumeMount patch")
+
+	// first volume: uuid file is preset, so shm creates the arrays
+	rawJSON = strings.Replace(rawJSON, `"image": "test:image"`, `"image": "test:image", "env": [{"name": "EDG_MARBLE_UUID_FILE", "value": "uuid"}]`, 1)
+	response, err = (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "kubernetes.azure.com/sgx_epc_mem_in_MiB"}).mutate([]byte(rawJSON), true)
+	require.NoError(err, "failed to mutate request")
+	require.NoError(json.Unmarshal(response, &r), "failed to unmarshal response with error %s", err)
+
+	assert.Contains(string(r.Response.Patch), `{"op":"add","path":"/spec/volumes","value":[{"name":"shm-705ab4f5-6393-11e8-b7cc-42010a800002","emptyDir":{"medium":"Memory","sizeLimit":"512Mi"}}]}`, "failed to apply shm volume patch")
+	assert.Contains(string(r.Response.Patch), `{"op":"add","path":"/spec/containers/0/volumeMounts","value":[{"name":"shm-705ab4f5-6393-11e8-b7cc-42010a800002","mountPath":"/dev/shm"}]}`, "failed to apply shm volumeMount patch")
+
+	// invalid size
+	rawJSON = strings.Replace(rawJSON, `"marblerun/shm-size": "512Mi"`, `"marblerun/shm-size": "lots"`, 1)
+	_, err = (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "kubernetes.azure.com/sgx_epc_mem_in_MiB"}).mutate([]byte(rawJSON), true)
+	assert.Error(err, "did not fail on invalid shm size")
+}
+
+func TestSimulationMode(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	rawJSON := `{
+		"apiVersion": "admission.k8s.io/v1",
+		"kind": "AdmissionReview",
+		"request": {
+			"uid": "705ab4f5-6393-11e8-b7cc-42010a800002",
+			"namespace": "injectable",
+			"operation": "CREATE",
+			"object": {
+				"kind": "Pod",
+				"apiVersion": "v1",
+				"metadata": {
+					"name": "testpod",
+					"namespace": "injectable",
+					"labels": {
+						"name": "testpod",
+						"marblerun/marbletype": "test"
+					},
+					"annotations": {
+						"marblerun/mode": "simulation"
+					}
+				},
+				"spec": {
+					"containers": [
+						{
+							"name": "testpod",
+							"image": "test:image"
+						}
+					]
+				}
+			}
+		}
+	}`
+
+	response, err := (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "kubernetes.azure.com/sgx_epc_mem_in_MiB"}).mutate([]byte(rawJSON), true)
+	require.NoError(err, "failed to mutate request")
+
+	r := v1.AdmissionReview{}
+	require.NoError(json.Unmarshal(response, &r), "failed to unmarshal response with error %s", err)
+
+	assert.NotContains(string(r.Response.Patch), `"path":"/spec/containers/0/resources"`, "applied resource patch in simulation mode")
+	assert.NotContains(string(r.Response.Patch), `"path":"/spec/tolerations"`, "applied tolerations patch in simulation mode")
+	assert.Contains(string(r.Response.Patch), `"op":"add","path":"/spec/containers/0/env","value":[{"name":"EDG_MARBLE_COORDINATOR_ADDR","value":"coordinator-mesh-api.marblerun:2001"}]`, "failed to apply coordinator env variable patch")
+	assert.Contains(string(r.Response.Patch), `"op":"add","path":"/spec/containers/0/env/-","value":{"name":"EDG_MARBLE_TYPE","value":"test"}`, "failed to apply marble type env variable patch")
+}
+
+func TestGenerateName(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	rawJSON := `{
+		"apiVersion": "admission.k8s.io/v1",
+		"kind": "AdmissionReview",
+		"request": {
+			"uid": "705AB4F5-6393-11E8-B7CC-42010A800002",
+			"namespace": "injectable",
+			"operation": "CREATE",
+			"object": {
+				"kind": "Pod",
+				"apiVersion": "v1",
+				"metadata": {
+					"generateName": "testpod-5d8f9c7b6-",
+					"namespace": "injectable",
+					"labels": {
+						"marblerun/marbletype": "test"
+					},
+					"ownerReferences": [
+						{
+							"apiVersion": "apps/v1",
+							"kind": "ReplicaSet",
+							"name": "testpod-5d8f9c7b6",
+							"uid": "6a3f0c4e-0f6b-4c1e-9f5a-7c7d1c3e2b1a",
+							"controller": true
+						}
+					]
+				},
+				"spec": {
+					"containers": [
+						{
+							"name": "testpod",
+							"image": "test:image"
+						}
+					]
+				}
+			}
+		}
+	}`
+
+	response, err := (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "kubernetes.azure.com/sgx_epc_mem_in_MiB"}).mutate([]byte(rawJSON), true)
+	require.NoError(err, "failed to mutate request")
+
+	r := v1.AdmissionReview{}
+	require.NoError(json.Unmarshal(response, &r), "failed to unmarshal response with error %s", err)
+
+	assert.Contains(string(r.Response.Patch), `"name":"uuid-file-705ab4f5-6393-11e8-b7cc-42010a800002"`, "volume name is not a valid DNS label")
+	assert.Contains(string(r.Response.Patch), `{"op":"add","path":"/spec/containers/0/volumeMounts","value":[{"name":"uuid-file-705ab4f5-6393-11e8-b7cc-42010a800002"`, "volumeMount does not reference the volume")
+
+	var pod corev1.Pod
+	require.NoError(json.Unmarshal([]byte(gjson.Get(rawJSON, "request.object").Raw), &pod))
+	assert.Equal("testpod-5d8f9c7b6-<generated>", getPodName(pod))
+	pod.GenerateName = ""
+	assert.Equal("ReplicaSet/testpod-5d8f9c7b6", getPodName(pod))
+	pod.OwnerReferences = nil
+	assert.Equal("<unnamed>", getPodName(pod))
+}
+
+func TestVolumeName(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("uuid-file-705ab4f5-6393-11e8-b7cc-42010a800002", volumeName("uuid-file", "705ab4f5-6393-11e8-b7cc-42010a800002"))
+	assert.Equal("shm-abc-def", volumeName("shm", "ABC_DEF"))
+	assert.Len(volumeName("uuid-file", types.UID(strings.Repeat("a", 100))), 63)
+}
+
+func TestMutateSafePatches(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	rawJSON := `{
+		"apiVersion": "admission.k8s.io/v1",
+		"kind": "AdmissionReview",
+		"request": {
+			"uid": "705ab4f5-6393-11e8-b7cc-42010a800002",
+			"namespace": "injectable",
+			"operation": "CREATE",
+			"object": {
+				"kind": "Pod",
+				"apiVersion": "v1",
+				"metadata": {
+					"name": "testpod",
+					"namespace": "injectable",
+					"labels": {
+						"marblerun/marbletype": "test"
+					}
+				},
+				"spec": {
+					"containers": [
+						{
+							"name": "testpod",
+							"image": "test:image",
+							"resources": {}
+						}
+					],
+					"tolerations": [
+						{
+							"key": "node.kubernetes.io/not-ready",
+							"operator": "Exists",
+							"effect": "NoExecute"
+						}
+					]
+				}
+			}
+		}
+	}`
+
+	response, err := (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "sgx.intel.com/epc", SafePatches: true}).mutate([]byte(rawJSON), true)
+	require.NoError(err)
+	r := v1.AdmissionReview{}
+	require.NoError(json.Unmarshal(response, &r))
+
+	var patch []map[string]interface{}
+	require.NoError(json.Unmarshal(r.Response.Patch, &patch))
+
+	// each location is tested right before the first add operation modifying it
+	tested := make(map[string]bool)
+	for idx, op := range patch {
+		path := strings.TrimSuffix(op["path"].(string), "/-")
+		switch op["op"] {
+		case "test":
+			assert.False(tested[path], "%s is tested twice", path)
+			tested[path] = true
+			require.Less(idx+1, len(patch))
+			assert.Equal("add", patch[idx+1]["op"])
+			assert.Equal(path, strings.TrimSuffix(patch[idx+1]["path"].(string), "/-"))
+		case "add":
+			assert.True(tested[path], "%s is added without test", path)
+		}
+	}
+
+	// new fields are expected to be absent, arrays are expected to be unchanged
+	assert.Contains(string(r.Response.Patch), `{"op":"test","path":"/spec/containers/0/env","value":null}`)
+	assert.Contains(string(r.Response.Patch), `{"op":"test","path":"/spec/containers/0/resources","value":{}}`)
+	assert.Contains(string(r.Response.Patch), `{"op":"test","path":"/spec/tolerations","value":[{"effect":"NoExecute","key":"node.kubernetes.io/not-ready","operator":"Exists"}]}`)
+
+	// the patch applies to the pod of the request
+	pod := []byte(gjson.Get(rawJSON, "request.object").Raw)
+	jsonPatch, err := jsonpatch.DecodePatch(r.Response.Patch)
+	require.NoError(err)
+	_, err = jsonPatch.Apply(pod)
+	assert.NoError(err)
+
+	// the patch is rejected if another webhook created the env array in the meantime
+	var podObject corev1.Pod
+	require.NoError(json.Unmarshal(pod, &podObject))
+	podObject.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "OTHER", Value: "value"}}
+	modifiedPod, err := json.Marshal(podObject)
+	require.NoError(err)
+	_, err = jsonPatch.Apply(modifiedPod)
+	assert.Error(err)
+
+	// without safe patches, no test operations are added
+	response, err = (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "sgx.intel.com/epc"}).mutate([]byte(rawJSON), true)
+	require.NoError(err)
+	require.NoError(json.Unmarshal(response, &r))
+	assert.NotContains(string(r.Response.Patch), `"op":"test"`)
+}
+
+func TestMutateLabels(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	rawJSON := `{
+		"apiVersion": "admission.k8s.io/v1",
+		"kind": "AdmissionReview",
+		"request": {
+			"uid": "705ab4f5-6393-11e8-b7cc-42010a800002",
+			"namespace": "injectable",
+			"operation": "CREATE",
+			"object": {
+				"kind": "Pod",
+				"apiVersion": "v1",
+				"metadata": {
+					"name": "testpod",
+					"namespace": "injectable",
+					"labels": {
+						"marblerun/marbletype": "test",
+						"app": "existing"
+					}
+				},
+				"spec": {
+					"containers": [
+						{
+							"name": "testpod",
+							"image": "test:image"
+						}
+					]
+				}
+			}
+		}
+	}`
+	labels := map[string]string{"marblerun/injected": "true", "app": "marble"}
+
+	response, err := (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "sgx.intel.com/epc", Labels: labels, MarbleTypeLabel: "marblerun/type"}).mutate([]byte(rawJSON), true)
+	require.NoError(err)
+	r := v1.AdmissionReview{}
+	require.NoError(json.Unmarshal(response, &r))
+
+	jsonPatch, err := jsonpatch.DecodePatch(r.Response.Patch)
+	require.NoError(err)
+	patchedPod, err := jsonPatch.Apply([]byte(gjson.Get(rawJSON, "request.object").Raw))
+	require.NoError(err)
+	var pod corev1.Pod
+	require.NoError(json.Unmarshal(patchedPod, &pod))
+
+	assert.Equal(map[string]string{
+		"marblerun/marbletype": "test",
+		"marblerun/injected":   "true",
+		"marblerun/type":       "test",
+		"app":                  "existing",
+	}, pod.Labels)
+
+	// no patch is created if all labels are set
+	response, err = (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "sgx.intel.com/epc", Labels: map[string]string{"app": "marble"}}).mutate([]byte(rawJSON), true)
+	require.NoError(err)
+	require.NoError(json.Unmarshal(response, &r))
+	assert.NotContains(string(r.Response.Patch), "/metadata/labels")
+}
+
+func TestCreateLabelPatch(t *testing.T) {
+	assert := assert.New(t)
+
+	newLabels := map[string]string{"marblerun/injected": "true", "tier": "backend"}
+
+	// the labels map is created if the pod has no labels
+	patch := createLabelPatch(nil, newLabels)
+	assert.Equal([]map[string]interface{}{
+		{"op": "add", "path": "/metadata/labels", "value": map[string]string{"marblerun/injected": "true", "tier": "backend"}},
+	}, patch)
+
+	// otherwise, unset labels are added to the existing map
+	patch = createLabelPatch(map[string]string{"tier": "frontend"}, newLabels)
+	assert.Equal([]map[string]interface{}{
+		{"op": "add", "path": "/metadata/labels/marblerun~1injected", "value": "true"},
+	}, patch)
+
+	assert.Empty(createLabelPatch(map[string]string{"marblerun/injected": "false", "tier": "frontend"}, newLabels))
+}
+
+func TestMarbleDNSNames(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	assert.Equal([]string{"backend", "backend.default", "backend.default.svc.cluster.local"}, MarbleDNSNames("backend", "", "cluster.local"))
+
+	for _, namespace := range []string{"injectable", ""} {
+		rawJSON := `{
+			"apiVersion": "admission.k8s.io/v1",
+			"kind": "AdmissionReview",
+			"request": {
+				"uid": "705ab4f5-6393-11e8-b7cc-42010a800002",
+				"operation": "CREATE",
+				"object": {
+					"kind": "Pod",
+					"apiVersion": "v1",
+					"metadata": {
+						"name": "testpod",
+						"namespace": "` + namespace + `",
+						"labels": {"marblerun/marbletype": "test"}
+					},
+					"spec": {"containers": [{"name": "testpod", "image": "test:image"}]}
+				}
+			}
+		}`
+
+		response, err := (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "kubernetes.azure.com/sgx_epc_mem_in_MiB"}).mutate([]byte(rawJSON), true)
+		require.NoError(err)
+		r := v1.AdmissionReview{}
+		require.NoError(json.Unmarshal(response, &r))
+
+		var injected string
+		for _, op := range gjson.ParseBytes(r.Response.Patch).Array() {
+			if op.Get("value.name").String() == "EDG_MARBLE_DNS_NAMES" {
+				injected = op.Get("value.value").String()
+			}
+		}
+		assert.Equal(strings.Join(MarbleDNSNames("test", namespace, "cluster.local"), ","), injected, namespace)
+	}
+}
+
+func TestCreateResourcePatchRequests(t *testing.T) {
+	assert := assert.New(t)
+
+	const resourceKey = "sgx.intel.com/epc"
+	quantity := resource.MustParse("1")
+	sgxQuantity := resource.MustParse("10")
+	sgxResources := corev1.ResourceList{resourceKey: sgxQuantity}
+
+	// without requests, only the limits are set
+	patch := createResourcePatch(corev1.Container{}, 0, sgxResources, nil)
+	assert.Equal([]map[string]interface{}{
+		{"op": "add", "path": "/spec/containers/0/resources", "value": map[string]interface{}{
+			"limits": sgxResources,
+		}},
+	}, patch)
+
+	// the resources are created with limits and requests
+	patch = createResourcePatch(corev1.Container{}, 0, sgxResources, sgxResources)
+	assert.Equal([]map[string]interface{}{
+		{"op": "add", "path": "/spec/containers/0/resources", "value": map[string]interface{}{
+			"limits":   sgxResources,
+			"requests": sgxResources,
+		}},
+	}, patch)
+
+	// the requests path is created if only limits exist
+	container := corev1.Container{Resources: corev1.ResourceRequirements{
+		Limits: corev1.ResourceList{corev1.ResourceCPU: quantity},
+	}}
+	patch = createResourcePatch(container, 1, sgxResources, sgxResources)
+	assert.Equal([]map[string]interface{}{
+		{"op": "add", "path": "/spec/containers/1/resources/limits/sgx.intel.com~1epc", "value": sgxQuantity},
+		{"op": "add", "path": "/spec/containers/1/resources/requests", "value": sgxResources},
+	}, patch)
+
+	// the request is appended to existing requests
+	container = corev1.Container{Resources: corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceCPU: quantity},
+	}}
+	patch = createResourcePatch(container, 0, sgxResources, sgxResources)
+	assert.Equal([]map[string]interface{}{
+		{"op": "add", "path": "/spec/containers/0/resources/limits", "value": sgxResources},
+		{"op": "add", "path": "/spec/containers/0/resources/requests/sgx.intel.com~1epc", "value": sgxQuantity},
+	}, patch)
+
+	container = corev1.Container{Resources: corev1.ResourceRequirements{
+		Limits:   corev1.ResourceList{corev1.ResourceCPU: quantity},
+		Requests: corev1.ResourceList{corev1.ResourceCPU: quantity},
+	}}
+	patch = createResourcePatch(container, 0, sgxResources, sgxResources)
+	assert.Equal([]map[string]interface{}{
+		{"op": "add", "path": "/spec/containers/0/resources/limits/sgx.intel.com~1epc", "value": sgxQuantity},
+		{"op": "add", "path": "/spec/containers/0/resources/requests/sgx.intel.com~1epc", "value": sgxQuantity},
+	}, patch)
+}
+
+func TestMarbleResources(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	rawJSON := `{
+		"apiVersion": "admission.k8s.io/v1",
+		"kind": "AdmissionReview",
+		"request": {
+			"uid": "705ab4f5-6393-11e8-b7cc-42010a800002",
+			"operation": "CREATE",
+			"object": {
+				"kind": "Pod",
+				"apiVersion": "v1",
+				"metadata": {
+					"name": "testpod",
+					"namespace": "injectable",
+					"labels": {"marblerun/marbletype": "test"}
+				},
+				"spec": {
+					"containers": [
+						{"name": "default", "image": "test:image"},
+						{"name": "custom", "image": "test:image", "resources": {"limits": {"memory": "4Gi"}}}
+					]
+				}
+			}
+		}
+	}`
+	marbleResources := map[string]corev1.ResourceRequirements{
+		"test": {
+			Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("2Gi")},
+			Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m"), corev1.ResourceMemory: resource.MustParse("1Gi")},
+		},
+	}
+
+	response, err := (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "sgx.intel.com/epc", MarbleResources: marbleResources}).mutate([]byte(rawJSON), true)
+	require.NoError(err)
+	r := v1.AdmissionReview{}
+	require.NoError(json.Unmarshal(response, &r))
+
+	var pod corev1.Pod
+	require.NoError(json.Unmarshal([]byte(gjson.Get(rawJSON, "request.object").Raw), &pod))
+	rawPod, err := json.Marshal(pod)
+	require.NoError(err)
+	patch, err := jsonpatch.DecodePatch(r.Response.Patch)
+	require.NoError(err)
+	rawPod, err = patch.Apply(rawPod)
+	require.NoError(err)
+	require.NoError(json.Unmarshal(rawPod, &pod))
+
+	// the defaults are applied to the container lacking resources, together with the sgx resource
+	resources := pod.Spec.Containers[0].Resources
+	assert.True(resource.MustParse("2Gi").Equal(resources.Limits[corev1.ResourceMemory]))
+	assert.True(resource.MustParse("10").Equal(resources.Limits["sgx.intel.com/epc"]))
+	assert.True(resource.MustParse("500m").Equal(resources.Requests[corev1.ResourceCPU]))
+	assert.True(resource.MustParse("1Gi").Equal(resources.Requests[corev1.ResourceMemory]))
+
+	// user-specified resources are not overridden
+	resources = pod.Spec.Containers[1].Resources
+	assert.True(resource.MustParse("4Gi").Equal(resources.Limits[corev1.ResourceMemory]))
+	assert.True(resource.MustParse("10").Equal(resources.Limits["sgx.intel.com/epc"]))
+	assert.True(resource.MustParse("500m").Equal(resources.Requests[corev1.ResourceCPU]))
+	_, ok := resources.Requests[corev1.ResourceMemory]
+	assert.False(ok)
+
+	// marble types without defaults only get the sgx resource
+	response, err = (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "sgx.intel.com/epc", MarbleResources: marbleResources}).mutate([]byte(strings.Replace(rawJSON, `"marblerun/marbletype": "test"`, `"marblerun/marbletype": "other"`, 1)), true)
+	require.NoError(err)
+	require.NoError(json.Unmarshal(response, &r))
+	assert.Contains(string(r.Response.Patch), `{"op":"add","path":"/spec/containers/0/resources","value":{"limits":{"sgx.intel.com/epc":"10"}}}`)
+}
+
+func TestSGXQuantity(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	testCases := map[string]struct {
+		value    string
+		expected string
+	}{
+		"integer":       {value: "10", expected: `"10"`},
+		"milli":         {value: "500m", expected: `"500m"`},
+		"byte quantity": {value: "64Mi", expected: `"64Mi"`},
+	}
+	for name, tc := range testCases {
+		quantity, err := ParseSGXQuantity(tc.value)
+		require.NoError(err, name)
+
+		patch := createResourcePatch(corev1.Container{}, 0, corev1.ResourceList{"sgx.intel.com/epc": quantity}, corev1.ResourceList{"sgx.intel.com/epc": quantity})
+		rawPatch, err := json.Marshal(patch)
+		require.NoError(err, name)
+		assert.JSONEq(`[{"op":"add","path":"/spec/containers/0/resources","value":{"limits":{"sgx.intel.com/epc":`+tc.expected+`},"requests":{"sgx.intel.com/epc":`+tc.expected+`}}}]`, string(rawPatch), name)
+
+		// the patched pod is accepted as valid pod spec
+		jsonPatch, err := jsonpatch.DecodePatch(rawPatch)
+		require.NoError(err, name)
+		patched, err := jsonPatch.Apply([]byte(`{"spec":{"containers":[{"name":"test"}]}}`))
+		require.NoError(err, name)
+		var pod corev1.Pod
+		require.NoError(json.Unmarshal(patched, &pod), name)
+		assert.True(quantity.Equal(pod.Spec.Containers[0].Resources.Limits["sgx.intel.com/epc"]), name)
+	}
+
+	for _, value := range []string{"", "ten", "0", "-1"} {
+		_, err := ParseSGXQuantity(value)
+		assert.Error(err, value)
+	}
+}
+
+func TestExtraVolumes(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	extraVolumes, err := LoadExtraVolumes([]byte(`
+Marbles:
+  test:
+    Package: backend
+    Kubernetes:
+      Volumes:
+      - name: data
+        persistentVolumeClaim:
+          claimName: data-claim
+      VolumeMounts:
+      - name: data
+        mountPath: /data
+`))
+	require.NoError(err)
+
+	rawJSON := `{
+		"apiVersion": "admission.k8s.io/v1",
+		"kind": "AdmissionReview",
+		"request": {
+			"uid": "705ab4f5-6393-11e8-b7cc-42010a800002",
+			"namespace": "injectable",
+			"operation": "CREATE",
+			"object": {
+				"kind": "Pod",
+				"apiVersion": "v1",
+				"metadata": {
+					"name": "testpod",
+					"namespace": "injectable",
+					"labels": {
+						"name": "testpod",
+						"marblerun/marbletype": "test"
+					}
+				},
+				"spec": {
+					"containers": [
+						{
+							"name": "testpod",
+							"image": "test:image"
+						}
+					]
+				}
+			}
+		}
+	}`
+
+	// volumes and volumeMounts arrays are created by the uuid patches, the extra volume is appended
+	response, err := (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "kubernetes.azure.com/sgx_epc_mem_in_MiB", ExtraVolumes: extraVolumes}).mutate([]byte(rawJSON), true)
+	require.NoError(err, "failed to mutate request")
+
+	r := v1.AdmissionReview{}
+	require.NoError(json.Unmarshal(response, &r), "failed to unmarshal response with error %s", err)
+
+	assert.Contains(string(r.Response.Patch), `{"op":"add","path":"/spec/volumes/-","value":{"name":"data","persistentVolumeClaim":{"claimName":"data-claim"}}}`, "failed to apply extra volume patch")
+	assert.Contains(string(r.Response.Patch), `{"op":"add","path":"/spec/containers/0/volumeMounts/-","value":{"name":"data","mountPath":"/data"}}`, "failed to apply extra volumeMount patch")
+
+	// first volume: uuid file is preset, so the extra volume creates the arrays
+	rawJSON = strings.Replace(rawJSON, `"image": "test:image"`, `"image": "test:image", "env": [{"name": "EDG_MARBLE_UUID_FILE", "value": "uuid"}]`, 1)
+	response, err = (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "kubernetes.azure.com/sgx_epc_mem_in_MiB", ExtraVolumes: extraVolumes}).mutate([]byte(rawJSON), true)
+	require.NoError(err, "failed to mutate request")
+	require.NoError(json.Unmarshal(response, &r), "failed to unmarshal response with error %s", err)
+
+	assert.Contains(string(r.Response.Patch), `{"op":"add","path":"/spec/volumes","value":[{"name":"data","persistentVolumeClaim":{"claimName":"data-claim"}}]}`, "failed to apply extra volume patch")
+	assert.Contains(string(r.Response.Patch), `{"op":"add","path":"/spec/containers/0/volumeMounts","value":[{"name":"data","mountPath":"/data"}]}`, "failed to apply extra volumeMount patch")
+
+	// marble types without extra volumes are not affected
+	rawJSON = strings.Replace(rawJSON, `"marblerun/marbletype": "test"`, `"marblerun/marbletype": "other"`, 1)
+	response, err = (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "kubernetes.azure.com/sgx_epc_mem_in_MiB", ExtraVolumes: extraVolumes}).mutate([]byte(rawJSON), true)
+	require.NoError(err, "failed to mutate request")
+	require.NoError(json.Unmarshal(response, &r), "failed to unmarshal response with error %s", err)
+
+	assert.NotContains(string(r.Response.Patch), `"name":"data"`, "applied extra volume to other marble type")
+}
+
+func TestCoordinatorCA(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	rawJSON := `{
+		"apiVersion": "admission.k8s.io/v1",
+		"kind": "AdmissionReview",
+		"request": {
+			"uid": "705ab4f5-6393-11e8-b7cc-42010a800002",
+			"namespace": "injectable",
+			"operation": "CREATE",
+			"object": {
+				"kind": "Pod",
+				"apiVersion": "v1",
+				"metadata": {
+					"name": "testpod",
+					"namespace": "injectable",
+					"labels": {
+						"name": "testpod",
+						"marblerun/marbletype": "test"
+					}
+				},
+				"spec": {
+					"containers": [
+						{
+							"name": "testpod",
+							"image": "test:image"
+						},
+						{
+							"name": "preset",
+							"image": "test:image",
+							"env": [{"name": "EDG_MARBLE_COORDINATOR_CA_FILE", "value": "/custom/ca.crt"}]
+						}
+					]
+				}
+			}
+		}
+	}`
+
+	response, err := (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "kubernetes.azure.com/sgx_epc_mem_in_MiB", CoordinatorCAConfigMap: "coordinator-ca", CoordinatorCAMountPath: "/etc/marblerun/coordinator-ca"}).mutate([]byte(rawJSON), true)
+	require.NoError(err, "failed to mutate request")
+
+	r := v1.AdmissionReview{}
+	require.NoError(json.Unmarshal(response, &r), "failed to unmarshal response with error %s", err)
+
+	// apply the patch to check that volume, mount, and env var fit together
+	var pod corev1.Pod
+	require.NoError(json.Unmarshal([]byte(gjson.Get(rawJSON, "request.object").Raw), &pod))
+	rawPod, err := json.Marshal(pod)
+	require.NoError(err)
+	patch, err := jsonpatch.DecodePatch(r.Response.Patch)
+	require.NoError(err)
+	rawPod, err = patch.Apply(rawPod)
+	require.NoError(err)
+	require.NoError(json.Unmarshal(rawPod, &pod))
+
+	var caVolume *corev1.Volume
+	for idx, volume := range pod.Spec.Volumes {
+		if volume.ConfigMap != nil {
+			caVolume = &pod.Spec.Volumes[idx]
+		}
+	}
+	require.NotNil(caVolume, "failed to apply coordinator CA volume patch")
+	assert.Equal("coordinator-ca", caVolume.ConfigMap.Name)
+	assert.Equal([]corev1.KeyToPath{{Key: "ca.crt", Path: "ca.crt"}}, caVolume.ConfigMap.Items)
+
+	// the CA file is mounted and passed to the first container
+	assert.Contains(pod.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{Name: caVolume.Name, MountPath: "/etc/marblerun/coordinator-ca", ReadOnly: true})
+	assert.Contains(pod.Spec.Containers[0].Env, corev1.EnvVar{Name: "EDG_MARBLE_COORDINATOR_CA_FILE", Value: "/etc/marblerun/coordinator-ca/ca.crt"})
+
+	// a CA file set by the user is kept
+	assert.NotContains(pod.Spec.Containers[1].VolumeMounts, corev1.VolumeMount{Name: caVolume.Name, MountPath: "/etc/marblerun/coordinator-ca", ReadOnly: true})
+	assert.Equal([]corev1.EnvVar{{Name: "EDG_MARBLE_COORDINATOR_CA_FILE", Value: "/custom/ca.crt"}}, pod.Spec.Containers[1].Env[:1])
+
+	// nothing is injected if unset
+	response, err = (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "kubernetes.azure.com/sgx_epc_mem_in_MiB", CoordinatorCAMountPath: "/etc/marblerun/coordinator-ca"}).mutate([]byte(rawJSON), true)
+	require.NoError(err, "failed to mutate request")
+	require.NoError(json.Unmarshal(response, &r), "failed to unmarshal response with error %s", err)
+	assert.NotContains(string(r.Response.Patch), "coordinator-ca")
+}
+
+func TestPreStopHook(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	rawJSON := `{
+		"apiVersion": "admission.k8s.io/v1",
+		"kind": "AdmissionReview",
+		"request": {
+			"uid": "705ab4f5-6393-11e8-b7cc-42010a800002",
+			"namespace": "injectable",
+			"operation": "CREATE",
+			"object": {
+				"kind": "Pod",
+				"apiVersion": "v1",
+				"metadata": {
+					"name": "testpod",
+					"namespace": "injectable",
+					"labels": {
+						"name": "testpod",
+						"marblerun/marbletype": "test"
+					}
+				},
+				"spec": {
+					"containers": [
+						{
+							"name": "nolifecycle",
+							"image": "test:image"
+						},
+						{
+							"name": "poststart",
+							"image": "test:image",
+							"lifecycle": {"postStart": {"exec": {"command": ["/bin/start"]}}}
+						},
+						{
+							"name": "prestop",
+							"image": "test:image",
+							"lifecycle": {"preStop": {"exec": {"command": ["/bin/stop"]}}}
+						}
+					]
+				}
+			}
+		}
+	}`
+
+	preStop, err := ParsePreStopURL("https://coordinator-client-api.marblerun:4433/marble/terminate?type=test")
+	require.NoError(err)
+
+	response, err := (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "kubernetes.azure.com/sgx_epc_mem_in_MiB", PreStop: preStop}).mutate([]byte(rawJSON), true)
+	require.NoError(err, "failed to mutate request")
+
+	r := v1.AdmissionReview{}
+	require.NoError(json.Unmarshal(response, &r), "failed to unmarshal response with error %s", err)
+
+	var pod corev1.Pod
+	require.NoError(json.Unmarshal([]byte(gjson.Get(rawJSON, "request.object").Raw), &pod))
+	rawPod, err := json.Marshal(pod)
+	require.NoError(err)
+	patch, err := jsonpatch.DecodePatch(r.Response.Patch)
+	require.NoError(err)
+	rawPod, err = patch.Apply(rawPod)
+	require.NoError(err)
+	require.NoError(json.Unmarshal(rawPod, &pod))
+
+	// the lifecycle is created for containers without one
+	require.NotNil(pod.Spec.Containers[0].Lifecycle)
+	assert.Equal(preStop, pod.Spec.Containers[0].Lifecycle.PreStop)
+
+	// the preStop hook is added to an existing lifecycle
+	require.NotNil(pod.Spec.Containers[1].Lifecycle)
+	assert.Equal(preStop, pod.Spec.Containers[1].Lifecycle.PreStop)
+	assert.Equal([]string{"/bin/start"}, pod.Spec.Containers[1].Lifecycle.PostStart.Exec.Command)
+
+	// an existing preStop hook is kept
+	require.NotNil(pod.Spec.Containers[2].Lifecycle)
+	assert.Nil(pod.Spec.Containers[2].Lifecycle.PreStop.HTTPGet)
+	assert.Equal([]string{"/bin/stop"}, pod.Spec.Containers[2].Lifecycle.PreStop.Exec.Command)
+
+	// nothing is added if unset
+	response, err = (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "kubernetes.azure.com/sgx_epc_mem_in_MiB"}).mutate([]byte(rawJSON), true)
+	require.NoError(err, "failed to mutate request")
+	require.NoError(json.Unmarshal(response, &r), "failed to unmarshal response with error %s", err)
+	assert.NotContains(string(r.Response.Patch), "lifecycle")
+}
+
+func TestParsePreStopURL(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	preStop, err := ParsePreStopURL("https://coordinator:4433/terminate?type=test")
+	require.NoError(err)
+	assert.Equal(corev1.URISchemeHTTPS, preStop.HTTPGet.Scheme)
+	assert.Equal("coordinator", preStop.HTTPGet.Host)
+	assert.Equal(4433, preStop.HTTPGet.Port.IntValue())
+	assert.Equal("/terminate?type=test", preStop.HTTPGet.Path)
+
+	// default ports
+	preStop, err = ParsePreStopURL("http://coordinator/terminate")
+	require.NoError(err)
+	assert.Equal(corev1.URISchemeHTTP, preStop.HTTPGet.Scheme)
+	assert.Equal(80, preStop.HTTPGet.Port.IntValue())
+	preStop, err = ParsePreStopURL("https://coordinator")
+	require.NoError(err)
+	assert.Equal(443, preStop.HTTPGet.Port.IntValue())
+	assert.Equal("/", preStop.HTTPGet.Path)
+
+	_, err = ParsePreStopURL("ftp://coordinator/terminate")
+	assert.Error(err)
+	_, err = ParsePreStopURL("https:///terminate")
+	assert.Error(err)
+	_, err = ParsePreStopURL("://coordinator")
+	assert.Error(err)
+}
+
+func TestRuntimeDir(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	rawJSON := `{
+		"apiVersion": "admission.k8s.io/v1",
+		"kind": "AdmissionReview",
+		"request": {
+			"uid": "705ab4f5-6393-11e8-b7cc-42010a800002",
+			"operation": "CREATE",
+			"object": {
+				"kind": "Pod",
+				"apiVersion": "v1",
+				"metadata": {
+					"name": "testpod",
+					"namespace": "injectable",
+					"labels": {"marblerun/marbletype": "test"}
+				},
+				"spec": {
+					"containers": [
+						{"name": "default", "image": "test:image", "env": [{"name": "EDG_MARBLE_UUID_FILE", "value": "/uuid"}]}
+					]
+				}
+			}
+		}
+	}`
+	rawJSONWithVolumes := strings.Replace(rawJSON, `"containers": [`, `"volumes": [{"name": "data", "emptyDir": {}}],
+					"containers": [`, 1)
+	rawJSONWithVolumes = strings.Replace(rawJSONWithVolumes, `"image": "test:image",`, `"image": "test:image", "volumeMounts": [{"name": "data", "mountPath": "/data"}],`, 1)
+	volumeName := "runtime-dir-705ab4f5-6393-11e8-b7cc-42010a800002"
+
+	testCases := map[string]struct {
+		rawJSON     string
+		mountPatch  string
+		volumePatch string
+		sizeLimit   resource.Quantity
+	}{
+		"pod without volumes": {
+			rawJSON:     rawJSON,
+			mountPatch:  `{"op":"add","path":"/spec/containers/0/volumeMounts","value":[{"name":"` + volumeName + `","mountPath":"/graphene-tmp"}]}`,
+			volumePatch: `{"op":"add","path":"/spec/volumes","value":[{"name":"` + volumeName + `","emptyDir":{}}]}`,
+		},
+		"pod with volumes": {
+			rawJSON:     rawJSONWithVolumes,
+			mountPatch:  `{"op":"add","path":"/spec/containers/0/volumeMounts/-","value":{"name":"` + volumeName + `","mountPath":"/graphene-tmp"}}`,
+			volumePatch: `{"op":"add","path":"/spec/volumes/-","value":{"name":"` + volumeName + `","emptyDir":{"sizeLimit":"64Mi"}}}`,
+			sizeLimit:   resource.MustParse("64Mi"),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			response, err := (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "sgx.intel.com/epc", RuntimeDirPath: "/graphene-tmp", RuntimeDirSizeLimit: tc.sizeLimit}).mutate([]byte(tc.rawJSON), true)
+			require.NoError(err)
+			r := v1.AdmissionReview{}
+			require.NoError(json.Unmarshal(response, &r))
+			assert.Contains(string(r.Response.Patch), tc.mountPatch)
+			assert.Contains(string(r.Response.Patch), tc.volumePatch)
+		})
+	}
+
+	// the volume is opt-in
+	response, err := (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "sgx.intel.com/epc"}).mutate([]byte(rawJSON), true)
+	require.NoError(err)
+	r := v1.AdmissionReview{}
+	require.NoError(json.Unmarshal(response, &r))
+	assert.NotContains(string(r.Response.Patch), "runtime-dir")
+}
+
+func TestKnownMarbleTypes(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	rawJSON := `{
+		"apiVersion": "admission.k8s.io/v1",
+		"kind": "AdmissionReview",
+		"request": {
+			"uid": "705ab4f5-6393-11e8-b7cc-42010a800002",
+			"operation": "CREATE",
+			"object": {
+				"kind": "Pod",
+				"apiVersion": "v1",
+				"metadata": {
+					"name": "testpod",
+					"namespace": "injectable",
+					"labels": {"marblerun/marbletype": "test"}
+				},
+				"spec": {
+					"containers": [
+						{"name": "default", "image": "test:image"}
+					]
+				}
+			}
+		}
+	}`
+	knownMarbleTypes := map[string]bool{"test": true}
+	unknownJSON := strings.Replace(rawJSON, `"marblerun/marbletype": "test"`, `"marblerun/marbletype": "other"`, 1)
+
+	// known marble types are injected
+	response, err := (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "sgx.intel.com/epc", KnownMarbleTypes: knownMarbleTypes, DenyUnknownMarbleTypes: true}).mutate([]byte(rawJSON), true)
+	require.NoError(err)
+	r := v1.AdmissionReview{}
+	require.NoError(json.Unmarshal(response, &r))
+	assert.True(r.Response.Allowed)
+	assert.Contains(string(r.Response.Patch), `"name":"EDG_MARBLE_TYPE","value":"test"`)
+	assert.Empty(r.Response.Warnings)
+
+	// unknown marble types are admitted with a warning, but not injected
+	response, err = (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "sgx.intel.com/epc", KnownMarbleTypes: knownMarbleTypes}).mutate([]byte(unknownJSON), true)
+	require.NoError(err)
+	r = v1.AdmissionReview{}
+	require.NoError(json.Unmarshal(response, &r))
+	assert.True(r.Response.Allowed)
+	assert.Nil(r.Response.Patch)
+	assert.Equal([]string{"Unknown marble type [other], injection skipped"}, r.Response.Warnings)
+
+	// or denied
+	response, err = (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "sgx.intel.com/epc", KnownMarbleTypes: knownMarbleTypes, DenyUnknownMarbleTypes: true}).mutate([]byte(unknownJSON), true)
+	require.NoError(err)
+	r = v1.AdmissionReview{}
+	require.NoError(json.Unmarshal(response, &r))
+	assert.False(r.Response.Allowed)
+	assert.Nil(r.Response.Patch)
+	assert.EqualValues(http.StatusForbidden, r.Response.Result.Code)
+
+	// all marble types are injected without restriction
+	response, err = (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "sgx.intel.com/epc", DenyUnknownMarbleTypes: true}).mutate([]byte(unknownJSON), true)
+	require.NoError(err)
+	r = v1.AdmissionReview{}
+	require.NoError(json.Unmarshal(response, &r))
+	assert.True(r.Response.Allowed)
+	assert.Contains(string(r.Response.Patch), `"name":"EDG_MARBLE_TYPE","value":"other"`)
+}
+
+func TestStartupProbe(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	rawJSON := `{
+		"apiVersion": "admission.k8s.io/v1",
+		"kind": "AdmissionReview",
+		"request": {
+			"uid": "705ab4f5-6393-11e8-b7cc-42010a800002",
+			"operation": "CREATE",
+			"object": {
+				"kind": "Pod",
+				"apiVersion": "v1",
+				"metadata": {
+					"name": "testpod",
+					"namespace": "injectable",
+					"labels": {"marblerun/marbletype": "test"}
+				},
+				"spec": {
+					"containers": [
+						{"name": "default", "image": "test:image"},
+						{"name": "probed", "image": "test:image", "startupProbe": {"exec": {"command": ["true"]}}}
+					]
+				}
+			}
+		}
+	}`
+	startupProbe, err := ParseStartupProbe("http://:8080/healthz", 60, 5)
+	require.NoError(err)
+
+	response, err := (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "sgx.intel.com/epc", StartupProbe: startupProbe}).mutate([]byte(rawJSON), true)
+	require.NoError(err)
+	r := v1.AdmissionReview{}
+	require.NoError(json.Unmarshal(response, &r))
+	assert.Contains(string(r.Response.Patch), `{"op":"add","path":"/spec/containers/0/startupProbe","value":{"httpGet":{"path":"/healthz","port":8080,"scheme":"HTTP"},"periodSeconds":5,"failureThreshold":60}}`)
+	// an existing startup probe is not overwritten
+	assert.NotContains(string(r.Response.Patch), "/spec/containers/1/startupProbe")
+
+	// the probe is opt-in
+	response, err = (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "sgx.intel.com/epc"}).mutate([]byte(rawJSON), true)
+	require.NoError(err)
+	r = v1.AdmissionReview{}
+	require.NoError(json.Unmarshal(response, &r))
+	assert.NotContains(string(r.Response.Patch), "startupProbe")
+}
+
+func TestSGXLimitAnnotation(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	rawJSON := `{
+		"apiVersion": "admission.k8s.io/v1",
+		"kind": "AdmissionReview",
+		"request": {
+			"uid": "705ab4f5-6393-11e8-b7cc-42010a800002",
+			"operation": "CREATE",
+			"object": {
+				"kind": "Pod",
+				"apiVersion": "v1",
+				"metadata": {
+					"name": "testpod",
+					"namespace": "injectable",
+					"labels": {"marblerun/marbletype": "test"},
+					"annotations": {"marblerun/sgx-limit": "4"}
+				},
+				"spec": {
+					"containers": [
+						{"name": "default", "image": "test:image"}
+					]
+				}
+			}
+		}
+	}`
+
+	testCases := map[string]struct {
+		rawJSON  string
+		expected string
+	}{
+		"valid override":     {rawJSON: rawJSON, expected: `"4"`},
+		"missing annotation": {rawJSON: strings.Replace(rawJSON, `"annotations": {"marblerun/sgx-limit": "4"}`, `"annotations": {}`, 1), expected: `"20"`},
+		"not an integer":     {rawJSON: strings.Replace(rawJSON, `"marblerun/sgx-limit": "4"`, `"marblerun/sgx-limit": "64Mi"`, 1), expected: `"20"`},
+		"not positive":       {rawJSON: strings.Replace(rawJSON, `"marblerun/sgx-limit": "4"`, `"marblerun/sgx-limit": "0"`, 1), expected: `"20"`},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			response, err := (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "sgx.intel.com/epc", SGXQuantity: resource.MustParse("20")}).mutate([]byte(tc.rawJSON), true)
+			require.NoError(err)
+			r := v1.AdmissionReview{}
+			require.NoError(json.Unmarshal(response, &r))
+			assert.Contains(string(r.Response.Patch), `"limits":{"sgx.intel.com/epc":`+tc.expected+`}`)
+		})
+	}
+}
+
+func TestTopologySpread(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	rawJSON := `{
+		"apiVersion": "admission.k8s.io/v1",
+		"kind": "AdmissionReview",
+		"request": {
+			"uid": "705ab4f5-6393-11e8-b7cc-42010a800002",
+			"operation": "CREATE",
+			"object": {
+				"kind": "Pod",
+				"apiVersion": "v1",
+				"metadata": {
+					"name": "testpod",
+					"namespace": "injectable",
+					"labels": {"marblerun/marbletype": "test"}
+				},
+				"spec": {
+					"containers": [
+						{"name": "default", "image": "test:image"}
+					]
+				}
+			}
+		}
+	}`
+	hostnameConstraint := `"topologySpreadConstraints": [{"maxSkew": 2, "topologyKey": "kubernetes.io/hostname", "whenUnsatisfiable": "DoNotSchedule"}],
+					"containers": [`
+	zoneConstraint := strings.Replace(hostnameConstraint, "kubernetes.io/hostname", "topology.kubernetes.io/zone", 1)
+	expectedConstraint := `{"maxSkew":1,"topologyKey":"topology.kubernetes.io/zone","whenUnsatisfiable":"ScheduleAnyway","labelSelector":{"matchLabels":{"marblerun/marbletype":"test"}}}`
+
+	topologySpread, err := NewTopologySpreadConstraint("topology.kubernetes.io/zone", 1, "ScheduleAnyway")
+	require.NoError(err)
+
+	testCases := map[string]struct {
+		rawJSON     string
+		patch       string
+		constraints int
+	}{
+		"pod without constraints": {
+			rawJSON:     rawJSON,
+			patch:       `{"op":"add","path":"/spec/topologySpreadConstraints","value":[` + expectedConstraint + `]}`,
+			constraints: 1,
+		},
+		"pod with constraints": {
+			rawJSON:     strings.Replace(rawJSON, `"containers": [`, hostnameConstraint, 1),
+			patch:       `{"op":"add","path":"/spec/topologySpreadConstraints/-","value":` + expectedConstraint + `}`,
+			constraints: 2,
+		},
+		"pod with constraint for the same topology key": {
+			rawJSON:     strings.Replace(rawJSON, `"containers": [`, zoneConstraint, 1),
+			constraints: 1,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			response, err := (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "sgx.intel.com/epc", TopologySpread: topologySpread}).mutate([]byte(tc.rawJSON), true)
+			require.NoError(err)
+			r := v1.AdmissionReview{}
+			require.NoError(json.Unmarshal(response, &r))
+			if tc.patch != "" {
+				assert.Contains(string(r.Response.Patch), tc.patch)
+			} else {
+				assert.NotContains(string(r.Response.Patch), "topologySpreadConstraints")
+			}
+
+			// the patched pod is accepted as valid pod spec
+			jsonPatch, err := jsonpatch.DecodePatch(r.Response.Patch)
+			require.NoError(err)
+			var review v1.AdmissionReview
+			require.NoError(json.Unmarshal([]byte(tc.rawJSON), &review))
+			patched, err := jsonPatch.Apply(review.Request.Object.Raw)
+			require.NoError(err)
+			var pod corev1.Pod
+			require.NoError(json.Unmarshal(patched, &pod))
+			assert.Len(pod.Spec.TopologySpreadConstraints, tc.constraints)
+		})
+	}
+
+	// the constraint is opt-in
+	response, err := (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "sgx.intel.com/epc"}).mutate([]byte(rawJSON), true)
+	require.NoError(err)
+	r := v1.AdmissionReview{}
+	require.NoError(json.Unmarshal(response, &r))
+	assert.NotContains(string(r.Response.Patch), "topologySpreadConstraints")
+}
+
+func TestSidecar(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	rawJSON := `{
+		"apiVersion": "admission.k8s.io/v1",
+		"kind": "AdmissionReview",
+		"request": {
+			"uid": "705ab4f5-6393-11e8-b7cc-42010a800002",
+			"operation": "CREATE",
+			"object": {
+				"kind": "Pod",
+				"apiVersion": "v1",
+				"metadata": {
+					"name": "testpod",
+					"namespace": "injectable",
+					"labels": {"marblerun/marbletype": "test"}
+				},
+				"spec": {
+					"containers": [
+						{"name": "default", "image": "test:image"}
+					]
+				}
+			}
+		}
+	}`
+	sidecar := &corev1.Container{
+		Name:         "log-shipper",
+		Image:        "fluent/fluent-bit:1.7",
+		Args:         []string{"-c", "/fluent-bit/etc/fluent-bit.conf"},
+		VolumeMounts: []corev1.VolumeMount{{Name: "logs", MountPath: "/var/log/marble", ReadOnly: true}},
+	}
+
+	applyPatch := func(rawJSON string, sidecar *corev1.Container) (string, corev1.Pod) {
+		response, err := (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "sgx.intel.com/epc", SetSGXRequests: true, Sidecar: sidecar}).mutate([]byte(rawJSON), true)
+		require.NoError(err)
+		r := v1.AdmissionReview{}
+		require.NoError(json.Unmarshal(response, &r))
+
+		jsonPatch, err := jsonpatch.DecodePatch(r.Response.Patch)
+		require.NoError(err)
+		var review v1.AdmissionReview
+		require.NoError(json.Unmarshal([]byte(rawJSON), &review))
+		patched, err := jsonPatch.Apply(review.Request.Object.Raw)
+		require.NoError(err)
+		var pod corev1.Pod
+		require.NoError(json.Unmarshal(patched, &pod))
+		return string(r.Response.Patch), pod
+	}
+
+	// the sidecar is appended without sgx resources and marble environment
+	patch, pod := applyPatch(rawJSON, sidecar)
+	assert.Contains(patch, `{"op":"add","path":"/spec/containers/-","value":{"name":"log-shipper","image":"fluent/fluent-bit:1.7","args":["-c","/fluent-bit/etc/fluent-bit.conf"]`)
+	require.Len(pod.Spec.Containers, 2)
+	assert.Equal("default", pod.Spec.Containers[0].Name)
+	assert.Contains(pod.Spec.Containers[0].Resources.Limits, corev1.ResourceName("sgx.intel.com/epc"))
+	assert.Contains(pod.Spec.Containers[0].Resources.Requests, corev1.ResourceName("sgx.intel.com/epc"))
+	assert.Equal(*sidecar, pod.Spec.Containers[1])
+	assert.Empty(pod.Spec.Containers[1].Resources.Limits)
+	assert.Empty(pod.Spec.Containers[1].Resources.Requests)
+	assert.Empty(pod.Spec.Containers[1].Env)
+
+	// the tolerations of the pod are injected as usual
+	require.Len(pod.Spec.Tolerations, 1)
+	assert.Equal("sgx.intel.com/epc", pod.Spec.Tolerations[0].Key)
+
+	// a container with the name of the sidecar is not replaced
+	_, pod = applyPatch(strings.Replace(rawJSON, `"name": "default"`, `"name": "log-shipper"`, 1), sidecar)
+	require.Len(pod.Spec.Containers, 1)
+	assert.Equal("test:image", pod.Spec.Containers[0].Image)
+
+	// the containers array is created if needed
+	_, pod = applyPatch(strings.Replace(rawJSON, `{"name": "default", "image": "test:image"}`, "", 1), sidecar)
+	require.Len(pod.Spec.Containers, 1)
+	assert.Equal(*sidecar, pod.Spec.Containers[0])
+
+	// the sidecar is opt-in
+	patch, pod = applyPatch(rawJSON, nil)
+	assert.NotContains(patch, "log-shipper")
+	assert.Len(pod.Spec.Containers, 1)
+}
+
+func TestContainerMarbleTypes(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	rawJSON := `{
+		"apiVersion": "admission.k8s.io/v1",
+		"kind": "AdmissionReview",
+		"request": {
+			"uid": "705ab4f5-6393-11e8-b7cc-42010a800002",
+			"operation": "CREATE",
+			"object": {
+				"kind": "Pod",
+				"apiVersion": "v1",
+				"metadata": {
+					"name": "testpod",
+					"namespace": "injectable",
+					"labels": {"marblerun/marbletype": "frontend"},
+					"annotations": {"marblerun/container-marbletypes": "api=backend"}
+				},
+				"spec": {
+					"containers": [
+						{"name": "web", "image": "web:image"},
+						{"name": "api", "image": "api:image"}
+					]
+				}
+			}
+		}
+	}`
+
+	mutatePod := func(rawJSON string, knownMarbleTypes map[string]bool) (v1.AdmissionReview, corev1.Pod) {
+		response, err := (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "sgx.intel.com/epc", KnownMarbleTypes: knownMarbleTypes, DenyUnknownMarbleTypes: true}).mutate([]byte(rawJSON), true)
+		require.NoError(err)
+		r := v1.AdmissionReview{}
+		require.NoError(json.Unmarshal(response, &r))
+		if r.Response.Patch == nil {
+			return r, corev1.Pod{}
+		}
+
+		jsonPatch, err := jsonpatch.DecodePatch(r.Response.Patch)
+		require.NoError(err)
+		var review v1.AdmissionReview
+		require.NoError(json.Unmarshal([]byte(rawJSON), &review))
+		patched, err := jsonPatch.Apply(review.Request.Object.Raw)
+		require.NoError(err)
+		var pod corev1.Pod
+		require.NoError(json.Unmarshal(patched, &pod))
+		return r, pod
+	}
+	getEnv := func(container corev1.Container, name string) string {
+		for _, env := range container.Env {
+			if env.Name == name {
+				return env.Value
+			}
+		}
+		return ""
+	}
+
+	// each container gets the environment of its marble type
+	_, pod := mutatePod(rawJSON, nil)
+	require.Len(pod.Spec.Containers, 2)
+	web, api := pod.Spec.Containers[0], pod.Spec.Containers[1]
+	assert.Equal("frontend", getEnv(web, "EDG_MARBLE_TYPE"))
+	assert.Equal("backend", getEnv(api, "EDG_MARBLE_TYPE"))
+	assert.Equal("frontend,frontend.injectable,frontend.injectable.svc.cluster.local", getEnv(web, "EDG_MARBLE_DNS_NAMES"))
+	assert.Equal("backend,backend.injectable,backend.injectable.svc.cluster.local", getEnv(api, "EDG_MARBLE_DNS_NAMES"))
+	assert.Equal("/frontend-uid/uuid-file", getEnv(web, "EDG_MARBLE_UUID_FILE"))
+	assert.Equal("/backend-uid/uuid-file", getEnv(api, "EDG_MARBLE_UUID_FILE"))
+	assert.Len(web.Env, 4)
+	assert.Len(api.Env, 4)
+
+	// the marbles share the UUID volume, but not their UUID files
+	require.Len(pod.Spec.Volumes, 1)
+	require.Len(web.VolumeMounts, 1)
+	require.Len(api.VolumeMounts, 1)
+	assert.Equal(pod.Spec.Volumes[0].Name, web.VolumeMounts[0].Name)
+	assert.Equal(pod.Spec.Volumes[0].Name, api.VolumeMounts[0].Name)
+	assert.Equal("/frontend-uid", web.VolumeMounts[0].MountPath)
+	assert.Equal("/backend-uid", api.VolumeMounts[0].MountPath)
+	assert.Equal("frontend", web.VolumeMounts[0].SubPath)
+	assert.Equal("backend", api.VolumeMounts[0].SubPath)
+
+	// each marble type gets its own UUID from an annotation
+	items := pod.Spec.Volumes[0].DownwardAPI.Items
+	require.Len(items, 2)
+	assert.Equal("frontend/uuid-file", items[0].Path)
+	assert.Equal("metadata.annotations['marblerun/uuid-0']", items[0].FieldRef.FieldPath)
+	assert.Equal("backend/uuid-file", items[1].Path)
+	assert.Equal("metadata.annotations['marblerun/uuid-1']", items[1].FieldRef.FieldPath)
+	frontendUUID, err := uuid.Parse(pod.Annotations["marblerun/uuid-0"])
+	require.NoError(err)
+	backendUUID, err := uuid.Parse(pod.Annotations["marblerun/uuid-1"])
+	require.NoError(err)
+	assert.NotEqual(frontendUUID, backendUUID)
+
+	// both marble types must be known
+	_, pod = mutatePod(rawJSON, map[string]bool{"frontend": true, "backend": true})
+	assert.Len(pod.Spec.Volumes, 1)
+	r, _ := mutatePod(rawJSON, map[string]bool{"frontend": true})
+	assert.False(r.Response.Allowed)
+	assert.Equal("Unknown marble type [backend], pod denied", r.Response.Result.Message)
+
+	// containers must exist
+	_, err = (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "sgx.intel.com/epc"}).mutate([]byte(strings.Replace(rawJSON, "api=backend", "db=backend", 1)), true)
+	assert.Error(err)
+}
+
+func TestMaxEnvSize(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	rawJSON := `{
+		"apiVersion": "admission.k8s.io/v1",
+		"kind": "AdmissionReview",
+		"request": {
+			"uid": "705ab4f5-6393-11e8-b7cc-42010a800002",
+			"operation": "CREATE",
+			"object": {
+				"kind": "Pod",
+				"apiVersion": "v1",
+				"metadata": {
+					"name": "testpod",
+					"namespace": "injectable",
+					"labels": {"marblerun/marbletype": "test"}
+				},
+				"spec": {
+					"containers": [
+						{"name": "default", "image": "test:image"}
+					]
+				}
+			}
+		}
+	}`
+	// the cluster domain is part of the injected DNS names
+	oversizedDomain := strings.Repeat("a", 1024) + ".local"
+	message := "Environment injected into container [default] has 1219 bytes, exceeding the limit of 1024 bytes"
+
+	testCases := map[string]struct {
+		domain  string
+		deny    bool
+		allowed bool
+		warning bool
+	}{
+		"env within limit": {
+			domain:  "cluster.local",
+			allowed: true,
+		},
+		"oversized env warns": {
+			domain:  oversizedDomain,
+			allowed: true,
+			warning: true,
+		},
+		"oversized env is denied": {
+			domain: oversizedDomain,
+			deny:   true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			response, err := (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: tc.domain, SGXResource: "sgx.intel.com/epc", MaxEnvSize: 1024, DenyOversizedEnv: tc.deny}).mutate([]byte(rawJSON), true)
+			require.NoError(err)
+			r := v1.AdmissionReview{}
+			require.NoError(json.Unmarshal(response, &r))
+			assert.Equal(tc.allowed, r.Response.Allowed)
+
+			if tc.allowed {
+				assert.Contains(string(r.Response.Patch), "EDG_MARBLE_DNS_NAMES")
+			} else {
+				assert.Nil(r.Response.Patch)
+				assert.Nil(r.Response.PatchType)
+				assert.Equal(message, r.Response.Result.Message)
+				assert.EqualValues(http.StatusUnprocessableEntity, r.Response.Result.Code)
+			}
+			if tc.warning {
+				assert.Equal([]string{message}, r.Response.Warnings)
+			} else {
+				assert.Empty(r.Response.Warnings)
+			}
+		})
+	}
+
+	// variables set by the container are not injected and do not count
+	rawJSON = strings.Replace(rawJSON, `"image": "test:image"`, `"image": "test:image", "env": [{"name": "EDG_MARBLE_DNS_NAMES", "value": "test"}]`, 1)
+	response, err := (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: oversizedDomain, SGXResource: "sgx.intel.com/epc", MaxEnvSize: 1024, DenyOversizedEnv: true}).mutate([]byte(rawJSON), true)
+	require.NoError(err)
+	r := v1.AdmissionReview{}
+	require.NoError(json.Unmarshal(response, &r))
+	assert.True(r.Response.Allowed)
+}
+
+func TestSkipEnvContainers(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	rawJSON := `{
+		"apiVersion": "admission.k8s.io/v1",
+		"kind": "AdmissionReview",
+		"request": {
+			"uid": "705ab4f5-6393-11e8-b7cc-42010a800002",
+			"operation": "CREATE",
+			"object": {
+				"kind": "Pod",
+				"apiVersion": "v1",
+				"metadata": {
+					"name": "testpod",
+					"namespace": "injectable",
+					"labels": {"marblerun/marbletype": "test"},
+					"annotations": {"marblerun/skip-env-containers": "fluentd", "marblerun/shm-size": "64Mi"}
+				},
+				"spec": {
+					"containers": [
+						{"name": "enclave", "image": "test:image"},
+						{"name": "fluentd", "image": "fluentd:image"}
+					]
+				}
+			}
+		}
+	}`
+
+	response, err := (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "sgx.intel.com/epc"}).mutate([]byte(rawJSON), true)
+	require.NoError(err)
+	r := v1.AdmissionReview{}
+	require.NoError(json.Unmarshal(response, &r))
+	assert.True(r.Response.Allowed)
+	assert.NotContains(string(r.Response.Patch), "/spec/containers/1/")
+
+	jsonPatch, err := jsonpatch.DecodePatch(r.Response.Patch)
+	require.NoError(err)
+	var review v1.AdmissionReview
+	require.NoError(json.Unmarshal([]byte(rawJSON), &review))
+	patched, err := jsonPatch.Apply(review.Request.Object.Raw)
+	require.NoError(err)
+	var pod corev1.Pod
+	require.NoError(json.Unmarshal(patched, &pod))
+
+	// the enclave container is injected
+	require.Len(pod.Spec.Containers, 2)
+	enclave, fluentd := pod.Spec.Containers[0], pod.Spec.Containers[1]
+	assert.Len(enclave.Env, 4)
+	assert.Len(enclave.VolumeMounts, 2)
+	assert.Contains(enclave.Resources.Limits, corev1.ResourceName("sgx.intel.com/epc"))
+
+	// the excluded container is left as it is
+	assert.Empty(fluentd.Env)
+	assert.Empty(fluentd.VolumeMounts)
+	assert.Empty(fluentd.Resources.Limits)
+
+	// the pod level injection is not affected
+	assert.Len(pod.Spec.Volumes, 2)
+	assert.Len(pod.Spec.Tolerations, 1)
+}
+
+func TestDeviceResources(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	devices := []DeviceResource{
+		{ResourceKey: "nvidia.com/gpu", Quantity: resource.MustParse("1"), Toleration: true},
+		{ResourceKey: "example.com/fpga", Quantity: resource.MustParse("2")},
+	}
+
+	testCases := map[string]struct {
+		resources       string
+		tolerations     string
+		wantLimits      map[string]string
+		wantTolerations []string
+	}{
+		"no resources or tolerations": {
+			wantLimits:      map[string]string{"sgx.intel.com/epc": "10", "nvidia.com/gpu": "1", "example.com/fpga": "2"},
+			wantTolerations: []string{"sgx.intel.com/epc", "nvidia.com/gpu"},
+		},
+		"existing resources and tolerations": {
+			resources:       `, "resources": {"limits": {"cpu": "1", "nvidia.com/gpu": "2"}}`,
+			tolerations:     `, "tolerations": [{"key": "node.kubernetes.io/not-ready", "operator": "Exists", "effect": "NoExecute"}]`,
+			wantLimits:      map[string]string{"cpu": "1", "sgx.intel.com/epc": "10", "nvidia.com/gpu": "2", "example.com/fpga": "2"},
+			wantTolerations: []string{"node.kubernetes.io/not-ready", "sgx.intel.com/epc", "nvidia.com/gpu"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			rawJSON := `{
+				"apiVersion": "admission.k8s.io/v1",
+				"kind": "AdmissionReview",
+				"request": {
+					"uid": "705ab4f5-6393-11e8-b7cc-42010a800002",
+					"operation": "CREATE",
+					"object": {
+						"kind": "Pod",
+						"apiVersion": "v1",
+						"metadata": {
+							"name": "testpod",
+							"namespace": "injectable",
+							"labels": {"marblerun/marbletype": "test"}
+						},
+						"spec": {
+							"containers": [{"name": "enclave", "image": "test:image"` + tc.resources + `}]` + tc.tolerations + `
+						}
+					}
+				}
+			}`
+
+			response, err := (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "sgx.intel.com/epc", DeviceResources: devices}).mutate([]byte(rawJSON), true)
+			require.NoError(err)
+			r := v1.AdmissionReview{}
+			require.NoError(json.Unmarshal(response, &r))
+
+			jsonPatch, err := jsonpatch.DecodePatch(r.Response.Patch)
+			require.NoError(err)
+			var review v1.AdmissionReview
+			require.NoError(json.Unmarshal([]byte(rawJSON), &review))
+			patched, err := jsonPatch.Apply(review.Request.Object.Raw)
+			require.NoError(err)
+			var pod corev1.Pod
+			require.NoError(json.Unmarshal(patched, &pod))
+
+			limits := pod.Spec.Containers[0].Resources.Limits
+			require.Len(limits, len(tc.wantLimits))
+			for name, quantity := range tc.wantLimits {
+				assert.True(resource.MustParse(quantity).Equal(limits[corev1.ResourceName(name)]), "unexpected limit of %s", name)
+			}
+
+			require.Len(pod.Spec.Tolerations, len(tc.wantTolerations))
+			for idx, key := range tc.wantTolerations {
+				assert.Equal(key, pod.Spec.Tolerations[idx].Key)
+			}
+		})
+	}
+
+	// device resources are not injected into pods without sgx injection
+	rawJSON := `{
+		"apiVersion": "admission.k8s.io/v1",
+		"kind": "AdmissionReview",
+		"request": {
+			"uid": "705ab4f5-6393-11e8-b7cc-42010a800002",
+			"operation": "CREATE",
+			"object": {
+				"kind": "Pod",
+				"apiVersion": "v1",
+				"metadata": {"name": "testpod", "namespace": "injectable", "labels": {"marblerun/marbletype": "test"}},
+				"spec": {"containers": [{"name": "enclave", "image": "test:image"}]}
+			}
+		}
+	}`
+	response, err := (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "sgx.intel.com/epc", DeviceResources: devices}).mutate([]byte(rawJSON), false)
+	require.NoError(err)
+	r := v1.AdmissionReview{}
+	require.NoError(json.Unmarshal(response, &r))
+	assert.NotContains(string(r.Response.Patch), "nvidia.com")
+	assert.NotContains(string(r.Response.Patch), "/spec/tolerations")
+}
+
+func TestImagePullSecret(t *testing.T) {
+	testCases := map[string]struct {
+		pullSecrets string
+		want        []string
+	}{
+		"no pull secrets": {
+			want: []string{"enclave-registry"},
+		},
+		"existing pull secret": {
+			pullSecrets: `, "imagePullSecrets": [{"name": "other-registry"}]`,
+			want:        []string{"other-registry", "enclave-registry"},
+		},
+		"secret already referenced": {
+			pullSecrets: `, "imagePullSecrets": [{"name": "enclave-registry"}]`,
+			want:        []string{"enclave-registry"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			rawJSON := `{
+				"apiVersion": "admission.k8s.io/v1",
+				"kind": "AdmissionReview",
+				"request": {
+					"uid": "705ab4f5-6393-11e8-b7cc-42010a800002",
+					"operation": "CREATE",
+					"object": {
+						"kind": "Pod",
+						"apiVersion": "v1",
+						"metadata": {"name": "testpod", "namespace": "injectable", "labels": {"marblerun/marbletype": "test"}},
+						"spec": {"containers": [{"name": "enclave", "image": "registry.example.com/enclave:latest"}]` + tc.pullSecrets + `}
+					}
+				}
+			}`
+
+			response, err := (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "sgx.intel.com/epc", ImagePullSecret: "enclave-registry"}).mutate([]byte(rawJSON), true)
+			require.NoError(err)
+			r := v1.AdmissionReview{}
+			require.NoError(json.Unmarshal(response, &r))
+
+			jsonPatch, err := jsonpatch.DecodePatch(r.Response.Patch)
+			require.NoError(err)
+			var review v1.AdmissionReview
+			require.NoError(json.Unmarshal([]byte(rawJSON), &review))
+			patched, err := jsonPatch.Apply(review.Request.Object.Raw)
+			require.NoError(err)
+			var pod corev1.Pod
+			require.NoError(json.Unmarshal(patched, &pod))
+
+			require.Len(pod.Spec.ImagePullSecrets, len(tc.want))
+			for idx, name := range tc.want {
+				assert.Equal(name, pod.Spec.ImagePullSecrets[idx].Name)
+			}
+		})
+	}
+}
+
+func TestServiceAccountToken(t *testing.T) {
+	testCases := map[string]struct {
+		mounts    string
+		wantMount bool
+	}{
+		"token is mounted": {
+			wantMount: true,
+		},
+		"mount path already set": {
+			mounts: `, "volumeMounts": [{"name": "own-token", "mountPath": "/var/run/secrets/tokens"}]`,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			rawJSON := `{
+				"apiVersion": "admission.k8s.io/v1",
+				"kind": "AdmissionReview",
+				"request": {
+					"uid": "705ab4f5-6393-11e8-b7cc-42010a800002",
+					"operation": "CREATE",
+					"object": {
+						"kind": "Pod",
+						"apiVersion": "v1",
+						"metadata": {"name": "testpod", "namespace": "injectable", "labels": {"marblerun/marbletype": "test"}},
+						"spec": {
+							"containers": [{"name": "enclave", "image": "enclave:latest"` + tc.mounts + `}],
+							"volumes": [{"name": "own-token", "emptyDir": {}}]
+						}
+					}
+				}
+			}`
+
+			token, err := NewServiceAccountToken("https://vault.example.com", 3600, "/var/run/secrets/tokens")
+			require.NoError(err)
+			response, err := (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "sgx.intel.com/epc", ServiceAccountToken: token}).mutate([]byte(rawJSON), true)
+			require.NoError(err)
+			r := v1.AdmissionReview{}
+			require.NoError(json.Unmarshal(response, &r))
+
+			jsonPatch, err := jsonpatch.DecodePatch(r.Response.Patch)
+			require.NoError(err)
+			var review v1.AdmissionReview
+			require.NoError(json.Unmarshal([]byte(rawJSON), &review))
+			patched, err := jsonPatch.Apply(review.Request.Object.Raw)
+			require.NoError(err)
+			var pod corev1.Pod
+			require.NoError(json.Unmarshal(patched, &pod))
+
+			volumeName := volumeName("sa-token", review.Request.UID)
+			var tokenVolume *corev1.Volume
+			for idx, volume := range pod.Spec.Volumes {
+				if volume.Name == volumeName {
+					tokenVolume = &pod.Spec.Volumes[idx]
+				}
+			}
+			require.NotNil(tokenVolume)
+			require.NotNil(tokenVolume.Projected)
+			require.Len(tokenVolume.Projected.Sources, 1)
+			projection := tokenVolume.Projected.Sources[0].ServiceAccountToken
+			require.NotNil(projection)
+			assert.Equal("https://vault.example.com", projection.Audience)
+			assert.Equal(int64(3600), *projection.ExpirationSeconds)
+			assert.Equal("token", projection.Path)
+
+			var tokenMounts []corev1.VolumeMount
+			for _, mount := range pod.Spec.Containers[0].VolumeMounts {
+				if mount.MountPath == "/var/run/secrets/tokens" {
+					tokenMounts = append(tokenMounts, mount)
+				}
+			}
+			require.Len(tokenMounts, 1)
+			if tc.wantMount {
+				assert.Equal(volumeName, tokenMounts[0].Name)
+				assert.True(tokenMounts[0].ReadOnly)
+			} else {
+				assert.Equal("own-token", tokenMounts[0].Name)
+			}
+		})
+	}
+}
+
+func TestNewServiceAccountToken(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := NewServiceAccountToken("vault", 600, "/var/run/secrets/tokens")
+	assert.NoError(err)
+	_, err = NewServiceAccountToken("", 3600, "/var/run/secrets/tokens")
+	assert.Error(err)
+	_, err = NewServiceAccountToken("vault", 599, "/var/run/secrets/tokens")
+	assert.Error(err)
+	_, err = NewServiceAccountToken("vault", 3600, "tokens")
+	assert.Error(err)
+}
+
+func TestFSGroup(t *testing.T) {
+	testCases := map[string]struct {
+		securityContext string
+		wantFSGroup     int64
+		wantRunAsUser   *int64
+	}{
+		"no security context": {
+			wantFSGroup: 2000,
+		},
+		"security context without fsGroup": {
+			securityContext: `, "securityContext": {"runAsUser": 1000}`,
+			wantFSGroup:     2000,
+			wantRunAsUser:   func() *int64 { user := int64(1000); return &user }(),
+		},
+		"existing fsGroup": {
+			securityContext: `, "securityContext": {"fsGroup": 3000}`,
+			wantFSGroup:     3000,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			rawJSON := `{
+				"apiVersion": "admission.k8s.io/v1",
+				"kind": "AdmissionReview",
+				"request": {
+					"uid": "705ab4f5-6393-11e8-b7cc-42010a800002",
+					"operation": "CREATE",
+					"object": {
+						"kind": "Pod",
+						"apiVersion": "v1",
+						"metadata": {"name": "testpod", "namespace": "injectable", "labels": {"marblerun/marbletype": "test"}},
+						"spec": {"containers": [{"name": "enclave", "image": "enclave:latest"}]` + tc.securityContext + `}
+					}
+				}
+			}`
+
+			fsGroup := int64(2000)
+			response, err := (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "sgx.intel.com/epc", SafePatches: true, FSGroup: &fsGroup}).mutate([]byte(rawJSON), true)
+			require.NoError(err)
+			r := v1.AdmissionReview{}
+			require.NoError(json.Unmarshal(response, &r))
+
+			jsonPatch, err := jsonpatch.DecodePatch(r.Response.Patch)
+			require.NoError(err)
+			var review v1.AdmissionReview
+			require.NoError(json.Unmarshal([]byte(rawJSON), &review))
+			patched, err := jsonPatch.Apply(review.Request.Object.Raw)
+			require.NoError(err)
+			var pod corev1.Pod
+			require.NoError(json.Unmarshal(patched, &pod))
+
+			require.NotNil(pod.Spec.SecurityContext)
+			require.NotNil(pod.Spec.SecurityContext.FSGroup)
+			assert.Equal(tc.wantFSGroup, *pod.Spec.SecurityContext.FSGroup)
+			assert.Equal(tc.wantRunAsUser, pod.Spec.SecurityContext.RunAsUser)
+		})
+	}
+}
+
+func TestDNSConfig(t *testing.T) {
+	ndots := "2"
+	testCases := map[string]struct {
+		dnsConfig string
+		want      corev1.PodDNSConfig
+	}{
+		"no dns config": {
+			want: corev1.PodDNSConfig{
+				Options:  []corev1.PodDNSConfigOption{{Name: "ndots", Value: &ndots}, {Name: "single-request"}},
+				Searches: []string{"marblerun.svc.cluster.local"},
+			},
+		},
+		"existing dns config": {
+			dnsConfig: `, "dnsConfig": {"nameservers": ["1.2.3.4"]}`,
+			want:      corev1.PodDNSConfig{Nameservers: []string{"1.2.3.4"}},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			rawJSON := `{
+				"apiVersion": "admission.k8s.io/v1",
+				"kind": "AdmissionReview",
+				"request": {
+					"uid": "705ab4f5-6393-11e8-b7cc-42010a800002",
+					"operation": "CREATE",
+					"object": {
+						"kind": "Pod",
+						"apiVersion": "v1",
+						"metadata": {"name": "testpod", "namespace": "injectable", "labels": {"marblerun/marbletype": "test"}},
+						"spec": {"containers": [{"name": "enclave", "image": "enclave:latest"}]` + tc.dnsConfig + `}
+					}
+				}
+			}`
+
+			dnsConfig, err := ParseDNSConfig("ndots:2,single-request", "marblerun.svc.cluster.local")
+			require.NoError(err)
+			response, err := (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "sgx.intel.com/epc", SafePatches: true, DNSConfig: dnsConfig}).mutate([]byte(rawJSON), true)
+			require.NoError(err)
+			r := v1.AdmissionReview{}
+			require.NoError(json.Unmarshal(response, &r))
+
+			jsonPatch, err := jsonpatch.DecodePatch(r.Response.Patch)
+			require.NoError(err)
+			var review v1.AdmissionReview
+			require.NoError(json.Unmarshal([]byte(rawJSON), &review))
+			patched, err := jsonPatch.Apply(review.Request.Object.Raw)
+			require.NoError(err)
+			var pod corev1.Pod
+			require.NoError(json.Unmarshal(patched, &pod))
+
+			require.NotNil(pod.Spec.DNSConfig)
+			assert.Equal(tc.want, *pod.Spec.DNSConfig)
+		})
+	}
+}
+
+func TestUUIDEnv(t *testing.T) {
+	testCases := map[string]struct {
+		uuidEnv        bool
+		skipUUIDFile   bool
+		containerTypes string
+		wantEnv        bool
+		wantFile       bool
+	}{
+		"file only": {
+			wantFile: true,
+		},
+		"env and file": {
+			uuidEnv:  true,
+			wantEnv:  true,
+			wantFile: true,
+		},
+		"env only": {
+			uuidEnv:      true,
+			skipUUIDFile: true,
+			wantEnv:      true,
+		},
+		"marbles of different types don't share the pod UID": {
+			uuidEnv:        true,
+			skipUUIDFile:   true,
+			containerTypes: `, "annotations": {"marblerun/container-marbletypes": "sidecar=other"}`,
+			wantFile:       true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			rawJSON := `{
+				"apiVersion": "admission.k8s.io/v1",
+				"kind": "AdmissionReview",
+				"request": {
+					"uid": "705ab4f5-6393-11e8-b7cc-42010a800002",
+					"operation": "CREATE",
+					"object": {
+						"kind": "Pod",
+						"apiVersion": "v1",
+						"metadata": {"name": "testpod", "namespace": "injectable", "labels": {"marblerun/marbletype": "test"}` + tc.containerTypes + `},
+						"spec": {
+							"containers": [{"name": "enclave", "image": "enclave:latest"}, {"name": "sidecar", "image": "sidecar:latest"}]
+						}
+					}
+				}
+			}`
+
+			response, err := (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "sgx.intel.com/epc", UUIDEnv: tc.uuidEnv, SkipUUIDFile: tc.skipUUIDFile}).mutate([]byte(rawJSON), true)
+			require.NoError(err)
+			r := v1.AdmissionReview{}
+			require.NoError(json.Unmarshal(response, &r))
+
+			jsonPatch, err := jsonpatch.DecodePatch(r.Response.Patch)
+			require.NoError(err)
+			var review v1.AdmissionReview
+			require.NoError(json.Unmarshal([]byte(rawJSON), &review))
+			patched, err := jsonPatch.Apply(review.Request.Object.Raw)
+			require.NoError(err)
+			var pod corev1.Pod
+			require.NoError(json.Unmarshal(patched, &pod))
+
+			for _, container := range pod.Spec.Containers {
+				var uuidEnv, uuidFileEnv *corev1.EnvVar
+				for idx, env := range container.Env {
+					switch env.Name {
+					case "EDG_MARBLE_UUID":
+						uuidEnv = &container.Env[idx]
+					case "EDG_MARBLE_UUID_FILE":
+						uuidFileEnv = &container.Env[idx]
+					}
+				}
+				if tc.wantEnv {
+					require.NotNil(uuidEnv, container.Name)
+					assert.Empty(uuidEnv.Value)
+					require.NotNil(uuidEnv.ValueFrom)
+					require.NotNil(uuidEnv.ValueFrom.FieldRef)
+					assert.Equal("metadata.uid", uuidEnv.ValueFrom.FieldRef.FieldPath)
+				} else {
+					assert.Nil(uuidEnv, container.Name)
+				}
+				assert.Equal(tc.wantFile, uuidFileEnv != nil, container.Name)
+				assert.Equal(tc.wantFile, len(container.VolumeMounts) > 0, container.Name)
+			}
+			assert.Equal(tc.wantFile, volumeIsSet(pod.Spec.Volumes, volumeName("uuid-file", review.Request.UID)))
+		})
+	}
+}
+
+func TestMarbleAnnotations(t *testing.T) {
+	testCases := map[string]struct {
+		annotations     string
+		wantAnnotations map[string]string
+	}{
+		"pod without annotations": {
+			wantAnnotations: map[string]string{"example.com/cost-center": "1234", "team": "payments"},
+		},
+		"existing annotations are kept": {
+			annotations:     `, "annotations": {"team": "platform", "other": "value"}`,
+			wantAnnotations: map[string]string{"example.com/cost-center": "1234", "team": "platform", "other": "value"},
+		},
+	}
+
+	marbleAnnotations := map[string]map[string]string{
+		"test":  {"example.com/cost-center": "1234", "team": "payments"},
+		"other": {"unrelated": "value"},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			rawJSON := `{
+				"apiVersion": "admission.k8s.io/v1",
+				"kind": "AdmissionReview",
+				"request": {
+					"uid": "705ab4f5-6393-11e8-b7cc-42010a800002",
+					"operation": "CREATE",
+					"object": {
+						"kind": "Pod",
+						"apiVersion": "v1",
+						"metadata": {"name": "testpod", "namespace": "injectable", "labels": {"marblerun/marbletype": "test"}` + tc.annotations + `},
+						"spec": {
+							"containers": [{"name": "enclave", "image": "enclave:latest"}]
+						}
+					}
+				}
+			}`
+
+			response, err := (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "sgx.intel.com/epc", MarbleAnnotations: marbleAnnotations}).mutate([]byte(rawJSON), true)
+			require.NoError(err)
+			r := v1.AdmissionReview{}
+			require.NoError(json.Unmarshal(response, &r))
+
+			jsonPatch, err := jsonpatch.DecodePatch(r.Response.Patch)
+			require.NoError(err)
+			var review v1.AdmissionReview
+			require.NoError(json.Unmarshal([]byte(rawJSON), &review))
+			patched, err := jsonPatch.Apply(review.Request.Object.Raw)
+			require.NoError(err)
+			var pod corev1.Pod
+			require.NoError(json.Unmarshal(patched, &pod))
+
+			assert.Equal(tc.wantAnnotations, pod.Annotations)
+		})
+	}
+}
+
+func TestRestrictedSecurityContext(t *testing.T) {
+	testCases := map[string]struct {
+		podSecurityContext       string
+		containerSecurityContext string
+		fsGroup                  *int64
+		wantRunAsUser            *int64
+		wantFSGroup              *int64
+		wantDrop                 []corev1.Capability
+		wantAllowPrivEscalation  bool
+	}{
+		"no security contexts": {
+			wantDrop: []corev1.Capability{"ALL"},
+		},
+		"fsGroup": {
+			fsGroup:     func() *int64 { group := int64(2000); return &group }(),
+			wantFSGroup: func() *int64 { group := int64(2000); return &group }(),
+			wantDrop:    []corev1.Capability{"ALL"},
+		},
+		"existing security contexts": {
+			podSecurityContext:       `, "securityContext": {"runAsUser": 1000}`,
+			containerSecurityContext: `, "securityContext": {"readOnlyRootFilesystem": true}`,
+			wantRunAsUser:            func() *int64 { user := int64(1000); return &user }(),
+			wantDrop:                 []corev1.Capability{"ALL"},
+		},
+		"existing capabilities": {
+			containerSecurityContext: `, "securityContext": {"capabilities": {"add": ["NET_BIND_SERVICE"]}}`,
+			wantDrop:                 []corev1.Capability{"ALL"},
+		},
+		"existing dropped capabilities": {
+			containerSecurityContext: `, "securityContext": {"capabilities": {"drop": ["NET_RAW"]}}`,
+			wantDrop:                 []corev1.Capability{"NET_RAW", "ALL"},
+		},
+		"privilege escalation set by the container": {
+			containerSecurityContext: `, "securityContext": {"allowPrivilegeEscalation": true, "capabilities": {"drop": ["ALL"]}}`,
+			wantDrop:                 []corev1.Capability{"ALL"},
+			wantAllowPrivEscalation:  true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			rawJSON := `{
+				"apiVersion": "admission.k8s.io/v1",
+				"kind": "AdmissionReview",
+				"request": {
+					"uid": "705ab4f5-6393-11e8-b7cc-42010a800002",
+					"operation": "CREATE",
+					"object": {
+						"kind": "Pod",
+						"apiVersion": "v1",
+						"metadata": {"name": "testpod", "namespace": "injectable", "labels": {"marblerun/marbletype": "test"}},
+						"spec": {"containers": [{"name": "enclave", "image": "enclave:latest"` + tc.containerSecurityContext + `}]` + tc.podSecurityContext + `}
+					}
+				}
+			}`
+
+			response, err := (&Mutator{CoordAddr: "coordinator-mesh-api.marblerun:2001", DomainName: "cluster.local", SGXResource: "sgx.intel.com/epc", SafePatches: true, FSGroup: tc.fsGroup, RestrictedSecurityContext: true}).mutate([]byte(rawJSON), true)
+			require.NoError(err)
+			r := v1.AdmissionReview{}
+			require.NoError(json.Unmarshal(response, &r))
+
+			jsonPatch, err := jsonpatch.DecodePatch(r.Response.Patch)
+			require.NoError(err)
+			var review v1.AdmissionReview
+			require.NoError(json.Unmarshal([]byte(rawJSON), &review))
+			patched, err := jsonPatch.Apply(review.Request.Object.Raw)
+			require.NoError(err)
+			var pod corev1.Pod
+			require.NoError(json.Unmarshal(patched, &pod))
+
+			// fields of the restricted profile set on the pod
+			podSecurityContext := pod.Spec.SecurityContext
+			require.NotNil(podSecurityContext)
+			require.NotNil(podSecurityContext.RunAsNonRoot)
+			assert.True(*podSecurityContext.RunAsNonRoot)
+			require.NotNil(podSecurityContext.SeccompProfile)
+			assert.Equal(corev1.SeccompProfileTypeRuntimeDefault, podSecurityContext.SeccompProfile.Type)
+			assert.Equal(tc.wantFSGroup, podSecurityContext.FSGroup)
+			assert.Equal(tc.wantRunAsUser, podSecurityContext.RunAsUser)
+
+			// fields of the restricted profile set on the container
+			require.Len(pod.Spec.Containers, 1)
+			container := pod.Spec.Containers[0]
+			require.NotNil(container.SecurityContext)
+			require.NotNil(container.SecurityContext.AllowPrivilegeEscalation)
+			assert.Equal(tc.wantAllowPrivEscalation, *container.SecurityContext.AllowPrivilegeEscalation)
+			require.NotNil(container.SecurityContext.Capabilities)
+			assert.Equal(tc.wantDrop, container.SecurityContext.Capabilities.Drop)
+			assert.Nil(container.SecurityContext.Privileged)
+
+			// the SGX resources are still injected
+			assert.Equal("10", container.Resources.Limits.Name("sgx.intel.com/epc", resource.DecimalSI).String())
+		})
+	}
+}
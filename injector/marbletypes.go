@@ -0,0 +1,53 @@
+package injector
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// LoadMarbleTypes reads the marble types declared in a manifest in JSON or YAML format
+func LoadMarbleTypes(rawManifest []byte) (map[string]bool, error) {
+	manifestJSON, err := yaml.YAMLToJSON(rawManifest)
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest: %v", err)
+	}
+
+	// only parse the parts of the manifest relevant for the injector
+	var manifest struct {
+		Marbles map[string]json.RawMessage
+	}
+	if err := json.Unmarshal(manifestJSON, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %v", err)
+	}
+
+	marbleTypes := make(map[string]bool, len(manifest.Marbles))
+	for marbleType := range manifest.Marbles {
+		marbleTypes[marbleType] = true
+	}
+	return marbleTypes, nil
+}
+
+// parseContainerMarbleTypes parses the value of the containerMarbleTypesAnnotation, a comma-separated list of container=marbletype pairs, e.g., "web=frontend,api=backend"
+func parseContainerMarbleTypes(annotation string, containers []corev1.Container) (map[string]string, error) {
+	containerNames := make(map[string]bool, len(containers))
+	for _, container := range containers {
+		containerNames[container.Name] = true
+	}
+
+	marbleTypes := make(map[string]string)
+	for _, pair := range strings.Split(annotation, ",") {
+		containerType := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(containerType) != 2 || containerType[0] == "" || containerType[1] == "" {
+			return nil, fmt.Errorf("invalid container marble type: %s", pair)
+		}
+		if !containerNames[containerType[0]] {
+			return nil, fmt.Errorf("pod has no container %s", containerType[0])
+		}
+		marbleTypes[containerType[0]] = containerType[1]
+	}
+	return marbleTypes, nil
+}
@@ -0,0 +1,52 @@
+package injector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestLoadMarbleTypes(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	marbleTypes, err := LoadMarbleTypes([]byte(`
+Marbles:
+  frontend:
+    Package: frontend
+  backend:
+    Package: backend
+`))
+	require.NoError(err)
+	assert.Equal(map[string]bool{"frontend": true, "backend": true}, marbleTypes)
+
+	// a manifest without marbles allows no marble type
+	marbleTypes, err = LoadMarbleTypes([]byte(`{"Packages": {}}`))
+	require.NoError(err)
+	assert.NotNil(marbleTypes)
+	assert.Empty(marbleTypes)
+
+	_, err = LoadMarbleTypes([]byte(`{"Marbles": []}`))
+	assert.Error(err)
+}
+
+func TestParseContainerMarbleTypes(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	containers := []corev1.Container{{Name: "web"}, {Name: "api"}, {Name: "sidecar"}}
+	marbleTypes, err := parseContainerMarbleTypes("web=frontend, api=backend", containers)
+	require.NoError(err)
+	assert.Equal(map[string]string{"web": "frontend", "api": "backend"}, marbleTypes)
+
+	_, err = parseContainerMarbleTypes("web", containers)
+	assert.Error(err)
+	_, err = parseContainerMarbleTypes("web=", containers)
+	assert.Error(err)
+	_, err = parseContainerMarbleTypes("=frontend", containers)
+	assert.Error(err)
+	_, err = parseContainerMarbleTypes("db=backend", containers)
+	assert.Error(err)
+}
@@ -0,0 +1,79 @@
+package injector
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// ParseStartupProbe creates a startup probe from an http, https, or tcp URL, e.g., "http://:8080/healthz" or "tcp://:8080",
+// or from a command prefixed with "exec:", e.g., "exec:cat /tmp/ready".
+// The host of a URL may be omitted to probe the pod's IP.
+// The probe tolerates a boot time of up to failureThreshold*periodSeconds seconds.
+func ParseStartupProbe(probe string, failureThreshold int32, periodSeconds int32) (*corev1.Probe, error) {
+	if failureThreshold < 1 || periodSeconds < 1 {
+		return nil, fmt.Errorf("failure threshold and period of the startup probe must be positive")
+	}
+
+	var handler corev1.Handler
+	if strings.HasPrefix(probe, "exec:") {
+		command := strings.Fields(strings.TrimPrefix(probe, "exec:"))
+		if len(command) == 0 {
+			return nil, fmt.Errorf("missing command in startup probe: %s", probe)
+		}
+		handler.Exec = &corev1.ExecAction{Command: command}
+	} else {
+		u, err := url.Parse(probe)
+		if err != nil {
+			return nil, err
+		}
+		portNumber, err := strconv.Atoi(u.Port())
+		if err != nil {
+			return nil, fmt.Errorf("invalid port in startup probe: %s", probe)
+		}
+
+		switch u.Scheme {
+		case "http", "https":
+			handler.HTTPGet = &corev1.HTTPGetAction{
+				Scheme: corev1.URISchemeHTTP,
+				Host:   u.Hostname(),
+				Port:   intstr.FromInt(portNumber),
+				Path:   u.RequestURI(),
+			}
+			if u.Scheme == "https" {
+				handler.HTTPGet.Scheme = corev1.URISchemeHTTPS
+			}
+		case "tcp":
+			handler.TCPSocket = &corev1.TCPSocketAction{
+				Host: u.Hostname(),
+				Port: intstr.FromInt(portNumber),
+			}
+		default:
+			return nil, fmt.Errorf("unsupported scheme of startup probe: %s", u.Scheme)
+		}
+	}
+
+	return &corev1.Probe{
+		Handler:          handler,
+		FailureThreshold: failureThreshold,
+		PeriodSeconds:    periodSeconds,
+	}, nil
+}
+
+// createStartupProbePatch adds the startup probe to a container, unless the container already sets one
+func createStartupProbePatch(container corev1.Container, idx int, startupProbe corev1.Probe) []map[string]interface{} {
+	if container.StartupProbe != nil {
+		return nil
+	}
+	return []map[string]interface{}{
+		{
+			"op":    "add",
+			"path":  fmt.Sprintf("/spec/containers/%d/startupProbe", idx),
+			"value": startupProbe,
+		},
+	}
+}
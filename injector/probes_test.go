@@ -0,0 +1,42 @@
+package injector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestParseStartupProbe(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	probe, err := ParseStartupProbe("http://:8080/healthz", 30, 10)
+	require.NoError(err)
+	assert.Equal(&corev1.HTTPGetAction{Scheme: corev1.URISchemeHTTP, Port: intstr.FromInt(8080), Path: "/healthz"}, probe.HTTPGet)
+	assert.EqualValues(30, probe.FailureThreshold)
+	assert.EqualValues(10, probe.PeriodSeconds)
+
+	probe, err = ParseStartupProbe("https://localhost:8443/", 30, 10)
+	require.NoError(err)
+	assert.Equal(corev1.URISchemeHTTPS, probe.HTTPGet.Scheme)
+	assert.Equal("localhost", probe.HTTPGet.Host)
+
+	probe, err = ParseStartupProbe("tcp://:2001", 30, 10)
+	require.NoError(err)
+	assert.Equal(&corev1.TCPSocketAction{Port: intstr.FromInt(2001)}, probe.TCPSocket)
+	assert.Nil(probe.HTTPGet)
+
+	probe, err = ParseStartupProbe("exec:cat /tmp/ready", 30, 10)
+	require.NoError(err)
+	assert.Equal([]string{"cat", "/tmp/ready"}, probe.Exec.Command)
+
+	for _, invalid := range []string{"exec:", "http://:http/", "tcp://localhost", "udp://:53", "::"} {
+		_, err = ParseStartupProbe(invalid, 30, 10)
+		assert.Error(err, invalid)
+	}
+	_, err = ParseStartupProbe("tcp://:2001", 0, 10)
+	assert.Error(err)
+}
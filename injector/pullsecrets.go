@@ -0,0 +1,34 @@
+package injector
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// createImagePullSecretPatch adds a reference to the pull secret with the given name to the image pull secrets of a pod.
+// Nothing is added if the pod already references the secret.
+func createImagePullSecretPatch(pullSecrets []corev1.LocalObjectReference, name string) []map[string]interface{} {
+	for _, pullSecret := range pullSecrets {
+		if pullSecret.Name == name {
+			return nil
+		}
+	}
+
+	if len(pullSecrets) == 0 {
+		// create array if this is the first pull secret of the pod
+		return []map[string]interface{}{
+			{
+				"op":    "add",
+				"path":  "/spec/imagePullSecrets",
+				"value": []corev1.LocalObjectReference{{Name: name}},
+			},
+		}
+	}
+	// append as last element of the pull secrets array otherwise
+	return []map[string]interface{}{
+		{
+			"op":    "add",
+			"path":  "/spec/imagePullSecrets/-",
+			"value": corev1.LocalObjectReference{Name: name},
+		},
+	}
+}
@@ -0,0 +1,44 @@
+package injector
+
+import (
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// LoadMarbleResources reads the resource limits and requests of each marble type from a manifest in JSON or YAML format
+func LoadMarbleResources(rawManifest []byte) (map[string]corev1.ResourceRequirements, error) {
+	manifestJSON, err := yaml.YAMLToJSON(rawManifest)
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest: %v", err)
+	}
+
+	// only parse the parts of the manifest relevant for the injector
+	var manifest struct {
+		Marbles map[string]struct {
+			Kubernetes *struct {
+				Resources *corev1.ResourceRequirements
+			}
+		}
+	}
+	if err := json.Unmarshal(manifestJSON, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %v", err)
+	}
+
+	marbleResources := make(map[string]corev1.ResourceRequirements)
+	for marbleType, marble := range manifest.Marbles {
+		if marble.Kubernetes == nil || marble.Kubernetes.Resources == nil {
+			continue
+		}
+		resources := *marble.Kubernetes.Resources
+		for name, request := range resources.Requests {
+			if limit, ok := resources.Limits[name]; ok && request.Cmp(limit) > 0 {
+				return nil, fmt.Errorf("marble %s requests more %s than its limit", marbleType, name)
+			}
+		}
+		marbleResources[marbleType] = resources
+	}
+	return marbleResources, nil
+}
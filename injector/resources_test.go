@@ -0,0 +1,43 @@
+package injector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestLoadMarbleResources(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// YAML manifest
+	marbleResources, err := LoadMarbleResources([]byte(`
+Marbles:
+  frontend:
+    Package: frontend
+  backend:
+    Package: backend
+    Kubernetes:
+      Resources:
+        limits:
+          memory: 2Gi
+        requests:
+          cpu: 500m
+          memory: 1Gi
+`))
+	require.NoError(err)
+	assert.Len(marbleResources, 1)
+	assert.True(resource.MustParse("2Gi").Equal(marbleResources["backend"].Limits[corev1.ResourceMemory]))
+	assert.True(resource.MustParse("500m").Equal(marbleResources["backend"].Requests[corev1.ResourceCPU]))
+
+	// request above the limit
+	_, err = LoadMarbleResources([]byte(`{"Marbles": {"backend": {"Kubernetes": {"Resources": {"limits": {"memory": "1Gi"}, "requests": {"memory": "2Gi"}}}}}}`))
+	assert.Error(err)
+
+	// invalid quantity
+	_, err = LoadMarbleResources([]byte(`{"Marbles": {"backend": {"Kubernetes": {"Resources": {"limits": {"memory": "lots"}}}}}}`))
+	assert.Error(err)
+}
@@ -0,0 +1,66 @@
+package injector
+
+import (
+	"errors"
+	"fmt"
+	"path"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// serviceAccountTokenPath is the name of the token file in the projected volume
+const serviceAccountTokenPath = "token"
+
+// minTokenExpirationSeconds is the minimum expiration of projected service account tokens accepted by the kubelet
+const minTokenExpirationSeconds = 600
+
+// ServiceAccountToken is a projected service account token with a custom audience, which is mounted into the containers of marbles,
+// e.g., for marbles calling external services using workload identity
+type ServiceAccountToken struct {
+	// Audience is the intended audience of the token
+	Audience string
+	// ExpirationSeconds is the requested lifetime of the token, the kubelet rotates the token before it expires
+	ExpirationSeconds int64
+	// MountPath is the directory the volume is mounted to, the token is stored in the file "token" within it
+	MountPath string
+}
+
+// NewServiceAccountToken creates a projected service account token for the given audience
+func NewServiceAccountToken(audience string, expirationSeconds int64, mountPath string) (*ServiceAccountToken, error) {
+	if audience == "" {
+		return nil, errors.New("invalid service account token: empty audience")
+	}
+	if expirationSeconds < minTokenExpirationSeconds {
+		return nil, fmt.Errorf("invalid service account token: expiration must be at least %d seconds", minTokenExpirationSeconds)
+	}
+	if !path.IsAbs(mountPath) {
+		return nil, fmt.Errorf("invalid service account token: mount path %s is not absolute", mountPath)
+	}
+	return &ServiceAccountToken{
+		Audience:          audience,
+		ExpirationSeconds: expirationSeconds,
+		MountPath:         mountPath,
+	}, nil
+}
+
+// createServiceAccountTokenVolume creates a projected volume providing a service account token for the audience of token
+func createServiceAccountTokenVolume(uid types.UID, token ServiceAccountToken) *corev1.Volume {
+	expirationSeconds := token.ExpirationSeconds
+	return &corev1.Volume{
+		Name: volumeName("sa-token", uid),
+		VolumeSource: corev1.VolumeSource{
+			Projected: &corev1.ProjectedVolumeSource{
+				Sources: []corev1.VolumeProjection{
+					{
+						ServiceAccountToken: &corev1.ServiceAccountTokenProjection{
+							Audience:          token.Audience,
+							ExpirationSeconds: &expirationSeconds,
+							Path:              serviceAccountTokenPath,
+						},
+					},
+				},
+			},
+		},
+	}
+}
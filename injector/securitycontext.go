@@ -0,0 +1,121 @@
+package injector
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// createPodSecurityContextPatch sets the fields of a pod's security context which the pod does not set itself.
+// fsGroup makes the volumes of the pod accessible by the group, it is not set if nil.
+// restricted sets runAsNonRoot and the RuntimeDefault seccomp profile, as required by the restricted Pod Security Standard.
+func createPodSecurityContextPatch(securityContext *corev1.PodSecurityContext, fsGroup *int64, restricted bool) []map[string]interface{} {
+	newSecurityContext := corev1.PodSecurityContext{FSGroup: fsGroup}
+	if restricted {
+		runAsNonRoot := true
+		newSecurityContext.RunAsNonRoot = &runAsNonRoot
+		newSecurityContext.SeccompProfile = &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault}
+	}
+
+	if securityContext == nil {
+		if newSecurityContext.FSGroup == nil && newSecurityContext.RunAsNonRoot == nil {
+			return nil
+		}
+		// create the security context if the pod has none
+		return []map[string]interface{}{
+			{
+				"op":    "add",
+				"path":  "/spec/securityContext",
+				"value": newSecurityContext,
+			},
+		}
+	}
+
+	var patch []map[string]interface{}
+	if newSecurityContext.FSGroup != nil && securityContext.FSGroup == nil {
+		patch = append(patch, map[string]interface{}{
+			"op":    "add",
+			"path":  "/spec/securityContext/fsGroup",
+			"value": *newSecurityContext.FSGroup,
+		})
+	}
+	if newSecurityContext.RunAsNonRoot != nil && securityContext.RunAsNonRoot == nil {
+		patch = append(patch, map[string]interface{}{
+			"op":    "add",
+			"path":  "/spec/securityContext/runAsNonRoot",
+			"value": *newSecurityContext.RunAsNonRoot,
+		})
+	}
+	if newSecurityContext.SeccompProfile != nil && securityContext.SeccompProfile == nil {
+		patch = append(patch, map[string]interface{}{
+			"op":    "add",
+			"path":  "/spec/securityContext/seccompProfile",
+			"value": *newSecurityContext.SeccompProfile,
+		})
+	}
+	return patch
+}
+
+// createRestrictedContainerSecurityContextPatch disallows privilege escalation and drops all capabilities of a container, as required by the restricted Pod Security Standard.
+// Fields the container sets itself are not overwritten. The enclave accesses the SGX devices through the resources of the device plugin, which requires neither privileges nor capabilities.
+func createRestrictedContainerSecurityContextPatch(container corev1.Container, idx int) []map[string]interface{} {
+	basePath := fmt.Sprintf("/spec/containers/%d/securityContext", idx)
+	allowPrivilegeEscalation := false
+	dropAll := []corev1.Capability{"ALL"}
+
+	securityContext := container.SecurityContext
+	if securityContext == nil {
+		// create the security context if the container has none
+		return []map[string]interface{}{
+			{
+				"op":   "add",
+				"path": basePath,
+				"value": corev1.SecurityContext{
+					AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+					Capabilities:             &corev1.Capabilities{Drop: dropAll},
+				},
+			},
+		}
+	}
+
+	var patch []map[string]interface{}
+	if securityContext.AllowPrivilegeEscalation == nil {
+		patch = append(patch, map[string]interface{}{
+			"op":    "add",
+			"path":  basePath + "/allowPrivilegeEscalation",
+			"value": allowPrivilegeEscalation,
+		})
+	}
+	switch {
+	case securityContext.Capabilities == nil:
+		patch = append(patch, map[string]interface{}{
+			"op":    "add",
+			"path":  basePath + "/capabilities",
+			"value": corev1.Capabilities{Drop: dropAll},
+		})
+	case len(securityContext.Capabilities.Drop) <= 0:
+		patch = append(patch, map[string]interface{}{
+			"op":    "add",
+			"path":  basePath + "/capabilities/drop",
+			"value": dropAll,
+		})
+	case !dropsAllCapabilities(securityContext.Capabilities.Drop):
+		// append as last element of the dropped capabilities otherwise
+		patch = append(patch, map[string]interface{}{
+			"op":    "add",
+			"path":  basePath + "/capabilities/drop/-",
+			"value": dropAll[0],
+		})
+	}
+	return patch
+}
+
+// dropsAllCapabilities checks if the dropped capabilities contain ALL
+func dropsAllCapabilities(drop []corev1.Capability) bool {
+	for _, capability := range drop {
+		if capability == "ALL" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package injector
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// LoadSidecar reads the specification of a sidecar container in JSON or YAML format, e.g., a log shipper.
+// The container must have a name and an image. Its volume mounts must refer to volumes of the injected pods, e.g., volumes declared in the manifest.
+func LoadSidecar(rawSidecar []byte) (*corev1.Container, error) {
+	var sidecar corev1.Container
+	if err := yaml.UnmarshalStrict(rawSidecar, &sidecar); err != nil {
+		return nil, fmt.Errorf("invalid sidecar: %v", err)
+	}
+	if sidecar.Name == "" || sidecar.Image == "" {
+		return nil, fmt.Errorf("invalid sidecar: name and image must be set")
+	}
+	return &sidecar, nil
+}
+
+// createSidecarPatch appends the sidecar to the containers of the pod.
+// Nothing is added if the pod already has a container with the name of the sidecar.
+func createSidecarPatch(containers []corev1.Container, sidecar corev1.Container) []map[string]interface{} {
+	for _, container := range containers {
+		if container.Name == sidecar.Name {
+			return nil
+		}
+	}
+
+	if len(containers) == 0 {
+		// create array if the pod has no containers
+		return []map[string]interface{}{
+			{
+				"op":    "add",
+				"path":  "/spec/containers",
+				"value": []corev1.Container{sidecar},
+			},
+		}
+	}
+	// append as last element of the containers array otherwise
+	return []map[string]interface{}{
+		{
+			"op":    "add",
+			"path":  "/spec/containers/-",
+			"value": sidecar,
+		},
+	}
+}
@@ -0,0 +1,42 @@
+package injector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadSidecar(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	rawSidecar := `
+name: log-shipper
+image: fluent/fluent-bit:1.7
+args: ["-c", "/fluent-bit/etc/fluent-bit.conf"]
+volumeMounts:
+- name: logs
+  mountPath: /var/log/marble
+  readOnly: true
+`
+	sidecar, err := LoadSidecar([]byte(rawSidecar))
+	require.NoError(err)
+	assert.Equal("log-shipper", sidecar.Name)
+	assert.Equal("fluent/fluent-bit:1.7", sidecar.Image)
+	assert.Equal([]string{"-c", "/fluent-bit/etc/fluent-bit.conf"}, sidecar.Args)
+	require.Len(sidecar.VolumeMounts, 1)
+	assert.Equal("/var/log/marble", sidecar.VolumeMounts[0].MountPath)
+
+	// JSON is accepted as well
+	sidecar, err = LoadSidecar([]byte(`{"name": "log-shipper", "image": "fluent/fluent-bit:1.7"}`))
+	require.NoError(err)
+	assert.Equal("log-shipper", sidecar.Name)
+
+	_, err = LoadSidecar([]byte(`{"name": "log-shipper"}`))
+	assert.Error(err)
+	_, err = LoadSidecar([]byte(`{"image": "fluent/fluent-bit:1.7"}`))
+	assert.Error(err)
+	_, err = LoadSidecar([]byte(`{"name": "log-shipper", "image": "fluent/fluent-bit:1.7", "unknown": true}`))
+	assert.Error(err)
+}
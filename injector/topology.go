@@ -0,0 +1,61 @@
+package injector
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NewTopologySpreadConstraint creates the template of a topology spread constraint, which spreads the pods of each marble type across the domains of topologyKey, e.g., "topology.kubernetes.io/zone"
+func NewTopologySpreadConstraint(topologyKey string, maxSkew int32, whenUnsatisfiable string) (*corev1.TopologySpreadConstraint, error) {
+	if topologyKey == "" {
+		return nil, fmt.Errorf("missing topology key")
+	}
+	if maxSkew < 1 {
+		return nil, fmt.Errorf("invalid max skew %d: must be positive", maxSkew)
+	}
+	action := corev1.UnsatisfiableConstraintAction(whenUnsatisfiable)
+	if action != corev1.DoNotSchedule && action != corev1.ScheduleAnyway {
+		return nil, fmt.Errorf("invalid action for unsatisfiable topology spread constraint: %s", whenUnsatisfiable)
+	}
+	return &corev1.TopologySpreadConstraint{
+		TopologyKey:       topologyKey,
+		MaxSkew:           maxSkew,
+		WhenUnsatisfiable: action,
+	}, nil
+}
+
+// createTopologySpreadPatch adds a topology spread constraint selecting the pods of the marble type.
+// Nothing is added if the pod already spreads across the topology key of the constraint.
+func createTopologySpreadPatch(constraints []corev1.TopologySpreadConstraint, template corev1.TopologySpreadConstraint, marbleType string) []map[string]interface{} {
+	for _, constraint := range constraints {
+		if constraint.TopologyKey == template.TopologyKey {
+			return nil
+		}
+	}
+
+	constraint := template
+	constraint.LabelSelector = &metav1.LabelSelector{
+		MatchLabels: map[string]string{"marblerun/marbletype": marbleType},
+	}
+
+	if len(constraints) == 0 {
+		// create array if this is the first constraint of the pod
+		return []map[string]interface{}{
+			{
+				"op":    "add",
+				"path":  "/spec/topologySpreadConstraints",
+				"value": []corev1.TopologySpreadConstraint{constraint},
+			},
+		}
+	}
+	// append as last element of the constraints array otherwise
+	return []map[string]interface{}{
+		{
+			"op":    "add",
+			"path":  "/spec/topologySpreadConstraints/-",
+			"value": constraint,
+		},
+	}
+}
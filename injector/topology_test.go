@@ -0,0 +1,28 @@
+package injector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestNewTopologySpreadConstraint(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	constraint, err := NewTopologySpreadConstraint("topology.kubernetes.io/zone", 2, "DoNotSchedule")
+	require.NoError(err)
+	assert.Equal("topology.kubernetes.io/zone", constraint.TopologyKey)
+	assert.EqualValues(2, constraint.MaxSkew)
+	assert.Equal(corev1.DoNotSchedule, constraint.WhenUnsatisfiable)
+	assert.Nil(constraint.LabelSelector)
+
+	_, err = NewTopologySpreadConstraint("", 1, "DoNotSchedule")
+	assert.Error(err)
+	_, err = NewTopologySpreadConstraint("topology.kubernetes.io/zone", 0, "DoNotSchedule")
+	assert.Error(err)
+	_, err = NewTopologySpreadConstraint("topology.kubernetes.io/zone", 1, "Ignore")
+	assert.Error(err)
+}
@@ -0,0 +1,59 @@
+package injector
+
+import (
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// ExtraVolumes are volumes declared for a marble type in the manifest, which are added to the pods of this marble type
+type ExtraVolumes struct {
+	// Volumes are added to the pod
+	Volumes []corev1.Volume
+	// VolumeMounts are added to each container of the pod
+	VolumeMounts []corev1.VolumeMount
+}
+
+// LoadExtraVolumes reads the extra volumes of each marble type from a manifest in JSON or YAML format
+func LoadExtraVolumes(rawManifest []byte) (map[string]ExtraVolumes, error) {
+	manifestJSON, err := yaml.YAMLToJSON(rawManifest)
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest: %v", err)
+	}
+
+	// only parse the parts of the manifest relevant for the injector
+	var manifest struct {
+		Marbles map[string]struct {
+			Kubernetes *ExtraVolumes
+		}
+	}
+	if err := json.Unmarshal(manifestJSON, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %v", err)
+	}
+
+	extraVolumes := make(map[string]ExtraVolumes)
+	for marbleType, marble := range manifest.Marbles {
+		if marble.Kubernetes == nil {
+			continue
+		}
+		for _, mount := range marble.Kubernetes.VolumeMounts {
+			if !volumeIsSet(marble.Kubernetes.Volumes, mount.Name) {
+				return nil, fmt.Errorf("marble %s mounts undeclared volume %s", marbleType, mount.Name)
+			}
+		}
+		extraVolumes[marbleType] = *marble.Kubernetes
+	}
+	return extraVolumes, nil
+}
+
+// volumeIsSet checks if a volume with the given name exists
+func volumeIsSet(volumes []corev1.Volume, name string) bool {
+	for _, volume := range volumes {
+		if volume.Name == name {
+			return true
+		}
+	}
+	return false
+}
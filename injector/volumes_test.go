@@ -0,0 +1,42 @@
+package injector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadExtraVolumes(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// JSON manifest
+	extraVolumes, err := LoadExtraVolumes([]byte(`{
+		"Marbles": {
+			"frontend": {"Package": "frontend"},
+			"backend": {
+				"Package": "backend",
+				"Kubernetes": {
+					"Volumes": [{"name": "data", "emptyDir": {}}],
+					"VolumeMounts": [{"name": "data", "mountPath": "/data"}]
+				}
+			}
+		}
+	}`))
+	require.NoError(err)
+	assert.Len(extraVolumes, 1)
+	require.Len(extraVolumes["backend"].Volumes, 1)
+	assert.Equal("data", extraVolumes["backend"].Volumes[0].Name)
+	assert.NotNil(extraVolumes["backend"].Volumes[0].EmptyDir)
+	require.Len(extraVolumes["backend"].VolumeMounts, 1)
+	assert.Equal("/data", extraVolumes["backend"].VolumeMounts[0].MountPath)
+
+	// mount of an undeclared volume
+	_, err = LoadExtraVolumes([]byte(`{"Marbles": {"backend": {"Kubernetes": {"VolumeMounts": [{"name": "data", "mountPath": "/data"}]}}}}`))
+	assert.Error(err)
+
+	// invalid manifest
+	_, err = LoadExtraVolumes([]byte(`{"Marbles": [}`))
+	assert.Error(err)
+}
@@ -22,10 +22,11 @@ const CoordinatorAddrDefault = "localhost:2001"
 // Type is the marble's type used for attestation with the coordinator
 const Type = "EDG_MARBLE_TYPE"
 
-// DNSNames are the alternative dns names for the marble's certificate
+// DNSNames are the alternative dns names for the marble's certificate.
+// If unset, the coordinator assigns the DNSNames defined for the marble's type in the manifest.
 const DNSNames = "EDG_MARBLE_DNS_NAMES"
 
-// DNSNamesDefault are the default alternative dns names for the marble's certificate
+// DNSNamesDefault are the default alternative dns names for the marble's certificate used during activation
 const DNSNamesDefault = "localhost"
 
 // UUIDFile is the file path to store the marble's uuid
@@ -33,3 +34,41 @@ const UUIDFile = "EDG_MARBLE_UUID_FILE"
 
 // UUIDFileDefault is the default file path to store the marble's uuid
 func UUIDFileDefault() string { return filepath.Join(util.MustGetwd(), "uuid") }
+
+// UUID is the marble's uuid, e.g., the UID of its pod set by the marble-injector. If set, it takes precedence over the UUID file.
+const UUID = "EDG_MARBLE_UUID"
+
+// DumpQuotePath is the file path to which the marble writes its quote and the quoted message before activation (for debugging)
+const DumpQuotePath = "EDG_MARBLE_DUMP_QUOTE_PATH"
+
+// CoordinatorCAFile is the path to a PEM file holding the CA used to verify the coordinator during activation. If unset, the coordinator is not verified.
+const CoordinatorCAFile = "EDG_MARBLE_COORDINATOR_CA_FILE"
+
+// Simulation enables the simulation mode if set to "1". The marble sends an empty quote, which is only accepted by a coordinator that runs in simulation mode as well.
+const Simulation = "EDG_MARBLE_SIMULATION"
+
+// CertWatchdogInterval enables a watchdog if set to a duration, e.g., "5m". The watchdog periodically checks if the coordinator still uses the root certificate the marble's credentials are issued by.
+// If the coordinator rotated its root certificate, the process is terminated, so it can be restarted and activated again.
+const CertWatchdogInterval = "EDG_MARBLE_CERT_WATCHDOG_INTERVAL"
+
+// FailureGrace is the duration, e.g., "30s", the process is kept alive after the activation failed, so its logs can be collected before it exits.
+// If unset, the process exits immediately.
+const FailureGrace = "EDG_MARBLE_FAILURE_GRACE"
+
+// ExpectedMREnclave is the hex encoded MRENCLAVE the marble expects to run with. If set, the marble checks its own measurement before contacting the coordinator.
+const ExpectedMREnclave = "EDG_MARBLE_EXPECTED_MRENCLAVE"
+
+// FileOwner is the owner applied to the files written during activation and the directories created for them, formatted as numeric uid:gid, e.g., "1000:1000".
+// It allows applications running as a non-root user to read their secrets. If unset, the files are owned by the user running the marble.
+const FileOwner = "EDG_MARBLE_FILE_OWNER"
+
+// SharedCredentialsPath is a directory on the host file system the marble additionally writes its issued certificate chain, private key, and the coordinator's intermediate CA to,
+// e.g., an emptyDir volume shared with a non-enclave sidecar proxying mesh traffic. If unset, the credentials are only available inside the enclave.
+// WARNING: this reduces the trust boundary, as the private key of the marble leaves the enclave and is accessible to anyone who can read the directory.
+const SharedCredentialsPath = "EDG_MARBLE_SHARED_CREDENTIALS_PATH"
+
+// MaxMsgSize is the maximum size in bytes of the activation response the marble accepts. It should match the maximum message size of the coordinator.
+const MaxMsgSize = "EDG_MARBLE_MAX_MSG_SIZE"
+
+// MaxMsgSizeDefault is the default maximum size of the activation response (16 MiB), matching the coordinator's default
+const MaxMsgSizeDefault = "16777216"
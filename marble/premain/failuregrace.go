@@ -0,0 +1,48 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package premain
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/edgelesssys/marblerun/coordinator/rpc"
+	"github.com/edgelesssys/marblerun/marble/config"
+)
+
+// getFailureGrace returns the duration the process is kept alive after a failed activation, or 0 if it exits immediately
+func getFailureGrace() (time.Duration, error) {
+	value := os.Getenv(config.FailureGrace)
+	if value == "" {
+		return 0, nil
+	}
+	grace, err := time.ParseDuration(value)
+	if err != nil || grace < 0 {
+		return 0, fmt.Errorf("invalid duration for %s: %v", config.FailureGrace, value)
+	}
+	return grace, nil
+}
+
+// waitFailureGrace logs diagnostics of a failed activation and blocks for the grace period, so the logs can be collected before the process exits
+func waitFailureGrace(grace time.Duration, req *rpc.ActivationReq, coordAddr string, activationErr error) {
+	if grace <= 0 {
+		return
+	}
+	log.Printf("activation failed: %v", activationErr)
+	log.Println("coordinator address:", coordAddr)
+	log.Println("marble type:", req.GetMarbleType())
+	log.Println("UUID:", req.GetUUID())
+	log.Printf("quote size: %d bytes", len(req.GetQuote()))
+	if len(req.GetQuote()) <= 0 {
+		log.Println("the quote is empty, activation only succeeds with a coordinator that runs in simulation mode")
+	}
+	log.Println("waiting", grace, "before exiting")
+	time.Sleep(grace)
+	log.Println("grace period elapsed, exiting")
+}
@@ -0,0 +1,86 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package premain
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/edgelesssys/marblerun/marble/config"
+	"github.com/spf13/afero"
+)
+
+// fileOwner is the owner applied to the files written during activation
+type fileOwner struct {
+	uid int
+	gid int
+}
+
+// getFileOwner returns the owner of the files written during activation, or nil if the files keep the owner of the process
+func getFileOwner() (*fileOwner, error) {
+	value := os.Getenv(config.FileOwner)
+	if value == "" {
+		return nil, nil
+	}
+	uidGid := strings.SplitN(value, ":", 2)
+	if len(uidGid) != 2 {
+		return nil, fmt.Errorf("invalid value for %s: expected uid:gid, got %v", config.FileOwner, value)
+	}
+	uid, err := strconv.ParseUint(uidGid[0], 10, 31)
+	if err != nil {
+		return nil, fmt.Errorf("invalid uid for %s: %v", config.FileOwner, uidGid[0])
+	}
+	gid, err := strconv.ParseUint(uidGid[1], 10, 31)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gid for %s: %v", config.FileOwner, uidGid[1])
+	}
+	return &fileOwner{uid: int(uid), gid: int(gid)}, nil
+}
+
+// mkdirAll is like fs.MkdirAll, but applies owner to the directories it creates, so the owner can access the files within them
+func mkdirAll(fs afero.Fs, path string, perm os.FileMode, owner *fileOwner) error {
+	// collect the directories which don't exist yet, existing ones keep their owner
+	var created []string
+	for dir := filepath.Clean(path); ; dir = filepath.Dir(dir) {
+		if _, err := fs.Stat(dir); err == nil {
+			break
+		}
+		created = append(created, dir)
+		if dir == filepath.Dir(dir) {
+			break
+		}
+	}
+
+	if err := fs.MkdirAll(path, perm); err != nil {
+		return err
+	}
+	for _, dir := range created {
+		if err := chown(fs, dir, owner); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// chown applies owner to a file. It is a no-op if owner is nil or the file system doesn't support changing owners.
+func chown(fs afero.Fs, path string, owner *fileOwner) error {
+	if owner == nil {
+		return nil
+	}
+	err := fs.Chown(path, owner.uid, owner.gid)
+	if errors.Is(err, syscall.ENOSYS) || errors.Is(err, syscall.ENOTSUP) || errors.Is(err, syscall.EOPNOTSUPP) {
+		log.Printf("WARNING: the file system does not support changing the owner of %s: %v", path, err)
+		return nil
+	}
+	return err
+}
@@ -8,15 +8,21 @@
 package premain
 
 import (
+	"bytes"
 	"context"
 	"crypto/ecdsa"
 	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/edgelesssys/marblerun/coordinator/quote"
 	"github.com/edgelesssys/marblerun/coordinator/quote/ertvalidator"
@@ -60,6 +66,16 @@ func readUUID(appFs afero.Fs, filename string) (*uuid.UUID, error) {
 
 // getUUID loads or generates the uuid
 func getUUID(appFs afero.Fs, uuidFile string) (uuid.UUID, error) {
+	// a uuid set in the environment, e.g., by the admission controller, takes precedence over the file
+	if uuidEnv := os.Getenv(config.UUID); uuidEnv != "" {
+		marbleUUID, err := uuid.Parse(uuidEnv)
+		if err != nil {
+			return uuid.UUID{}, fmt.Errorf("invalid value for %s: %v", config.UUID, err)
+		}
+		log.Println("found UUID in environment:", marbleUUID.String())
+		return marbleUUID, nil
+	}
+
 	// check if we have a uuid stored in the fs (means we are restarted or it was set by the admission controller)
 	log.Println("loading UUID")
 	existingUUID, err := readUUID(appFs, uuidFile)
@@ -81,6 +97,26 @@ func getUUID(appFs afero.Fs, uuidFile string) (uuid.UUID, error) {
 	return *existingUUID, nil
 }
 
+// attestationEvidence is the debug output written to the path set in EDG_MARBLE_DUMP_QUOTE_PATH
+type attestationEvidence struct {
+	Quote   []byte
+	Message []byte
+}
+
+// dumpQuote writes the quote and the quoted message to the given file. Errors are only logged, as this is a debugging aid.
+func dumpQuote(appFs afero.Fs, filename string, quote []byte, message []byte) {
+	evidence, err := json.Marshal(attestationEvidence{Quote: quote, Message: message})
+	if err != nil {
+		log.Printf("failed to marshal attestation evidence: %v", err)
+		return
+	}
+	if err := afero.WriteFile(appFs, filename, evidence, 0600); err != nil {
+		log.Printf("failed to write attestation evidence to %s: %v", filename, err)
+		return
+	}
+	log.Println("wrote attestation evidence to", filename)
+}
+
 func generateCertificate() (*x509.Certificate, *ecdsa.PrivateKey, error) {
 	marbleDNSNamesString := util.Getenv(config.DNSNames, config.DNSNamesDefault)
 	marbleDNSNames := strings.Split(marbleDNSNamesString, ",")
@@ -88,6 +124,27 @@ func generateCertificate() (*x509.Certificate, *ecdsa.PrivateKey, error) {
 	return util.GenerateCert(marbleDNSNames, ipAddrs, false)
 }
 
+// loadTLSCredentials loads the credentials for the activation connection.
+// If a coordinator CA file is set, it is the sole trust anchor for the coordinator's certificate.
+// Otherwise, InsecureSkipVerify is enabled. (The coordinator verifies the marble, but not the other way round.)
+func loadTLSCredentials(appFs afero.Fs, cert *x509.Certificate, privk *ecdsa.PrivateKey) (credentials.TransportCredentials, error) {
+	caFile := os.Getenv(config.CoordinatorCAFile)
+	if caFile == "" {
+		return util.LoadGRPCTLSCredentials(cert, privk, true)
+	}
+
+	log.Println("loading coordinator CA from", caFile)
+	caPEM, err := afero.ReadFile(appFs, caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read coordinator CA file: %v", err)
+	}
+	rootCAs := x509.NewCertPool()
+	if !rootCAs.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no valid certificate found in coordinator CA file %s", caFile)
+	}
+	return util.LoadGRPCTLSCredentialsWithRootCAs(cert, privk, rootCAs), nil
+}
+
 // PreMain runs before the App's actual main routine and authenticates with the Coordinator
 //
 // It obtains a quote from the CPU and authenticates itself to the Coordinator through remote attestation.
@@ -126,18 +183,34 @@ func PreMainEx(issuer quote.Issuer, activate ActivateFunc, hostfs, enclavefs afe
 	log.Println("fetching env variables")
 	coordAddr := util.Getenv(config.CoordinatorAddr, config.CoordinatorAddrDefault)
 	marbleType := util.MustGetenv(config.Type)
-	marbleDNSNamesString := util.Getenv(config.DNSNames, config.DNSNamesDefault)
-	marbleDNSNames := strings.Split(marbleDNSNamesString, ",")
+	// without DNS names in the CSR, the coordinator assigns those of the marble type
+	var marbleDNSNames []string
+	if marbleDNSNamesString := os.Getenv(config.DNSNames); marbleDNSNamesString != "" {
+		marbleDNSNames = strings.Split(marbleDNSNamesString, ",")
+	} else {
+		log.Println("no DNS names set, requesting the DNS names of the marble type from the coordinator")
+	}
 	uuidFile := util.Getenv(config.UUIDFile, config.UUIDFileDefault())
+	watchdogInterval, err := getWatchdogInterval()
+	if err != nil {
+		return err
+	}
+	failureGrace, err := getFailureGrace()
+	if err != nil {
+		return err
+	}
+	owner, err := getFileOwner()
+	if err != nil {
+		return err
+	}
 
 	cert, privk, err := generateCertificate()
 	if err != nil {
 		return err
 	}
 
-	// Load TLS Credentials with InsecureSkipVerify enabled. (The coordinator verifies the marble, but not the other way round.)
 	log.Println("loading TLS Credentials")
-	tlsCredentials, err := util.LoadGRPCTLSCredentials(cert, privk, true)
+	tlsCredentials, err := loadTLSCredentials(hostfs, cert, privk)
 	if err != nil {
 		return err
 	}
@@ -161,6 +234,13 @@ func PreMainEx(issuer quote.Issuer, activate ActivateFunc, hostfs, enclavefs afe
 		// default
 		issuer = ertvalidator.NewERTIssuer()
 	}
+	if os.Getenv(config.Simulation) == "1" {
+		log.Println("WARNING: running in simulation mode. Activation will only succeed with a coordinator that runs in simulation mode as well")
+		issuer = quote.NewSimulationIssuer()
+	}
+	if err := verifyMeasurement(issuer); err != nil {
+		return err
+	}
 	quote, err := issuer.Issue(cert.Raw)
 	if err != nil {
 		log.Printf("failed to get quote: %v. Proceeding in simulation mode", err)
@@ -170,6 +250,11 @@ func PreMainEx(issuer quote.Issuer, activate ActivateFunc, hostfs, enclavefs afe
 		quote = []byte{}
 	}
 
+	// dump quote for debugging if requested
+	if dumpQuotePath := os.Getenv(config.DumpQuotePath); dumpQuotePath != "" {
+		dumpQuote(hostfs, dumpQuotePath, quote, cert.Raw)
+	}
+
 	// authenticate with Coordinator
 	req := &rpc.ActivationReq{
 		CSR:        csr.Raw,
@@ -180,23 +265,86 @@ func PreMainEx(issuer quote.Issuer, activate ActivateFunc, hostfs, enclavefs afe
 	log.Println("activating marble of type", marbleType)
 	params, err := activate(req, coordAddr, tlsCredentials)
 	if err != nil {
+		waitFailureGrace(failureGrace, req, coordAddr, err)
 		return err
 	}
 
-	if err := applyParameters(params, enclavefs); err != nil {
+	if err := applyParameters(params, enclavefs, owner); err != nil {
 		return err
 	}
 
+	if sharedCredentialsPath := os.Getenv(config.SharedCredentialsPath); sharedCredentialsPath != "" {
+		if err := writeSharedCredentials(params, hostfs, sharedCredentialsPath, owner); err != nil {
+			return err
+		}
+	}
+
+	if watchdogInterval > 0 {
+		log.Println("starting certificate watchdog with interval", watchdogInterval)
+		watchdog, err := newCertWatchdog(params, coordAddr, watchdogInterval)
+		if err != nil {
+			return err
+		}
+		go watchdog.run(context.Background())
+	}
+
 	log.Println("done with PreMain")
 	return nil
 }
 
+// getWatchdogInterval returns the interval of the certificate watchdog, or 0 if the watchdog is disabled
+func getWatchdogInterval() (time.Duration, error) {
+	value := os.Getenv(config.CertWatchdogInterval)
+	if value == "" {
+		return 0, nil
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval <= 0 {
+		return 0, fmt.Errorf("invalid duration for %s: %v", config.CertWatchdogInterval, value)
+	}
+	return interval, nil
+}
+
+// verifyMeasurement checks the measurement of the enclave against the expected value, if one is set
+func verifyMeasurement(issuer quote.Issuer) error {
+	expectedValue := os.Getenv(config.ExpectedMREnclave)
+	if expectedValue == "" {
+		return nil
+	}
+	expected, err := hex.DecodeString(expectedValue)
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %v", config.ExpectedMREnclave, expectedValue)
+	}
+	if os.Getenv(config.Simulation) == "1" {
+		log.Println("WARNING: running in simulation mode. Skipping the check of the enclave measurement")
+		return nil
+	}
+
+	log.Println("verifying enclave measurement")
+	reporter, ok := issuer.(quote.MeasurementReporter)
+	if !ok {
+		return errors.New("cannot verify the enclave measurement: the quote issuer does not report it")
+	}
+	measurement, err := reporter.Measurement()
+	if err != nil {
+		return fmt.Errorf("failed to get the enclave measurement: %v", err)
+	}
+	if !bytes.Equal(measurement, expected) {
+		return fmt.Errorf("enclave measurement mismatch: expected %x, got %x", expected, measurement)
+	}
+	return nil
+}
+
 // ActivateFunc is called by premain to activate the Marble and get its parameters.
 type ActivateFunc func(req *rpc.ActivationReq, coordAddr string, tlsCredentials credentials.TransportCredentials) (*rpc.Parameters, error)
 
 // ActivateRPC sends an activation request to the Coordinator.
 func ActivateRPC(req *rpc.ActivationReq, coordAddr string, tlsCredentials credentials.TransportCredentials) (*rpc.Parameters, error) {
-	connection, err := grpc.Dial(coordAddr, grpc.WithTransportCredentials(tlsCredentials))
+	maxMsgSize, err := strconv.Atoi(util.Getenv(config.MaxMsgSize, config.MaxMsgSizeDefault))
+	if err != nil || maxMsgSize <= 0 {
+		return nil, fmt.Errorf("invalid message size for %s: %v", config.MaxMsgSize, os.Getenv(config.MaxMsgSize))
+	}
+	connection, err := grpc.Dial(coordAddr, grpc.WithTransportCredentials(tlsCredentials), grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(maxMsgSize)))
 	if err != nil {
 		return nil, err
 	}
@@ -211,14 +359,25 @@ func ActivateRPC(req *rpc.ActivationReq, coordAddr string, tlsCredentials creden
 	return activationResp.GetParameters(), nil
 }
 
-func applyParameters(params *rpc.Parameters, fs afero.Fs) error {
+func applyParameters(params *rpc.Parameters, fs afero.Fs, owner *fileOwner) error {
 	// Store files in file system
 	log.Println("creating files from manifest")
 	for path, data := range params.Files {
-		if err := fs.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		if err := mkdirAll(fs, filepath.Dir(path), 0700, owner); err != nil {
+			return err
+		}
+		mode := os.FileMode(0600)
+		if fileMode, ok := params.FileModes[path]; ok {
+			mode = os.FileMode(fileMode) & os.ModePerm
+		}
+		if err := afero.WriteFile(fs, path, []byte(data), mode); err != nil {
+			return err
+		}
+		// WriteFile only applies the mode to newly created files
+		if err := fs.Chmod(path, mode); err != nil {
 			return err
 		}
-		if err := afero.WriteFile(fs, path, []byte(data), 0600); err != nil {
+		if err := chown(fs, path, owner); err != nil {
 			return err
 		}
 	}
@@ -7,14 +7,29 @@
 package premain
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
 	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
 	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
 	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
+	"github.com/edgelesssys/ego/marble"
 	"github.com/edgelesssys/marblerun/coordinator/quote"
 	"github.com/edgelesssys/marblerun/coordinator/rpc"
 	"github.com/edgelesssys/marblerun/marble/config"
+	"github.com/edgelesssys/marblerun/util"
 	"github.com/google/uuid"
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
@@ -146,3 +161,520 @@ func TestPreMain(t *testing.T) {
 		assert.Equal([]string{"not modified"}, os.Args)
 	}
 }
+
+func TestPreMainDumpQuote(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	argsBackup := os.Args
+	defer func() { os.Args = argsBackup }()
+
+	var sentQuote []byte
+	activate := func(req *rpc.ActivationReq, coordAddr string, tlsCredentials credentials.TransportCredentials) (*rpc.Parameters, error) {
+		sentQuote = req.Quote
+		return &rpc.Parameters{}, nil
+	}
+	issuer := quote.NewMockIssuer()
+
+	require.NoError(os.Setenv(config.CoordinatorAddr, "addr"))
+	require.NoError(os.Setenv(config.Type, "type"))
+	require.NoError(os.Setenv(config.UUIDFile, "uuidfile"))
+	require.NoError(os.Setenv(config.DNSNames, "dns1,dns2"))
+	defer os.Unsetenv(config.DumpQuotePath)
+
+	// no-op when unset
+	require.NoError(os.Unsetenv(config.DumpQuotePath))
+	hostfs := afero.NewMemMapFs()
+	require.NoError(PreMainEx(issuer, activate, hostfs, afero.NewMemMapFs()))
+	_, err := hostfs.Stat("quotedump")
+	assert.True(os.IsNotExist(err))
+
+	// evidence is written when set
+	require.NoError(os.Setenv(config.DumpQuotePath, "quotedump"))
+	hostfs = afero.NewMemMapFs()
+	require.NoError(PreMainEx(issuer, activate, hostfs, afero.NewMemMapFs()))
+	data, err := afero.ReadFile(hostfs, "quotedump")
+	require.NoError(err)
+	var evidence attestationEvidence
+	require.NoError(json.Unmarshal(data, &evidence))
+	assert.Equal(sentQuote, evidence.Quote)
+	expectedQuote, err := issuer.Issue(evidence.Message)
+	require.NoError(err)
+	assert.Equal(expectedQuote, evidence.Quote)
+
+	// write errors do not block startup
+	hostfs = afero.NewMemMapFs()
+	require.NoError(hostfs.Mkdir("quotedump", 0700))
+	require.NoError(PreMainEx(issuer, activate, hostfs, afero.NewMemMapFs()))
+}
+
+func TestPreMainFileModes(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	argsBackup := os.Args
+	defer func() { os.Args = argsBackup }()
+
+	activate := func(req *rpc.ActivationReq, coordAddr string, tlsCredentials credentials.TransportCredentials) (*rpc.Parameters, error) {
+		return &rpc.Parameters{
+			Files: map[string]string{
+				"/secrets/readonly.key":  "key1",
+				"/secrets/readwrite.key": "key2",
+				"/secrets/default.key":   "key3",
+			},
+			FileModes: map[string]uint32{
+				"/secrets/readonly.key":  0400,
+				"/secrets/readwrite.key": 0600,
+			},
+		}, nil
+	}
+
+	require.NoError(os.Setenv(config.CoordinatorAddr, "addr"))
+	require.NoError(os.Setenv(config.Type, "type"))
+	require.NoError(os.Setenv(config.UUIDFile, "uuidfile"))
+	require.NoError(os.Setenv(config.DNSNames, "dns1,dns2"))
+
+	enclavefs := afero.NewMemMapFs()
+	require.NoError(PreMainEx(quote.NewMockIssuer(), activate, afero.NewMemMapFs(), enclavefs))
+
+	for path, expectedMode := range map[string]os.FileMode{
+		"/secrets/readonly.key":  0400,
+		"/secrets/readwrite.key": 0600,
+		"/secrets/default.key":   0600,
+	} {
+		info, err := enclavefs.Stat(path)
+		require.NoError(err)
+		assert.Equal(expectedMode, info.Mode().Perm(), path)
+	}
+	data, err := afero.ReadFile(enclavefs, "/secrets/readonly.key")
+	require.NoError(err)
+	assert.Equal([]byte("key1"), data)
+}
+
+func TestPreMainEnvSecrets(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	argsBackup := os.Args
+	defer func() { os.Args = argsBackup }()
+	defer os.Unsetenv("DATABASE_PASSWORD")
+
+	activate := func(req *rpc.ActivationReq, coordAddr string, tlsCredentials credentials.TransportCredentials) (*rpc.Parameters, error) {
+		return &rpc.Parameters{
+			Env: map[string]string{"DATABASE_PASSWORD": "deadbeef"},
+		}, nil
+	}
+
+	require.NoError(os.Setenv(config.CoordinatorAddr, "addr"))
+	require.NoError(os.Setenv(config.Type, "type"))
+	require.NoError(os.Setenv(config.UUIDFile, "uuidfile"))
+	require.NoError(os.Setenv(config.DNSNames, "dns1,dns2"))
+	require.NoError(os.Unsetenv("DATABASE_PASSWORD"))
+
+	enclavefs := afero.NewMemMapFs()
+	require.NoError(PreMainEx(quote.NewMockIssuer(), activate, afero.NewMemMapFs(), enclavefs))
+
+	// the environment passed on to the application contains the secret
+	assert.Contains(os.Environ(), "DATABASE_PASSWORD=deadbeef")
+
+	// and it is not written to the file system
+	files, err := afero.ReadDir(enclavefs, "/")
+	require.NoError(err)
+	assert.Empty(files)
+}
+
+func TestPreMainCoordinatorCA(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	argsBackup := os.Args
+	defer func() { os.Args = argsBackup }()
+	defer os.Unsetenv(config.CoordinatorCAFile)
+
+	// the coordinator is mocked by a TLS server with a self-signed certificate
+	coordinatorCert, coordinatorKey, err := util.GenerateCert([]string{"localhost"}, util.DefaultCertificateIPAddresses, false)
+	require.NoError(err)
+	listener, err := tls.Listen("tcp", "localhost:0", &tls.Config{
+		Certificates: []tls.Certificate{*util.TLSCertFromDER(coordinatorCert.Raw, coordinatorKey)},
+		ClientAuth:   tls.RequireAnyClientCert,
+	})
+	require.NoError(err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	activate := func(req *rpc.ActivationReq, coordAddr string, tlsCredentials credentials.TransportCredentials) (*rpc.Parameters, error) {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+		if _, _, err := tlsCredentials.ClientHandshake(context.Background(), "localhost", conn); err != nil {
+			return nil, err
+		}
+		return &rpc.Parameters{}, nil
+	}
+
+	require.NoError(os.Setenv(config.CoordinatorAddr, "addr"))
+	require.NoError(os.Setenv(config.Type, "type"))
+	require.NoError(os.Setenv(config.UUIDFile, "uuidfile"))
+	require.NoError(os.Setenv(config.DNSNames, "dns1,dns2"))
+
+	otherCert, _, err := util.GenerateCert([]string{"localhost"}, util.DefaultCertificateIPAddresses, false)
+	require.NoError(err)
+
+	hostfs := afero.NewMemMapFs()
+	require.NoError(afero.WriteFile(hostfs, "coordinator-ca.pem", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: coordinatorCert.Raw}), 0600))
+	require.NoError(afero.WriteFile(hostfs, "other-ca.pem", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: otherCert.Raw}), 0600))
+	require.NoError(afero.WriteFile(hostfs, "malformed.pem", []byte("-----BEGIN CERTIFICATE-----\nfoo\n-----END CERTIFICATE-----\n"), 0600))
+
+	// unset: the coordinator is not verified
+	require.NoError(os.Unsetenv(config.CoordinatorCAFile))
+	assert.NoError(PreMainEx(quote.NewMockIssuer(), activate, hostfs, afero.NewMemMapFs()))
+
+	// valid CA file
+	require.NoError(os.Setenv(config.CoordinatorCAFile, "coordinator-ca.pem"))
+	assert.NoError(PreMainEx(quote.NewMockIssuer(), activate, hostfs, afero.NewMemMapFs()))
+
+	// the CA file is the sole trust anchor
+	require.NoError(os.Setenv(config.CoordinatorCAFile, "other-ca.pem"))
+	assert.Error(PreMainEx(quote.NewMockIssuer(), activate, hostfs, afero.NewMemMapFs()))
+
+	// missing file
+	require.NoError(os.Setenv(config.CoordinatorCAFile, "missing.pem"))
+	assert.Error(PreMainEx(quote.NewMockIssuer(), activate, hostfs, afero.NewMemMapFs()))
+
+	// malformed PEM
+	require.NoError(os.Setenv(config.CoordinatorCAFile, "malformed.pem"))
+	assert.Error(PreMainEx(quote.NewMockIssuer(), activate, hostfs, afero.NewMemMapFs()))
+}
+
+func TestPreMainSimulation(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	argsBackup := os.Args
+	defer func() { os.Args = argsBackup }()
+	defer os.Unsetenv(config.Simulation)
+
+	// Mocks a coordinator which skips quote validation in simulation mode.
+	validator := quote.NewMockValidator()
+	var coordinatorSimulation bool
+	var sentQuote []byte
+	activate := func(req *rpc.ActivationReq, coordAddr string, tlsCredentials credentials.TransportCredentials) (*rpc.Parameters, error) {
+		sentQuote = req.Quote
+		if !coordinatorSimulation {
+			if err := validator.Validate(req.Quote, nil, quote.PackageProperties{}, quote.InfrastructureProperties{}); err != nil {
+				return nil, err
+			}
+		}
+		return &rpc.Parameters{}, nil
+	}
+
+	require.NoError(os.Setenv(config.CoordinatorAddr, "addr"))
+	require.NoError(os.Setenv(config.Type, "type"))
+	require.NoError(os.Setenv(config.UUIDFile, "uuidfile"))
+	require.NoError(os.Setenv(config.DNSNames, "dns1,dns2"))
+
+	// the issuer is replaced, so activation does not depend on SGX
+	require.NoError(os.Setenv(config.Simulation, "1"))
+	coordinatorSimulation = true
+	require.NoError(PreMainEx(quote.NewFailIssuer(), activate, afero.NewMemMapFs(), afero.NewMemMapFs()))
+	assert.NotNil(sentQuote)
+	assert.Empty(sentQuote)
+
+	// a coordinator not running in simulation mode rejects the marble
+	coordinatorSimulation = false
+	assert.Error(PreMainEx(quote.NewFailIssuer(), activate, afero.NewMemMapFs(), afero.NewMemMapFs()))
+
+	// only enabled by "1"
+	require.NoError(os.Setenv(config.Simulation, "0"))
+	coordinatorSimulation = true
+	require.NoError(PreMainEx(quote.NewMockIssuer(), activate, afero.NewMemMapFs(), afero.NewMemMapFs()))
+	assert.NotEmpty(sentQuote)
+}
+
+func TestPreMainExpectedMREnclave(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	argsBackup := os.Args
+	defer func() { os.Args = argsBackup }()
+	defer os.Unsetenv(config.ExpectedMREnclave)
+
+	activated := false
+	activate := func(req *rpc.ActivationReq, coordAddr string, tlsCredentials credentials.TransportCredentials) (*rpc.Parameters, error) {
+		activated = true
+		return &rpc.Parameters{}, nil
+	}
+
+	require.NoError(os.Setenv(config.CoordinatorAddr, "addr"))
+	require.NoError(os.Setenv(config.Type, "type"))
+	require.NoError(os.Setenv(config.UUIDFile, "uuidfile"))
+	require.NoError(os.Setenv(config.DNSNames, "dns1,dns2"))
+
+	measurement := []byte{0x01, 0x02, 0x03, 0x04}
+	issuer := quote.NewMockIssuerWithMeasurement(measurement)
+
+	// unset: the measurement is not checked
+	require.NoError(os.Unsetenv(config.ExpectedMREnclave))
+	assert.NoError(PreMainEx(issuer, activate, afero.NewMemMapFs(), afero.NewMemMapFs()))
+	assert.True(activated)
+
+	// matching measurement
+	activated = false
+	require.NoError(os.Setenv(config.ExpectedMREnclave, "01020304"))
+	assert.NoError(PreMainEx(issuer, activate, afero.NewMemMapFs(), afero.NewMemMapFs()))
+	assert.True(activated)
+
+	// mismatching measurement aborts before contacting the coordinator
+	activated = false
+	require.NoError(os.Setenv(config.ExpectedMREnclave, "05060708"))
+	err := PreMainEx(issuer, activate, afero.NewMemMapFs(), afero.NewMemMapFs())
+	require.Error(err)
+	assert.Contains(err.Error(), "mismatch")
+	assert.False(activated)
+
+	// invalid expected value
+	require.NoError(os.Setenv(config.ExpectedMREnclave, "invalid"))
+	assert.Error(PreMainEx(issuer, activate, afero.NewMemMapFs(), afero.NewMemMapFs()))
+	assert.False(activated)
+}
+
+func TestPreMainDNSNames(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	argsBackup := os.Args
+	defer func() { os.Args = argsBackup }()
+	defer os.Setenv(config.DNSNames, "dns1,dns2")
+
+	var requestedDNSNames []string
+	activate := func(req *rpc.ActivationReq, coordAddr string, tlsCredentials credentials.TransportCredentials) (*rpc.Parameters, error) {
+		csr, err := x509.ParseCertificateRequest(req.CSR)
+		require.NoError(err)
+		requestedDNSNames = csr.DNSNames
+		return &rpc.Parameters{}, nil
+	}
+
+	require.NoError(os.Setenv(config.CoordinatorAddr, "addr"))
+	require.NoError(os.Setenv(config.Type, "type"))
+	require.NoError(os.Setenv(config.UUIDFile, "uuidfile"))
+	issuer := quote.NewMockIssuer()
+
+	// the DNS names set in the environment are requested
+	require.NoError(os.Setenv(config.DNSNames, "dns1,dns2"))
+	require.NoError(PreMainEx(issuer, activate, afero.NewMemMapFs(), afero.NewMemMapFs()))
+	assert.Equal([]string{"dns1", "dns2"}, requestedDNSNames)
+
+	// without DNS names in the environment, none are requested, so the coordinator assigns those of the marble type
+	require.NoError(os.Unsetenv(config.DNSNames))
+	require.NoError(PreMainEx(issuer, activate, afero.NewMemMapFs(), afero.NewMemMapFs()))
+	assert.Empty(requestedDNSNames)
+}
+
+func TestPreMainFileOwner(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	argsBackup := os.Args
+	defer func() { os.Args = argsBackup }()
+
+	activate := func(req *rpc.ActivationReq, coordAddr string, tlsCredentials credentials.TransportCredentials) (*rpc.Parameters, error) {
+		return &rpc.Parameters{
+			Files: map[string]string{"/secrets/app/private.key": "key"},
+		}, nil
+	}
+
+	// only root may give files away, other users can only apply their own uid
+	uid, gid := os.Getuid(), os.Getgid()
+	if uid == 0 {
+		uid, gid = 1234, 5678
+	}
+
+	require.NoError(os.Setenv(config.CoordinatorAddr, "addr"))
+	require.NoError(os.Setenv(config.Type, "type"))
+	require.NoError(os.Setenv(config.UUIDFile, "uuidfile"))
+	require.NoError(os.Setenv(config.DNSNames, "dns1,dns2"))
+	require.NoError(os.Setenv(config.FileOwner, fmt.Sprintf("%d:%d", uid, gid)))
+	defer os.Unsetenv(config.FileOwner)
+
+	tempDir, err := ioutil.TempDir("", "")
+	require.NoError(err)
+	defer os.RemoveAll(tempDir)
+	enclavefs := afero.NewBasePathFs(afero.NewOsFs(), tempDir)
+	require.NoError(PreMainEx(quote.NewMockIssuer(), activate, afero.NewMemMapFs(), enclavefs))
+
+	// the file and the directories created for it are owned by the configured user
+	for _, path := range []string{"secrets", "secrets/app", "secrets/app/private.key"} {
+		info, err := os.Stat(filepath.Join(tempDir, path))
+		require.NoError(err)
+		stat, ok := info.Sys().(*syscall.Stat_t)
+		require.True(ok)
+		assert.EqualValues(uid, stat.Uid, path)
+		assert.EqualValues(gid, stat.Gid, path)
+	}
+
+	// file systems which don't support changing owners are skipped
+	enclavefs = &noChownFs{afero.NewMemMapFs()}
+	require.NoError(PreMainEx(quote.NewMockIssuer(), activate, afero.NewMemMapFs(), enclavefs))
+	data, err := afero.ReadFile(enclavefs, "/secrets/app/private.key")
+	require.NoError(err)
+	assert.Equal([]byte("key"), data)
+
+	// invalid owners
+	for _, value := range []string{"1000", "user:group", "-1:1000", "1000:"} {
+		require.NoError(os.Setenv(config.FileOwner, value))
+		assert.Error(PreMainEx(quote.NewMockIssuer(), activate, afero.NewMemMapFs(), afero.NewMemMapFs()), value)
+	}
+}
+
+// noChownFs is a file system which doesn't support changing the owner of files
+type noChownFs struct {
+	afero.Fs
+}
+
+func (fs *noChownFs) Chown(name string, uid, gid int) error {
+	return &os.PathError{Op: "chown", Path: name, Err: syscall.ENOTSUP}
+}
+
+func TestPreMainSharedCredentials(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	argsBackup := os.Args
+	defer func() { os.Args = argsBackup }()
+
+	env := map[string]string{
+		marble.MarbleEnvironmentCertificateChain: "cert",
+		marble.MarbleEnvironmentPrivateKey:       "key",
+		marble.MarbleEnvironmentIntermediateCA:   "ca",
+	}
+	activate := func(req *rpc.ActivationReq, coordAddr string, tlsCredentials credentials.TransportCredentials) (*rpc.Parameters, error) {
+		return &rpc.Parameters{Env: env}, nil
+	}
+
+	require.NoError(os.Setenv(config.CoordinatorAddr, "addr"))
+	require.NoError(os.Setenv(config.Type, "type"))
+	require.NoError(os.Setenv(config.UUIDFile, "uuidfile"))
+	require.NoError(os.Setenv(config.DNSNames, "dns1,dns2"))
+
+	// the credentials are only written to the host if requested
+	hostfs := afero.NewMemMapFs()
+	require.NoError(PreMainEx(quote.NewMockIssuer(), activate, hostfs, afero.NewMemMapFs()))
+	_, err := hostfs.Stat("/shared/key.pem")
+	assert.True(os.IsNotExist(err))
+
+	require.NoError(os.Setenv(config.SharedCredentialsPath, "/shared"))
+	defer os.Unsetenv(config.SharedCredentialsPath)
+	require.NoError(PreMainEx(quote.NewMockIssuer(), activate, hostfs, afero.NewMemMapFs()))
+	for name, expected := range map[string]string{"cert.pem": "cert", "key.pem": "key", "ca.pem": "ca"} {
+		data, err := afero.ReadFile(hostfs, filepath.Join("/shared", name))
+		require.NoError(err)
+		assert.Equal(expected, string(data))
+		info, err := hostfs.Stat(filepath.Join("/shared", name))
+		require.NoError(err)
+		assert.Equal(os.FileMode(0600), info.Mode().Perm())
+	}
+
+	// activation fails if the response lacks credentials
+	delete(env, marble.MarbleEnvironmentPrivateKey)
+	assert.Error(PreMainEx(quote.NewMockIssuer(), activate, afero.NewMemMapFs(), afero.NewMemMapFs()))
+}
+
+func TestPreMainUUIDEnv(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	argsBackup := os.Args
+	defer func() { os.Args = argsBackup }()
+
+	marbleUUID := uuid.New()
+	activate := func(req *rpc.ActivationReq, coordAddr string, tlsCredentials credentials.TransportCredentials) (*rpc.Parameters, error) {
+		assert.Equal(marbleUUID.String(), req.UUID)
+		return &rpc.Parameters{}, nil
+	}
+
+	require.NoError(os.Setenv(config.CoordinatorAddr, "addr"))
+	require.NoError(os.Setenv(config.Type, "type"))
+	require.NoError(os.Setenv(config.UUIDFile, "uuidfile"))
+	require.NoError(os.Setenv(config.DNSNames, "dns1,dns2"))
+	require.NoError(os.Setenv(config.UUID, marbleUUID.String()))
+	defer os.Unsetenv(config.UUID)
+
+	// the UUID in the environment takes precedence over the file, which is neither read nor written
+	hostfs := afero.NewMemMapFs()
+	require.NoError(afero.WriteFile(hostfs, "uuidfile", []byte(uuid.New().String()), 0600))
+	require.NoError(PreMainEx(quote.NewMockIssuer(), activate, hostfs, afero.NewMemMapFs()))
+
+	hostfs = afero.NewMemMapFs()
+	require.NoError(PreMainEx(quote.NewMockIssuer(), activate, hostfs, afero.NewMemMapFs()))
+	_, err := hostfs.Stat("uuidfile")
+	assert.True(os.IsNotExist(err))
+
+	require.NoError(os.Setenv(config.UUID, "invalid"))
+	assert.Error(PreMainEx(quote.NewMockIssuer(), activate, afero.NewMemMapFs(), afero.NewMemMapFs()))
+}
+
+func TestPreMainFailureGrace(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	argsBackup := os.Args
+	defer func() { os.Args = argsBackup }()
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	marbleUUID := uuid.New()
+	activate := func(req *rpc.ActivationReq, coordAddr string, tlsCredentials credentials.TransportCredentials) (*rpc.Parameters, error) {
+		return nil, errors.New("activation error")
+	}
+
+	require.NoError(os.Setenv(config.CoordinatorAddr, "addr"))
+	require.NoError(os.Setenv(config.Type, "type"))
+	require.NoError(os.Setenv(config.UUIDFile, "uuidfile"))
+	require.NoError(os.Setenv(config.DNSNames, "dns1,dns2"))
+	require.NoError(os.Setenv(config.UUID, marbleUUID.String()))
+	defer os.Unsetenv(config.UUID)
+
+	// without a grace period, the error is returned immediately
+	assert.Error(PreMainEx(quote.NewMockIssuer(), activate, afero.NewMemMapFs(), afero.NewMemMapFs()))
+	assert.NotContains(logs.String(), "activation failed")
+
+	const grace = 200 * time.Millisecond
+	require.NoError(os.Setenv(config.FailureGrace, grace.String()))
+	defer os.Unsetenv(config.FailureGrace)
+	logs.Reset()
+	start := time.Now()
+	assert.Error(PreMainEx(quote.NewMockIssuer(), activate, afero.NewMemMapFs(), afero.NewMemMapFs()))
+	assert.True(time.Since(start) >= grace)
+	assert.Contains(logs.String(), "activation failed: activation error")
+	assert.Contains(logs.String(), "coordinator address: addr")
+	assert.Contains(logs.String(), "marble type: type")
+	assert.Contains(logs.String(), "UUID: "+marbleUUID.String())
+	assert.Contains(logs.String(), "grace period elapsed")
+
+	// the diagnostics are logged before the grace period starts
+	assert.Less(strings.Index(logs.String(), "activation failed"), strings.Index(logs.String(), "waiting"))
+
+	// a successful activation is not delayed
+	logs.Reset()
+	succeed := func(req *rpc.ActivationReq, coordAddr string, tlsCredentials credentials.TransportCredentials) (*rpc.Parameters, error) {
+		return &rpc.Parameters{}, nil
+	}
+	require.NoError(PreMainEx(quote.NewMockIssuer(), succeed, afero.NewMemMapFs(), afero.NewMemMapFs()))
+	assert.NotContains(logs.String(), "grace period")
+
+	require.NoError(os.Setenv(config.FailureGrace, "invalid"))
+	assert.Error(PreMainEx(quote.NewMockIssuer(), succeed, afero.NewMemMapFs(), afero.NewMemMapFs()))
+}
@@ -0,0 +1,84 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package premain
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/edgelesssys/ego/marble"
+	"github.com/edgelesssys/marblerun/coordinator/rpc"
+	"github.com/edgelesssys/marblerun/marble/config"
+	"github.com/edgelesssys/marblerun/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// secretFetcher fetches a version of a shared secret from the coordinator
+type secretFetcher func(ctx context.Context, name string, version uint64) (*rpc.SecretResp, error)
+
+// FetchSecret fetches a version of a shared secret from the coordinator, 0 fetches the current version.
+// It must be called after PreMain, since the marble authenticates with the credentials it received during activation.
+// If the secret has been rotated, a request for an older version fails with codes.FailedPrecondition.
+// Secrets which the parameters of the marble's type don't reference are denied with codes.PermissionDenied.
+func FetchSecret(ctx context.Context, name string, version uint64) (*rpc.SecretResp, error) {
+	tlsConfig, err := marble.GetTLSConfig(false)
+	if err != nil {
+		return nil, err
+	}
+	coordAddr := util.Getenv(config.CoordinatorAddr, config.CoordinatorAddrDefault)
+	connection, err := grpc.DialContext(ctx, coordAddr, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	if err != nil {
+		return nil, err
+	}
+	defer connection.Close()
+
+	return rpc.NewMarbleClient(connection).GetSecret(ctx, &rpc.SecretReq{Name: name, Version: version})
+}
+
+// PollSecret fetches the current version of a shared secret and then checks for a rotation every interval until ctx is done.
+// onUpdate is called with the initial version and with each rotated version.
+func PollSecret(ctx context.Context, name string, interval time.Duration, onUpdate func(*rpc.SecretResp)) error {
+	return pollSecret(ctx, FetchSecret, name, interval, onUpdate)
+}
+
+func pollSecret(ctx context.Context, fetch secretFetcher, name string, interval time.Duration, onUpdate func(*rpc.SecretResp)) error {
+	secret, err := fetch(ctx, name, 0)
+	if err != nil {
+		return err
+	}
+	onUpdate(secret)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			// the coordinator rejects the known version once the secret was rotated
+			_, err := fetch(ctx, name, secret.Version)
+			if status.Code(err) != codes.FailedPrecondition {
+				if err != nil {
+					log.Printf("unable to check secret %s for a rotation: %v", name, err)
+				}
+				continue
+			}
+			rotated, err := fetch(ctx, name, 0)
+			if err != nil {
+				log.Printf("unable to fetch rotated secret %s: %v", name, err)
+				continue
+			}
+			secret = rotated
+			onUpdate(secret)
+		}
+	}
+}
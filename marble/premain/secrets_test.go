@@ -0,0 +1,73 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package premain
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edgelesssys/marblerun/coordinator/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestPollSecret(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// the fake coordinator rejects old versions like the real one
+	var mux sync.Mutex
+	current := uint64(1)
+	unavailable := true
+	fetch := func(ctx context.Context, name string, version uint64) (*rpc.SecretResp, error) {
+		mux.Lock()
+		defer mux.Unlock()
+		assert.Equal("secret", name)
+		if version != 0 && unavailable {
+			unavailable = false
+			return nil, errors.New("unavailable")
+		}
+		if version != 0 && version < current {
+			return nil, status.Error(codes.FailedPrecondition, "rotated")
+		}
+		return &rpc.SecretResp{Name: name, Version: current, Private: []byte{byte(current)}}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	updates := make(chan *rpc.SecretResp, 3)
+	done := make(chan error)
+	go func() {
+		done <- pollSecret(ctx, fetch, "secret", time.Millisecond, func(secret *rpc.SecretResp) { updates <- secret })
+	}()
+
+	// initial version
+	secret := <-updates
+	assert.EqualValues(1, secret.Version)
+
+	// a rotation is picked up, failed checks are skipped
+	mux.Lock()
+	current = 2
+	mux.Unlock()
+	secret = <-updates
+	assert.EqualValues(2, secret.Version)
+	assert.Equal([]byte{2}, secret.Private)
+
+	cancel()
+	require.NoError(<-done)
+	assert.Empty(updates)
+
+	// the initial fetch must succeed
+	failingFetch := func(ctx context.Context, name string, version uint64) (*rpc.SecretResp, error) {
+		return nil, errors.New("failed")
+	}
+	assert.Error(pollSecret(context.Background(), failingFetch, "secret", time.Millisecond, func(*rpc.SecretResp) {}))
+}
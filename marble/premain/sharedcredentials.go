@@ -0,0 +1,47 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package premain
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"github.com/edgelesssys/ego/marble"
+	"github.com/edgelesssys/marblerun/coordinator/rpc"
+	"github.com/spf13/afero"
+)
+
+// sharedCredentialFiles maps the files written to the shared credentials path to the environment variables holding their content
+var sharedCredentialFiles = map[string]string{
+	"cert.pem": marble.MarbleEnvironmentCertificateChain,
+	"key.pem":  marble.MarbleEnvironmentPrivateKey,
+	"ca.pem":   marble.MarbleEnvironmentIntermediateCA,
+}
+
+// writeSharedCredentials writes the credentials issued to the marble to dir on the host file system, so a sidecar can read them.
+// The private key leaves the enclave, so this must only be done if explicitly requested.
+func writeSharedCredentials(params *rpc.Parameters, hostfs afero.Fs, dir string, owner *fileOwner) error {
+	log.Println("WARNING: writing the marble's credentials to", dir, "outside of the enclave")
+	if err := mkdirAll(hostfs, dir, 0700, owner); err != nil {
+		return fmt.Errorf("failed to create shared credentials path: %v", err)
+	}
+	for name, env := range sharedCredentialFiles {
+		data, ok := params.Env[env]
+		if !ok {
+			return fmt.Errorf("failed to write shared credentials: missing %s in the activation response", env)
+		}
+		path := filepath.Join(dir, name)
+		if err := afero.WriteFile(hostfs, path, []byte(data), 0600); err != nil {
+			return fmt.Errorf("failed to write shared credentials: %v", err)
+		}
+		if err := chown(hostfs, path, owner); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package premain
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/edgelesssys/ego/marble"
+	"github.com/edgelesssys/marblerun/coordinator/rpc"
+)
+
+// certWatchdog periodically checks if the marble's intermediate CA is still issued by the coordinator's root certificate.
+// If the coordinator rotated its root certificate, other marbles won't accept the marble's credentials anymore, so it calls exit.
+// Failed checks, e.g., because the coordinator is unreachable, are logged, but never lead to an exit.
+type certWatchdog struct {
+	coordAddr        string
+	intermediateCert *x509.Certificate
+	interval         time.Duration
+	getRootCert      func(coordAddr string) (*x509.Certificate, error)
+	exit             func()
+}
+
+// newCertWatchdog creates a watchdog for the intermediate CA the marble received in its parameters
+func newCertWatchdog(params *rpc.Parameters, coordAddr string, interval time.Duration) (*certWatchdog, error) {
+	block, _ := pem.Decode([]byte(params.Env[marble.MarbleEnvironmentIntermediateCA]))
+	if block == nil {
+		return nil, errors.New("no intermediate CA found in the marble's parameters")
+	}
+	intermediateCert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse intermediate CA: %v", err)
+	}
+	return &certWatchdog{
+		coordAddr:        coordAddr,
+		intermediateCert: intermediateCert,
+		interval:         interval,
+		getRootCert:      getCoordinatorRootCert,
+		exit:             terminate,
+	}, nil
+}
+
+// run checks the coordinator's root certificate every interval until a rotation is detected or ctx is done
+func (w *certWatchdog) run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			rotated, err := w.rotated()
+			if err != nil {
+				log.Printf("certificate watchdog: unable to check the coordinator's root certificate: %v", err)
+				continue
+			}
+			if rotated {
+				log.Println("certificate watchdog: the coordinator's root certificate was rotated, terminating the marble for a restart")
+				w.exit()
+				return
+			}
+		}
+	}
+}
+
+// rotated reports whether the coordinator's current root certificate did not issue the marble's intermediate CA
+func (w *certWatchdog) rotated() (bool, error) {
+	rootCert, err := w.getRootCert(w.coordAddr)
+	if err != nil {
+		return false, err
+	}
+	return w.intermediateCert.CheckSignatureFrom(rootCert) != nil, nil
+}
+
+// getCoordinatorRootCert returns the self-signed root certificate the coordinator presents on its marble API
+func getCoordinatorRootCert(coordAddr string) (*x509.Certificate, error) {
+	// the coordinator's root certificate is the one to be checked, so it can't be verified during the handshake
+	conn, err := tls.Dial("tcp", coordAddr, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	peerCerts := conn.ConnectionState().PeerCertificates
+	if len(peerCerts) == 0 {
+		return nil, errors.New("coordinator presented no certificate")
+	}
+	rootCert := peerCerts[len(peerCerts)-1]
+	if err := rootCert.CheckSignatureFrom(rootCert); err != nil {
+		return nil, fmt.Errorf("coordinator presented an invalid root certificate: %v", err)
+	}
+	return rootCert, nil
+}
+
+// terminate sends SIGTERM to the own process, so it shuts down gracefully and is restarted by its supervisor
+func terminate() {
+	if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+		log.Printf("certificate watchdog: failed to terminate the marble: %v", err)
+	}
+}
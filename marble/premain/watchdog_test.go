@@ -0,0 +1,148 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package premain
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/edgelesssys/ego/marble"
+	"github.com/edgelesssys/marblerun/coordinator/rpc"
+	"github.com/edgelesssys/marblerun/marble/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertWatchdog(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	rootCert, rootKey := createTestCA(t, nil, nil)
+	intermediateCert, _ := createTestCA(t, rootCert, rootKey)
+	rotatedRootCert, _ := createTestCA(t, nil, nil)
+
+	params := &rpc.Parameters{Env: map[string]string{
+		marble.MarbleEnvironmentIntermediateCA: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: intermediateCert.Raw})),
+	}}
+	watchdog, err := newCertWatchdog(params, "coordinator:2001", time.Millisecond)
+	require.NoError(err)
+
+	testCases := map[string]struct {
+		rootCert *x509.Certificate
+		err      error
+		exit     bool
+	}{
+		"unchanged root certificate": {rootCert: rootCert},
+		"coordinator unreachable":    {err: errors.New("connection refused")},
+		"rotated root certificate":   {rootCert: rotatedRootCert, exit: true},
+	}
+	for name, tc := range testCases {
+		exited := make(chan struct{})
+		watchdog.getRootCert = func(coordAddr string) (*x509.Certificate, error) {
+			assert.Equal("coordinator:2001", coordAddr)
+			return tc.rootCert, tc.err
+		}
+		watchdog.exit = func() { close(exited) }
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		watchdog.run(ctx)
+		cancel()
+
+		select {
+		case <-exited:
+			assert.True(tc.exit, name)
+		default:
+			assert.False(tc.exit, name)
+		}
+	}
+
+	// the watchdog can't be created without an intermediate CA
+	_, err = newCertWatchdog(&rpc.Parameters{}, "coordinator:2001", time.Millisecond)
+	assert.Error(err)
+}
+
+func TestGetCoordinatorRootCert(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	rootCert, rootKey := createTestCA(t, nil, nil)
+	listener, err := tls.Listen("tcp", "localhost:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{rootCert.Raw}, PrivateKey: rootKey}},
+	})
+	require.NoError(err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	presentedCert, err := getCoordinatorRootCert(listener.Addr().String())
+	require.NoError(err)
+	assert.Equal(rootCert.Raw, presentedCert.Raw)
+}
+
+func TestGetWatchdogInterval(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	defer os.Unsetenv(config.CertWatchdogInterval)
+
+	// disabled by default
+	interval, err := getWatchdogInterval()
+	require.NoError(err)
+	assert.Zero(interval)
+
+	require.NoError(os.Setenv(config.CertWatchdogInterval, "5m"))
+	interval, err = getWatchdogInterval()
+	require.NoError(err)
+	assert.Equal(5*time.Minute, interval)
+
+	for _, value := range []string{"often", "0s", "-1m"} {
+		require.NoError(os.Setenv(config.CertWatchdogInterval, value))
+		_, err = getWatchdogInterval()
+		assert.Error(err, value)
+	}
+}
+
+// createTestCA creates a CA certificate, which is self-signed if parent is nil
+func createTestCA(t *testing.T, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	privk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	if parent == nil {
+		parent, parentKey = template, privk
+	}
+	certRaw, err := x509.CreateCertificate(rand.Reader, template, parent, &privk.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(certRaw)
+	require.NoError(t, err)
+	return cert, privk
+}
@@ -13,9 +13,11 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"fmt"
 	"math"
 	"math/big"
 	"net"
+	"strings"
 	"time"
 
 	"google.golang.org/grpc/credentials"
@@ -102,10 +104,15 @@ func GenerateCSR(dnsNames []string, privk *ecdsa.PrivateKey) (*x509.CertificateR
 	return csr, nil
 }
 
-// GenerateCertificateSerialNumber generates a random serial number for an X.509 certificate.
+// GenerateCertificateSerialNumber generates a random, positive serial number for an X.509 certificate.
 func GenerateCertificateSerialNumber() (*big.Int, error) {
 	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
-	return rand.Int(rand.Reader, serialNumberLimit)
+	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
+	if err != nil {
+		return nil, err
+	}
+	// RFC 5280 requires serial numbers to be positive
+	return serialNumber.Add(serialNumber, big.NewInt(1)), nil
 }
 
 // LoadGRPCTLSCredentials returns a TLS configuration based on cert and privk
@@ -118,7 +125,59 @@ func LoadGRPCTLSCredentials(cert *x509.Certificate, privk *ecdsa.PrivateKey, ins
 	return credentials.NewTLS(tlsConfig), nil
 }
 
+// LoadGRPCTLSCredentialsWithRootCAs returns a TLS configuration based on cert and privk, which verifies the server against rootCAs
+func LoadGRPCTLSCredentialsWithRootCAs(cert *x509.Certificate, privk *ecdsa.PrivateKey, rootCAs *x509.CertPool) credentials.TransportCredentials {
+	clientCert := TLSCertFromDER(cert.Raw, privk)
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{*clientCert},
+		RootCAs:      rootCAs,
+	}
+	return credentials.NewTLS(tlsConfig)
+}
+
 // TLSCertFromDER converts a DER certificate to a TLS certificate.
 func TLSCertFromDER(certDER []byte, privk interface{}) *tls.Certificate {
 	return &tls.Certificate{Certificate: [][]byte{certDER}, PrivateKey: privk}
 }
+
+// tlsVersions maps the accepted names of TLS versions to their identifiers
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ParseTLSVersion parses a TLS version of the form "1.2" or "1.3"
+func ParseTLSVersion(version string) (uint16, error) {
+	if parsed, ok := tlsVersions[version]; ok {
+		return parsed, nil
+	}
+	return 0, fmt.Errorf("invalid TLS version: %s", version)
+}
+
+// ParseCipherSuites parses a comma-separated list of cipher suite names, e.g., "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384".
+// Only the secure cipher suites implemented by crypto/tls are accepted. An empty list returns nil, so the defaults of crypto/tls apply.
+func ParseCipherSuites(names string) ([]uint16, error) {
+	if names == "" {
+		return nil, nil
+	}
+	var cipherSuites []uint16
+	for _, name := range strings.Split(names, ",") {
+		id, ok := secureCipherSuite(strings.TrimSpace(name))
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite: %s", name)
+		}
+		cipherSuites = append(cipherSuites, id)
+	}
+	return cipherSuites, nil
+}
+
+func secureCipherSuite(name string) (uint16, bool) {
+	for _, suite := range tls.CipherSuites() {
+		if suite.Name == name {
+			return suite.ID, true
+		}
+	}
+	return 0, false
+}
@@ -7,6 +7,7 @@
 package util
 
 import (
+	"crypto/tls"
 	"os"
 	"testing"
 
@@ -67,3 +68,37 @@ func TestXORBytes(t *testing.T) {
 	require.NoError(err)
 	assert.Equal(expectedResult, result)
 }
+
+func TestParseTLSVersion(t *testing.T) {
+	assert := assert.New(t)
+
+	version, err := ParseTLSVersion("1.3")
+	assert.NoError(err)
+	assert.EqualValues(tls.VersionTLS13, version)
+	version, err = ParseTLSVersion("1.2")
+	assert.NoError(err)
+	assert.EqualValues(tls.VersionTLS12, version)
+
+	for _, invalid := range []string{"", "1.4", "TLS1.2", "13"} {
+		_, err = ParseTLSVersion(invalid)
+		assert.Error(err, invalid)
+	}
+}
+
+func TestParseCipherSuites(t *testing.T) {
+	assert := assert.New(t)
+
+	cipherSuites, err := ParseCipherSuites("TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384, TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384")
+	assert.NoError(err)
+	assert.Equal([]uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384, tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384}, cipherSuites)
+
+	cipherSuites, err = ParseCipherSuites("")
+	assert.NoError(err)
+	assert.Nil(cipherSuites)
+
+	// insecure and unknown cipher suites are rejected
+	_, err = ParseCipherSuites("TLS_RSA_WITH_RC4_128_SHA")
+	assert.Error(err)
+	_, err = ParseCipherSuites("TLS_UNKNOWN")
+	assert.Error(err)
+}